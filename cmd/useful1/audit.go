@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/audit"
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// newAuditCmd returns the command that queries the audit log
+func newAuditCmd() *cobra.Command {
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show what the bot did to repositories, and check the audit log is intact",
+		Long: `List the entries of the audit log: comments, branches, pushes, forks and pull requests the bot made, and the prompts it sent to models and agents.
+
+The whole log is checked against its hash chain while it is read. The exit code is 1 if an entry was changed, removed or inserted, after printing the matching entries before it.`,
+		Example: `  useful1 audit --repo acme/api --since 24h
+  useful1 audit --issue acme/api#123 --output json
+  useful1 audit --action push --since 2026-10-01 --until 2026-10-08`,
+		Args: cobra.NoArgs,
+		// Entries are printed on stdout, so logs go to stderr
+		Annotations: map[string]string{logOutputAnnotation: "stderr"},
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(runAudit(cmd))
+		},
+	}
+
+	auditCmd.Flags().String("repo", "", "Only show entries for this repository (owner/repo)")
+	auditCmd.Flags().String("issue", "", "Only show entries for this issue or pull request (owner/repo#number)")
+	auditCmd.Flags().String("action", "", "Only show this action, e.g. comment, push, pull_request or model_call")
+	auditCmd.Flags().String("since", "", "Only show entries from this time on: a date, an RFC 3339 time or a duration such as 24h")
+	auditCmd.Flags().String("until", "", "Only show entries before this time: a date, an RFC 3339 time or a duration such as 1h")
+	auditCmd.Flags().String("file", "", "Audit log to read (defaults to Audit.Path in the config, then ~/.useful1/audit.jsonl)")
+	auditCmd.Flags().String("output", "text", "Output format: text or json (one entry per line, as stored)")
+
+	return auditCmd
+}
+
+// runAudit prints the matching entries of the audit log and returns the exit code
+func runAudit(cmd *cobra.Command) int {
+	flags := cmd.Flags()
+	repo, _ := flags.GetString("repo")
+	issue, _ := flags.GetString("issue")
+	action, _ := flags.GetString("action")
+	since, _ := flags.GetString("since")
+	until, _ := flags.GetString("until")
+	path, _ := flags.GetString("file")
+	output, _ := flags.GetString("output")
+
+	if output != "text" && output != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q, expected text or json\n", output)
+		return exitUsage
+	}
+
+	filter := audit.Filter{Repo: repo, Action: action}
+	if issue != "" {
+		owner, name, number, err := state.ParseIssueKey(issue)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid issue %q, expected owner/repo#number\n", issue)
+			return exitUsage
+		}
+		filter.Repo = owner + "/" + name
+		filter.Number = number
+	}
+
+	now := time.Now()
+	var err error
+	if filter.Since, err = parseAuditTime(since, now); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --since: %v\n", err)
+		return exitUsage
+	}
+	if filter.Until, err = parseAuditTime(until, now); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid --until: %v\n", err)
+		return exitUsage
+	}
+
+	if path == "" && config.Exists() {
+		if cfg, err := config.Load(); err == nil {
+			path = cfg.Audit.Path
+		}
+	}
+	if path == "" {
+		path = audit.DefaultPath()
+	}
+
+	entries, err := audit.Query(path, filter)
+	for _, entry := range entries {
+		if output == "json" {
+			line, _ := json.Marshal(entry)
+			fmt.Println(string(line))
+			continue
+		}
+		fmt.Println(formatAuditEntry(entry))
+	}
+
+	var chainErr *audit.ChainError
+	switch {
+	case errors.As(err, &chainErr):
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailed
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+	return exitSolved
+}
+
+// formatAuditEntry formats an entry as a line of text, leaving out the body
+func formatAuditEntry(entry audit.Entry) string {
+	target := entry.Repo
+	if entry.Number > 0 {
+		target = fmt.Sprintf("%s#%d", entry.Repo, entry.Number)
+	}
+
+	fields := []string{strconv.FormatInt(entry.Seq, 10), entry.Time.Local().Format(time.RFC3339), entry.Action}
+	for _, field := range []string{target, entry.Model, entry.Ref, entry.Summary} {
+		if field != "" {
+			fields = append(fields, strings.ReplaceAll(field, "\n", " "))
+		}
+	}
+	return strings.Join(fields, "  ")
+}
+
+// parseAuditTime parses a time given as an RFC 3339 time, a date in the local time zone,
+// or a duration before now. An empty value gives the zero time.
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date, an RFC 3339 time or a duration", value)
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/batch"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/events"
+	"github.com/hellausefulsoftware/useful1/internal/github"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+	"github.com/spf13/cobra"
+)
+
+// newBatchCmd returns the command that works through a list of issues
+func newBatchCmd() *cobra.Command {
+	batchCmd := &cobra.Command{
+		Use:   "batch",
+		Short: "Work through the issues matching a search or listed in a file",
+		Long: `Solve every issue matching a GitHub search, or listed in a file, with limits on concurrency and total spending, and write a report of the outcomes.
+
+Issues completed by earlier runs are skipped, so a batch can be run again until everything is done. Each issue runs in its own 'useful1 solve' process.`,
+		Example: `  useful1 batch --query "repo:acme/api label:good-first-issue" --concurrency 2 --total-budget 20
+  useful1 batch --file issues.txt --report outcomes.json`,
+		Args: cobra.NoArgs,
+		// Progress is printed on stdout, so logs go to stderr
+		Annotations: map[string]string{logOutputAnnotation: "stderr"},
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(runBatch(cmd))
+		},
+	}
+
+	batchCmd.Flags().String("query", "", "GitHub search selecting the issues, e.g. \"repo:acme/api label:good-first-issue\"")
+	batchCmd.Flags().String("file", "", "File listing one issue per line, as owner/repo#number or an issue URL")
+	batchCmd.Flags().Int("limit", 0, "Work on at most this many issues (0 for all)")
+	batchCmd.Flags().Int("concurrency", 1, "Number of issues worked on at the same time")
+	batchCmd.Flags().Float64("total-budget", 0, "Budget for the whole batch in USD (0 for no limit)")
+	batchCmd.Flags().Float64("budget", 0, "Budget for each issue in USD (defaults to the configured PR budget)")
+	batchCmd.Flags().String("report", "useful1-batch-report.csv", "File to write the report of outcomes to")
+	batchCmd.Flags().String("format", "", "Report format: csv or json (defaults to the report file's extension)")
+	batchCmd.Flags().Bool("plan", false, "Only list the issues that would be worked on")
+	batchCmd.Flags().Bool("skip-pr", false, "Push branches without opening pull requests")
+	batchCmd.Flags().String("agent", "", "Agent CLI to run instead of the configured one")
+
+	return batchCmd
+}
+
+// runBatch plans and runs a batch and returns the exit code: 0 if no issue failed, 1 if
+// any did and 2 for invalid arguments or configuration
+func runBatch(cmd *cobra.Command) int {
+	flags := cmd.Flags()
+	query, _ := flags.GetString("query")
+	file, _ := flags.GetString("file")
+	limit, _ := flags.GetInt("limit")
+	concurrency, _ := flags.GetInt("concurrency")
+	totalBudget, _ := flags.GetFloat64("total-budget")
+	issueBudget, _ := flags.GetFloat64("budget")
+	report, _ := flags.GetString("report")
+	format, _ := flags.GetString("format")
+	planOnly, _ := flags.GetBool("plan")
+	skipPR, _ := flags.GetBool("skip-pr")
+	agent, _ := flags.GetString("agent")
+
+	if (query == "") == (file == "") {
+		fmt.Fprintln(os.Stderr, "Error: give either --query or --file")
+		return exitUsage
+	}
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(report)), ".")
+	}
+	if format != "csv" && format != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown report format %q, expected csv or json\n", format)
+		return exitUsage
+	}
+
+	if !config.Exists() {
+		fmt.Fprintln(os.Stderr, "Error: Configuration is required. Run 'useful1 config' first to create a configuration.")
+		return exitUsage
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %s\n", err)
+		return exitUsage
+	}
+
+	// Each issue is capped at the configured run budget unless --budget says otherwise.
+	// solve enforces it on the agent, and reports what the agent and the analyzer spent.
+	if issueBudget <= 0 {
+		issueBudget = cfg.RunBudget()
+	}
+
+	var items []batch.Item
+	if file != "" {
+		items, err = readBatchFile(file)
+	} else {
+		items, err = searchBatchItems(cfg, query)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	store, err := state.Open(state.DefaultPath())
+	if err != nil {
+		logging.Warn("Failed to open issue state store, completed issues won't be skipped", "error", err)
+		store, _ = state.Open("")
+	}
+	todo, done := batch.Plan(items, store)
+	if limit > 0 && len(todo) > limit {
+		todo = todo[:limit]
+	}
+	for i := range done {
+		if st := store.Get(done[i].Issue); st.PullRequest > 0 {
+			owner, repo, _, _ := state.ParseIssueKey(done[i].Issue)
+			done[i].URL = fmt.Sprintf("%s/%s/%s/pull/%d", cfg.GitHubWebURL(), owner, repo, st.PullRequest)
+		}
+	}
+
+	fmt.Printf("Planned %d issues: %d to work on, %d already completed\n", len(todo)+len(done), len(todo), len(done))
+	for _, item := range todo {
+		fmt.Printf("  %s\n", item.Key())
+	}
+	if planOnly {
+		return exitSolved
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to find the useful1 executable: %v\n", err)
+		return exitUsage
+	}
+	solveArgs := []string{"--log-level", cmd.Flag("log-level").Value.String()}
+	if skipPR {
+		solveArgs = append(solveArgs, "--skip-pr")
+	}
+	if agent != "" {
+		solveArgs = append(solveArgs, "--agent", agent)
+	}
+
+	// Stop starting issues on Ctrl-C; running ones are stopped with their processes
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	started := time.Now()
+	progress := &batchProgress{}
+	results := batch.Run(ctx, todo, batch.Options{
+		Concurrency: concurrency,
+		TotalBudget: totalBudget,
+		IssueBudget: issueBudget,
+	}, solveRunner(executable, solveArgs, progress), progress.result)
+	results = append(results, done...)
+
+	if err := writeBatchReport(report, format, results); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitFailed
+	}
+
+	counts := make(map[string]int)
+	var cost float64
+	failed := false
+	for _, r := range results {
+		counts[r.Outcome]++
+		cost += r.CostUSD
+		failed = failed || r.Failed()
+	}
+	var summary []string
+	for outcome, n := range counts {
+		summary = append(summary, fmt.Sprintf("%d %s", n, outcome))
+	}
+	fmt.Printf("Finished %d issues in %s for $%.2f: %s\nReport written to %s\n",
+		len(results), time.Since(started).Round(time.Second), cost, strings.Join(summary, ", "), report)
+
+	if failed {
+		return exitFailed
+	}
+	return exitSolved
+}
+
+// readBatchFile reads issue references from a file, skipping blank lines and lines
+// starting with "#"
+func readBatchFile(path string) ([]batch.Item, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issue list: %w", err)
+	}
+
+	var items []batch.Item
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var item batch.Item
+		if strings.Contains(line, "://") {
+			item.Owner, item.Repo, item.Number, err = parseIssueURL(line)
+		} else {
+			item.Owner, item.Repo, item.Number, err = state.ParseIssueKey(line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid issue %q, expected owner/repo#number or an issue URL", path, i+1, line)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// searchBatchItems returns the open issues matching a GitHub search
+func searchBatchItems(cfg *config.Config, query string) ([]batch.Item, error) {
+	githubAdapter, err := github.NewAdapter(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub adapter: %w", err)
+	}
+
+	var items []batch.Item
+	for issue, err := range githubAdapter.SearchIssues(vcs.IssueQuery{Raw: query}, time.Time{}) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for issues: %w", err)
+		}
+		items = append(items, batch.Item{Owner: issue.GetOwner(), Repo: issue.GetRepo(), Number: issue.GetNumber()})
+	}
+	return items, nil
+}
+
+// solveRunner returns a batch.RunFunc that solves each issue in a 'useful1 solve' process.
+// The pipeline changes the working directory while it runs, so issues can't share a process.
+func solveRunner(executable string, solveArgs []string, progress *batchProgress) batch.RunFunc {
+	return func(ctx context.Context, item batch.Item, budget float64) batch.Result {
+		args := append([]string{"solve", item.Key(), "--output", "ndjson"}, solveArgs...)
+		if budget > 0 {
+			args = append(args, "--budget", strconv.FormatFloat(budget, 'f', 2, 64))
+		}
+
+		cmd := exec.CommandContext(ctx, executable, args...)
+		cmd.Stderr = os.Stderr
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return batch.Result{Outcome: "failed", Error: fmt.Sprintf("failed to run solve: %v", err)}
+		}
+		if err := cmd.Start(); err != nil {
+			return batch.Result{Outcome: "failed", Error: fmt.Sprintf("failed to run solve: %v", err)}
+		}
+
+		var result *solveEvent
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var event events.Event
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				logging.Debug("Ignoring unexpected solve output", "issue", item.Key(), "line", scanner.Text())
+				continue
+			}
+			if event.Type == "result" {
+				result = &solveEvent{}
+				if err := json.Unmarshal(scanner.Bytes(), result); err != nil {
+					result = nil
+				}
+				continue
+			}
+			progress.step(event)
+		}
+		waitErr := cmd.Wait()
+
+		if result == nil {
+			return batch.Result{Outcome: "failed", Error: fmt.Sprintf("solve ended without a result: %v", waitErr)}
+		}
+		return batch.Result{
+			Outcome:         result.Outcome,
+			Status:          result.Status,
+			URL:             result.URL,
+			Branch:          result.Branch,
+			CostUSD:         result.CostUSD,
+			DurationSeconds: result.ElapsedSeconds,
+			Error:           result.Error,
+		}
+	}
+}
+
+// batchProgress prints the progress of the issues in a batch, one line at a time
+type batchProgress struct {
+	mutex sync.Mutex
+}
+
+// step prints a stage reached by one of the issues
+func (p *batchProgress) step(event events.Event) {
+	line := event.Summary()
+	if line == "" || event.Type == events.TypeError {
+		return // failures are printed with the result
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	fmt.Printf("%s: %s\n", event.Issue, line)
+}
+
+// result prints the outcome of one of the issues
+func (p *batchProgress) result(result batch.Result) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	line := fmt.Sprintf("%s: %s ($%.2f, %s)", result.Issue, result.Outcome, result.CostUSD,
+		(time.Duration(result.DurationSeconds) * time.Second).Round(time.Second))
+	switch {
+	case result.Error != "":
+		line += " " + result.Error
+	case result.URL != "":
+		line += " " + result.URL
+	}
+	fmt.Println(line)
+}
+
+// writeBatchReport writes the results to path as CSV or JSON
+func writeBatchReport(path, format string, results []batch.Result) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+
+	if format == "json" {
+		err = batch.WriteJSON(file, results)
+	} else {
+		err = batch.WriteCSV(file, results)
+	}
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write report: %w", closeErr)
+	}
+	return err
+}
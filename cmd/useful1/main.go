@@ -1,33 +1,31 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
 
+	"github.com/hellausefulsoftware/useful1/internal/audit"
+	"github.com/hellausefulsoftware/useful1/internal/auth"
 	"github.com/hellausefulsoftware/useful1/internal/cli"
 	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
 	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/dryrun"
+	"github.com/hellausefulsoftware/useful1/internal/events"
 	"github.com/hellausefulsoftware/useful1/internal/github"
 	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/metrics"
+	"github.com/hellausefulsoftware/useful1/internal/state"
 	"github.com/hellausefulsoftware/useful1/internal/tui"
 	"github.com/hellausefulsoftware/useful1/internal/workflow"
 	"github.com/spf13/cobra"
 )
 
-// issueProcessorAdapter adapts a function to the IssueProcessor interface
-// This allows us to use our main flow processing logic with the VCS monitor
-type issueProcessorAdapter struct {
-	processFunc func(vcs.Issue) error
-}
-
-// Process calls the wrapped function
-func (a *issueProcessorAdapter) Process(issue vcs.Issue) error {
-	return a.processFunc(issue)
-}
+// logOutputAnnotation is set to "stderr" on commands whose stdout is meant for other programs
+const logOutputAnnotation = "useful1/log-output"
 
 func main() {
 	// Initialize logger with default configuration
@@ -77,9 +75,10 @@ func main() {
 			level = logging.LogLevelInfo
 		}
 
-		// Set output to stderr when in TUI mode to avoid breaking the interface
+		// Set output to stderr when in TUI mode, or when the command prints its results on
+		// stdout, to avoid breaking the output
 		output := os.Stdout
-		if tuiMode {
+		if tuiMode || cmd.Annotations[logOutputAnnotation] == "stderr" {
 			output = os.Stderr
 		}
 
@@ -97,7 +96,13 @@ func main() {
 		Use:   "config",
 		Short: "Generate or update configuration (use --tui for interactive mode)",
 		Long:  `Interactive configuration setup that handles OAuth for GitHub and Anthropic and sets task budgets. Add --tui flag for a more user-friendly interface.`,
+		// The result is printed on stdout as JSON, so logs go to stderr
+		Annotations: map[string]string{logOutputAnnotation: "stderr"},
 		Run: func(cmd *cobra.Command, args []string) {
+			if login, _ := cmd.Flags().GetBool("github-login"); login {
+				runGitHubLogin(cmd)
+				return
+			}
 			if tuiMode {
 				// Run TUI with the config screen
 				runTUIWithScreen(tui.ScreenConfig)
@@ -112,11 +117,15 @@ func main() {
 	configCmd.Flags().String("anthropic-token", "", "Anthropic API token")
 	configCmd.Flags().Float64("issue-budget", 0, "Budget for issue responses")
 	configCmd.Flags().Float64("pr-budget", 0, "Budget for PR creation")
+	configCmd.Flags().Bool("github-login", false, "Sign in to GitHub in the browser with the OAuth device flow")
+	configCmd.Flags().String("client-id", "", "OAuth client ID for --github-login (defaults to USEFUL1_GITHUB_CLIENT_ID or the config)")
 
 	monitorCmd := &cobra.Command{
 		Use:   "monitor",
-		Short: "Monitor GitHub issues assigned to user (use --tui for interactive mode)",
-		Long:  `Start monitoring GitHub issues assigned to the user and respond automatically. Add --tui flag for interactive Terminal UI mode.`,
+		Short: "Monitor GitHub issues matching the trigger rules (use --tui for interactive mode)",
+		Long:  `Start monitoring GitHub issues matching the configured trigger rules (by default, issues assigned to the user) and respond automatically. Add --tui flag for interactive Terminal UI mode.`,
+		// Events are printed on stdout as NDJSON, so logs go to stderr
+		Annotations: map[string]string{logOutputAnnotation: "stderr"},
 		Run: func(cmd *cobra.Command, args []string) {
 			if tuiMode {
 				// Run TUI with the monitor screen
@@ -132,6 +141,9 @@ func main() {
 	monitorCmd.Flags().Int("interval", 60, "Polling interval in seconds")
 	monitorCmd.Flags().Bool("auto-respond", false, "Automatically respond to issues")
 	monitorCmd.Flags().Bool("once", false, "Run a one-time check instead of continuous monitoring")
+	monitorCmd.Flags().Bool("dry-run", false, "Run the agent locally but only record the comments, branches, pushes and pull requests it would make")
+	monitorCmd.Flags().String("report", "", "Write the dry-run report to this file instead of stdout")
+	monitorCmd.Flags().String("metrics-addr", "", "Serve /metrics, /healthz and /readyz on this address, e.g. :9090 (defaults to Metrics.Listen in the config)")
 
 	// Add execute command
 	executeCmd := &cobra.Command{
@@ -191,7 +203,7 @@ func main() {
 	}
 
 	// Add commands for help/completion
-	rootCmd.AddCommand(configCmd, monitorCmd, executeCmd)
+	rootCmd.AddCommand(configCmd, monitorCmd, executeCmd, newWorkspaceCmd(), newSolveCmd(), newBatchCmd(), newAuditCmd())
 
 	// Execute root command
 	if err := rootCmd.Execute(); err != nil {
@@ -200,6 +212,44 @@ func main() {
 	}
 }
 
+// runGitHubLogin signs in to GitHub with the device flow and saves the token to the config
+func runGitHubLogin(cmd *cobra.Command) {
+	clientID, err := cmd.Flags().GetString("client-id")
+	if err != nil {
+		logging.Warn("Failed to get client-id flag", "error", err)
+	}
+
+	// The config may not exist yet; a nil config means github.com
+	cfg, loadErr := config.LoadConfig()
+	if loadErr != nil {
+		cfg = nil
+	}
+	if clientID == "" && cfg != nil {
+		clientID = cfg.GitHubOAuth.ClientID
+	}
+
+	if clientID == "" {
+		fmt.Fprintln(os.Stderr, "Error: a GitHub OAuth client ID is required. Pass --client-id or set USEFUL1_GITHUB_CLIENT_ID.")
+		os.Exit(1)
+	}
+
+	flow := auth.NewDeviceFlow(cfg, clientID, auth.DeviceFlowScopes...)
+	githubAuth, err := auth.LoginWithDeviceFlow(context.Background(), cfg, flow, os.Stdout)
+	if err != nil {
+		logging.Error("GitHub sign-in failed", "error", err)
+		fmt.Fprintf(os.Stderr, "Error: GitHub sign-in failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := config.SaveGitHubLogin(githubAuth.Token, githubAuth.User, clientID); err != nil {
+		logging.Error("Failed to save GitHub credentials", "error", err)
+		fmt.Fprintf(os.Stderr, "Error: failed to save GitHub credentials: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("GitHub credentials saved to %s\n", config.GetConfigPath())
+}
+
 // runTUI launches the TUI application
 func runTUI() {
 	runTUIWithScreen(tui.ScreenMainMenu)
@@ -225,6 +275,9 @@ func runTUIWithScreen(screen tui.ScreenType) {
 			logging.Warn("Error loading configuration", "error", err)
 		}
 	}
+	if err := audit.Configure(cfg); err != nil {
+		logging.Warn("Failed to open audit log, changes won't be recorded", "error", err)
+	}
 
 	// Launch TUI app with specified screen
 	if err := tui.RunWithScreen(cfg, screen); err != nil {
@@ -244,7 +297,7 @@ func runCLIExecutor(cmd *cobra.Command, screenType tui.ScreenType) {
 			"hint", "run 'useful1 config' first to create a configuration")
 
 		// Since CLI is the default mode, always show the error
-		fmt.Fprintf(os.Stderr, "{\"status\": \"error\", \"message\": \"Configuration is required. Run 'useful1 config' first to create a configuration.\"}\n")
+		events.NDJSON(os.Stderr)(events.Event{Type: events.TypeError, Message: "Configuration is required. Run 'useful1 config' first to create a configuration."})
 		os.Exit(1)
 	}
 
@@ -254,13 +307,22 @@ func runCLIExecutor(cmd *cobra.Command, screenType tui.ScreenType) {
 		logging.Error("Failed to load configuration", "error", err)
 
 		// Since CLI is the default mode, always show the error
-		fmt.Fprintf(os.Stderr, "{\"status\": \"error\", \"message\": \"Error loading configuration: %s\"}\n", err)
+		events.NDJSON(os.Stderr)(events.Event{Type: events.TypeError, Message: "Error loading configuration", Error: err.Error()})
 		os.Exit(1)
 	}
 
+	// Record every change made to repositories
+	if err := audit.Configure(cfg); err != nil {
+		logging.Warn("Failed to open audit log, changes won't be recorded", "error", err)
+	}
+
 	// Create CLI executor
 	executor := cli.NewExecutor(cfg)
 
+	// Everything the command and the pipeline report goes to stdout as NDJSON
+	bus := events.NewBus()
+	bus.Subscribe(events.NDJSON(os.Stdout))
+
 	// Get command flags and arguments
 	flags := cmd.Flags()
 
@@ -271,7 +333,7 @@ func runCLIExecutor(cmd *cobra.Command, screenType tui.ScreenType) {
 		// Config shows success in CLI mode since config is already loaded
 		logging.Info("Configuration loaded successfully")
 		// Since CLI is the default mode, always show the success message
-		fmt.Println("{\"status\": \"success\", \"message\": \"Configuration loaded successfully\"}")
+		bus.Publish(events.Event{Type: events.TypeStatus, Status: "success", Message: "Configuration loaded successfully"})
 		return
 
 	case tui.ScreenExecute:
@@ -296,7 +358,7 @@ func runCLIExecutor(cmd *cobra.Command, screenType tui.ScreenType) {
 		// Start monitoring in CLI mode
 		logging.Info("Starting monitoring")
 		// Since CLI is the default mode, always show the start message
-		fmt.Println("{\"status\": \"starting\", \"message\": \"Monitoring started\"}")
+		bus.Publish(events.Event{Type: events.TypeStatus, Status: "starting", Message: "Monitoring started"})
 
 		// Get monitor parameters from flags
 		repo, err := flags.GetString("repo")
@@ -323,7 +385,7 @@ func runCLIExecutor(cmd *cobra.Command, screenType tui.ScreenType) {
 			parts := strings.Split(repo, "/")
 			if len(parts) != 2 {
 				logging.Error("Invalid repo format, expected 'owner/repo'", "repo", repo)
-				fmt.Println("{\"status\": \"error\", \"message\": \"Invalid repo format, expected 'owner/repo'\"}")
+				bus.Publish(events.Event{Type: events.TypeError, Message: "Invalid repo format, expected 'owner/repo'"})
 				os.Exit(1)
 			}
 
@@ -339,162 +401,92 @@ func runCLIExecutor(cmd *cobra.Command, screenType tui.ScreenType) {
 		}
 		logging.Info("Set poll interval", "seconds", interval, "minutes", cfg.Monitor.PollInterval)
 
-		// Set if we're monitoring assigned issues only (always true for CLI mode)
-		cfg.Monitor.AssignedIssuesOnly = true
-		logging.Info("Monitoring assigned issues only")
+		if len(cfg.Triggers.Rules) > 0 {
+			logging.Info("Using trigger rules", "rules", strings.Join(cfg.Triggers.Rules, "; "))
+		} else {
+			logging.Info("Monitoring assigned issues only")
+		}
 
 		// Create monitor (no need to separately create GitHub client)
 		// Create GitHub adapter for VCS service
 		githubAdapter, err := github.NewAdapter(cfg)
 		if err != nil {
 			logging.Error("Failed to create GitHub adapter", "error", err)
-			fmt.Println("{\"status\": \"error\", \"message\": \"Failed to create GitHub adapter\"}")
+			bus.Publish(events.Event{Type: events.TypeError, Message: "Failed to create GitHub adapter", Error: err.Error()})
 			os.Exit(1)
 		}
 
-		// Create a processor adapter that delegates to our main flow processing
-		// Create a function to handle processing discovered issues in the main execution flow
-		processIssueFunc := func(issue vcs.Issue) error {
-			// Get authenticated username
-			username, authErr := githubAdapter.GetAuthenticatedUser()
-			if authErr != nil {
-				logging.Warn("Failed to get authenticated user", "error", authErr)
-			}
-
-			logging.Info("Processing issue in main execution flow",
-				"number", issue.GetNumber(),
-				"owner", issue.GetOwner(),
-				"repo", issue.GetRepo(),
-				"title", issue.GetTitle())
-
-			// Check if the issue is already closed
-			if strings.ToLower(issue.GetState()) == "closed" {
-				logging.Info("Issue is closed, skipping")
-				return nil
-			}
-
-			// Check if the last comment was from the bot
-			comments := issue.GetComments()
-			if len(comments) > 0 && comments[len(comments)-1].User == username {
-				logging.Info("Last comment was from bot, skipping to avoid duplicate responses")
-				return nil
-			}
-
-			// Check if we already have a PR for this issue
-			prs, prErr := githubAdapter.GetPullRequestsForIssue(issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
-			if prErr != nil {
-				logging.Warn("Failed to check for existing draft PRs", "error", prErr)
-			} else {
-				// Check if any of these PRs are open drafts created by our user
-				for _, pr := range prs {
-					// Only consider open draft PRs
-					if pr.GetState() == "open" && pr.GetIsDraft() && pr.GetUser() == username {
-						logging.Info("Issue already has a draft PR, skipping")
-						return nil
-					}
-				}
-			}
-
-			// Create implementation workflow to handle the issue
-			implementationWorkflow := workflow.NewImplementationWorkflow(cfg)
-
-			// Generate branch name for the issue
-			branchName, prTitle, genErr := implementationWorkflow.GenerateBranchAndTitle(
-				issue.GetOwner(),
-				issue.GetRepo(),
-				issue.GetTitle(),
-				issue.GetBody(),
-			)
-			if genErr != nil {
-				return fmt.Errorf("failed to generate branch name: %w", genErr)
-			}
-
-			logging.Info("Generated branch name with workflow",
-				"branch", branchName,
-				"pr_title", prTitle)
-
-			// Get default branch
-			defaultBranch, defaultErr := githubAdapter.GetDefaultBranch(issue.GetOwner(), issue.GetRepo())
-			if defaultErr != nil {
-				logging.Warn("Failed to get default branch, using 'main'", "error", defaultErr)
-				defaultBranch = "main" // Default fallback
-			}
-
-			// Create the branch
-			logging.Info("Creating branch", "branch", branchName, "base", defaultBranch)
-			if createErr := githubAdapter.CreateBranch(issue.GetOwner(), issue.GetRepo(), branchName, defaultBranch); createErr != nil {
-				return fmt.Errorf("failed to create branch: %w", createErr)
-			}
-
-			// Create implementation plan and get Claude output
-			claudeOutput, planErr := implementationWorkflow.CreateImplementationPromptAndExecute(
-				issue.GetOwner(),
-				issue.GetRepo(),
-				branchName,
-				issue.GetNumber(),
-			)
-			if planErr != nil {
-				logging.Warn("Failed to create implementation plan", "error", planErr)
-				claudeOutput = "" // Empty if there was an error
-				// Continue anyway - we'll still create the PR
-			}
-
-			logging.Info("Creating PR",
-				"owner", issue.GetOwner(),
-				"repo", issue.GetRepo(),
-				"title", prTitle,
-				"branch", branchName,
-				"base", defaultBranch)
-
-			// Get home directory for repository path
-			homeDir, homeDirErr := os.UserHomeDir()
-			if homeDirErr != nil {
-				return fmt.Errorf("failed to get user home directory: %w", homeDirErr)
-			}
-
-			// Create the PR using the implementation output
-			pr, prErr := implementationWorkflow.CreatePullRequestForIssue(
-				issue.GetOwner(),
-				issue.GetRepo(),
-				branchName,
-				defaultBranch,
-				issue.GetNumber(),
-				claudeOutput,
-				// Pass the repository directory that was used for implementation
-				fmt.Sprintf("%s/.useful1/temp/%s_%d", homeDir, issue.GetRepo(), issue.GetNumber()),
-			)
-			if prErr != nil {
-				return fmt.Errorf("failed to create draft PR: %w", prErr)
+		// Serve metrics and health checks for running the monitor as a service
+		metricsAddr, err := flags.GetString("metrics-addr")
+		if err != nil {
+			logging.Warn("Failed to get metrics-addr flag", "error", err)
+		}
+		if metricsAddr == "" {
+			metricsAddr = cfg.Metrics.Listen
+		}
+		if metricsAddr != "" {
+			collector := metrics.NewCollector(bus, githubAdapter)
+			if err := metrics.ListenAndServe(context.Background(), metricsAddr, collector); err != nil {
+				logging.Error("Failed to serve metrics", "address", metricsAddr, "error", err)
+				bus.Publish(events.Event{Type: events.TypeError, Message: "Failed to serve metrics", Error: err.Error()})
+				os.Exit(1)
 			}
+		}
 
-			logging.Info("Successfully created draft PR",
-				"pr_number", pr.GetNumber(),
-				"url", pr.GetURL())
+		// A dry run records what it would change and reports it at the end
+		dryRun, err := flags.GetBool("dry-run")
+		if err != nil {
+			logging.Warn("Failed to get dry-run flag", "error", err)
+		}
+		var recorder *dryrun.Recorder
+		if dryRun {
+			recorder = dryrun.NewRecorder()
+			logging.Info("Dry run: comments, branches, pushes and pull requests are recorded, not made")
+		}
 
-			return nil
+		// Open the issue state store so parked issues survive restarts; a dry run reads it but never saves it
+		openStore := state.Open
+		if dryRun {
+			openStore = state.OpenReadOnly
+		}
+		store, err := openStore(state.DefaultPath())
+		if err != nil {
+			logging.Warn("Failed to open issue state store, using in-memory state", "error", err)
+			store = nil
 		}
 
-		processor := &issueProcessorAdapter{
-			processFunc: processIssueFunc,
+		// Create the pipeline that handles discovered issues
+		processor, err := workflow.NewPipeline(workflow.PipelineConfig{
+			Config:   cfg,
+			Service:  githubAdapter,
+			Store:    store,
+			Recorder: recorder,
+			Events:   bus,
+		})
+		if err != nil {
+			logging.Error("Failed to create issue pipeline", "error", err)
+			bus.Publish(events.Event{Type: events.TypeError, Message: "Failed to create issue pipeline", Error: err.Error()})
+			os.Exit(1)
 		}
 		monitorConfig := vcs.MonitorConfig{
 			Config:    cfg,
 			Service:   githubAdapter,
 			Processor: processor,
+			Events:    bus,
 		}
 
 		// Create the new monitor
 		monitor, err := vcs.NewMonitor(monitorConfig)
 		if err != nil {
 			logging.Error("Failed to create monitor", "error", err)
-			fmt.Println("{\"status\": \"error\", \"message\": \"Failed to create monitor\"}")
+			bus.Publish(events.Event{Type: events.TypeError, Message: "Failed to create monitor", Error: err.Error()})
 			os.Exit(1)
 		}
 
 		// Check if username was found
 		if monitor.GetUsername() == "" {
 			logging.Error("Failed to determine GitHub username, check your token permissions")
-			fmt.Println("{\"status\": \"error\", \"message\": \"Failed to determine GitHub username, check your token permissions\"}")
+			bus.Publish(events.Event{Type: events.TypeError, Message: "Failed to determine GitHub username, check your token permissions"})
 			os.Exit(1)
 		}
 
@@ -520,24 +512,18 @@ func runCLIExecutor(cmd *cobra.Command, screenType tui.ScreenType) {
 			err := monitor.CheckOnce()
 			if err != nil {
 				logging.Error("Check failed", "error", err)
-				fmt.Printf("{\"status\": \"error\", \"message\": \"Check failed: %s\"}\n", err.Error())
+				bus.Publish(events.Event{Type: events.TypeError, Message: "Check failed", Error: err.Error()})
 				os.Exit(1)
 			}
 
-			// Get stats and output as JSON
+			// Report the stats with the result
 			stats := monitor.GetStats()
-			statsJSON, err := json.Marshal(stats)
-			if err != nil {
-				logging.Error("Failed to marshal stats", "error", err)
-			} else {
-				logging.Info("Monitoring stats", "stats", string(statsJSON))
-			}
-
-			fmt.Println("{\"status\": \"success\", \"message\": \"Check completed successfully\", \"stats\": " + string(statsJSON) + "}")
+			logging.Info("Monitoring stats", "stats", stats)
+			bus.Publish(events.Event{Type: events.TypeStatus, Status: "success", Message: "Check completed successfully", Data: stats})
 		} else {
 			// Run continuous monitoring
 			logging.Info("Starting continuous monitoring")
-			fmt.Println("{\"status\": \"running\", \"message\": \"Continuous monitoring started\"}")
+			bus.Publish(events.Event{Type: events.TypeStatus, Status: "running", Message: "Continuous monitoring started"})
 
 			// Start monitoring in a goroutine so we can capture signals
 			monitorDone := make(chan struct{})
@@ -556,10 +542,21 @@ func runCLIExecutor(cmd *cobra.Command, screenType tui.ScreenType) {
 			select {
 			case <-sigChan:
 				logging.Info("Received interrupt, shutting down")
-				fmt.Println("{\"status\": \"stopping\", \"message\": \"Monitoring stopped by user\"}")
+				bus.Publish(events.Event{Type: events.TypeStatus, Status: "stopping", Message: "Monitoring stopped by user"})
 			case <-monitorDone:
 				logging.Info("Monitoring completed")
-				fmt.Println("{\"status\": \"completed\", \"message\": \"Monitoring completed\"}")
+				bus.Publish(events.Event{Type: events.TypeStatus, Status: "completed", Message: "Monitoring completed"})
+			}
+		}
+
+		// Without a report file, the dry-run actions are reported as an event so stdout stays NDJSON
+		if recorder != nil {
+			report, _ := flags.GetString("report")
+			if report == "" {
+				bus.Publish(events.Event{Type: events.TypeDryRun, Data: recorder.Actions()})
+			} else if err := writeDryRunReport(recorder, report); err != nil {
+				logging.Error("Failed to write dry-run report", "error", err)
+				os.Exit(1)
 			}
 		}
 
@@ -574,7 +571,30 @@ func runCLIExecutor(cmd *cobra.Command, screenType tui.ScreenType) {
 		logging.Error("Command execution failed", "error", cmdErr)
 
 		// Since CLI is the default mode, always show the error in JSON format
-		fmt.Fprintf(os.Stderr, "{\"status\": \"error\", \"message\": \"%s\"}\n", cmdErr.Error())
+		events.NDJSON(os.Stderr)(events.Event{Type: events.TypeError, Message: "Command execution failed", Error: cmdErr.Error()})
 		os.Exit(1)
 	}
 }
+
+// writeDryRunReport writes the report of a dry run to path, or to stdout if path is empty
+func writeDryRunReport(recorder *dryrun.Recorder, path string) error {
+	if path == "" {
+		fmt.Println()
+		return recorder.WriteReport(os.Stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	if err := recorder.WriteReport(file); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	logging.Info("Wrote dry-run report", "path", path, "actions", len(recorder.Actions()))
+	return nil
+}
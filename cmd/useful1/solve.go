@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/anthropic"
+	"github.com/hellausefulsoftware/useful1/internal/audit"
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/dryrun"
+	"github.com/hellausefulsoftware/useful1/internal/events"
+	"github.com/hellausefulsoftware/useful1/internal/github"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+	"github.com/hellausefulsoftware/useful1/internal/workflow"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes of the solve command
+const (
+	exitSolved     = 0 // a pull request was opened, or the branch pushed with --skip-pr
+	exitFailed     = 1 // the run failed
+	exitUsage      = 2 // the arguments or the configuration are invalid
+	exitNeedsInput = 3 // waiting for a human: answers to questions, plan approval or a review of flagged text
+	exitSkipped    = 4 // nothing was done: the issue is closed, cancelled, handed over or already has a pull request
+)
+
+// newSolveCmd returns the command that runs the pipeline on a single issue
+func newSolveCmd() *cobra.Command {
+	solveCmd := &cobra.Command{
+		Use:   "solve [owner/repo#number]",
+		Short: "Work on a single issue now and report the outcome",
+		Long: `Run the same pipeline as the monitor on one issue, without waiting for a poll, and print its progress.
+
+Exit codes: 0 solved, 1 failed, 2 invalid arguments or configuration, 3 waiting for a human (questions, plan approval or flagged text), 4 skipped (closed, cancelled, handed over or already has a pull request).`,
+		Example: `  useful1 solve acme/api#123
+  useful1 solve --url https://github.com/acme/api/issues/123 --output ndjson`,
+		Args: cobra.MaximumNArgs(1),
+		// Progress is printed on stdout, so logs go to stderr
+		Annotations: map[string]string{logOutputAnnotation: "stderr"},
+		Run: func(cmd *cobra.Command, args []string) {
+			os.Exit(runSolve(cmd, args))
+		},
+	}
+
+	solveCmd.Flags().String("url", "", "URL of the issue, instead of owner/repo#number")
+	solveCmd.Flags().String("base", "", "Branch to open the pull request against (defaults to the repository's default branch)")
+	solveCmd.Flags().String("agent", "", "Agent CLI to run instead of the configured one")
+	solveCmd.Flags().Float64("budget", 0, "Budget for the agent in this run in USD (defaults to the issue's or configured budget)")
+	solveCmd.Flags().Bool("skip-pr", false, "Push the branch without opening a pull request")
+	solveCmd.Flags().Bool("dry-run", false, "Run the agent locally but only record the comments, branches, pushes and pull requests it would make")
+	solveCmd.Flags().String("report", "", "Write the dry-run report to this file instead of stdout")
+	solveCmd.Flags().String("output", "text", "Progress format: text or ndjson")
+
+	return solveCmd
+}
+
+// solveEvent is the last line of NDJSON output, after the pipeline's events
+type solveEvent struct {
+	Type           string          `json:"type"` // "result"
+	Issue          string          `json:"issue"`
+	Detail         string          `json:"detail,omitempty"`
+	Outcome        string          `json:"outcome,omitempty"`
+	Status         string          `json:"status,omitempty"`
+	Branch         string          `json:"branch,omitempty"`
+	PullRequest    int             `json:"pull_request,omitempty"`
+	URL            string          `json:"url,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	ElapsedSeconds float64         `json:"elapsed_seconds"`
+	CostUSD        float64         `json:"cost_usd"`
+	ExitCode       *int            `json:"exit_code,omitempty"`
+	DryRun         []dryrun.Action `json:"dry_run,omitempty"`
+}
+
+// solvePrinter writes the progress of a solve run as text or NDJSON
+type solvePrinter struct {
+	out     io.Writer
+	ndjson  bool
+	started time.Time
+	write   func(events.Event) // writes events as NDJSON
+}
+
+// newSolvePrinter creates a printer writing to out
+func newSolvePrinter(out io.Writer, ndjson bool) *solvePrinter {
+	return &solvePrinter{out: out, ndjson: ndjson, started: time.Now(), write: events.NDJSON(out)}
+}
+
+// event prints an event of the run. Text output shows the stages and the outcome only.
+func (p *solvePrinter) event(event events.Event) {
+	if p.ndjson {
+		p.write(event)
+		return
+	}
+
+	line := event.Summary()
+	if line == "" {
+		return
+	}
+	elapsed := time.Duration(event.Elapsed * float64(time.Second))
+	fmt.Fprintf(p.out, "[%s] %s\n", elapsed.Round(time.Second), strings.ReplaceAll(line, "`", ""))
+}
+
+// result prints the outcome of the run
+func (p *solvePrinter) result(event solveEvent) {
+	event.Type = "result"
+	event.ElapsedSeconds = time.Since(p.started).Seconds()
+	if p.ndjson {
+		line, err := json.Marshal(event)
+		if err != nil {
+			logging.Error("Failed to encode result", "error", err)
+			return
+		}
+		fmt.Fprintln(p.out, string(line))
+		return
+	}
+
+	fmt.Fprintf(p.out, "%s: %s", event.Issue, event.Outcome)
+	switch {
+	case event.Error != "":
+		fmt.Fprintf(p.out, " (%s)", event.Error)
+	case event.URL != "":
+		fmt.Fprintf(p.out, " (%s)", event.URL)
+	case event.Detail != "":
+		fmt.Fprintf(p.out, " (%s)", event.Detail)
+	}
+	fmt.Fprintln(p.out)
+}
+
+// runSolve runs the pipeline on the issue named by the arguments and returns the exit code
+func runSolve(cmd *cobra.Command, args []string) int {
+	flags := cmd.Flags()
+	issueURL, _ := flags.GetString("url")
+	base, _ := flags.GetString("base")
+	agent, _ := flags.GetString("agent")
+	budget, _ := flags.GetFloat64("budget")
+	skipPR, _ := flags.GetBool("skip-pr")
+	dryRun, _ := flags.GetBool("dry-run")
+	report, _ := flags.GetString("report")
+	output, _ := flags.GetString("output")
+
+	if output != "text" && output != "ndjson" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q, expected text or ndjson\n", output)
+		return exitUsage
+	}
+
+	var owner, repo string
+	var number int
+	var err error
+	switch {
+	case issueURL != "" && len(args) > 0:
+		err = fmt.Errorf("give either owner/repo#number or --url, not both")
+	case issueURL != "":
+		owner, repo, number, err = parseIssueURL(issueURL)
+	case len(args) > 0:
+		if owner, repo, number, err = state.ParseIssueKey(args[0]); err != nil {
+			err = fmt.Errorf("invalid issue %q, expected owner/repo#number", args[0])
+		}
+	default:
+		err = fmt.Errorf("an issue is required, as owner/repo#number or --url")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	if !config.Exists() {
+		fmt.Fprintln(os.Stderr, "Error: Configuration is required. Run 'useful1 config' first to create a configuration.")
+		return exitUsage
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %s\n", err)
+		return exitUsage
+	}
+	if agent != "" {
+		cfg.CLI.Command = agent
+	}
+	if err := audit.Configure(cfg); err != nil {
+		logging.Warn("Failed to open audit log, changes won't be recorded", "error", err)
+	}
+
+	githubAdapter, err := github.NewAdapter(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to create GitHub adapter: %v\n", err)
+		return exitUsage
+	}
+
+	// A dry run reads the issue state but never saves it
+	openStore := state.Open
+	var recorder *dryrun.Recorder
+	if dryRun {
+		openStore = state.OpenReadOnly
+		recorder = dryrun.NewRecorder()
+	}
+	store, err := openStore(state.DefaultPath())
+	if err != nil {
+		logging.Warn("Failed to open issue state store, using in-memory state", "error", err)
+		store, _ = state.Open("")
+	}
+
+	// The base branch applies like the chat command of the same name; the budget only to this run
+	key := state.IssueKey(owner, repo, number)
+	st := store.Get(key)
+	before := st.Status
+	if base != "" {
+		st.BaseBranch = base
+		if err := store.Put(key, st); err != nil {
+			logging.Warn("Failed to save issue state", "issue", key, "error", err)
+		}
+	}
+
+	printer := newSolvePrinter(os.Stdout, output == "ndjson")
+	bus := events.NewBus()
+	bus.Subscribe(printer.event)
+	pipeline, err := workflow.NewPipeline(workflow.PipelineConfig{
+		Config:          cfg,
+		Service:         githubAdapter,
+		Store:           store,
+		Recorder:        recorder,
+		Events:          bus,
+		OnDemand:        true,
+		SkipPullRequest: skipPR,
+		Budget:          budget,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to create issue pipeline: %v\n", err)
+		return exitUsage
+	}
+
+	startSpent := anthropic.Spent()
+	issue, err := githubAdapter.GetIssueWithComments(owner, repo, number)
+	var runErr error
+	if err != nil {
+		runErr = fmt.Errorf("failed to get issue: %w", err)
+	} else {
+		runErr = pipeline.Process(issue)
+	}
+
+	st = store.Get(key)
+	outcome, code := solveOutcome(before, st, runErr)
+	result := solveEvent{
+		Issue:       key,
+		Outcome:     outcome,
+		Status:      string(st.Status),
+		Branch:      st.Branch,
+		PullRequest: st.PullRequest,
+		CostUSD:     anthropic.Spent() - startSpent,
+		ExitCode:    &code,
+	}
+	if runErr != nil {
+		result.Error = runErr.Error()
+	}
+	if st.PullRequest > 0 {
+		result.URL = fmt.Sprintf("%s/%s/%s/pull/%d", cfg.GitHubWebURL(), owner, repo, st.PullRequest)
+	}
+
+	// NDJSON output carries the dry-run actions in the result instead of a separate report
+	if recorder != nil && printer.ndjson && report == "" {
+		result.DryRun = recorder.Actions()
+	}
+	printer.result(result)
+
+	if recorder != nil && (!printer.ndjson || report != "") {
+		if err := writeDryRunReport(recorder, report); err != nil {
+			logging.Error("Failed to write dry-run report", "error", err)
+			return exitFailed
+		}
+	}
+	return code
+}
+
+// solveOutcome names how a solve run ended and returns its exit code. before is the
+// issue's status before the run, so work finished by an earlier run counts as skipped.
+func solveOutcome(before state.Status, st state.IssueState, err error) (string, int) {
+	switch {
+	case err != nil:
+		return "failed", exitFailed
+	case st.Status == state.StatusCompleted && before != state.StatusCompleted:
+		return "solved", exitSolved
+	case st.Status == state.StatusParked:
+		return "needs_info", exitNeedsInput
+	case st.Status == state.StatusAwaitingApproval:
+		return "awaiting_approval", exitNeedsInput
+	case st.Status == state.StatusNeedsHuman:
+		return "needs_review", exitNeedsInput
+	default:
+		return "skipped", exitSkipped
+	}
+}
+
+// parseIssueURL returns the issue a URL such as https://github.com/owner/repo/issues/123
+// points to. Pull request URLs are accepted too, since pull requests are issues on GitHub.
+func parseIssueURL(rawURL string) (owner, repo string, number int, err error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return "", "", 0, fmt.Errorf("invalid issue URL %q", rawURL)
+	}
+
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) != 4 || (parts[2] != "issues" && parts[2] != "pull") {
+		return "", "", 0, fmt.Errorf("invalid issue URL %q, expected .../owner/repo/issues/number", rawURL)
+	}
+
+	number, err = strconv.Atoi(parts[3])
+	if err != nil || number <= 0 {
+		return "", "", 0, fmt.Errorf("invalid issue number in URL %q", rawURL)
+	}
+	return parts[0], parts[1], number, nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/github"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+	"github.com/hellausefulsoftware/useful1/internal/workflow"
+	"github.com/hellausefulsoftware/useful1/internal/workspace"
+	"github.com/spf13/cobra"
+)
+
+// newWorkspaceCmd returns the command group for inspecting and pruning issue workspaces
+func newWorkspaceCmd() *cobra.Command {
+	workspaceCmd := &cobra.Command{
+		Use:   "workspace",
+		Short: "Inspect and prune repository mirrors and issue worktrees",
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List mirrors, worktrees, legacy clones and leftover temp files",
+		Run: func(cmd *cobra.Command, args []string) {
+			entries := listWorkspaces(loadWorkspaceConfig())
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KIND\tREPOSITORY\tISSUE\tBRANCH\tSIZE\tLAST USED\tSTATUS\tPATH")
+			for _, e := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+					e.Kind, entryRepo(e), entryIssue(e), dash(e.Branch), formatSize(e.Size),
+					formatAge(e.ModTime), entryStatus(e), e.Path)
+			}
+			_ = w.Flush()
+		},
+	}
+
+	duCmd := &cobra.Command{
+		Use:   "du",
+		Short: "Show disk usage by kind and against the quota",
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg := loadWorkspaceConfig()
+			entries := listWorkspaces(cfg)
+
+			sizes := make(map[workspace.Kind]int64)
+			counts := make(map[workspace.Kind]int)
+			var total int64
+			for _, e := range entries {
+				sizes[e.Kind] += e.Size
+				counts[e.Kind]++
+				total += e.Size
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "KIND\tCOUNT\tSIZE")
+			for _, kind := range []workspace.Kind{workspace.KindMirror, workspace.KindWorktree, workspace.KindLegacy, workspace.KindTempFile} {
+				fmt.Fprintf(w, "%s\t%d\t%s\n", kind, counts[kind], formatSize(sizes[kind]))
+			}
+			fmt.Fprintf(w, "total\t%d\t%s\n", len(entries), formatSize(total))
+			_ = w.Flush()
+
+			if cfg.Workspace.MaxDiskMB > 0 {
+				quota := int64(cfg.Workspace.MaxDiskMB) * 1024 * 1024
+				fmt.Printf("\nQuota: %s (%.0f%% used)\n", formatSize(quota), float64(total)*100/float64(quota))
+			}
+		},
+	}
+
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove workspaces for finished issues, old workspaces and workspaces beyond the disk quota",
+		Long:  "Remove worktrees for closed issues and merged pull requests, workspaces untouched for longer than the maximum age, and the least recently used workspaces beyond the disk quota. Workspaces with a run in progress are never removed.",
+		Run:   runWorkspacePrune,
+	}
+	pruneCmd.Flags().Bool("dry-run", false, "Show what would be removed without removing anything")
+	pruneCmd.Flags().Int("max-age-days", -1, "Remove workspaces untouched for this many days (defaults to Workspace.MaxAgeDays)")
+	pruneCmd.Flags().Int("quota-mb", -1, "Disk quota in MB (defaults to Workspace.MaxDiskMB)")
+	pruneCmd.Flags().Bool("skip-issue-check", false, "Don't ask GitHub which issues are closed; prune by age and quota only")
+
+	workspaceCmd.AddCommand(listCmd, duCmd, pruneCmd)
+	return workspaceCmd
+}
+
+// runWorkspacePrune prunes workspaces with the configured policy and any overrides from flags
+func runWorkspacePrune(cmd *cobra.Command, args []string) {
+	flags := cmd.Flags()
+	dryRun, _ := flags.GetBool("dry-run")
+	skipIssueCheck, _ := flags.GetBool("skip-issue-check")
+
+	var cfg *config.Config
+	if skipIssueCheck {
+		cfg = loadWorkspaceConfig()
+	} else {
+		var err error
+		if cfg, err = config.Load(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading configuration: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if maxAge, _ := flags.GetInt("max-age-days"); maxAge >= 0 {
+		cfg.Workspace.MaxAgeDays = maxAge
+	}
+	if quota, _ := flags.GetInt("quota-mb"); quota >= 0 {
+		cfg.Workspace.MaxDiskMB = quota
+	}
+
+	var removed []workspace.Entry
+	var err error
+	if skipIssueCheck {
+		opts := workspace.PruneOptionsFromConfig(cfg)
+		opts.DryRun = dryRun
+		removed, err = workspace.NewManager(cfg).Prune(opts)
+	} else {
+		removed, err = pruneWithIssueCheck(cfg, dryRun)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error pruning workspaces: %s\n", err)
+		os.Exit(1)
+	}
+
+	verb := "Removed"
+	if dryRun {
+		verb = "Would remove"
+	}
+
+	var freed int64
+	for _, e := range removed {
+		freed += e.Size
+		fmt.Printf("%s %s %s (%s)\n", verb, e.Kind, e.Path, formatSize(e.Size))
+	}
+	fmt.Printf("%s %d workspaces, %s\n", verb, len(removed), formatSize(freed))
+}
+
+// pruneWithIssueCheck prunes through the pipeline so worktrees of closed issues and merged pull requests go too
+func pruneWithIssueCheck(cfg *config.Config, dryRun bool) ([]workspace.Entry, error) {
+	adapter, err := github.NewAdapter(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub adapter: %w", err)
+	}
+
+	store, err := state.Open(state.DefaultPath())
+	if err != nil {
+		logging.Warn("Failed to open issue state store, merged pull requests won't be detected", "error", err)
+		store = nil
+	}
+
+	pipeline, err := workflow.NewPipeline(workflow.PipelineConfig{
+		Config:  cfg,
+		Service: adapter,
+		Store:   store,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.PruneWorkspaces(dryRun)
+}
+
+// loadWorkspaceConfig loads the configuration without requiring credentials
+func loadWorkspaceConfig() *config.Config {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration: %s\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+// listWorkspaces lists the workspaces on disk or exits on failure
+func listWorkspaces(cfg *config.Config) []workspace.Entry {
+	entries, err := workspace.NewManager(cfg).List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing workspaces: %s\n", err)
+		os.Exit(1)
+	}
+	return entries
+}
+
+// entryRepo returns the repository an entry belongs to, as far as it is known
+func entryRepo(e workspace.Entry) string {
+	switch {
+	case e.Owner != "":
+		return e.Owner + "/" + e.Repo
+	case e.Repo != "":
+		return e.Repo
+	}
+	return "-"
+}
+
+// entryIssue returns the issue an entry belongs to, if any
+func entryIssue(e workspace.Entry) string {
+	if e.Issue == 0 {
+		return "-"
+	}
+	return "#" + strconv.Itoa(e.Issue)
+}
+
+// entryStatus tells whether a run is using the entry
+func entryStatus(e workspace.Entry) string {
+	if e.InFlight {
+		return "in use"
+	}
+	return "idle"
+}
+
+// dash returns "-" for empty strings
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatSize formats a byte count for humans
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// formatAge formats how long ago something was last modified
+func formatAge(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	age := time.Since(t)
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+}
@@ -2,7 +2,6 @@
 package anthropic
 
 import (
-	"context"
 	"encoding/base64"
 	"fmt"
 	"strings"
@@ -12,6 +11,7 @@ import (
 	"github.com/hellausefulsoftware/useful1/internal/config"
 	"github.com/hellausefulsoftware/useful1/internal/logging"
 	"github.com/hellausefulsoftware/useful1/internal/models"
+	"github.com/hellausefulsoftware/useful1/internal/security"
 )
 
 // Constants for API
@@ -84,7 +84,7 @@ func NewAnalyzer(cfg *config.Config) *IssueAnalyzer {
 
 // SummarizeIssue takes an issue transcript and returns a concise summary
 func (a *IssueAnalyzer) SummarizeIssue(transcript string) (string, error) {
-	return a.summarizeIssue(transcript)
+	return a.summarizeIssue(transcript, nil)
 }
 
 // GenerateImplementationPlan generates a detailed implementation plan for solving an issue
@@ -105,7 +105,7 @@ func (a *IssueAnalyzer) GeneratePRDescription(issue *models.Issue, implementatio
 	logging.Info("Generating PR description", "implementationPlan", implementationPlan)
 
 	// Use Claude 3.7 Sonnet to generate a detailed PR description
-	return a.generatePRDescription(transcript, implementationPlan, changedFiles)
+	return a.generatePRDescription(transcript, implementationPlan, changedFiles, issue)
 }
 
 // AnalyzeIssue analyzes an issue using the Anthropic API and returns a branch name suggestion
@@ -121,7 +121,7 @@ func (a *IssueAnalyzer) AnalyzeIssue(issue *models.Issue) (string, error) {
 
 	// 2. Summarize the issue using Claude 3.5 Sonnet
 	logging.Info("Requesting issue summary from Anthropic API")
-	summary, err := a.summarizeIssue(transcript)
+	summary, err := a.summarizeIssue(transcript, issue)
 	if err != nil {
 		logging.Error("Failed to summarize issue", "error", err)
 		return defaultBranchName(issue), err
@@ -130,7 +130,7 @@ func (a *IssueAnalyzer) AnalyzeIssue(issue *models.Issue) (string, error) {
 
 	// 3. Classify the issue type using Claude 3 Haiku
 	logging.Info("Requesting issue classification from Anthropic API")
-	issueType, err := a.classifyIssueType(summary)
+	issueType, err := a.classifyIssueType(summary, issue)
 	if err != nil {
 		logging.Error("Failed to classify issue type", "error", err)
 		return defaultBranchName(issue), err
@@ -153,8 +153,11 @@ func (a *IssueAnalyzer) AnalyzeIssue(issue *models.Issue) (string, error) {
 func formatIssueTranscript(issue *models.Issue) string {
 	var transcript strings.Builder
 
+	// Everything users wrote is delimited so the model doesn't take it as instructions
+	transcript.WriteString(security.UntrustedNotice + "\n\n")
+
 	// Issue metadata
-	transcript.WriteString(fmt.Sprintf("ISSUE #%d: %s\n\n", issue.Number, issue.Title))
+	transcript.WriteString(fmt.Sprintf("ISSUE #%d: %s\n\n", issue.Number, security.Delimit("title", issue.Title)))
 	transcript.WriteString(fmt.Sprintf("Created by: %s\n", issue.User))
 	transcript.WriteString(fmt.Sprintf("State: %s\n", issue.State))
 	transcript.WriteString(fmt.Sprintf("Created: %s\n", issue.CreatedAt.Format("2006-01-02")))
@@ -170,7 +173,7 @@ func formatIssueTranscript(issue *models.Issue) string {
 
 	// Issue description
 	transcript.WriteString("\nISSUE DESCRIPTION:\n")
-	transcript.WriteString(issue.Body)
+	transcript.WriteString(security.Delimit("description by @"+issue.User, issue.Body))
 	transcript.WriteString("\n\n")
 
 	// Comments
@@ -181,7 +184,7 @@ func formatIssueTranscript(issue *models.Issue) string {
 				i+1,
 				comment.User,
 				comment.CreatedAt.Format("2006-01-02")))
-			transcript.WriteString(comment.Body)
+			transcript.WriteString(security.Delimit("comment by @"+comment.User, comment.Body))
 			transcript.WriteString("\n\n")
 		}
 	}
@@ -190,7 +193,7 @@ func formatIssueTranscript(issue *models.Issue) string {
 }
 
 // summarizeIssue uses Claude 3.5 Sonnet to summarize the issue
-func (a *IssueAnalyzer) summarizeIssue(transcript string) (string, error) {
+func (a *IssueAnalyzer) summarizeIssue(transcript string, issue *models.Issue) (string, error) {
 	prompt := `You are a technical project manager reviewing GitHub issues. Analyze this issue transcript and provide a concise summary. 
 Focus only on the technical details and remove any off-topic comments or non-technical discussions.
 Be brief but detailed enough to understand the core problem or request.
@@ -211,7 +214,7 @@ Provide a concise summary of the issue in 1-3 short paragraphs, focusing only on
 		"prompt_length", len(prompt))
 
 	// Create a message using the SDK
-	message, err := a.client.Messages.New(context.Background(), anthropicAPI.MessageNewParams{
+	message, err := a.newMessage(issue, anthropicAPI.MessageNewParams{
 		Model:     anthropicAPI.F(SummaryModel),
 		MaxTokens: anthropicAPI.F(int64(500)),
 		Messages: anthropicAPI.F([]anthropicAPI.MessageParam{
@@ -251,7 +254,7 @@ Provide a concise summary of the issue in 1-3 short paragraphs, focusing only on
 }
 
 // classifyIssueType uses Claude 3 Haiku to determine the issue type
-func (a *IssueAnalyzer) classifyIssueType(summary string) (string, error) {
+func (a *IssueAnalyzer) classifyIssueType(summary string, issue *models.Issue) (string, error) {
 	prompt := `You are a software development issue classifier. Based on the following issue summary, classify this issue as one of these types:
 - bug: A problem with existing functionality
 - feature: A request for new functionality
@@ -274,7 +277,7 @@ Respond with only one word: "bug", "feature", or "chore".`
 		"summary_length", len(summary))
 
 	// Create a message using the SDK
-	message, err := a.client.Messages.New(context.Background(), anthropicAPI.MessageNewParams{
+	message, err := a.newMessage(issue, anthropicAPI.MessageNewParams{
 		Model:     anthropicAPI.F(ClassifierModel),
 		MaxTokens: anthropicAPI.F(int64(10)),
 		Messages: anthropicAPI.F([]anthropicAPI.MessageParam{
@@ -345,7 +348,7 @@ Give only the branch name, e.g., "fix-header-overflow" or "add-user-permissions"
 		"summary_length", len(summary))
 
 	// Create a message using the SDK
-	message, err := a.client.Messages.New(context.Background(), anthropicAPI.MessageNewParams{
+	message, err := a.newMessage(issue, anthropicAPI.MessageNewParams{
 		Model:     anthropicAPI.F(ClassifierModel),
 		MaxTokens: anthropicAPI.F(int64(20)),
 		Messages: anthropicAPI.F([]anthropicAPI.MessageParam{
@@ -451,7 +454,7 @@ Given all of the above parameters, what is the step by step plan? Do not omit or
 		"prompt_length", len(prompt))
 
 	// Create a message using the SDK
-	message, err := a.client.Messages.New(context.Background(), anthropicAPI.MessageNewParams{
+	message, err := a.newMessage(issue, anthropicAPI.MessageNewParams{
 		Model:     anthropicAPI.F(AnalysisModel),
 		MaxTokens: anthropicAPI.F(int64(2000)),
 		Messages: anthropicAPI.F([]anthropicAPI.MessageParam{
@@ -489,7 +492,7 @@ Given all of the above parameters, what is the step by step plan? Do not omit or
 }
 
 // generatePRDescription creates a detailed PR description using Claude 3.7 Sonnet
-func (a *IssueAnalyzer) generatePRDescription(transcript string, implementationPlan string, changedFiles []string) (string, error) {
+func (a *IssueAnalyzer) generatePRDescription(transcript string, implementationPlan string, changedFiles []string, issue *models.Issue) (string, error) {
 	// Handle empty implementation plan
 	if implementationPlan == "" {
 		implementationPlan = "No implementation provided yet."
@@ -562,7 +565,7 @@ The implementation is complete - use past tense to describe what was done, not f
 		"prompt_length", len(prompt))
 
 	// Create a message using the SDK
-	message, err := a.client.Messages.New(context.Background(), anthropicAPI.MessageNewParams{
+	message, err := a.newMessage(issue, anthropicAPI.MessageNewParams{
 		Model:     anthropicAPI.F(AnalysisModel),
 		MaxTokens: anthropicAPI.F(int64(2000)),
 		Messages: anthropicAPI.F([]anthropicAPI.MessageParam{
@@ -608,7 +611,10 @@ func (a *IssueAnalyzer) GenerateCommitMessage(issue *models.Issue, changedFiles
 	prompt := `You are a software developer creating a concise and meaningful git commit message.
 Based on the issue description and the changed files, write a clear, specific commit message.
 
-ISSUE: #${issue_number} - ${issue_title}
+${untrusted_notice}
+
+ISSUE: #${issue_number}
+${issue_title}
 ${issue_description}
 
 CHANGED FILES:
@@ -628,8 +634,9 @@ Respond with ONLY the commit message, nothing else.`
 
 	// Replace placeholders
 	prompt = strings.Replace(prompt, "${issue_number}", fmt.Sprintf("%d", issue.Number), 1)
-	prompt = strings.Replace(prompt, "${issue_title}", issue.Title, 1)
-	prompt = strings.Replace(prompt, "${issue_description}", issue.Body, 1)
+	prompt = strings.Replace(prompt, "${untrusted_notice}", security.UntrustedNotice, 1)
+	prompt = strings.Replace(prompt, "${issue_title}", security.Delimit("title", issue.Title), 1)
+	prompt = strings.Replace(prompt, "${issue_description}", security.Delimit("description", issue.Body), 1)
 	prompt = strings.Replace(prompt, "${changed_files}", strings.Join(changedFiles, "\n"), 1)
 	prompt = strings.Replace(prompt, "${change_summary}", changeSummary, 1)
 
@@ -641,7 +648,7 @@ Respond with ONLY the commit message, nothing else.`
 		"prompt_length", len(prompt))
 
 	// Create a message using the SDK
-	message, err := a.client.Messages.New(context.Background(), anthropicAPI.MessageNewParams{
+	message, err := a.newMessage(issue, anthropicAPI.MessageNewParams{
 		Model:     anthropicAPI.F(CommitModel),
 		MaxTokens: anthropicAPI.F(int64(150)),
 		Messages: anthropicAPI.F([]anthropicAPI.MessageParam{
@@ -710,3 +717,41 @@ func defaultBranchName(issue *models.Issue) string {
 
 	return fmt.Sprintf("bugfix/issue-%d-%s", issue.Number, sanitizedTitle)
 }
+
+// complete sends a single-turn prompt about an issue to the given model and returns the text response
+func (a *IssueAnalyzer) complete(issue *models.Issue, model string, maxTokens int64, prompt string) (string, error) {
+	logging.Debug("Anthropic API request details",
+		"model", model,
+		"max_tokens", maxTokens,
+		"prompt_length", len(prompt))
+
+	message, err := a.newMessage(issue, anthropicAPI.MessageNewParams{
+		Model:     anthropicAPI.F(model),
+		MaxTokens: anthropicAPI.F(maxTokens),
+		Messages: anthropicAPI.F([]anthropicAPI.MessageParam{
+			anthropicAPI.NewUserMessage(
+				anthropicAPI.NewTextBlock(prompt),
+			),
+		}),
+	})
+	if err != nil {
+		logging.Error("Anthropic API error",
+			"error", err.Error(),
+			"error_type", fmt.Sprintf("%T", err))
+		return "", err
+	}
+
+	if len(message.Content) == 0 {
+		logging.Warn("Empty response from Anthropic API")
+		return "", fmt.Errorf("empty response from API")
+	}
+
+	var responseText string
+	for _, content := range message.Content {
+		if content.Type == "text" {
+			responseText += content.Text
+		}
+	}
+
+	return responseText, nil
+}
@@ -0,0 +1,248 @@
+package anthropic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	anthropicAPI "github.com/anthropics/anthropic-sdk-go"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+)
+
+// ErrBudgetExceeded is returned when an agent run has spent its budget
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// defaultBaseURL is where the meter forwards requests unless ANTHROPIC_BASE_URL is set
+const defaultBaseURL = "https://api.anthropic.com"
+
+// Meter is a local proxy for the Anthropic API that an agent CLI is pointed at with
+// ANTHROPIC_BASE_URL. It adds what each response cost to Spent, and once the agent's
+// calls have cost the budget it refuses further requests, which stops the agent.
+type Meter struct {
+	budget   float64
+	url      string
+	proxy    *httputil.ReverseProxy
+	server   *http.Server
+	listener net.Listener
+
+	mutex sync.Mutex
+	spent float64
+}
+
+// StartMeter starts a meter on a local port that forwards to the Anthropic API.
+// A budget of zero or less only measures what the agent spends.
+func StartMeter(budget float64) (*Meter, error) {
+	base := os.Getenv("ANTHROPIC_BASE_URL")
+	if base == "" {
+		base = defaultBaseURL
+	}
+	upstream, err := url.Parse(base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ANTHROPIC_BASE_URL: %w", err)
+	}
+	return startMeter(budget, upstream)
+}
+
+// startMeter starts a meter that forwards to upstream
+func startMeter(budget float64, upstream *url.URL) (*Meter, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the API meter: %w", err)
+	}
+
+	m := &Meter{
+		budget:   budget,
+		url:      "http://" + listener.Addr().String(),
+		listener: listener,
+	}
+	m.proxy = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(upstream)
+			// Let the transport negotiate compression so responses can be read here
+			r.Out.Header.Del("Accept-Encoding")
+		},
+		FlushInterval:  -1, // pass streamed responses on as they arrive
+		ModifyResponse: m.meterResponse,
+	}
+	m.server = &http.Server{Handler: m, ReadHeaderTimeout: 30 * time.Second}
+
+	go func() {
+		if err := m.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Warn("API meter stopped", "error", err)
+		}
+	}()
+	return m, nil
+}
+
+// Env returns the environment variables that point an agent CLI at the meter
+func (m *Meter) Env() []string {
+	return []string{"ANTHROPIC_BASE_URL=" + m.url}
+}
+
+// Spent returns what the agent's calls through the meter have cost in USD
+func (m *Meter) Spent() float64 {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.spent
+}
+
+// Exceeded reports whether the agent's calls have cost the whole budget
+func (m *Meter) Exceeded() bool {
+	return m.budget > 0 && m.Spent() >= m.budget
+}
+
+// Close stops the meter
+func (m *Meter) Close() error {
+	return m.server.Close()
+}
+
+// ServeHTTP forwards a request to the API, or refuses it once the budget is spent
+func (m *Meter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !m.Exceeded() {
+		m.proxy.ServeHTTP(w, r)
+		return
+	}
+
+	logging.Warn("Refusing agent API request over budget", "budget_usd", m.budget, "spent_usd", m.Spent())
+	// The API's error format, so the agent shows the reason; 400 isn't retried like 429 or 5xx
+	body, _ := json.Marshal(map[string]any{
+		"type": "error",
+		"error": map[string]string{
+			"type":    "invalid_request_error",
+			"message": fmt.Sprintf("useful1: the budget of $%.2f for this run is spent", m.budget),
+		},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write(body)
+}
+
+// meterResponse arranges for the cost of a successful Messages API response to be
+// recorded once its body has been passed on
+func (m *Meter) meterResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK || !strings.HasSuffix(resp.Request.URL.Path, "/v1/messages") {
+		return nil
+	}
+	resp.Body = &meteredBody{
+		ReadCloser: resp.Body,
+		stream:     strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"),
+		record:     m.record,
+	}
+	return nil
+}
+
+// record adds the cost of a response to the meter and the process totals
+func (m *Meter) record(model string, callUsage anthropicAPI.Usage) {
+	cost := usageCost(model, callUsage)
+	recordUsage(model, callUsage, cost)
+
+	m.mutex.Lock()
+	m.spent += cost
+	m.mutex.Unlock()
+
+	logging.Debug("Agent API usage",
+		"model", model,
+		"input_tokens", callUsage.InputTokens,
+		"output_tokens", callUsage.OutputTokens,
+		"cost_usd", cost)
+}
+
+// meteredBody keeps a copy of a response body as it is read and records its usage when closed
+type meteredBody struct {
+	io.ReadCloser
+	stream bool
+	record func(string, anthropicAPI.Usage)
+
+	data   bytes.Buffer
+	closed bool
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.data.Write(p[:n])
+	return n, err
+}
+
+func (b *meteredBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		if model, callUsage, ok := responseUsage(b.data.Bytes(), b.stream); ok {
+			b.record(model, callUsage)
+		}
+	}
+	return b.ReadCloser.Close()
+}
+
+// tokenUsage is the usage object of a Messages API response or stream event
+type tokenUsage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+}
+
+// responseUsage reads the model and token usage from a Messages API response, either a
+// single JSON message or a stream of server-sent events
+func responseUsage(body []byte, stream bool) (string, anthropicAPI.Usage, bool) {
+	var model string
+	var u tokenUsage
+
+	if !stream {
+		var message struct {
+			Model string     `json:"model"`
+			Usage tokenUsage `json:"usage"`
+		}
+		if err := json.Unmarshal(body, &message); err != nil {
+			return "", anthropicAPI.Usage{}, false
+		}
+		model, u = message.Model, message.Usage
+	} else {
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			data, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte("data:"))
+			if !ok {
+				continue
+			}
+			var event struct {
+				Type    string `json:"type"`
+				Message struct {
+					Model string     `json:"model"`
+					Usage tokenUsage `json:"usage"`
+				} `json:"message"`
+				Usage tokenUsage `json:"usage"`
+			}
+			if err := json.Unmarshal(bytes.TrimSpace(data), &event); err != nil {
+				continue
+			}
+			switch event.Type {
+			case "message_start":
+				model, u = event.Message.Model, event.Message.Usage
+			case "message_delta":
+				// Counts in a delta are running totals for the message
+				u.InputTokens = max(u.InputTokens, event.Usage.InputTokens)
+				u.OutputTokens = max(u.OutputTokens, event.Usage.OutputTokens)
+				u.CacheCreationInputTokens = max(u.CacheCreationInputTokens, event.Usage.CacheCreationInputTokens)
+				u.CacheReadInputTokens = max(u.CacheReadInputTokens, event.Usage.CacheReadInputTokens)
+			}
+		}
+	}
+
+	if model == "" {
+		return "", anthropicAPI.Usage{}, false
+	}
+	return model, anthropicAPI.Usage{
+		InputTokens:              u.InputTokens,
+		OutputTokens:             u.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens,
+	}, true
+}
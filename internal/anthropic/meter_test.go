@@ -0,0 +1,83 @@
+package anthropic
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestMeterStopsAgentAtBudget(t *testing.T) {
+	var requests int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("upstream got path %q, want /v1/messages", r.URL.Path)
+		}
+		// Chunked responses end only after the proxy has closed the body and recorded the cost
+		defer w.(http.Flusher).Flush()
+		if r.URL.Query().Get("beta") == "true" {
+			// A streamed response: a million input tokens and 100,000 output tokens of Sonnet, $4.50
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = io.WriteString(w, "event: message_start\n"+
+				`data: {"type":"message_start","message":{"model":"claude-sonnet-4-5-20250929","usage":{"input_tokens":1000000,"output_tokens":1}}}`+"\n\n"+
+				"event: message_delta\n"+
+				`data: {"type":"message_delta","usage":{"output_tokens":100000}}`+"\n\n")
+			return
+		}
+		// A JSON response: a million input tokens of Haiku 3.5, $0.80
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"model":"claude-3-5-haiku-20241022","usage":{"input_tokens":1000000,"output_tokens":0}}`)
+	}))
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meter, err := startMeter(5, target)
+	if err != nil {
+		t.Fatalf("startMeter failed: %v", err)
+	}
+	defer func() { _ = meter.Close() }()
+
+	base := strings.TrimPrefix(meter.Env()[0], "ANTHROPIC_BASE_URL=")
+	post := func(query string) int {
+		t.Helper()
+		resp, err := http.Post(base+"/v1/messages"+query, "application/json", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatalf("request through meter failed: %v", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantSpent  float64
+		exceeded   bool
+	}{
+		{query: "?beta=true", wantStatus: http.StatusOK, wantSpent: 4.5},
+		{query: "", wantStatus: http.StatusOK, wantSpent: 5.3, exceeded: true},
+		{query: "", wantStatus: http.StatusBadRequest, wantSpent: 5.3, exceeded: true},
+	}
+	for i, tt := range tests {
+		if status := post(tt.query); status != tt.wantStatus {
+			t.Errorf("request %d status = %d, want %d", i+1, status, tt.wantStatus)
+		}
+		if spent := meter.Spent(); math.Abs(spent-tt.wantSpent) > 1e-9 {
+			t.Errorf("after request %d spent = %.4f, want %.4f", i+1, spent, tt.wantSpent)
+		}
+		if meter.Exceeded() != tt.exceeded {
+			t.Errorf("after request %d Exceeded() = %v, want %v", i+1, meter.Exceeded(), tt.exceeded)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("upstream got %d requests, want 2 with the last one refused", requests)
+	}
+}
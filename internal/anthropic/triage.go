@@ -0,0 +1,95 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/models"
+)
+
+// TriageModel is the model used to score issues before work starts
+const TriageModel = "claude-3-7-sonnet-20250219"
+
+// TriageResult holds the scores the triage pass gives an issue.
+// Scores run from 1 (poor) to 5 (excellent).
+type TriageResult struct {
+	Clarity     int      `json:"clarity"`
+	Scope       int      `json:"scope"`
+	Feasibility int      `json:"feasibility"`
+	Questions   []string `json:"questions"`
+	Reasoning   string   `json:"reasoning"`
+}
+
+// TriageIssue scores an issue for clarity, scope and feasibility and suggests
+// clarifying questions for anything that is underspecified
+func (a *IssueAnalyzer) TriageIssue(issue *models.Issue) (*TriageResult, error) {
+	minScore := a.config.Triage.MinScore
+	if minScore <= 0 {
+		minScore = 3
+	}
+	prompt := triagePrompt(formatIssueTranscript(issue), minScore)
+
+	logging.Debug("Sending triage request to Anthropic API", "model", TriageModel)
+
+	response, err := a.complete(issue, TriageModel, 800, prompt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to triage issue: %w", err)
+	}
+
+	result, err := parseTriageResult(response)
+	if err != nil {
+		return nil, err
+	}
+
+	logging.Info("Received issue triage",
+		"issue_number", issue.Number,
+		"clarity", result.Clarity,
+		"scope", result.Scope,
+		"feasibility", result.Feasibility,
+		"questions", len(result.Questions))
+
+	return result, nil
+}
+
+// triagePrompt builds the triage prompt for an issue transcript. Questions are asked
+// for scores below minScore, the score the pipeline needs to proceed.
+func triagePrompt(transcript string, minScore int) string {
+	prompt := `You are a senior engineer triaging a GitHub issue before an automated agent starts working on it.
+The agent can only read the issue and the repository; it cannot ask follow-up questions while it works.
+
+ISSUE TRANSCRIPT:
+${transcript}
+
+Score the issue from 1 (poor) to 5 (excellent) on each of these dimensions:
+- clarity: is it clear what is wrong or what is being asked for, and what "done" looks like?
+- scope: is the change small and well-bounded enough for a single pull request?
+- feasibility: can it be solved by changing code in the repository, without access to external systems or decisions?
+
+If any score is below ${min_score}, write up to 3 short, specific questions for the issue author that would resolve the uncertainty.
+Do not ask questions the transcript already answers.
+
+Respond with ONLY a JSON object in this exact format:
+{"clarity": 1-5, "scope": 1-5, "feasibility": 1-5, "questions": ["..."], "reasoning": "one sentence"}`
+
+	prompt = strings.Replace(prompt, "${min_score}", strconv.Itoa(minScore), 1)
+	return strings.Replace(prompt, "${transcript}", transcript, 1)
+}
+
+// parseTriageResult extracts the JSON object from a triage response
+func parseTriageResult(response string) (*TriageResult, error) {
+	start := strings.Index(response, "{")
+	end := strings.LastIndex(response, "}")
+	if start == -1 || end < start {
+		return nil, fmt.Errorf("triage response did not contain JSON: %q", response)
+	}
+
+	var result TriageResult
+	if err := json.Unmarshal([]byte(response[start:end+1]), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode triage response: %w", err)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,16 @@
+package anthropic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTriagePromptUsesMinScore(t *testing.T) {
+	prompt := triagePrompt("#1 Fix login", 2)
+	if !strings.Contains(prompt, "If any score is below 2,") {
+		t.Errorf("prompt doesn't ask questions below the minimum score:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "#1 Fix login") {
+		t.Errorf("prompt is missing the transcript:\n%s", prompt)
+	}
+}
@@ -0,0 +1,162 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	anthropicAPI "github.com/anthropics/anthropic-sdk-go"
+	"github.com/hellausefulsoftware/useful1/internal/audit"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/models"
+)
+
+// modelPrice is the list price of a model in USD per million tokens
+type modelPrice struct {
+	input, output float64
+}
+
+// modelPrices holds the prices of the models the analyzer uses
+var modelPrices = map[string]modelPrice{
+	"claude-3-7-sonnet-20250219": {input: 3, output: 15},
+	"claude-3-5-haiku-20241022":  {input: 0.8, output: 4},
+}
+
+// familyPrices price the models missing from modelPrices, such as those an agent picks,
+// at the highest price in their family so a budget is never overspent on a new model
+var familyPrices = []struct {
+	family string
+	price  modelPrice
+}{
+	{"opus", modelPrice{input: 15, output: 75}},
+	{"sonnet", modelPrice{input: 3, output: 15}},
+	{"haiku", modelPrice{input: 1, output: 5}},
+}
+
+var (
+	spentMutex sync.Mutex
+	spent      float64
+	usage      = make(map[string]*ModelUsage) // by model
+)
+
+// ModelUsage is what the API calls to one model have used since the process started
+type ModelUsage struct {
+	Model        string
+	Calls        int
+	InputTokens  int64 // including cache writes and reads
+	OutputTokens int64
+	CostUSD      float64
+}
+
+// Spent returns what the analyzer's API calls, and the agent's calls through a Meter, have
+// cost in USD since the process started. Callers measure a piece of work by the difference
+// before and after it.
+func Spent() float64 {
+	spentMutex.Lock()
+	defer spentMutex.Unlock()
+	return spent
+}
+
+// Usage returns the usage of each model called since the process started, sorted by model
+func Usage() []ModelUsage {
+	spentMutex.Lock()
+	defer spentMutex.Unlock()
+
+	models := make([]ModelUsage, 0, len(usage))
+	for _, u := range usage {
+		models = append(models, *u)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Model < models[j].Model })
+	return models
+}
+
+// recordUsage adds a call's token usage and cost to the totals
+func recordUsage(model string, callUsage anthropicAPI.Usage, cost float64) {
+	spentMutex.Lock()
+	defer spentMutex.Unlock()
+
+	spent += cost
+	u, ok := usage[model]
+	if !ok {
+		u = &ModelUsage{Model: model}
+		usage[model] = u
+	}
+	u.Calls++
+	u.InputTokens += callUsage.InputTokens + callUsage.CacheCreationInputTokens + callUsage.CacheReadInputTokens
+	u.OutputTokens += callUsage.OutputTokens
+	u.CostUSD += cost
+}
+
+// priceOf returns the price of a model, by name or else by family
+func priceOf(model string) (modelPrice, bool) {
+	if price, ok := modelPrices[model]; ok {
+		return price, true
+	}
+	for _, f := range familyPrices {
+		if strings.Contains(model, f.family) {
+			return f.price, true
+		}
+	}
+	return modelPrice{}, false
+}
+
+// usageCost returns the cost of a call's token usage in USD
+func usageCost(model string, usage anthropicAPI.Usage) float64 {
+	price, ok := priceOf(model)
+	if !ok {
+		logging.Debug("No price known for model, not counting its cost", "model", model)
+		return 0
+	}
+
+	// Cache writes cost a quarter more than input tokens and cache reads a tenth
+	input := float64(usage.InputTokens) + 1.25*float64(usage.CacheCreationInputTokens) + 0.1*float64(usage.CacheReadInputTokens)
+	return (input*price.input + float64(usage.OutputTokens)*price.output) / 1e6
+}
+
+// newMessage sends a request about an issue to the Messages API and records what it cost.
+// The issue may be nil when the request isn't about a known issue.
+func (a *IssueAnalyzer) newMessage(issue *models.Issue, params anthropicAPI.MessageNewParams) (*anthropicAPI.Message, error) {
+	message, err := a.client.Messages.New(context.Background(), params)
+	if err != nil {
+		return nil, err
+	}
+
+	cost := usageCost(params.Model.Value, message.Usage)
+	recordUsage(params.Model.Value, message.Usage, cost)
+	entry := audit.Entry{
+		Action:  audit.ActionModelCall,
+		Model:   params.Model.Value,
+		Summary: fmt.Sprintf("%d input and %d output tokens, $%.4f", message.Usage.InputTokens, message.Usage.OutputTokens, cost),
+		Body:    promptText(params),
+	}
+	if issue != nil && issue.Owner != "" {
+		entry.Repo = issue.Owner + "/" + issue.Repo
+		entry.Number = issue.Number
+	}
+	audit.Record(entry)
+
+	logging.Debug("Anthropic API usage",
+		"model", params.Model.Value,
+		"input_tokens", message.Usage.InputTokens,
+		"output_tokens", message.Usage.OutputTokens,
+		"cost_usd", cost)
+	return message, nil
+}
+
+// promptText returns the text of a request's system prompt and messages
+func promptText(params anthropicAPI.MessageNewParams) string {
+	var parts []string
+	for _, block := range params.System.Value {
+		parts = append(parts, block.Text.Value)
+	}
+	for _, message := range params.Messages.Value {
+		for _, block := range message.Content.Value {
+			if text, ok := block.(anthropicAPI.TextBlockParam); ok {
+				parts = append(parts, text.Text.Value)
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,281 @@
+// Package audit keeps an append-only record of the changes the bot makes to repositories
+// and of the prompts it sends to models and agents. Each entry carries the hash of the one
+// before it, so changing or removing earlier entries breaks the chain and can be detected.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+)
+
+// Actions recorded in the log
+const (
+	ActionComment     = "comment"      // a comment was posted on an issue or pull request
+	ActionCommentEdit = "comment_edit" // a comment was edited
+	ActionReviewReply = "review_reply" // a reply was posted to an inline review comment
+	ActionAssign      = "assign"       // users were assigned to an issue
+	ActionUnassign    = "unassign"     // users were unassigned from an issue
+	ActionBranch      = "branch"       // a branch was created through the API
+	ActionFork        = "fork"         // a repository was forked
+	ActionPush        = "push"         // commits were pushed; Ref is the pushed commit
+	ActionPullRequest = "pull_request" // a pull request was opened
+	ActionModelCall   = "model_call"   // a prompt was sent to a model
+	ActionAgentRun    = "agent_run"    // a prompt was given to the agent CLI
+)
+
+// Entry is a line of the log
+type Entry struct {
+	Seq      int64     `json:"seq"` // position in the log, starting at 1
+	Time     time.Time `json:"time"`
+	Action   string    `json:"action"`
+	Repo     string    `json:"repo,omitempty"`   // "owner/repo"
+	Number   int       `json:"number,omitempty"` // issue or pull request number
+	Ref      string    `json:"ref,omitempty"`    // what was created or changed: a comment ID, branch, commit SHA or URL
+	Summary  string    `json:"summary,omitempty"`
+	Model    string    `json:"model,omitempty"` // model or agent CLI a prompt was sent to
+	Body     string    `json:"body,omitempty"`  // comment text or prompt
+	PrevHash string    `json:"prev_hash"`       // Hash of the previous entry, empty for the first
+	Hash     string    `json:"hash"`            // SHA-256 of the entry with Hash left empty
+}
+
+// hashEntry returns the hash of an entry, computed over its JSON with Hash left empty
+func hashEntry(entry Entry) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log appends entries to a JSON Lines file. Several processes may append to the same file;
+// a file lock keeps their entries in one chain.
+type Log struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// DefaultPath returns the default location of the audit log
+func DefaultPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".useful1", "audit.jsonl")
+}
+
+// Open returns the log at path, creating its directory if needed
+func Open(path string) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+	return &Log{path: path}, nil
+}
+
+// Append chains an entry to the end of the log and returns it as written
+func (l *Log) Append(entry Entry) (Entry, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return entry, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		return entry, fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	defer func() { _ = syscall.Flock(int(file.Fd()), syscall.LOCK_UN) }()
+
+	line, err := lastLine(file)
+	if err != nil {
+		return entry, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if line != nil {
+		var last Entry
+		if err := json.Unmarshal(line, &last); err != nil {
+			return entry, fmt.Errorf("failed to read the last audit log entry: %w", err)
+		}
+		entry.Seq = last.Seq + 1
+		entry.PrevHash = last.Hash
+	} else {
+		entry.Seq = 1
+		entry.PrevHash = ""
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	entry.Hash = hashEntry(entry)
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return entry, fmt.Errorf("failed to encode audit log entry: %w", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return entry, fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return entry, fmt.Errorf("failed to sync audit log: %w", err)
+	}
+	return entry, nil
+}
+
+// lastLine returns the last line of a file without its newline, or nil if the file is empty
+func lastLine(file *os.File) ([]byte, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read backwards a chunk at a time, since prompts can make lines long
+	const chunkSize = 64 * 1024
+	var tail []byte
+	for end := info.Size(); end > 0; {
+		start := max(end-chunkSize, 0)
+		chunk := make([]byte, end-start)
+		if _, err := file.ReadAt(chunk, start); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		tail = append(chunk, tail...)
+
+		trimmed := bytes.TrimRight(tail, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+		end = start
+	}
+
+	if trimmed := bytes.TrimRight(tail, "\n"); len(trimmed) > 0 {
+		return trimmed, nil
+	}
+	return nil, nil
+}
+
+// ChainError reports where the hash chain of a log is broken
+type ChainError struct {
+	Line   int // line of the file, starting at 1
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit log chain broken at line %d: %s", e.Line, e.Reason)
+}
+
+// Scan reads a log, checking its hash chain, and calls fn with each entry in order. It stops
+// with a *ChainError at the first entry that was changed, removed or inserted.
+func Scan(r io.Reader, fn func(Entry)) error {
+	reader := bufio.NewReader(r)
+	prev := Entry{}
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			var entry Entry
+			if jsonErr := json.Unmarshal(data, &entry); jsonErr != nil {
+				return &ChainError{Line: line, Reason: "not a valid entry"}
+			}
+			switch {
+			case entry.Hash != hashEntry(entry):
+				return &ChainError{Line: line, Reason: fmt.Sprintf("entry %d does not match its hash", entry.Seq)}
+			case entry.PrevHash != prev.Hash || entry.Seq != prev.Seq+1:
+				return &ChainError{Line: line, Reason: fmt.Sprintf("entry %d does not follow entry %d", entry.Seq, prev.Seq)}
+			}
+			fn(entry)
+			prev = entry
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audit log: %w", err)
+		}
+	}
+}
+
+// Filter selects entries from a log. Zero fields match everything.
+type Filter struct {
+	Repo   string // "owner/repo"
+	Number int    // issue or pull request number in Repo
+	Action string
+	Since  time.Time
+	Until  time.Time
+}
+
+// Matches reports whether an entry is selected by the filter
+func (f Filter) Matches(entry Entry) bool {
+	return (f.Repo == "" || entry.Repo == f.Repo) &&
+		(f.Number == 0 || entry.Number == f.Number) &&
+		(f.Action == "" || entry.Action == f.Action) &&
+		(f.Since.IsZero() || !entry.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || entry.Time.Before(f.Until))
+}
+
+// Query returns the entries of the log at path that match the filter. If the chain is
+// broken it returns the matching entries before the break along with a *ChainError.
+func Query(path string, filter Filter) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var entries []Entry
+	err = Scan(file, func(entry Entry) {
+		if filter.Matches(entry) {
+			entries = append(entries, entry)
+		}
+	})
+	return entries, err
+}
+
+var (
+	defaultMutex sync.Mutex
+	defaultLog   *Log
+)
+
+// Configure sends Record to the log set in the config, or turns recording off if the
+// config disables auditing
+func Configure(cfg *config.Config) error {
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+
+	defaultLog = nil
+	if cfg == nil || !cfg.Audit.Enabled {
+		return nil
+	}
+
+	path := cfg.Audit.Path
+	if path == "" {
+		path = DefaultPath()
+	}
+	log, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defaultLog = log
+	return nil
+}
+
+// Record appends an entry to the configured log, if there is one. A failure to record is
+// logged but doesn't stop the change, which has already been made.
+func Record(entry Entry) {
+	defaultMutex.Lock()
+	log := defaultLog
+	defaultMutex.Unlock()
+	if log == nil {
+		return
+	}
+
+	if _, err := log.Append(entry); err != nil {
+		logging.Error("Failed to write audit log entry", "action", entry.Action, "repo", entry.Repo, "error", err)
+	}
+}
@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogChainsEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	for i, entry := range []Entry{
+		{Action: ActionBranch, Repo: "octo/demo", Ref: "fix/login"},
+		{Action: ActionComment, Repo: "octo/demo", Number: 7, Body: "Working on it \"now\"\n"},
+		{Action: ActionPush, Repo: "octo/other", Number: 3, Ref: "0123abcd"},
+	} {
+		entry.Time = start.Add(time.Duration(i) * time.Hour)
+		if _, err := log.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := Query(path, Filter{})
+	if err != nil {
+		t.Fatalf("intact log failed verification: %v", err)
+	}
+	if len(entries) != 3 || entries[2].Seq != 3 || entries[2].PrevHash != entries[1].Hash || entries[0].PrevHash != "" {
+		t.Fatalf("unexpected chain: %+v", entries)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"repo", Filter{Repo: "octo/demo"}, 2},
+		{"issue", Filter{Repo: "octo/demo", Number: 7}, 1},
+		{"action", Filter{Action: ActionPush}, 1},
+		{"time range", Filter{Since: start.Add(30 * time.Minute), Until: start.Add(2 * time.Hour)}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := Query(path, tt.filter)
+			if err != nil || len(entries) != tt.want {
+				t.Errorf("got %d entries (%v), want %d", len(entries), err, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanDetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range []string{"first", "second", "third"} {
+		if _, err := log.Append(Entry{Action: ActionComment, Repo: "octo/demo", Number: 1, Body: body}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+
+	tests := []struct {
+		name string
+		log  string
+		line int
+	}{
+		{"edited body", strings.Replace(string(data), `"second"`, `"edited"`, 1), 2},
+		{"removed entry", lines[0] + lines[2], 2},
+		{"swapped entries", lines[1] + lines[0] + lines[2], 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Scan(strings.NewReader(tt.log), func(Entry) {})
+			var chainErr *ChainError
+			if !errors.As(err, &chainErr) || chainErr.Line != tt.line {
+				t.Errorf("got %v, want a broken chain at line %d", err, tt.line)
+			}
+		})
+	}
+}
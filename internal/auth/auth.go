@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/google/go-github/v45/github"
+	"github.com/hellausefulsoftware/useful1/internal/config"
 	"golang.org/x/oauth2"
 )
 
@@ -26,10 +27,25 @@ type AnthropicAuth struct {
 	Token string
 }
 
-// SetupGitHubOAuth guides the user through GitHub OAuth setup
-func SetupGitHubOAuth() (*GitHubAuth, error) {
+// SetupGitHubOAuth guides the user through GitHub OAuth setup on the configured GitHub server.
+// With a client ID the user can sign in through the browser with the device flow instead of
+// pasting a token.
+func SetupGitHubOAuth(cfg *config.Config, clientID string) (*GitHubAuth, error) {
 	reader := bufio.NewReader(os.Stdin)
 
+	if clientID != "" {
+		fmt.Println("Sign in to GitHub with your browser? (y/n)")
+		useBrowser, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("error reading input: %w", err)
+		}
+		useBrowser = strings.TrimSpace(strings.ToLower(useBrowser))
+
+		if useBrowser == "y" || useBrowser == "yes" {
+			return LoginWithDeviceFlow(context.Background(), cfg, NewDeviceFlow(cfg, clientID, DeviceFlowScopes...), os.Stdout)
+		}
+	}
+
 	// Check if user already has a token
 	fmt.Println("Do you already have a GitHub Personal Access Token? (y/n)")
 	hasToken, err := reader.ReadString('\n')
@@ -50,7 +66,7 @@ func SetupGitHubOAuth() (*GitHubAuth, error) {
 	} else {
 		// Guide user to create a new token
 		fmt.Println("Please create a new GitHub Personal Access Token:")
-		fmt.Println("1. Go to https://github.com/settings/tokens")
+		fmt.Printf("1. Go to %s/settings/tokens\n", cfg.GitHubWebURL())
 		fmt.Println("2. Click 'Generate new token'")
 		fmt.Println("3. Give it a name like 'Useful1 CLI'")
 		fmt.Println("4. Select the following scopes: repo, workflow, read:org, notifications")
@@ -64,21 +80,81 @@ func SetupGitHubOAuth() (*GitHubAuth, error) {
 		token = strings.TrimSpace(token)
 	}
 
-	// Validate the token
+	user, err := verifyGitHubToken(cfg, token)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("✅ Successfully authenticated as: %s\n", user)
+	fmt.Println("✅ Successfully verified repository access")
+
+	return &GitHubAuth{
+		Token: token,
+		User:  user,
+	}, nil
+}
+
+// LoginWithDeviceFlow signs in through the device flow, printing the verification URL and
+// code to out, and stores the resulting token
+func LoginWithDeviceFlow(ctx context.Context, cfg *config.Config, flow *DeviceFlow, out io.Writer) (*GitHubAuth, error) {
+	code, err := flow.RequestCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(out, "Open %s and enter the code: %s\n", code.VerificationURI, code.UserCode)
+	fmt.Fprintln(out, "Waiting for you to authorize useful1...")
+
+	githubAuth, err := CompleteDeviceLogin(ctx, cfg, flow, code)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(out, "✅ Successfully authenticated as: %s\n", githubAuth.User)
+	return githubAuth, nil
+}
+
+// CompleteDeviceLogin waits for the user to enter the device code, verifies the token against
+// the configured GitHub server and stores it with its refresh token
+func CompleteDeviceLogin(ctx context.Context, cfg *config.Config, flow *DeviceFlow, code *DeviceCode) (*GitHubAuth, error) {
+	token, err := flow.PollToken(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := verifyGitHubToken(cfg, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := SaveDeviceToken(DeviceTokenPath(), token); err != nil {
+		return nil, err
+	}
+
+	return &GitHubAuth{
+		Token: token.AccessToken,
+		User:  user,
+	}, nil
+}
+
+// verifyGitHubToken checks that a token works on the configured GitHub server and returns
+// the login it belongs to
+func verifyGitHubToken(cfg *config.Config, token string) (string, error) {
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
 	tc := oauth2.NewClient(context.Background(), ts)
 	client := github.NewClient(tc)
+	if err := SetGitHubBaseURLs(client, cfg); err != nil {
+		return "", err
+	}
 
 	// Get the authenticated user to verify token
 	user, resp, err := client.Users.Get(context.Background(), "")
 	if err != nil || resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("invalid GitHub token: %v", err)
+		return "", fmt.Errorf("invalid GitHub token: %v", err)
 	}
 
-	fmt.Printf("✅ Successfully authenticated as: %s\n", *user.Login)
-
 	// List accessible repositories to verify permissions
 	opts := &github.RepositoryListOptions{
 		ListOptions: github.ListOptions{PerPage: 1},
@@ -86,15 +162,10 @@ func SetupGitHubOAuth() (*GitHubAuth, error) {
 
 	_, resp, err = client.Repositories.List(context.Background(), "", opts)
 	if err != nil || resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unable to list repositories: %v", err)
+		return "", fmt.Errorf("unable to list repositories: %v", err)
 	}
 
-	fmt.Println("✅ Successfully verified repository access")
-
-	return &GitHubAuth{
-		Token: token,
-		User:  *user.Login,
-	}, nil
+	return user.GetLogin(), nil
 }
 
 // SetupAnthropicOAuth guides the user through Anthropic API setup
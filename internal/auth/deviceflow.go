@@ -0,0 +1,388 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"golang.org/x/oauth2"
+)
+
+// DefaultGitHubWebURL is the host serving GitHub's OAuth endpoints
+const DefaultGitHubWebURL = "https://github.com"
+
+// DeviceFlowScopes are the OAuth scopes the bot asks for; GitHub Apps ignore them
+var DeviceFlowScopes = []string{"repo", "workflow", "read:org"}
+
+var (
+	// ErrDeviceCodeExpired means the user didn't authorize the device before the code expired
+	ErrDeviceCodeExpired = errors.New("device code expired before it was authorized")
+
+	// ErrAccessDenied means the user declined the authorization request
+	ErrAccessDenied = errors.New("authorization was denied")
+)
+
+// DeviceFlow runs the OAuth device authorization flow against GitHub
+type DeviceFlow struct {
+	ClientID   string
+	Scopes     []string
+	WebURL     string // GitHub web host, DefaultGitHubWebURL when empty
+	HTTPClient *http.Client
+
+	sleep func(ctx context.Context, d time.Duration) error
+	now   func() time.Time
+}
+
+// DeviceCode is the code the user enters to authorize the device
+type DeviceCode struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"` // seconds until the code expires
+	Interval        int    `json:"interval"`   // minimum seconds between token polls
+}
+
+// DeviceToken is an OAuth token obtained through the device flow
+type DeviceToken struct {
+	AccessToken        string    `json:"access_token"`
+	TokenType          string    `json:"token_type,omitempty"`
+	Scope              string    `json:"scope,omitempty"`
+	RefreshToken       string    `json:"refresh_token,omitempty"`
+	Expiry             time.Time `json:"expiry,omitempty"`               // zero when the token doesn't expire
+	RefreshTokenExpiry time.Time `json:"refresh_token_expiry,omitempty"` // zero when there is no refresh token
+}
+
+// tokenResponse is the token endpoint's reply, which carries errors with a 200 status
+type tokenResponse struct {
+	AccessToken           string `json:"access_token"`
+	TokenType             string `json:"token_type"`
+	Scope                 string `json:"scope"`
+	RefreshToken          string `json:"refresh_token"`
+	ExpiresIn             int    `json:"expires_in"`
+	RefreshTokenExpiresIn int    `json:"refresh_token_expires_in"`
+	Interval              int    `json:"interval"`
+	Error                 string `json:"error"`
+	ErrorDescription      string `json:"error_description"`
+}
+
+// NewDeviceFlow creates a device flow for an OAuth or GitHub App client ID on the configured
+// GitHub server; a nil config means github.com
+func NewDeviceFlow(cfg *config.Config, clientID string, scopes ...string) *DeviceFlow {
+	return &DeviceFlow{
+		ClientID: clientID,
+		Scopes:   scopes,
+		WebURL:   cfg.GitHubWebURL(),
+	}
+}
+
+// RequestCode starts the flow and returns the code to show the user
+func (f *DeviceFlow) RequestCode(ctx context.Context) (*DeviceCode, error) {
+	form := url.Values{"client_id": {f.ClientID}}
+	if len(f.Scopes) > 0 {
+		form.Set("scope", strings.Join(f.Scopes, " "))
+	}
+
+	var code DeviceCode
+	if err := f.post(ctx, "/login/device/code", form, &code); err != nil {
+		return nil, fmt.Errorf("failed to request device code: %w", err)
+	}
+
+	if code.DeviceCode == "" || code.UserCode == "" {
+		return nil, fmt.Errorf("failed to request device code: empty response")
+	}
+	return &code, nil
+}
+
+// PollToken waits for the user to authorize the device, polling at the interval the server asks for
+func (f *DeviceFlow) PollToken(ctx context.Context, code *DeviceCode) (*DeviceToken, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	var deadline time.Time
+	if code.ExpiresIn > 0 {
+		deadline = f.clock().Add(time.Duration(code.ExpiresIn) * time.Second)
+	}
+
+	form := url.Values{
+		"client_id":   {f.ClientID},
+		"device_code": {code.DeviceCode},
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+	}
+
+	for {
+		if !deadline.IsZero() && f.clock().After(deadline) {
+			return nil, ErrDeviceCodeExpired
+		}
+
+		if err := f.wait(ctx, interval); err != nil {
+			return nil, err
+		}
+
+		var resp tokenResponse
+		if err := f.post(ctx, "/login/oauth/access_token", form, &resp); err != nil {
+			return nil, fmt.Errorf("failed to poll for token: %w", err)
+		}
+
+		switch resp.Error {
+		case "":
+			return f.toDeviceToken(resp), nil
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			// The server sends the new interval; otherwise back off by five seconds
+			if resp.Interval > 0 {
+				interval = time.Duration(resp.Interval) * time.Second
+			} else {
+				interval += 5 * time.Second
+			}
+			logging.Debug("Device flow asked to slow down", "interval", interval)
+		case "expired_token":
+			return nil, ErrDeviceCodeExpired
+		case "access_denied":
+			return nil, ErrAccessDenied
+		default:
+			return nil, fmt.Errorf("device authorization failed: %s: %s", resp.Error, resp.ErrorDescription)
+		}
+	}
+}
+
+// Refresh exchanges a refresh token for a new access token
+func (f *DeviceFlow) Refresh(ctx context.Context, refreshToken string) (*DeviceToken, error) {
+	form := url.Values{
+		"client_id":     {f.ClientID},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	}
+
+	var resp tokenResponse
+	if err := f.post(ctx, "/login/oauth/access_token", form, &resp); err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("failed to refresh token: %s: %s", resp.Error, resp.ErrorDescription)
+	}
+
+	return f.toDeviceToken(resp), nil
+}
+
+// toDeviceToken converts relative expiry times to absolute ones
+func (f *DeviceFlow) toDeviceToken(resp tokenResponse) *DeviceToken {
+	now := f.clock()
+	token := &DeviceToken{
+		AccessToken:  resp.AccessToken,
+		TokenType:    resp.TokenType,
+		Scope:        resp.Scope,
+		RefreshToken: resp.RefreshToken,
+	}
+	if resp.ExpiresIn > 0 {
+		token.Expiry = now.Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	if resp.RefreshTokenExpiresIn > 0 {
+		token.RefreshTokenExpiry = now.Add(time.Duration(resp.RefreshTokenExpiresIn) * time.Second)
+	}
+	return token
+}
+
+// post sends a form to an OAuth endpoint and decodes the JSON reply
+func (f *DeviceFlow) post(ctx context.Context, path string, form url.Values, out interface{}) error {
+	webURL := f.WebURL
+	if webURL == "" {
+		webURL = DefaultGitHubWebURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(webURL, "/")+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	client := f.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			logging.Warn("Failed to close response body", "error", closeErr)
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return json.Unmarshal(body, out)
+}
+
+// wait sleeps between polls unless the context ends first
+func (f *DeviceFlow) wait(ctx context.Context, d time.Duration) error {
+	if f.sleep != nil {
+		return f.sleep(ctx, d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// clock returns the current time
+func (f *DeviceFlow) clock() time.Time {
+	if f.now != nil {
+		return f.now()
+	}
+	return time.Now()
+}
+
+// DeviceTokenPath returns where the device flow token is stored
+func DeviceTokenPath() string {
+	return filepath.Join(filepath.Dir(config.GetConfigPath()), "github_token.json")
+}
+
+// LoadDeviceToken reads a stored device flow token
+func LoadDeviceToken(path string) (*DeviceToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var token DeviceToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("failed to decode device token: %w", err)
+	}
+	return &token, nil
+}
+
+// SaveDeviceToken stores a device flow token readable only by the current user
+func SaveDeviceToken(path string, token *DeviceToken) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create token directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode device token: %w", err)
+	}
+
+	// Write to a temporary file first so other processes never read a partial token
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write device token: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace device token: %w", err)
+	}
+	return nil
+}
+
+// deviceTokenSource returns the stored access token and refreshes it shortly before it expires
+type deviceTokenSource struct {
+	flow *DeviceFlow
+	path string
+
+	mu    sync.Mutex
+	token *DeviceToken
+}
+
+// NewDeviceTokenSource creates a token source that keeps a stored device flow token fresh
+func NewDeviceTokenSource(flow *DeviceFlow, path string, token *DeviceToken) oauth2.TokenSource {
+	return &deviceTokenSource{flow: flow, path: path, token: token}
+}
+
+// Token returns a valid access token, refreshing and saving it when needed
+func (s *deviceTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.expiring(s.token) {
+		if err := s.refresh(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &oauth2.Token{
+		AccessToken: s.token.AccessToken,
+		Expiry:      s.token.Expiry,
+	}, nil
+}
+
+// expiring reports whether a refreshable token expires within the next minute
+func (s *deviceTokenSource) expiring(token *DeviceToken) bool {
+	return token.RefreshToken != "" && !token.Expiry.IsZero() && s.flow.clock().Add(time.Minute).After(token.Expiry)
+}
+
+// refresh replaces the token with a fresh one; the caller holds mu. GitHub invalidates a
+// refresh token once it is used, and other processes, such as the solve runs of a batch,
+// share the token file. So the file is locked and read again first, and a newer token
+// another process saved is used instead of refreshing the one held here.
+func (s *deviceTokenSource) refresh() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to create token directory: %w", err)
+	}
+	lock, err := os.OpenFile(s.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open device token lock: %w", err)
+	}
+	defer func() { _ = lock.Close() }()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock device token: %w", err)
+	}
+	defer func() { _ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN) }()
+
+	if stored, err := LoadDeviceToken(s.path); err == nil && stored.Expiry.After(s.token.Expiry) {
+		s.token = stored
+		if !s.expiring(stored) {
+			logging.Debug("Using GitHub access token refreshed by another process", "expiry", stored.Expiry)
+			return nil
+		}
+	}
+
+	if !s.token.RefreshTokenExpiry.IsZero() && s.flow.clock().After(s.token.RefreshTokenExpiry) {
+		return fmt.Errorf("GitHub refresh token expired, run 'useful1 config --github-login' again")
+	}
+
+	refreshed, err := s.flow.Refresh(context.Background(), s.token.RefreshToken)
+	if err != nil {
+		return err
+	}
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = s.token.RefreshToken
+		refreshed.RefreshTokenExpiry = s.token.RefreshTokenExpiry
+	}
+
+	s.token = refreshed
+	if err := SaveDeviceToken(s.path, refreshed); err != nil {
+		logging.Warn("Failed to save refreshed GitHub token", "error", err)
+	}
+	logging.Debug("Refreshed GitHub access token", "expiry", refreshed.Expiry)
+	return nil
+}
@@ -0,0 +1,256 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"golang.org/x/oauth2"
+)
+
+// deviceServer is a local stand-in for GitHub's device flow endpoints
+type deviceServer struct {
+	polls     []string // replies to successive device code polls
+	pollNum   int
+	refreshes int
+}
+
+func (s *deviceServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Header.Get("Accept") != "application/json" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	reply := map[string]interface{}{}
+	switch {
+	case r.URL.Path == "/login/device/code":
+		reply = map[string]interface{}{
+			"device_code":      "dev-123",
+			"user_code":        "ABCD-1234",
+			"verification_uri": "https://github.example/login/device",
+			"expires_in":       900,
+			"interval":         1,
+		}
+	case r.URL.Path == "/login/oauth/access_token" && r.Form.Get("grant_type") == "refresh_token":
+		s.refreshes++
+		// Refresh tokens are rotated, so the first one only works once
+		if s.refreshes > 1 && r.Form.Get("refresh_token") == "refresh-1" {
+			reply["error"] = "bad_refresh_token"
+			break
+		}
+		reply = map[string]interface{}{
+			"access_token":  "refreshed-token",
+			"refresh_token": "refresh-2",
+			"expires_in":    28800,
+		}
+	case r.URL.Path == "/login/oauth/access_token" && r.Form.Get("device_code") == "dev-123":
+		next := s.polls[s.pollNum]
+		s.pollNum++
+		if next == "" {
+			reply = map[string]interface{}{
+				"access_token":             "user-token",
+				"refresh_token":            "refresh-1",
+				"expires_in":               28800,
+				"refresh_token_expires_in": 15897600,
+			}
+		} else {
+			// GitHub reports pending and slow_down with a 200 status
+			reply["error"] = next
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reply)
+}
+
+func TestDeviceFlowPollToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		polls     []string
+		wantSleep []time.Duration
+		wantErr   error
+	}{
+		{
+			name:      "pending then slow down",
+			polls:     []string{"authorization_pending", "slow_down", ""},
+			wantSleep: []time.Duration{time.Second, time.Second, 6 * time.Second},
+		},
+		{
+			name:      "denied",
+			polls:     []string{"authorization_pending", "access_denied"},
+			wantSleep: []time.Duration{time.Second, time.Second},
+			wantErr:   ErrAccessDenied,
+		},
+		{
+			name:      "expired",
+			polls:     []string{"expired_token"},
+			wantSleep: []time.Duration{time.Second},
+			wantErr:   ErrDeviceCodeExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(&deviceServer{polls: tt.polls})
+			defer server.Close()
+
+			var slept []time.Duration
+			flow := NewDeviceFlow(nil, "client-1", DeviceFlowScopes...)
+			flow.WebURL = server.URL
+			flow.sleep = func(ctx context.Context, d time.Duration) error {
+				slept = append(slept, d)
+				return nil
+			}
+
+			code, err := flow.RequestCode(context.Background())
+			if err != nil {
+				t.Fatalf("RequestCode() error = %v", err)
+			}
+			if code.UserCode != "ABCD-1234" || code.VerificationURI == "" {
+				t.Fatalf("unexpected device code: %+v", code)
+			}
+
+			token, err := flow.PollToken(context.Background(), code)
+			if err != tt.wantErr {
+				t.Fatalf("PollToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(slept) != len(tt.wantSleep) {
+				t.Fatalf("polled after %v, want %v", slept, tt.wantSleep)
+			}
+			for i := range slept {
+				if slept[i] != tt.wantSleep[i] {
+					t.Errorf("poll %d waited %v, want %v", i, slept[i], tt.wantSleep[i])
+				}
+			}
+
+			if tt.wantErr == nil {
+				if token.AccessToken != "user-token" || token.RefreshToken != "refresh-1" || token.Expiry.IsZero() {
+					t.Errorf("unexpected token: %+v", token)
+				}
+			}
+		})
+	}
+}
+
+func TestDeviceTokenSourceRefreshes(t *testing.T) {
+	stand := &deviceServer{}
+	server := httptest.NewServer(stand)
+	defer server.Close()
+
+	flow := NewDeviceFlow(nil, "client-1")
+	flow.WebURL = server.URL
+
+	path := filepath.Join(t.TempDir(), "github_token.json")
+	ts := NewDeviceTokenSource(flow, path, &DeviceToken{
+		AccessToken:  "stale-token",
+		RefreshToken: "refresh-1",
+		Expiry:       time.Now().Add(30 * time.Second), // inside the refresh margin
+	})
+
+	token, err := ts.Token()
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if token.AccessToken != "refreshed-token" {
+		t.Errorf("Token() = %q, want the refreshed token", token.AccessToken)
+	}
+
+	// The fresh token is reused until it nears expiry
+	if _, err := ts.Token(); err != nil || stand.refreshes != 1 {
+		t.Errorf("expected a single refresh, got %d (err %v)", stand.refreshes, err)
+	}
+
+	saved, err := LoadDeviceToken(path)
+	if err != nil {
+		t.Fatalf("LoadDeviceToken() error = %v", err)
+	}
+	if saved.AccessToken != "refreshed-token" || saved.RefreshToken != "refresh-2" {
+		t.Errorf("unexpected saved token: %+v", saved)
+	}
+}
+
+func TestDeviceTokenSourcesShareRefreshedToken(t *testing.T) {
+	stand := &deviceServer{}
+	server := httptest.NewServer(stand)
+	defer server.Close()
+
+	flow := NewDeviceFlow(nil, "client-1")
+	flow.WebURL = server.URL
+
+	// Two processes loaded the same token before either refreshed it
+	path := filepath.Join(t.TempDir(), "github_token.json")
+	stale := DeviceToken{
+		AccessToken:  "stale-token",
+		RefreshToken: "refresh-1",
+		Expiry:       time.Now().Add(30 * time.Second),
+	}
+	if err := SaveDeviceToken(path, &stale); err != nil {
+		t.Fatal(err)
+	}
+	first, second := stale, stale
+	sources := []oauth2.TokenSource{NewDeviceTokenSource(flow, path, &first), NewDeviceTokenSource(flow, path, &second)}
+
+	for i, ts := range sources {
+		token, err := ts.Token()
+		if err != nil {
+			t.Fatalf("source %d Token() error = %v", i+1, err)
+		}
+		if token.AccessToken != "refreshed-token" {
+			t.Errorf("source %d Token() = %q, want the refreshed token", i+1, token.AccessToken)
+		}
+	}
+	if stand.refreshes != 1 {
+		t.Errorf("got %d refreshes, want the second source to use the token the first saved", stand.refreshes)
+	}
+}
+
+func TestUserTokenSourceIsShared(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	if err := SaveDeviceToken(DeviceTokenPath(), &DeviceToken{AccessToken: "user-token"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{}
+	cfg.GitHubOAuth.ClientID = "client-shared"
+	if userTokenSource(cfg) != userTokenSource(cfg) {
+		t.Error("userTokenSource() built a second device token source for the same sign-in")
+	}
+}
+
+func TestDeviceLoginOnEnterprise(t *testing.T) {
+	// The server stands in for both the web host and the /api/v3 REST API of a GHES instance
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v3/user":
+			_, _ = w.Write([]byte(`{"login":"octocat"}`))
+		case "/api/v3/user/repos":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{}
+	cfg.GitHubEnterprise.WebURL = server.URL + "/"
+
+	if flow := NewDeviceFlow(cfg, "client-1"); flow.WebURL != server.URL {
+		t.Errorf("device flow WebURL = %q, want %q", flow.WebURL, server.URL)
+	}
+
+	user, err := verifyGitHubToken(cfg, "user-token")
+	if err != nil || user != "octocat" {
+		t.Fatalf("verifyGitHubToken() = %q, %v; requested %v", user, err, paths)
+	}
+}
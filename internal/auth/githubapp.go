@@ -0,0 +1,515 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/ratelimit"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// jwtLifetime is how long an app JWT is valid; GitHub allows at most 10 minutes
+	jwtLifetime = 9 * time.Minute
+
+	// tokenRefreshMargin is how long before expiry an installation token is replaced
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// searchRepoPattern finds the repository a search query is scoped to
+var searchRepoPattern = regexp.MustCompile(`\brepo:([\w.-]+)/([\w.-]+)`)
+
+// installationKey is the context key that pins a request to an installation
+type installationKey struct{}
+
+// WithInstallation returns a context whose GitHub App requests use the given installation
+func WithInstallation(ctx context.Context, installationID int64) context.Context {
+	return context.WithValue(ctx, installationKey{}, installationID)
+}
+
+// GitHubApp authenticates as the installations of a GitHub App
+type GitHubApp struct {
+	appID          int64
+	key            *rsa.PrivateKey
+	installationID int64
+	apps           *github.Client // authenticates with the app JWT
+	transport      http.RoundTripper
+	now            func() time.Time
+
+	mu            sync.Mutex
+	installations map[string]int64             // repository owner -> installation
+	tokens        map[int64]oauth2.TokenSource // installation -> cached token source
+	login         string
+}
+
+// NewGitHubApp creates a GitHub App authenticator. installationID is used for requests that
+// aren't tied to a repository and may be 0 when the app has a single installation.
+// An empty baseURL means github.com.
+func NewGitHubApp(appID int64, privateKeyPEM []byte, installationID int64, baseURL string) (*GitHubApp, error) {
+	key, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	app := &GitHubApp{
+		appID:          appID,
+		key:            key,
+		installationID: installationID,
+		transport:      http.DefaultTransport,
+		now:            time.Now,
+		installations:  make(map[string]int64),
+		tokens:         make(map[int64]oauth2.TokenSource),
+	}
+
+	app.apps = github.NewClient(&http.Client{Transport: &jwtTransport{app: app}})
+	if baseURL != "" {
+		base, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/")
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse GitHub API URL: %w", err)
+		}
+		app.apps.BaseURL = base
+	}
+
+	return app, nil
+}
+
+// parsePrivateKey decodes a PEM encoded RSA private key in PKCS#1 or PKCS#8 form
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode GitHub App private key: no PEM data found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub App private key: %w", err)
+	}
+
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse GitHub App private key: not an RSA key")
+	}
+	return key, nil
+}
+
+// JWT returns a signed token that authenticates as the app itself
+func (a *GitHubApp) JWT() (string, error) {
+	now := a.now()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT header: %w", err)
+	}
+
+	// Backdate the issue time to allow for clock drift
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(jwtLifetime).Unix(),
+		"iss": strconv.FormatInt(a.appID, 10),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode JWT claims: %w", err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Client returns an HTTP client that authenticates each request as the installation
+// covering the repository it targets, within the installation's rate limits
+func (a *GitHubApp) Client() *http.Client {
+	return &http.Client{Transport: &installationTransport{app: a, base: ratelimit.NewTransport(a.transport)}}
+}
+
+// Installations lists the installations of the app
+func (a *GitHubApp) Installations(ctx context.Context) ([]*github.Installation, error) {
+	var all []*github.Installation
+	opts := &github.ListOptions{PerPage: 100}
+
+	for {
+		installations, resp, err := a.apps.Apps.ListInstallations(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list app installations: %w", err)
+		}
+		all = append(all, installations...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return all, nil
+}
+
+// InstallationForRepo returns the installation that covers a repository.
+// Installations belong to an account, so lookups are cached per owner.
+func (a *GitHubApp) InstallationForRepo(ctx context.Context, owner, repo string) (int64, error) {
+	ownerKey := strings.ToLower(owner)
+
+	a.mu.Lock()
+	id, ok := a.installations[ownerKey]
+	a.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	installation, _, err := a.apps.Apps.FindRepositoryInstallation(ctx, owner, repo)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find app installation for %s/%s: %w", owner, repo, err)
+	}
+
+	logging.Debug("Found GitHub App installation", "owner", owner, "repo", repo, "installation", installation.GetID())
+
+	a.mu.Lock()
+	a.installations[ownerKey] = installation.GetID()
+	a.mu.Unlock()
+
+	return installation.GetID(), nil
+}
+
+// DefaultInstallation returns the installation used for requests that aren't tied to a repository
+func (a *GitHubApp) DefaultInstallation(ctx context.Context) (int64, error) {
+	a.mu.Lock()
+	id := a.installationID
+	a.mu.Unlock()
+	if id != 0 {
+		return id, nil
+	}
+
+	installations, err := a.Installations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(installations) != 1 {
+		return 0, fmt.Errorf("app has %d installations, set GitHubApp.InstallationID to choose one", len(installations))
+	}
+
+	a.mu.Lock()
+	a.installationID = installations[0].GetID()
+	a.mu.Unlock()
+
+	return installations[0].GetID(), nil
+}
+
+// TokenSource returns a token source for an installation that refreshes tokens before they expire
+func (a *GitHubApp) TokenSource(installationID int64) oauth2.TokenSource {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if ts, ok := a.tokens[installationID]; ok {
+		return ts
+	}
+
+	ts := oauth2.ReuseTokenSourceWithExpiry(nil, &installationTokenSource{app: a, installationID: installationID}, tokenRefreshMargin)
+	a.tokens[installationID] = ts
+	return ts
+}
+
+// BotLogin returns the login comments and pull requests made by the app appear under, e.g. "useful1[bot]"
+func (a *GitHubApp) BotLogin(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	login := a.login
+	a.mu.Unlock()
+	if login != "" {
+		return login, nil
+	}
+
+	app, _, err := a.apps.Apps.Get(ctx, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to get GitHub App: %w", err)
+	}
+
+	login = app.GetSlug() + "[bot]"
+
+	a.mu.Lock()
+	a.login = login
+	a.mu.Unlock()
+
+	return login, nil
+}
+
+// CommitIdentity returns the git author name and email that attribute commits to the app's bot user
+func (a *GitHubApp) CommitIdentity(ctx context.Context) (string, string, error) {
+	login, err := a.BotLogin(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	client := github.NewClient(a.Client())
+	client.BaseURL = a.apps.BaseURL
+
+	// GitHub Enterprise Server serves noreply addresses from its own host
+	domain := "users.noreply.github.com"
+	if host := a.apps.BaseURL.Hostname(); host != "api.github.com" {
+		domain = "users.noreply." + host
+	}
+
+	user, _, err := client.Users.Get(ctx, login)
+	if err != nil {
+		// The ID-less noreply address is still attributed to the bot
+		logging.Warn("Failed to look up GitHub App bot user", "login", login, "error", err)
+		return login, login + "@" + domain, nil
+	}
+
+	return login, fmt.Sprintf("%d+%s@%s", user.GetID(), login, domain), nil
+}
+
+// installationFor picks the installation a request should authenticate as
+func (a *GitHubApp) installationFor(req *http.Request) (int64, error) {
+	if id, ok := req.Context().Value(installationKey{}).(int64); ok {
+		return id, nil
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, a.apps.BaseURL.Path)
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	if len(parts) >= 3 && parts[0] == "repos" {
+		return a.InstallationForRepo(req.Context(), parts[1], parts[2])
+	}
+
+	// Searches scoped to one repository can use its installation
+	if len(parts) >= 1 && parts[0] == "search" {
+		if match := searchRepoPattern.FindStringSubmatch(req.URL.Query().Get("q")); match != nil {
+			return a.InstallationForRepo(req.Context(), match[1], match[2])
+		}
+	}
+
+	return a.DefaultInstallation(req.Context())
+}
+
+// jwtTransport authenticates requests as the app itself
+type jwtTransport struct {
+	app *GitHubApp
+}
+
+// RoundTrip adds a fresh app JWT to the request
+func (t *jwtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.app.JWT()
+	if err != nil {
+		return nil, err
+	}
+
+	authed := req.Clone(req.Context())
+	authed.Header.Set("Authorization", "Bearer "+token)
+	return t.app.transport.RoundTrip(authed)
+}
+
+// installationTransport authenticates requests as an installation of the app
+type installationTransport struct {
+	app  *GitHubApp
+	base http.RoundTripper
+}
+
+// RoundTrip adds the token of the installation covering the request
+func (t *installationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	installationID, err := t.app.installationFor(req)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := t.app.TokenSource(installationID).Token()
+	if err != nil {
+		return nil, err
+	}
+
+	authed := req.Clone(req.Context())
+	token.SetAuthHeader(authed)
+	return t.base.RoundTrip(authed)
+}
+
+// installationTokenSource exchanges the app JWT for installation access tokens
+type installationTokenSource struct {
+	app            *GitHubApp
+	installationID int64
+}
+
+// Token creates a new installation access token
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	token, _, err := s.app.apps.Apps.CreateInstallationToken(context.Background(), s.installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create installation token: %w", err)
+	}
+
+	logging.Debug("Created GitHub App installation token",
+		"installation", s.installationID,
+		"expires_at", token.GetExpiresAt())
+
+	return &oauth2.Token{
+		AccessToken: token.GetToken(),
+		TokenType:   "token",
+		Expiry:      token.GetExpiresAt(),
+	}, nil
+}
+
+var (
+	sharedAppsMu sync.Mutex
+	sharedApps   = make(map[int64]*GitHubApp)
+)
+
+// GitHubAppFromConfig returns the GitHub App for a configuration. Apps are shared across
+// callers so installation tokens are reused rather than minted for every client.
+func GitHubAppFromConfig(cfg *config.Config) (*GitHubApp, error) {
+	sharedAppsMu.Lock()
+	defer sharedAppsMu.Unlock()
+
+	if app, ok := sharedApps[cfg.GitHubApp.AppID]; ok {
+		return app, nil
+	}
+
+	keyPEM, err := os.ReadFile(cfg.GitHubApp.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+	}
+
+	app, err := NewGitHubApp(cfg.GitHubApp.AppID, keyPEM, cfg.GitHubApp.InstallationID, cfg.GitHubAPIURL())
+	if err != nil {
+		return nil, err
+	}
+
+	sharedApps[cfg.GitHubApp.AppID] = app
+	return app, nil
+}
+
+// NewGitHubHTTPClient returns an HTTP client for the GitHub API that authenticates as the
+// configured GitHub App, with the token from signing in through the device flow, or with
+// the personal access token
+func NewGitHubHTTPClient(cfg *config.Config) (*http.Client, error) {
+	if !cfg.UsesGitHubApp() {
+		return NewRateLimitedClient(userTokenSource(cfg)), nil
+	}
+
+	app, err := GitHubAppFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return app.Client(), nil
+}
+
+// NewRateLimitedClient returns an HTTP client that authenticates with ts and stays
+// within the token's rate limits
+func NewRateLimitedClient(ts oauth2.TokenSource) *http.Client {
+	base := &http.Client{Transport: ratelimit.NewTransport(http.DefaultTransport)}
+	return oauth2.NewClient(context.WithValue(context.Background(), oauth2.HTTPClient, base), ts)
+}
+
+var (
+	sharedDeviceSourcesMu sync.Mutex
+	sharedDeviceSources   = make(map[string]oauth2.TokenSource) // by client ID, server and token file
+)
+
+// userTokenSource returns the device flow token when the user signed in through the
+// browser, and the personal access token otherwise. Device flow token sources are shared
+// across callers, since refreshing the token in one invalidates the refresh token of others.
+func userTokenSource(cfg *config.Config) oauth2.TokenSource {
+	if cfg.GitHubOAuth.ClientID != "" {
+		sharedDeviceSourcesMu.Lock()
+		defer sharedDeviceSourcesMu.Unlock()
+
+		path := DeviceTokenPath()
+		key := cfg.GitHubOAuth.ClientID + " " + cfg.GitHubWebURL() + " " + path
+		if ts, ok := sharedDeviceSources[key]; ok {
+			return ts
+		}
+		if token, err := LoadDeviceToken(path); err == nil {
+			ts := NewDeviceTokenSource(NewDeviceFlow(cfg, cfg.GitHubOAuth.ClientID), path, token)
+			sharedDeviceSources[key] = ts
+			return ts
+		}
+	}
+
+	return oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: cfg.GitHub.Token},
+	)
+}
+
+// GitToken returns a token git can use over HTTPS for owner/repo: an installation token
+// for the installation covering the repository, or the user's token
+func GitToken(ctx context.Context, cfg *config.Config, owner, repo string) (string, error) {
+	ts := userTokenSource(cfg)
+	if cfg.UsesGitHubApp() {
+		app, err := GitHubAppFromConfig(cfg)
+		if err != nil {
+			return "", err
+		}
+		installationID, err := app.InstallationForRepo(ctx, owner, repo)
+		if err != nil {
+			return "", err
+		}
+		ts = app.TokenSource(installationID)
+	}
+
+	token, err := ts.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to get git token: %w", err)
+	}
+	return token.AccessToken, nil
+}
+
+// NewGitHubClient returns a GitHub API client for the configured server and credentials
+func NewGitHubClient(cfg *config.Config) (*github.Client, error) {
+	tc, err := NewGitHubHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	client := github.NewClient(tc)
+	if err := SetGitHubBaseURLs(client, cfg); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// SetGitHubBaseURLs points a client at the configured GitHub Enterprise Server, if any
+func SetGitHubBaseURLs(client *github.Client, cfg *config.Config) error {
+	if !cfg.UsesGitHubEnterprise() {
+		return nil
+	}
+
+	base, err := url.Parse(cfg.GitHubAPIURL())
+	if err != nil {
+		return fmt.Errorf("failed to parse GitHub API URL: %w", err)
+	}
+	client.BaseURL = base
+
+	if uploadURL := cfg.GitHubUploadURL(); uploadURL != "" {
+		upload, err := url.Parse(uploadURL)
+		if err != nil {
+			return fmt.Errorf("failed to parse GitHub upload URL: %w", err)
+		}
+		client.UploadURL = upload
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestApp creates an app with a fresh key against a fake GitHub API
+func newTestApp(t *testing.T, handler http.Handler) (*GitHubApp, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	app, err := NewGitHubApp(42, keyPEM, 0, server.URL)
+	if err != nil {
+		t.Fatalf("NewGitHubApp() error = %v", err)
+	}
+	return app, key
+}
+
+func TestGitHubAppJWT(t *testing.T) {
+	app, key := newTestApp(t, http.NotFoundHandler())
+	now := time.Unix(1700000000, 0)
+	app.now = func() time.Time { return now }
+
+	token, err := app.JWT()
+	if err != nil {
+		t.Fatalf("JWT() error = %v", err)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT has %d parts", len(parts))
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("failed to decode signature: %v", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Fatalf("JWT signature does not verify: %v", err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatalf("failed to decode claims: %v", err)
+	}
+	var claims struct {
+		IAT int64  `json:"iat"`
+		EXP int64  `json:"exp"`
+		ISS string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		t.Fatalf("failed to parse claims: %v", err)
+	}
+
+	if claims.ISS != "42" || claims.IAT != now.Add(-time.Minute).Unix() || claims.EXP != now.Add(jwtLifetime).Unix() {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestGitHubAppInstallationRouting(t *testing.T) {
+	var mu sync.Mutex
+	minted := map[string]int{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app/installations/", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") || r.Method != http.MethodPost {
+			http.Error(w, "bad token request", http.StatusUnauthorized)
+			return
+		}
+		id := strings.Split(strings.TrimPrefix(r.URL.Path, "/app/installations/"), "/")[0]
+
+		mu.Lock()
+		minted[id]++
+		mu.Unlock()
+
+		fmt.Fprintf(w, `{"token":"token-%s","expires_at":%q}`, id, time.Now().Add(time.Hour).Format(time.RFC3339))
+	})
+	mux.HandleFunc("/repos/alpha/one/installation", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":1}`)
+	})
+	mux.HandleFunc("/repos/beta/two/installation", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id":2}`)
+	})
+	mux.HandleFunc("/app/installations", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id":1},{"id":2}]`)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		// Echo the token so the test can see which installation was used
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	})
+
+	app, _ := newTestApp(t, mux)
+	client := app.Client()
+
+	get := func(path string) (string, error) {
+		resp, err := client.Get(app.apps.BaseURL.String() + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"repos/alpha/one/issues/1", "token token-1"},
+		{"repos/beta/two/pulls/2", "token token-2"},
+		{"repos/alpha/one/issues/3", "token token-1"},
+		{"search/issues?q=repo:beta/two+is:pr", "token token-2"},
+	}
+
+	for _, tt := range tests {
+		got, err := get(tt.path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", tt.path, err)
+		}
+		if got != tt.want {
+			t.Errorf("GET %s used %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if minted["1"] != 1 || minted["2"] != 1 {
+		t.Errorf("expected one token per installation, got %v", minted)
+	}
+
+	// Unscoped requests need an explicit installation when there are several
+	if _, err := get("user/repos"); err == nil {
+		t.Error("expected an error for an unscoped request with several installations")
+	}
+}
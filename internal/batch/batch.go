@@ -0,0 +1,213 @@
+// Package batch works through a list of issues with limits on concurrency and spending
+// and reports the outcome of each one.
+package batch
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+// Outcomes recorded by the batch itself; runs report their own, such as "solved" or "failed"
+const (
+	OutcomeCompleted       = "already_completed" // an earlier run finished the issue
+	OutcomeBudgetExhausted = "budget_exhausted"  // the total budget ran out before the issue was started
+	OutcomeCancelled       = "cancelled"         // the batch was stopped before the issue was started
+)
+
+// minBudget is the smallest budget worth starting an issue with, in USD
+const minBudget = 0.01
+
+// Item is an issue in a batch
+type Item struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+// Key returns the issue's state key, "owner/repo#n"
+func (i Item) Key() string {
+	return state.IssueKey(i.Owner, i.Repo, i.Number)
+}
+
+// Result is the outcome of one issue
+type Result struct {
+	Issue           string  `json:"issue"`
+	Outcome         string  `json:"outcome"`
+	Status          string  `json:"status,omitempty"`
+	URL             string  `json:"url,omitempty"` // the pull request that was opened
+	Branch          string  `json:"branch,omitempty"`
+	CostUSD         float64 `json:"cost_usd"` // model calls of the analyzer and the agent
+	DurationSeconds float64 `json:"duration_seconds"`
+	Error           string  `json:"error,omitempty"` // why the run failed
+}
+
+// Failed reports whether the issue's run failed
+func (r Result) Failed() bool {
+	return r.Outcome == "failed"
+}
+
+// RunFunc works on one issue, stopping the agent at budget USD if budget is positive
+type RunFunc func(ctx context.Context, item Item, budget float64) Result
+
+// Options limit how a batch is run
+type Options struct {
+	Concurrency int     // issues worked on at the same time; defaults to 1
+	TotalBudget float64 // USD for the whole batch; zero means no limit
+	IssueBudget float64 // USD for each issue; zero leaves the configured budget
+}
+
+// Plan removes duplicates from items and sets aside the issues that earlier runs
+// completed, so a batch can be run again until everything is done
+func Plan(items []Item, store *state.Store) (todo []Item, done []Result) {
+	seen := make(map[string]bool)
+	for _, item := range items {
+		key := item.Key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		st := store.Get(key)
+		if st.Status == state.StatusCompleted {
+			done = append(done, Result{Issue: key, Outcome: OutcomeCompleted, Status: string(st.Status), Branch: st.Branch})
+			continue
+		}
+		todo = append(todo, item)
+	}
+	return todo, done
+}
+
+// Run works through items in order and returns their results in the same order. Each
+// issue gets the lesser of IssueBudget and what is left of TotalBudget, counting the
+// budgets of runs still in progress as spent until they report their actual cost.
+// onResult, if set, is called as each issue finishes.
+func Run(ctx context.Context, items []Item, opts Options, run RunFunc, onResult func(Result)) []Result {
+	concurrency := max(opts.Concurrency, 1)
+	results := make([]Result, len(items))
+
+	var mutex sync.Mutex
+	cond := sync.NewCond(&mutex)
+	var wg sync.WaitGroup
+	inFlight := 0
+	spent, reserved := 0.0, 0.0
+
+	// available returns the budget for the next issue; the caller holds mutex
+	available := func() float64 {
+		if opts.TotalBudget <= 0 {
+			return opts.IssueBudget
+		}
+		left := opts.TotalBudget - spent - reserved
+		if opts.IssueBudget > 0 {
+			left = min(left, opts.IssueBudget)
+		}
+		return left
+	}
+
+	// Wake the scheduler if the batch is cancelled while it waits
+	stop := context.AfterFunc(ctx, func() {
+		mutex.Lock()
+		cond.Broadcast()
+		mutex.Unlock()
+	})
+	defer stop()
+
+	// finish records a result; the caller holds mutex, so onResult is never called concurrently
+	finish := func(i int, result Result) {
+		results[i] = result
+		if onResult != nil {
+			onResult(result)
+		}
+	}
+
+	for i, item := range items {
+		mutex.Lock()
+		// Wait for a free slot, and for running issues to report their cost if the budget
+		// they reserved is all that's left
+		for ctx.Err() == nil && (inFlight >= concurrency || (opts.TotalBudget > 0 && available() < minBudget && inFlight > 0)) {
+			cond.Wait()
+		}
+		budget := available()
+
+		switch {
+		case ctx.Err() != nil:
+			finish(i, Result{Issue: item.Key(), Outcome: OutcomeCancelled})
+			mutex.Unlock()
+			continue
+		case opts.TotalBudget > 0 && budget < minBudget:
+			finish(i, Result{Issue: item.Key(), Outcome: OutcomeBudgetExhausted})
+			mutex.Unlock()
+			continue
+		}
+
+		inFlight++
+		if opts.TotalBudget > 0 {
+			reserved += budget
+		}
+		mutex.Unlock()
+
+		wg.Add(1)
+		go func(i int, item Item, budget float64) {
+			defer wg.Done()
+
+			started := time.Now()
+			result := run(ctx, item, budget)
+			result.Issue = item.Key()
+			if result.DurationSeconds == 0 {
+				result.DurationSeconds = time.Since(started).Seconds()
+			}
+
+			mutex.Lock()
+			inFlight--
+			if opts.TotalBudget > 0 {
+				reserved -= budget
+			}
+			spent += result.CostUSD
+			finish(i, result)
+			cond.Broadcast()
+			mutex.Unlock()
+		}(i, item, budget)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// WriteJSON writes the results as an indented JSON array
+func WriteJSON(w io.Writer, results []Result) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(results); err != nil {
+		return fmt.Errorf("failed to write JSON report: %w", err)
+	}
+	return nil
+}
+
+// WriteCSV writes the results as CSV with a header row
+func WriteCSV(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	rows := [][]string{{"issue", "outcome", "status", "url", "branch", "cost_usd", "duration_seconds", "error"}}
+	for _, r := range results {
+		rows = append(rows, []string{
+			r.Issue,
+			r.Outcome,
+			r.Status,
+			r.URL,
+			r.Branch,
+			strconv.FormatFloat(r.CostUSD, 'f', 4, 64),
+			strconv.FormatFloat(r.DurationSeconds, 'f', 1, 64),
+			r.Error,
+		})
+	}
+	if err := writer.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write CSV report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"sync"
+	"testing"
+
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+func TestPlanSkipsCompletedIssues(t *testing.T) {
+	store, _ := state.Open("")
+	_ = store.Put("octo/demo#2", state.IssueState{Status: state.StatusCompleted, Branch: "fix/2"})
+	_ = store.Put("octo/demo#3", state.IssueState{Status: state.StatusFailed})
+
+	items := []Item{{"octo", "demo", 1}, {"octo", "demo", 2}, {"octo", "demo", 3}, {"octo", "demo", 1}}
+	todo, done := Plan(items, store)
+
+	if len(todo) != 2 || todo[0].Number != 1 || todo[1].Number != 3 {
+		t.Errorf("todo = %+v, want #1 and #3", todo)
+	}
+	if len(done) != 1 || done[0].Issue != "octo/demo#2" || done[0].Outcome != OutcomeCompleted {
+		t.Errorf("done = %+v, want #2 already completed", done)
+	}
+}
+
+func TestRunLimitsConcurrencyAndBudget(t *testing.T) {
+	var mutex sync.Mutex
+	running, peak := 0, 0
+	var budgets []float64
+
+	run := func(ctx context.Context, item Item, budget float64) Result {
+		mutex.Lock()
+		running++
+		peak = max(peak, running)
+		budgets = append(budgets, budget)
+		mutex.Unlock()
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		if item.Number == 2 {
+			return Result{Outcome: "failed", CostUSD: 1, Error: "push rejected"}
+		}
+		return Result{Outcome: "solved", CostUSD: budget}
+	}
+
+	items := []Item{{"octo", "demo", 1}, {"octo", "demo", 2}, {"octo", "demo", 3}, {"octo", "demo", 4}}
+	var reported []Result
+	results := Run(context.Background(), items, Options{Concurrency: 2, TotalBudget: 4, IssueBudget: 2}, run,
+		func(r Result) { reported = append(reported, r) })
+
+	if peak > 2 {
+		t.Errorf("ran %d issues at once, want at most 2", peak)
+	}
+	for _, budget := range budgets {
+		if budget > 2 {
+			t.Errorf("issue got a budget of %.2f, want at most 2", budget)
+		}
+	}
+	// $4 covers the first issues; the last starts with what is left or not at all
+	if results[0].Issue != "octo/demo#1" || results[1].Error != "push rejected" || !results[1].Failed() {
+		t.Errorf("unexpected results: %+v", results)
+	}
+	if results[3].Outcome != OutcomeBudgetExhausted {
+		t.Errorf("last issue = %+v, want it skipped once the budget ran out", results[3])
+	}
+	if len(reported) != len(items) {
+		t.Errorf("reported %d results, want %d", len(reported), len(items))
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	results := []Result{{Issue: "octo/demo#1", Outcome: "solved", URL: "https://github.com/octo/demo/pull/9", CostUSD: 0.25, DurationSeconds: 61}}
+	if err := WriteCSV(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1][0] != "octo/demo#1" || rows[1][3] != "https://github.com/octo/demo/pull/9" || rows[1][5] != "0.2500" {
+		t.Errorf("unexpected CSV: %q", rows)
+	}
+}
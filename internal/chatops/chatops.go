@@ -0,0 +1,215 @@
+// Package chatops parses and dispatches slash commands left for the bot in issue and pull request comments
+package chatops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/security"
+)
+
+// Prefix starts every command addressed to the bot
+const Prefix = "/useful1"
+
+// Action identifies a chat command
+type Action string
+
+// Supported actions
+const (
+	ActionRetry  Action = "retry"
+	ActionCancel Action = "cancel"
+	ActionPlan   Action = "plan"
+	ActionBudget Action = "budget"
+	ActionBase   Action = "base"
+	ActionStatus Action = "status"
+)
+
+// Usage lists the supported commands
+const Usage = "`/useful1 retry`, `/useful1 cancel`, `/useful1 plan`, `/useful1 budget <usd>`, `/useful1 base <branch>`, `/useful1 status`"
+
+// Command is a parsed chat command
+type Command struct {
+	Action Action
+	Budget float64 // for ActionBudget
+	Branch string  // for ActionBase
+}
+
+// Target identifies the issue or pull request a command applies to
+type Target struct {
+	Owner  string
+	Repo   string
+	Number int
+}
+
+// String returns the target in owner/repo#number form
+func (t Target) String() string {
+	return fmt.Sprintf("%s/%s#%d", t.Owner, t.Repo, t.Number)
+}
+
+// Handler carries out commands. Each method returns the acknowledgement to post;
+// an empty acknowledgement means the handler already replied itself.
+type Handler interface {
+	Retry(target Target) (string, error)
+	Cancel(target Target) (string, error)
+	Plan(target Target) (string, error)
+	SetBudget(target Target, amount float64) (string, error)
+	SetBase(target Target, branch string) (string, error)
+	Status(target Target) (string, error)
+}
+
+// Parse finds a command in a comment body. It returns nil with no error when
+// the comment is not addressed to the bot, and an error for malformed commands.
+func Parse(body string) (*Command, error) {
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.EqualFold(fields[0], Prefix) {
+			continue
+		}
+
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("missing command")
+		}
+
+		cmd := &Command{Action: Action(strings.ToLower(fields[1]))}
+		args := fields[2:]
+
+		switch cmd.Action {
+		case ActionRetry, ActionCancel, ActionPlan, ActionStatus:
+			// No arguments
+
+		case ActionBudget:
+			if len(args) != 1 {
+				return nil, fmt.Errorf("budget needs an amount in USD, e.g. `/useful1 budget 3.00`")
+			}
+			amount, err := strconv.ParseFloat(strings.TrimPrefix(args[0], "$"), 64)
+			if err != nil || amount <= 0 {
+				return nil, fmt.Errorf("invalid budget amount %q", args[0])
+			}
+			cmd.Budget = amount
+
+		case ActionBase:
+			if len(args) != 1 {
+				return nil, fmt.Errorf("base needs a branch name, e.g. `/useful1 base release-1.4`")
+			}
+			cmd.Branch = args[0]
+
+		default:
+			return nil, fmt.Errorf("unknown command %q", fields[1])
+		}
+
+		return cmd, nil
+	}
+
+	return nil, nil
+}
+
+// requiredPermission returns the role a commenter needs to run an action
+func requiredPermission(action Action) string {
+	if action == ActionStatus {
+		return "read"
+	}
+	return "write"
+}
+
+// Dispatcher checks permissions, runs commands and acknowledges them
+type Dispatcher struct {
+	service  vcs.Service
+	handler  Handler
+	username string
+}
+
+// NewDispatcher creates a new dispatcher. username is the bot's own login, whose
+// comments are never treated as commands.
+func NewDispatcher(service vcs.Service, handler Handler, username string) *Dispatcher {
+	return &Dispatcher{
+		service:  service,
+		handler:  handler,
+		username: username,
+	}
+}
+
+// Handle runs the command in a comment, if there is one. replyTo is where the
+// comment was left and target is the issue the command applies to.
+// It returns false when the comment holds no command, or with an error when the
+// author's permission couldn't be checked, so the comment is read again next time.
+func (d *Dispatcher) Handle(replyTo, target Target, comment vcs.IssueComment) (bool, error) {
+	if comment.User == "" || comment.User == d.username {
+		return false, nil
+	}
+
+	cmd, parseErr := Parse(comment.Body)
+	if cmd == nil && parseErr == nil {
+		return false, nil
+	}
+
+	required := "write"
+	if cmd != nil {
+		required = requiredPermission(cmd.Action)
+	}
+
+	permission, err := d.service.GetUserPermission(replyTo.Owner, replyTo.Repo, comment.User)
+	if err != nil {
+		return false, fmt.Errorf("failed to check permission for %s: %w", comment.User, err)
+	}
+
+	if !security.HasPermission(permission, required) {
+		logging.Info("Ignoring command from user without permission",
+			"user", comment.User,
+			"permission", permission,
+			"required", required,
+			"target", target.String())
+		return true, d.reply(replyTo, comment.User, fmt.Sprintf("you need %s access to this repository to run that command.", required))
+	}
+
+	if parseErr != nil {
+		return true, d.reply(replyTo, comment.User, fmt.Sprintf("%v. Supported commands: %s", parseErr, Usage))
+	}
+
+	logging.Info("Running chat command",
+		"action", cmd.Action,
+		"user", comment.User,
+		"target", target.String())
+
+	ack, err := d.dispatch(target, cmd)
+	if err != nil {
+		logging.Error("Chat command failed", "action", cmd.Action, "error", err)
+		return true, d.reply(replyTo, comment.User, fmt.Sprintf("`%s %s` failed: %v", Prefix, cmd.Action, err))
+	}
+
+	if ack == "" {
+		return true, nil
+	}
+	return true, d.reply(replyTo, comment.User, ack)
+}
+
+// dispatch calls the handler method for a command
+func (d *Dispatcher) dispatch(target Target, cmd *Command) (string, error) {
+	switch cmd.Action {
+	case ActionRetry:
+		return d.handler.Retry(target)
+	case ActionCancel:
+		return d.handler.Cancel(target)
+	case ActionPlan:
+		return d.handler.Plan(target)
+	case ActionBudget:
+		return d.handler.SetBudget(target, cmd.Budget)
+	case ActionBase:
+		return d.handler.SetBase(target, cmd.Branch)
+	case ActionStatus:
+		return d.handler.Status(target)
+	default:
+		return "", fmt.Errorf("unknown command %q", cmd.Action)
+	}
+}
+
+// reply posts an acknowledgement addressed to the commenter
+func (d *Dispatcher) reply(replyTo Target, user, message string) error {
+	body := fmt.Sprintf("@%s %s", user, message)
+	if err := d.service.RespondToIssue(replyTo.Owner, replyTo.Repo, replyTo.Number, body); err != nil {
+		return fmt.Errorf("failed to acknowledge command: %w", err)
+	}
+	return nil
+}
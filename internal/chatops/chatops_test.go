@@ -0,0 +1,211 @@
+package chatops
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    *Command
+		wantErr bool
+	}{
+		{name: "not a command", body: "thanks, looks good"},
+		{name: "retry", body: "/useful1 retry", want: &Command{Action: ActionRetry}},
+		{name: "case insensitive", body: "/Useful1 STATUS", want: &Command{Action: ActionStatus}},
+		{name: "command on a later line", body: "Hmm.\n/useful1 cancel\nsorry", want: &Command{Action: ActionCancel}},
+		{name: "budget", body: "/useful1 budget 3.00", want: &Command{Action: ActionBudget, Budget: 3}},
+		{name: "budget with dollar sign", body: "/useful1 budget $2.5", want: &Command{Action: ActionBudget, Budget: 2.5}},
+		{name: "base", body: "/useful1 base release-1.4", want: &Command{Action: ActionBase, Branch: "release-1.4"}},
+		{name: "missing action", body: "/useful1", wantErr: true},
+		{name: "unknown action", body: "/useful1 deploy", wantErr: true},
+		{name: "invalid budget", body: "/useful1 budget lots", wantErr: true},
+		{name: "negative budget", body: "/useful1 budget -1", wantErr: true},
+		{name: "base without branch", body: "/useful1 base", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				if got != nil && !tt.wantErr {
+					t.Errorf("Parse() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || *got != *tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeService records replies and returns fixed permissions
+type fakeService struct {
+	vcs.Service
+	permissions   map[string]string
+	permissionErr error
+	replies       []string
+}
+
+func (f *fakeService) GetUserPermission(owner, repo, username string) (string, error) {
+	if f.permissionErr != nil {
+		return "", f.permissionErr
+	}
+	if permission, ok := f.permissions[username]; ok {
+		return permission, nil
+	}
+	return "none", nil
+}
+
+func (f *fakeService) RespondToIssue(owner, repo string, issueNumber int, comment string) error {
+	f.replies = append(f.replies, comment)
+	return nil
+}
+
+// fakeHandler records the actions it was asked to carry out
+type fakeHandler struct {
+	calls []string
+}
+
+func (f *fakeHandler) Retry(target Target) (string, error) {
+	f.calls = append(f.calls, "retry")
+	return "retrying", nil
+}
+
+func (f *fakeHandler) Cancel(target Target) (string, error) {
+	f.calls = append(f.calls, "cancel")
+	return "cancelled", nil
+}
+
+func (f *fakeHandler) Plan(target Target) (string, error) {
+	f.calls = append(f.calls, "plan")
+	return "", nil
+}
+
+func (f *fakeHandler) SetBudget(target Target, amount float64) (string, error) {
+	f.calls = append(f.calls, "budget")
+	return "budget set", nil
+}
+
+func (f *fakeHandler) SetBase(target Target, branch string) (string, error) {
+	f.calls = append(f.calls, "base "+branch)
+	return "base set", nil
+}
+
+func (f *fakeHandler) Status(target Target) (string, error) {
+	f.calls = append(f.calls, "status")
+	return "all good", nil
+}
+
+func TestDispatcherHandlePermissionError(t *testing.T) {
+	target := Target{Owner: "octo", Repo: "demo", Number: 1}
+	service := &fakeService{permissionErr: errors.New("rate limited")}
+	handler := &fakeHandler{}
+	dispatcher := NewDispatcher(service, handler, "useful1")
+
+	handled, err := dispatcher.Handle(target, target, vcs.IssueComment{User: "maintainer", Body: "/useful1 retry"})
+	if err == nil {
+		t.Fatal("Handle() error = nil, want the permission error")
+	}
+	if handled {
+		t.Error("Handle() handled = true, want false so the command is read again")
+	}
+	if len(handler.calls) != 0 || len(service.replies) != 0 {
+		t.Errorf("unexpected calls %v and replies %q", handler.calls, service.replies)
+	}
+}
+
+func TestDispatcherHandle(t *testing.T) {
+	target := Target{Owner: "octo", Repo: "demo", Number: 1}
+
+	tests := []struct {
+		name        string
+		comment     vcs.IssueComment
+		wantHandled bool
+		wantCalls   []string
+		wantReply   string
+	}{
+		{
+			name:    "plain comment",
+			comment: vcs.IssueComment{User: "maintainer", Body: "nice work"},
+		},
+		{
+			name:    "bot's own comment",
+			comment: vcs.IssueComment{User: "useful1", Body: "/useful1 retry"},
+		},
+		{
+			name:        "maintainer retry",
+			comment:     vcs.IssueComment{User: "maintainer", Body: "/useful1 retry"},
+			wantHandled: true,
+			wantCalls:   []string{"retry"},
+			wantReply:   "@maintainer retrying",
+		},
+		{
+			name:        "base branch",
+			comment:     vcs.IssueComment{User: "maintainer", Body: "/useful1 base release-1.4"},
+			wantHandled: true,
+			wantCalls:   []string{"base release-1.4"},
+			wantReply:   "@maintainer base set",
+		},
+		{
+			name:        "reader can ask for status",
+			comment:     vcs.IssueComment{User: "reader", Body: "/useful1 status"},
+			wantHandled: true,
+			wantCalls:   []string{"status"},
+			wantReply:   "@reader all good",
+		},
+		{
+			name:        "reader cannot cancel",
+			comment:     vcs.IssueComment{User: "reader", Body: "/useful1 cancel"},
+			wantHandled: true,
+			wantReply:   "@reader you need write access",
+		},
+		{
+			name:        "handler that replies itself",
+			comment:     vcs.IssueComment{User: "maintainer", Body: "/useful1 plan"},
+			wantHandled: true,
+			wantCalls:   []string{"plan"},
+		},
+		{
+			name:        "unknown command",
+			comment:     vcs.IssueComment{User: "maintainer", Body: "/useful1 deploy"},
+			wantHandled: true,
+			wantReply:   "@maintainer unknown command",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{permissions: map[string]string{"maintainer": "maintain", "reader": "read"}}
+			handler := &fakeHandler{}
+			dispatcher := NewDispatcher(service, handler, "useful1")
+
+			handled, err := dispatcher.Handle(target, target, tt.comment)
+			if err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+			if handled != tt.wantHandled {
+				t.Errorf("Handle() handled = %v, want %v", handled, tt.wantHandled)
+			}
+			if strings.Join(handler.calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("handler calls = %v, want %v", handler.calls, tt.wantCalls)
+			}
+
+			switch {
+			case tt.wantReply == "" && len(service.replies) != 0:
+				t.Errorf("expected no reply, got %q", service.replies)
+			case tt.wantReply != "" && (len(service.replies) != 1 || !strings.HasPrefix(service.replies[0], tt.wantReply)):
+				t.Errorf("reply = %q, want prefix %q", service.replies, tt.wantReply)
+			}
+		})
+	}
+}
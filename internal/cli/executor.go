@@ -19,6 +19,7 @@ import (
 // Executor handles execution of CLI commands and interaction with prompts.
 type Executor struct {
 	config *config.Config
+	env    []string // added to the environment of the commands run with output
 }
 
 // NewExecutor creates a new command executor.
@@ -28,6 +29,11 @@ func NewExecutor(cfg *config.Config) *Executor {
 	}
 }
 
+// SetEnv adds environment variables for the commands run with ExecuteWithOutput
+func (e *Executor) SetEnv(env ...string) {
+	e.env = append(e.env, env...)
+}
+
 // Execute runs the command in interactive mode, handling the CLI directly.
 func (e *Executor) Execute(args []string) error {
 	logging.Info("Executing CLI tool", "command", e.config.CLI.Command, "args", args, "timeout", e.config.CLI.Timeout)
@@ -110,11 +116,12 @@ func (e *Executor) ExecuteWithOutput(args []string, promptContent string) (strin
 
 	// Use xterm-256color for better compatibility with TUI apps
 	customEnv = append(customEnv, "TERM=xterm-256color", "LINES=24", "COLUMNS=80")
-	cmd.Env = customEnv
+	cmd.Env = append(customEnv, e.env...)
 
 	// Spawn command with expect.
 	exp, _, err := expect.SpawnWithArgs(cmd.Args,
 		timeoutDuration,
+		expect.SetEnv(cmd.Env),
 		expect.Verbose(false),
 		expect.PartialMatch(true),
 		expect.CheckDuration(100*time.Millisecond))
@@ -0,0 +1,37 @@
+// Package vcs provides interfaces and implementations for version control system interactions
+package vcs
+
+// Check states, used for both single checks and the combined state of a commit
+const (
+	CheckPending = "pending"
+	CheckSuccess = "success"
+	CheckFailure = "failure"
+)
+
+// CheckResult represents a single CI check or commit status
+type CheckResult struct {
+	ID      string
+	Name    string
+	State   string // one of the Check* constants
+	Summary string // short description or output summary reported by the check
+	URL     string
+	HasLogs bool // whether GetCheckLogs can fetch the job log for this check
+}
+
+// CheckStatus is the combined CI status of a pull request's head commit
+type CheckStatus struct {
+	HeadSHA string
+	State   string // one of the Check* constants
+	Checks  []CheckResult
+}
+
+// Failing returns the checks that failed
+func (s *CheckStatus) Failing() []CheckResult {
+	var failing []CheckResult
+	for _, check := range s.Checks {
+		if check.State == CheckFailure {
+			failing = append(failing, check)
+		}
+	}
+	return failing
+}
@@ -13,6 +13,12 @@ type IssueComment struct {
 	CreatedAt time.Time
 }
 
+// Reaction represents an emoji reaction left by a user, e.g. "+1" or "heart"
+type Reaction struct {
+	User    string
+	Content string
+}
+
 // Issue represents a generic issue across VCS platforms
 type Issue interface {
 	GetOwner() string
@@ -28,6 +34,7 @@ type Issue interface {
 	GetComments() []IssueComment
 	GetLabels() []string
 	GetAssignees() []string
+	GetMilestone() string
 }
 
 // BaseIssue provides a common implementation of Issue
@@ -45,6 +52,7 @@ type BaseIssue struct {
 	Comments  []IssueComment
 	Labels    []string
 	Assignees []string
+	Milestone string
 }
 
 // GetOwner returns the repository owner
@@ -85,3 +93,6 @@ func (i *BaseIssue) GetLabels() []string { return i.Labels }
 
 // GetAssignees returns usernames of people assigned to the issue
 func (i *BaseIssue) GetAssignees() []string { return i.Assignees }
+
+// GetMilestone returns the title of the issue's milestone, or "" without one
+func (i *BaseIssue) GetMilestone() string { return i.Milestone }
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/events"
 	"github.com/hellausefulsoftware/useful1/internal/logging"
 )
 
@@ -16,6 +17,31 @@ type IssueProcessor interface {
 	Process(Issue) error
 }
 
+// WaitingProcessor is an optional interface for processors that wait on events which
+// don't update the issue, such as reactions. The issues it lists are checked on every
+// poll, since a search for updated issues doesn't find them.
+type WaitingProcessor interface {
+	WaitingIssues() []Issue
+}
+
+// PullRequestProcessor is an optional interface for processors that also handle
+// the open pull requests created by the monitored user
+type PullRequestProcessor interface {
+	ProcessPullRequest(PullRequest) error
+}
+
+// CleanupProcessor is an optional interface for processors that clean up after
+// finished work, such as local checkouts, once per poll
+type CleanupProcessor interface {
+	Cleanup() error
+}
+
+// RetryProcessor is an optional interface for processors that retry failed work later.
+// Issues due for a retry are processed even if they haven't been updated.
+type RetryProcessor interface {
+	RetriesDue(now time.Time) []Issue
+}
+
 // Monitor provides a generic VCS monitor for any platform
 type Monitor struct {
 	service      Service
@@ -26,6 +52,8 @@ type Monitor struct {
 	mutex        sync.Mutex
 	processor    IssueProcessor
 	repoFilter   []string
+	triggers     []*TriggerRule
+	events       *events.Bus
 }
 
 // MonitorConfig holds configuration for creating a monitor
@@ -33,6 +61,7 @@ type MonitorConfig struct {
 	Config    *config.Config
 	Service   Service
 	Processor IssueProcessor
+	Events    *events.Bus // Optional, receives the issues handed to the processor and its failures
 }
 
 // NewMonitor creates a new VCS monitor with the given configuration
@@ -51,10 +80,16 @@ func NewMonitor(cfg MonitorConfig) (*Monitor, error) {
 		return nil, fmt.Errorf("failed to get authenticated user: %w", err)
 	}
 
+	triggers, err := TriggerRules(cfg.Config)
+	if err != nil {
+		return nil, err
+	}
+
 	repoFilter := cfg.Config.Monitor.RepoFilter
 	logging.Info("Creating new monitor",
 		"username", username,
-		"repo_filter_count", len(repoFilter))
+		"repo_filter_count", len(repoFilter),
+		"trigger_rules", len(triggers))
 
 	return &Monitor{
 		service:      cfg.Service,
@@ -63,13 +98,16 @@ func NewMonitor(cfg MonitorConfig) (*Monitor, error) {
 		username:     username,
 		processedIDs: make(map[string]time.Time),
 		processor:    cfg.Processor,
+		repoFilter:   repoFilter,
+		triggers:     triggers,
+		events:       cfg.Events,
 	}, nil
 }
 
 // Start begins the continuous monitoring process
 func (m *Monitor) Start() error {
 	logging.Info("Starting VCS monitor")
-	logging.Info("Monitoring for issues assigned to user", "username", m.username)
+	logging.Info("Monitoring for issues matching trigger rules", "username", m.username, "rules", len(m.triggers))
 
 	if len(m.repoFilter) > 0 {
 		logging.Info("Monitoring specific repositories", "count", len(m.repoFilter))
@@ -82,10 +120,16 @@ func (m *Monitor) Start() error {
 
 	// Loop indefinitely, checking for new issues
 	for {
-		if err := m.checkForAssignedIssues(); err != nil {
-			logging.Error("Failed to check for assigned issues", "error", err)
+		if err := m.checkForIssues(); err != nil {
+			logging.Error("Failed to check for issues", "error", err)
 		}
 
+		if err := m.checkAuthoredPullRequests(); err != nil {
+			logging.Error("Failed to check authored pull requests", "error", err)
+		}
+
+		m.cleanup()
+
 		// Update last checked time
 		m.lastChecked = time.Now()
 
@@ -96,10 +140,10 @@ func (m *Monitor) Start() error {
 	}
 }
 
-// CheckOnce runs a single check for assigned issues
+// CheckOnce runs a single check for issues matching the trigger rules
 func (m *Monitor) CheckOnce() error {
-	logging.Info("Running one-time check for assigned issues")
-	logging.Info("Checking for issues assigned to user", "username", m.username)
+	logging.Info("Running one-time check for issues")
+	logging.Info("Checking for issues matching trigger rules", "username", m.username, "rules", len(m.triggers))
 
 	if len(m.repoFilter) > 0 {
 		logging.Info("Checking specific repositories", "count", len(m.repoFilter))
@@ -110,104 +154,265 @@ func (m *Monitor) CheckOnce() error {
 		logging.Info("Checking all accessible repositories")
 	}
 
-	err := m.checkForAssignedIssues()
+	err := m.checkForIssues()
 	if err != nil {
 		logging.Error("Check failed", "error", err)
 		return err
 	}
 
+	if err := m.checkAuthoredPullRequests(); err != nil {
+		logging.Error("Pull request check failed", "error", err)
+		return err
+	}
+
+	m.cleanup()
+
 	logging.Info("One-time check completed successfully")
 	return nil
 }
 
-// checkForAssignedIssues checks for open issues where the user is assigned
-func (m *Monitor) checkForAssignedIssues() error {
-	// Log the username we're checking for
-	logging.Info("Checking for issues assigned to user", "username", m.username)
-
-	// Get assigned issues from the service since the last check time
-	issues, err := m.service.GetAssignedIssues(m.username, m.lastChecked, 100)
-	if err != nil {
-		logging.Error("Failed to get assigned issues", "error", err)
-		return fmt.Errorf("error getting assigned issues: %w", err)
-	}
+// checkForIssues hands the issues matching the trigger rules to the processor
+func (m *Monitor) checkForIssues() error {
+	var foundIssues int
+	var matchingRepoIssues int
+	var triggeredIssues int
 
-	logging.Info("Found assigned issues", "count", len(issues))
+	// An issue matching several rules is only handled once per poll
+	seen := make(map[string]bool)
 
-	var accessibleIssues int
-	var matchingRepoIssues int
+	// Issues waiting on events that don't update them are checked even if nothing changed
+	waiting := m.waitingIssues()
+	waitingIDs := make(map[string]bool, len(waiting))
+	for _, issue := range waiting {
+		waitingIDs[fmt.Sprintf("%s/%s#%d", issue.GetOwner(), issue.GetRepo(), issue.GetNumber())] = true
+	}
 
-	// Process each issue
-	for _, issue := range issues {
-		accessibleIssues++
+rules:
+	for _, rule := range m.triggers {
+		logging.Info("Checking trigger rule", "rule", rule.String(), "username", m.username)
 
-		// Apply repository filter if configured
-		if len(m.repoFilter) > 0 {
-			repoName := issue.GetOwner() + "/" + issue.GetRepo()
-			repoFound := false
+		// Stream the issues updated since the last check, fetching further pages as they are reached
+		for issue, err := range m.service.SearchIssues(rule.Query(m.username), m.lastChecked) {
+			if err != nil {
+				logging.Error("Failed to search for issues", "rule", rule.String(), "error", err)
+				m.events.Publish(events.Event{Type: events.TypeError, Category: "search", Message: "Failed to search for issues", Error: err.Error()})
+				return fmt.Errorf("error searching for issues: %w", err)
+			}
 
-			for _, allowedRepo := range m.repoFilter {
-				if strings.EqualFold(allowedRepo, repoName) {
-					repoFound = true
-					break
-				}
+			issueID := fmt.Sprintf("%s/%s#%d", issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
+			if seen[issueID] {
+				continue
 			}
+			seen[issueID] = true
+			foundIssues++
 
 			// Skip issues that don't match our repository filter
-			if !repoFound {
+			if !m.matchesRepoFilter(issue.GetOwner(), issue.GetRepo()) {
 				logging.Debug("Issue does not match repository filter, skipping",
-					"repo", repoName,
+					"repo", issue.GetOwner()+"/"+issue.GetRepo(),
 					"issue", issue.GetNumber())
 				continue
 			}
-		}
 
-		matchingRepoIssues++
+			matchingRepoIssues++
 
-		// Check if we've already processed this issue recently
-		m.mutex.Lock()
-		issueID := fmt.Sprintf("%s/%s#%d", issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
-		lastProcessed, exists := m.processedIDs[issueID]
-		m.mutex.Unlock()
+			// Leave the remaining quota to work in progress; the issues are found again next poll
+			if limit, low := m.lowRateLimit(); low {
+				logging.Warn("API quota is low, deferring remaining issues to the next poll",
+					"resource", limit.Resource,
+					"remaining", limit.Remaining,
+					"limit", limit.Limit,
+					"reset", limit.Reset)
+				break rules
+			}
+
+			// Check if we've already processed this issue recently
+			m.mutex.Lock()
+			lastProcessed, exists := m.processedIDs[issueID]
+			m.mutex.Unlock()
+
+			if exists {
+				timeSince := time.Since(lastProcessed)
+				// Skip if we processed this issue in the last hour and nothing has changed since
+				if timeSince < time.Hour && !issue.GetUpdatedAt().After(lastProcessed) && !waitingIDs[issueID] {
+					logging.Debug("Skipping recently processed issue",
+						"number", issue.GetNumber(),
+						"processed_ago", timeSince.Round(time.Second))
+					continue
+				}
+			}
 
-		if exists {
-			timeSince := time.Since(lastProcessed)
-			// Skip if we processed this issue in the last hour
-			if timeSince < time.Hour {
-				logging.Debug("Skipping recently processed issue",
-					"number", issue.GetNumber(),
-					"processed_ago", timeSince.Round(time.Second))
+			// Get full issue data including comments
+			fullIssue, err := m.service.GetIssueWithComments(issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
+			if err != nil {
+				logging.Error("Failed to get issue details", "error", err)
 				continue
 			}
+
+			// Search results can be stale, so check the rule against the current issue
+			if rule.Matches(fullIssue, m.username) {
+				triggeredIssues++
+
+				// Process the issue using the issue processor
+				m.publishDiscovered(fullIssue)
+				if err := m.processor.Process(fullIssue); err != nil {
+					logging.Error("Failed to process issue", "error", err)
+					m.events.Publish(events.Event{Type: events.TypeError, Category: "process", Issue: issueID, Message: "Failed to process issue", Error: err.Error()})
+					continue
+				}
+			} else {
+				logging.Debug("Issue no longer matches trigger rule, skipping", "issue", issueID, "rule", rule.String())
+			}
+
+			// Mark issue as processed
+			m.mutex.Lock()
+			m.processedIDs[issueID] = time.Now()
+			m.mutex.Unlock()
 		}
+	}
+
+	// The retry and waiting issues are only seen here when the search didn't find them
+	retried := 0
+	if retrier, ok := m.processor.(RetryProcessor); ok {
+		retried = m.checkListed(retrier.RetriesDue(time.Now()), seen, "Retrying failed issue")
+	}
+	resumed := m.checkListed(waiting, seen, "Checking issue waiting on approval")
+
+	// Cleanup old processed IDs to prevent memory leaks
+	m.cleanupProcessedIDs()
+
+	// Create a summary message for both logging and TUI display
+	summaryMsg := fmt.Sprintf("Issues summary: %d found, %d matching repo filter, %d matching trigger rules",
+		foundIssues, matchingRepoIssues, triggeredIssues)
+
+	logging.Info(summaryMsg)
+	m.events.Publish(events.Event{Type: events.TypePollFinished, Data: events.PollStats{
+		Found:     foundIssues,
+		Skipped:   foundIssues - triggeredIssues,
+		Processed: triggeredIssues + retried + resumed,
+	}})
+	return nil
+}
+
+// checkListed hands issues listed by the processor, rather than found by a search, to the
+// processor and returns how many it handed over. Issues already handled in this poll are skipped.
+func (m *Monitor) checkListed(issues []Issue, seen map[string]bool, reason string) int {
+	handled := 0
+	for _, issue := range issues {
+		issueID := fmt.Sprintf("%s/%s#%d", issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
+		if seen[issueID] || !m.matchesRepoFilter(issue.GetOwner(), issue.GetRepo()) {
+			continue
+		}
+		seen[issueID] = true
 
-		// Get full issue data including comments
 		fullIssue, err := m.service.GetIssueWithComments(issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
 		if err != nil {
-			logging.Error("Failed to get issue details", "error", err)
+			logging.Error("Failed to get issue details", "issue", issueID, "error", err)
 			continue
 		}
 
-		// Process the issue using the issue processor
+		// Only handle issues that are still meant for the bot
+		if !m.matchesAnyTrigger(fullIssue) {
+			logging.Debug("Issue no longer matches any trigger rule, skipping", "issue", issueID)
+			continue
+		}
+
+		logging.Info(reason, "issue", issueID)
+		handled++
+		m.publishDiscovered(fullIssue)
 		if err := m.processor.Process(fullIssue); err != nil {
 			logging.Error("Failed to process issue", "error", err)
+			m.events.Publish(events.Event{Type: events.TypeError, Category: "process", Issue: issueID, Message: "Failed to process issue", Error: err.Error()})
 			continue
 		}
 
-		// Mark issue as processed
 		m.mutex.Lock()
 		m.processedIDs[issueID] = time.Now()
 		m.mutex.Unlock()
 	}
+	return handled
+}
 
-	// Cleanup old processed IDs to prevent memory leaks
-	m.cleanupProcessedIDs()
+// publishDiscovered publishes that an issue is being handed to the processor
+func (m *Monitor) publishDiscovered(issue Issue) {
+	m.events.Publish(events.Event{
+		Type:  events.TypeIssueDiscovered,
+		Issue: fmt.Sprintf("%s/%s#%d", issue.GetOwner(), issue.GetRepo(), issue.GetNumber()),
+		Title: issue.GetTitle(),
+		URL:   issue.GetURL(),
+	})
+}
 
-	// Create a summary message for both logging and TUI display
-	summaryMsg := fmt.Sprintf("Issues summary: %d total, %d accessible, %d matching repo filter",
-		len(issues), accessibleIssues, matchingRepoIssues)
+// matchesAnyTrigger reports whether the issue matches one of the trigger rules
+func (m *Monitor) matchesAnyTrigger(issue Issue) bool {
+	for _, rule := range m.triggers {
+		if rule.Matches(issue, m.username) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAuthoredPullRequests hands the open pull requests created by the user to the
+// processor, if it handles pull requests
+func (m *Monitor) checkAuthoredPullRequests() error {
+	prProcessor, ok := m.processor.(PullRequestProcessor)
+	if !ok {
+		return nil
+	}
+
+	prs, err := m.service.GetOpenPullRequestsByAuthor(m.username)
+	if err != nil {
+		return fmt.Errorf("error getting authored pull requests: %w", err)
+	}
+
+	logging.Info("Found authored pull requests", "count", len(prs))
+
+	for _, pr := range prs {
+		if !m.matchesRepoFilter(pr.GetOwner(), pr.GetRepo()) {
+			continue
+		}
+
+		if err := prProcessor.ProcessPullRequest(pr); err != nil {
+			logging.Error("Failed to process pull request",
+				"repo", pr.GetOwner()+"/"+pr.GetRepo(),
+				"number", pr.GetNumber(),
+				"error", err)
+		}
+	}
 
-	logging.Info(summaryMsg)
+	return nil
+}
+
+// cleanup lets the processor clean up after finished work, if it does any
+func (m *Monitor) cleanup() {
+	if cleaner, ok := m.processor.(CleanupProcessor); ok {
+		if err := cleaner.Cleanup(); err != nil {
+			logging.Error("Failed to clean up finished work", "error", err)
+		}
+	}
+}
+
+// matchesRepoFilter reports whether a repository passes the configured repository filter
+func (m *Monitor) matchesRepoFilter(owner, repo string) bool {
+	if len(m.repoFilter) == 0 {
+		return true
+	}
+
+	repoName := owner + "/" + repo
+	for _, allowedRepo := range m.repoFilter {
+		if strings.EqualFold(allowedRepo, repoName) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitingIssues returns the issues the processor wants checked on every poll
+func (m *Monitor) waitingIssues() []Issue {
+	if watcher, ok := m.processor.(WaitingProcessor); ok {
+		return watcher.WaitingIssues()
+	}
 	return nil
 }
 
@@ -224,6 +429,27 @@ func (m *Monitor) cleanupProcessedIDs() {
 	}
 }
 
+// deferBelow is the share of an API quota below which the monitor starts no new issues
+const deferBelow = 0.1
+
+// RateLimits returns the service's remaining API quota, if it tracks it
+func (m *Monitor) RateLimits() []RateLimit {
+	if reporter, ok := m.service.(RateLimitReporter); ok {
+		return reporter.RateLimits()
+	}
+	return nil
+}
+
+// lowRateLimit returns the first rate limit whose quota is too low to start new work on
+func (m *Monitor) lowRateLimit() (RateLimit, bool) {
+	for _, limit := range m.RateLimits() {
+		if limit.Limit > 0 && float64(limit.Remaining) < deferBelow*float64(limit.Limit) && limit.Reset.After(time.Now()) {
+			return limit, true
+		}
+	}
+	return RateLimit{}, false
+}
+
 // GetStats returns monitoring statistics
 func (m *Monitor) GetStats() map[string]interface{} {
 	m.mutex.Lock()
@@ -238,6 +464,18 @@ func (m *Monitor) GetStats() map[string]interface{} {
 		"repo_filters":     m.repoFilter,
 	}
 
+	if limits := m.RateLimits(); limits != nil {
+		rateLimits := make(map[string]interface{})
+		for _, limit := range limits {
+			rateLimits[limit.Resource] = map[string]interface{}{
+				"limit":     limit.Limit,
+				"remaining": limit.Remaining,
+				"reset":     limit.Reset,
+			}
+		}
+		stats["rate_limits"] = rateLimits
+	}
+
 	return stats
 }
 
@@ -3,6 +3,8 @@ package vcs
 
 // PullRequest represents a generic pull request across VCS platforms
 type PullRequest interface {
+	GetOwner() string
+	GetRepo() string
 	GetNumber() int
 	GetTitle() string
 	GetBody() string
@@ -16,6 +18,8 @@ type PullRequest interface {
 
 // BasePullRequest provides a common implementation of PullRequest
 type BasePullRequest struct {
+	Owner      string
+	Repo       string
 	Number     int
 	Title      string
 	Body       string
@@ -27,6 +31,12 @@ type BasePullRequest struct {
 	URL        string
 }
 
+// GetOwner returns the repository owner
+func (p *BasePullRequest) GetOwner() string { return p.Owner }
+
+// GetRepo returns the repository name
+func (p *BasePullRequest) GetRepo() string { return p.Repo }
+
 // GetNumber returns the PR number
 func (p *BasePullRequest) GetNumber() int { return p.Number }
 
@@ -53,3 +63,22 @@ func (p *BasePullRequest) GetBaseBranch() string { return p.BaseBranch }
 
 // GetURL returns the PR URL
 func (p *BasePullRequest) GetURL() string { return p.URL }
+
+// LinkType describes how a pull request is linked to an issue
+type LinkType string
+
+const (
+	// LinkCloses means merging the pull request closes the issue
+	LinkCloses LinkType = "closes"
+	// LinkMentions means the pull request references the issue without closing it
+	LinkMentions LinkType = "mentions"
+	// LinkCreatedByBot means the bot opened the pull request for the issue. It may not close
+	// the issue, for example when it targets a branch other than the default one.
+	LinkCreatedByBot LinkType = "created-by-bot"
+)
+
+// LinkedPullRequest is a pull request together with how it is linked to an issue
+type LinkedPullRequest struct {
+	PullRequest
+	Link LinkType
+}
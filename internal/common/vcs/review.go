@@ -0,0 +1,35 @@
+// Package vcs provides interfaces and implementations for version control system interactions
+package vcs
+
+import (
+	"time"
+)
+
+// Review states
+const (
+	ReviewApproved         = "APPROVED"
+	ReviewChangesRequested = "CHANGES_REQUESTED"
+	ReviewCommented        = "COMMENTED"
+)
+
+// Review represents a pull request review
+type Review struct {
+	ID          string
+	User        string
+	State       string // one of the Review* constants
+	Body        string
+	SubmittedAt time.Time
+}
+
+// ReviewComment represents an inline comment on a pull request diff
+type ReviewComment struct {
+	ID        string
+	ReviewID  string
+	InReplyTo string // ID of the first comment in the thread, empty for the first comment
+	User      string
+	Body      string
+	Path      string
+	Line      int
+	DiffHunk  string
+	CreatedAt time.Time
+}
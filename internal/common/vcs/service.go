@@ -2,6 +2,7 @@
 package vcs
 
 import (
+	"iter"
 	"time"
 )
 
@@ -10,26 +11,74 @@ type Service interface {
 	// Issue operations
 	GetIssue(owner, repo string, number int) (Issue, error)
 	GetIssueWithComments(owner, repo string, number int) (Issue, error)
+	// GetAssignedIssues returns up to limit assigned issues; a limit of zero or less returns them all
 	GetAssignedIssues(username string, since time.Time, limit int) ([]Issue, error)
+	// AssignedIssues streams the issues GetAssignedIssues returns, fetching pages as they are consumed
+	AssignedIssues(username string, since time.Time) iter.Seq2[Issue, error]
+	// SearchIssues streams the open issues matching a query that were updated since a time
+	SearchIssues(query IssueQuery, since time.Time) iter.Seq2[Issue, error]
 	RespondToIssue(owner, repo string, issueNumber int, comment string) error
+	// CreateComment posts a comment on an issue and returns its ID
+	CreateComment(owner, repo string, issueNumber int, body string) (string, error)
+	// UpdateComment replaces the body of a comment posted earlier
+	UpdateComment(owner, repo, commentID, body string) error
+	AssignIssue(owner, repo string, issueNumber int, assignees []string) error
+	UnassignIssue(owner, repo string, issueNumber int, assignees []string) error
+	GetCommentReactions(owner, repo, commentID string) ([]Reaction, error)
 
 	// Repository operations
 	GetRepository(owner, repo string) (Repository, error)
 	GetDefaultBranch(owner, repo string) (string, error)
 	CloneRepository(owner, repo, branch string, number int) (string, error)
 	GetRepositories() ([]Repository, error) // Get all accessible repositories for the authenticated user
+	// Repositories streams the repositories GetRepositories returns, fetching pages as they are consumed
+	Repositories() iter.Seq2[Repository, error]
+	// GetUserPermission returns a user's permission level: "admin", "maintain", "write", "triage", "read" or "none"
+	GetUserPermission(owner, repo, username string) (string, error)
 
 	// Branch operations
 	CreateBranch(owner, repo, branchName, baseBranch string) error
 
 	// PR operations
 	CreateDraftPullRequest(owner, repo, title, body, head, base string) (PullRequest, error)
-	GetPullRequestsForIssue(owner, repo string, issueNumber int) ([]PullRequest, error)
+	// GetPullRequest returns a pull request; its state is "open", "closed" or "merged"
+	GetPullRequest(owner, repo string, number int) (PullRequest, error)
+	// GetPullRequestsForIssue returns the pull requests that close or cross-reference an issue
+	GetPullRequestsForIssue(owner, repo string, issueNumber int) ([]LinkedPullRequest, error)
+	GetOpenPullRequestsByAuthor(username string) ([]PullRequest, error)
+
+	// Review operations
+	GetPullRequestReviews(owner, repo string, number int) ([]Review, error)
+	GetReviewComments(owner, repo string, number int) ([]ReviewComment, error)
+	ReplyToReviewComment(owner, repo string, number int, commentID, body string) error
+
+	// CI operations
+	GetPullRequestChecks(owner, repo string, number int) (*CheckStatus, error)
+	GetCheckLogs(owner, repo string, check CheckResult) (string, error)
 
 	// Authentication
 	GetAuthenticatedUser() (string, error)
 }
 
+// RateLimit is the remaining API quota for one kind of request, such as "core" or "search"
+type RateLimit struct {
+	Resource  string
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// RateLimitReporter is an optional interface for services that track their API rate limits
+type RateLimitReporter interface {
+	RateLimits() []RateLimit
+}
+
+// AuthChecker is an optional interface for services that can check their credentials are
+// still accepted without relying on cached results
+type AuthChecker interface {
+	CheckAuth() error
+}
+
 // ServiceProvider creates VCS service instances
 type ServiceProvider interface {
 	GetService() Service
@@ -0,0 +1,226 @@
+// Package vcs provides interfaces and implementations for version control system interactions
+package vcs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hellausefulsoftware/useful1/internal/config"
+)
+
+// IssueQuery selects open issues independently of a platform's search syntax. An issue
+// must match every field that is set.
+type IssueQuery struct {
+	Assignee      string   // assigned to this user
+	Mentions      string   // mentioning this user
+	Labels        []string // carrying all of these labels
+	ExcludeLabels []string // carrying none of these labels
+	Milestone     string   // in the milestone with this title
+	Repos         []string // in one of these "owner/repo" repositories (empty means any)
+	Raw           string   // further terms in the platform's own search syntax, such as a saved search
+}
+
+// TriggerRule selects issues for the bot to work on. A rule is written as space-separated
+// terms, for example `label:useful1:auto -label:wontfix repo:acme/api`:
+//
+//	assigned          issues assigned to the bot
+//	mentioned         issues that @mention the bot
+//	label:NAME        issues with the label
+//	milestone:TITLE   issues in the milestone
+//	query:SEARCH      issues matching a saved search in the platform's syntax
+//	-label:NAME       leaves out issues with the label
+//	repo:OWNER/NAME   limits the rule to a repository; repeat it for several
+//
+// A rule needs at least one of the first five terms, and an issue has to match all of
+// them. Values containing spaces are quoted, as in label:"good first issue".
+type TriggerRule struct {
+	text          string
+	assigned      bool
+	mentioned     bool
+	labels        []string
+	excludeLabels []string
+	milestone     string
+	query         string
+	repos         []string
+}
+
+// DefaultTriggerRule hands the bot the issues assigned to it
+const DefaultTriggerRule = "assigned"
+
+// TriggerRules parses the configured trigger rules, falling back to DefaultTriggerRule
+func TriggerRules(cfg *config.Config) ([]*TriggerRule, error) {
+	texts := cfg.Triggers.Rules
+	if len(texts) == 0 {
+		texts = []string{DefaultTriggerRule}
+	}
+
+	rules := make([]*TriggerRule, 0, len(texts))
+	for _, text := range texts {
+		rule, err := ParseTriggerRule(text)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// ParseTriggerRule parses a rule written in the trigger rule syntax
+func ParseTriggerRule(text string) (*TriggerRule, error) {
+	terms, err := splitTerms(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trigger rule %q: %w", text, err)
+	}
+
+	rule := &TriggerRule{text: strings.TrimSpace(text)}
+	triggers := 0
+	for _, term := range terms {
+		key, value, hasValue := strings.Cut(term, ":")
+		if hasValue && value == "" {
+			return nil, fmt.Errorf("invalid trigger rule %q: %s needs a value", text, key)
+		}
+
+		switch {
+		case key == "assigned" && !hasValue:
+			rule.assigned = true
+			triggers++
+		case key == "mentioned" && !hasValue:
+			rule.mentioned = true
+			triggers++
+		case key == "label" && hasValue:
+			rule.labels = append(rule.labels, value)
+			triggers++
+		case key == "milestone" && hasValue && rule.milestone == "":
+			rule.milestone = value
+			triggers++
+		case key == "query" && hasValue && rule.query == "":
+			rule.query = value
+			triggers++
+		case key == "-label" && hasValue:
+			rule.excludeLabels = append(rule.excludeLabels, value)
+		case key == "repo" && hasValue && strings.Count(value, "/") == 1:
+			rule.repos = append(rule.repos, value)
+		default:
+			return nil, fmt.Errorf("invalid trigger rule %q: unexpected term %q", text, term)
+		}
+	}
+
+	// Without a trigger a rule would hand the bot every open issue
+	if triggers == 0 {
+		return nil, fmt.Errorf("invalid trigger rule %q: needs assigned, mentioned, label:, milestone: or query:", text)
+	}
+	return rule, nil
+}
+
+// splitTerms splits a rule at spaces outside double quotes and removes the quotes
+func splitTerms(text string) ([]string, error) {
+	var terms []string
+	var term strings.Builder
+	quoted, inTerm := false, false
+
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inTerm = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if inTerm {
+				terms = append(terms, term.String())
+				term.Reset()
+				inTerm = false
+			}
+		default:
+			term.WriteRune(r)
+			inTerm = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inTerm {
+		terms = append(terms, term.String())
+	}
+	return terms, nil
+}
+
+// String returns the rule as it was written
+func (r *TriggerRule) String() string {
+	return r.text
+}
+
+// Query returns the search the platform runs to find candidates for the rule
+func (r *TriggerRule) Query(username string) IssueQuery {
+	query := IssueQuery{
+		Labels:        r.labels,
+		ExcludeLabels: r.excludeLabels,
+		Milestone:     r.milestone,
+		Repos:         r.repos,
+		Raw:           r.query,
+	}
+	if r.assigned {
+		query.Assignee = username
+	}
+	if r.mentioned {
+		query.Mentions = username
+	}
+	return query
+}
+
+// Matches checks an issue against the rule. Search indexes lag behind changes such as a
+// label being removed, so candidates are checked again before any work starts. Saved
+// searches can only be checked by the platform and always match.
+func (r *TriggerRule) Matches(issue Issue, username string) bool {
+	if len(r.repos) > 0 && !containsFold(r.repos, issue.GetOwner()+"/"+issue.GetRepo()) {
+		return false
+	}
+
+	// GitHub Apps can't be assigned issues, so their services search for a label instead
+	if r.assigned && !strings.HasSuffix(username, "[bot]") && !containsFold(issue.GetAssignees(), username) {
+		return false
+	}
+	if r.mentioned && !mentions(issue, username) {
+		return false
+	}
+
+	for _, label := range r.labels {
+		if !containsFold(issue.GetLabels(), label) {
+			return false
+		}
+	}
+	for _, label := range r.excludeLabels {
+		if containsFold(issue.GetLabels(), label) {
+			return false
+		}
+	}
+
+	return r.milestone == "" || strings.EqualFold(issue.GetMilestone(), r.milestone)
+}
+
+// mentions reports whether the issue or one of its comments @mentions the user
+func mentions(issue Issue, username string) bool {
+	// App bots are mentioned by their slug, without the "[bot]" suffix
+	name := regexp.QuoteMeta(strings.TrimSuffix(username, "[bot]"))
+	mention := regexp.MustCompile(`(?i)(^|[^\w/@])@` + name + `($|[^\w-])`)
+
+	if mention.MatchString(issue.GetBody()) {
+		return true
+	}
+	for _, comment := range issue.GetComments() {
+		if mention.MatchString(comment.Body) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether values contains value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package vcs
+
+import (
+	"fmt"
+	"iter"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/config"
+)
+
+func TestParseTriggerRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    IssueQuery
+		wantErr bool
+	}{
+		{rule: "assigned", want: IssueQuery{Assignee: "bot"}},
+		{rule: "mentioned repo:acme/api repo:acme/web", want: IssueQuery{Mentions: "bot", Repos: []string{"acme/api", "acme/web"}}},
+		{
+			rule: `label:useful1:auto -label:wontfix -label:"needs design"`,
+			want: IssueQuery{Labels: []string{"useful1:auto"}, ExcludeLabels: []string{"wontfix", "needs design"}},
+		},
+		{rule: `milestone:"v2.0 beta"`, want: IssueQuery{Milestone: "v2.0 beta"}},
+		{rule: `query:"org:acme label:bug no:assignee"`, want: IssueQuery{Raw: "org:acme label:bug no:assignee"}},
+		{rule: "", wantErr: true},
+		{rule: "-label:wontfix", wantErr: true}, // no trigger
+		{rule: "repo:acme/api", wantErr: true},  // no trigger
+		{rule: "label:", wantErr: true},         // missing value
+		{rule: "assigned:yes", wantErr: true},   // unexpected value
+		{rule: "assigned repo:acme", wantErr: true},
+		{rule: "label:bug author:alice", wantErr: true},
+		{rule: `label:"unterminated`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		rule, err := ParseTriggerRule(tt.rule)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTriggerRule(%q) error = %v, wantErr %v", tt.rule, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(rule.Query("bot"), tt.want) {
+			t.Errorf("ParseTriggerRule(%q).Query() = %+v, want %+v", tt.rule, rule.Query("bot"), tt.want)
+		}
+	}
+}
+
+func TestTriggerRuleMatches(t *testing.T) {
+	issue := func(modify func(*BaseIssue)) *BaseIssue {
+		i := &BaseIssue{Owner: "acme", Repo: "api", Number: 1, Labels: []string{"useful1:auto"}, Assignees: []string{"bot"}}
+		if modify != nil {
+			modify(i)
+		}
+		return i
+	}
+
+	tests := []struct {
+		rule     string
+		username string
+		issue    *BaseIssue
+		want     bool
+	}{
+		{"assigned", "bot", issue(nil), true},
+		{"assigned", "bot", issue(func(i *BaseIssue) { i.Assignees = nil }), false},
+		{"assigned", "useful1[bot]", issue(func(i *BaseIssue) { i.Assignees = nil }), true},
+		{"label:USEFUL1:auto -label:wontfix", "bot", issue(nil), true},
+		{"label:useful1:auto -label:wontfix", "bot", issue(func(i *BaseIssue) { i.Labels = append(i.Labels, "WontFix") }), false},
+		{"label:useful1:auto repo:acme/web", "bot", issue(nil), false},
+		{"milestone:v2", "bot", issue(func(i *BaseIssue) { i.Milestone = "v2" }), true},
+		{"milestone:v2", "bot", issue(nil), false},
+		{"mentioned", "bot", issue(func(i *BaseIssue) { i.Body = "cc @bot, can you look?" }), true},
+		{"mentioned", "bot", issue(func(i *BaseIssue) { i.Comments = []IssueComment{{Body: "@Bot please"}} }), true},
+		{"mentioned", "useful1[bot]", issue(func(i *BaseIssue) { i.Body = "@useful1 fix this" }), true},
+		{"mentioned", "bot", issue(func(i *BaseIssue) { i.Body = "@bottle and @bot-two, mail bot@example.com" }), false},
+		{`query:"label:bug"`, "bot", issue(nil), true},
+	}
+
+	for _, tt := range tests {
+		rule, err := ParseTriggerRule(tt.rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rule.Matches(tt.issue, tt.username); got != tt.want {
+			t.Errorf("%q.Matches(%+v) for %s = %v, want %v", tt.rule, tt.issue, tt.username, got, tt.want)
+		}
+	}
+}
+
+// fakeService serves search results per query and the current state of each issue.
+// Like GitHub's search, it only returns issues updated after since.
+type fakeService struct {
+	Service
+	results map[string][]Issue // search results by rule query
+	current map[int]*BaseIssue // issues as GetIssueWithComments returns them
+}
+
+func (f *fakeService) GetAuthenticatedUser() (string, error) { return "bot", nil }
+
+func (f *fakeService) SearchIssues(query IssueQuery, since time.Time) iter.Seq2[Issue, error] {
+	return func(yield func(Issue, error) bool) {
+		for _, issue := range f.results[fmt.Sprintf("%+v", query)] {
+			if !issue.GetUpdatedAt().After(since) {
+				continue
+			}
+			if !yield(issue, nil) {
+				return
+			}
+		}
+	}
+}
+
+func (f *fakeService) GetIssueWithComments(owner, repo string, number int) (Issue, error) {
+	return f.current[number], nil
+}
+
+// recordingProcessor records the issues it is handed
+type recordingProcessor struct {
+	processed []int
+}
+
+func (p *recordingProcessor) Process(issue Issue) error {
+	p.processed = append(p.processed, issue.GetNumber())
+	return nil
+}
+
+func TestMonitorTriggerRules(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Triggers.Rules = []string{"label:auto -label:wontfix", "mentioned"}
+
+	now := time.Now()
+	auto := &BaseIssue{Owner: "acme", Repo: "api", Number: 1, Labels: []string{"auto"}, UpdatedAt: now}
+	relabelled := &BaseIssue{Owner: "acme", Repo: "api", Number: 2, Labels: []string{"auto", "wontfix"}, UpdatedAt: now}
+	both := &BaseIssue{Owner: "acme", Repo: "api", Number: 3, Labels: []string{"auto"}, Body: "@bot", UpdatedAt: now}
+	unmentioned := &BaseIssue{Owner: "acme", Repo: "api", Number: 4, UpdatedAt: now}
+
+	rules, err := TriggerRules(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &fakeService{
+		results: map[string][]Issue{
+			// The search index still has issue 2 without its new label
+			fmt.Sprintf("%+v", rules[0].Query("bot")): {auto, &BaseIssue{Owner: "acme", Repo: "api", Number: 2, Labels: []string{"auto"}, UpdatedAt: now}, both},
+			fmt.Sprintf("%+v", rules[1].Query("bot")): {both, unmentioned},
+		},
+		current: map[int]*BaseIssue{1: auto, 2: relabelled, 3: both, 4: unmentioned},
+	}
+	processor := &recordingProcessor{}
+
+	monitor, err := NewMonitor(MonitorConfig{Config: cfg, Service: service, Processor: processor})
+	if err != nil {
+		t.Fatalf("NewMonitor() error = %v", err)
+	}
+	if err := monitor.checkForIssues(); err != nil {
+		t.Fatalf("checkForIssues() error = %v", err)
+	}
+
+	if want := []int{1, 3}; !reflect.DeepEqual(processor.processed, want) {
+		t.Errorf("processed issues %v, want %v", processor.processed, want)
+	}
+}
+
+// waitingProcessor lists issues waiting on events that don't update them
+type waitingProcessor struct {
+	recordingProcessor
+	waiting []Issue
+}
+
+func (p *waitingProcessor) WaitingIssues() []Issue { return p.waiting }
+
+func TestMonitorChecksWaitingIssues(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Triggers.Rules = []string{"label:auto"}
+
+	// A reaction approving issue 1 didn't update it, so the search doesn't find it again
+	approved := &BaseIssue{Owner: "acme", Repo: "api", Number: 1, Labels: []string{"auto"}, UpdatedAt: time.Now().Add(-48 * time.Hour)}
+	updated := &BaseIssue{Owner: "acme", Repo: "api", Number: 2, Labels: []string{"auto"}, UpdatedAt: time.Now()}
+	unlabelled := &BaseIssue{Owner: "acme", Repo: "api", Number: 3}
+
+	rules, err := TriggerRules(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &fakeService{
+		results: map[string][]Issue{fmt.Sprintf("%+v", rules[0].Query("bot")): {approved, updated}},
+		current: map[int]*BaseIssue{1: approved, 2: updated, 3: unlabelled},
+	}
+	processor := &waitingProcessor{waiting: []Issue{
+		&BaseIssue{Owner: "acme", Repo: "api", Number: 1},
+		&BaseIssue{Owner: "acme", Repo: "api", Number: 2},
+		&BaseIssue{Owner: "acme", Repo: "api", Number: 3},
+	}}
+
+	monitor, err := NewMonitor(MonitorConfig{Config: cfg, Service: service, Processor: processor})
+	if err != nil {
+		t.Fatalf("NewMonitor() error = %v", err)
+	}
+	if err := monitor.checkForIssues(); err != nil {
+		t.Fatalf("checkForIssues() error = %v", err)
+	}
+
+	// Issue 2 is only handled once, and issue 3 no longer matches the rule
+	if want := []int{2, 1}; !reflect.DeepEqual(processor.processed, want) {
+		t.Errorf("processed issues %v, want %v", processor.processed, want)
+	}
+}
+
+func TestNewMonitorRejectsInvalidRules(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Triggers.Rules = []string{"-label:wontfix"}
+
+	if _, err := NewMonitor(MonitorConfig{Config: cfg, Service: &fakeService{}}); err == nil {
+		t.Fatal("NewMonitor() accepted a rule without a trigger")
+	}
+}
+
+func TestMonitorSkipsFilteredRepos(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Triggers.Rules = []string{"label:auto"}
+	cfg.Monitor.RepoFilter = []string{"acme/api"}
+
+	now := time.Now()
+	api := &BaseIssue{Owner: "acme", Repo: "api", Number: 1, Labels: []string{"auto"}, UpdatedAt: now}
+	web := &BaseIssue{Owner: "acme", Repo: "web", Number: 2, Labels: []string{"auto"}, UpdatedAt: now}
+
+	rules, err := TriggerRules(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &fakeService{
+		results: map[string][]Issue{fmt.Sprintf("%+v", rules[0].Query("bot")): {api, web}},
+		current: map[int]*BaseIssue{1: api, 2: web},
+	}
+	processor := &recordingProcessor{}
+
+	monitor, err := NewMonitor(MonitorConfig{Config: cfg, Service: service, Processor: processor})
+	if err != nil {
+		t.Fatalf("NewMonitor() error = %v", err)
+	}
+	if err := monitor.checkForIssues(); err != nil {
+		t.Fatalf("checkForIssues() error = %v", err)
+	}
+
+	if want := []int{1}; !reflect.DeepEqual(processor.processed, want) {
+		t.Errorf("processed issues %v, want %v", processor.processed, want)
+	}
+}
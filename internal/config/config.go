@@ -6,8 +6,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -35,7 +37,7 @@ type Config struct {
 	Monitor struct {
 		PollInterval       int      // in minutes
 		RepoFilter         []string // optional list of repositories to filter on (empty means all)
-		AssignedIssuesOnly bool     // whether to only show issues assigned to the user
+		AssignedIssuesOnly bool     // kept for older config files; Triggers.Rules selects the issues to work on
 		AutoRespond        bool     // whether to automatically respond to issues
 	}
 	Logging struct {
@@ -46,19 +48,75 @@ type Config struct {
 	VCS struct {
 		Platform string // "github", "gitlab", "gogs", etc.
 	}
+	Triage struct {
+		Enabled           bool // whether to score issues and ask clarifying questions before starting work
+		MinScore          int  // minimum clarity/scope/feasibility score (1-5) needed to proceed (default 3)
+		MaxQuestionRounds int  // how many rounds of questions to ask before proceeding anyway (default 2)
+	}
+	Approval struct {
+		Enabled   bool     // whether to post the implementation plan and wait for approval before changing code
+		Repos     []string // repositories ("owner/repo") that need approval (empty with no labels means all)
+		Labels    []string // issue labels that need approval
+		Approvers []string // users allowed to approve (empty means anyone with write access)
+	}
+	GitHubEnterprise struct {
+		WebURL    string // GitHub Enterprise Server web URL, e.g. https://ghe.example.com (empty means github.com)
+		APIURL    string // REST API base URL (default WebURL + "/api/v3/")
+		UploadURL string // upload API base URL (default WebURL + "/api/uploads/")
+		GitHost   string // host used in SSH clone URLs (default the WebURL host)
+	}
+	GitHubOAuth struct {
+		ClientID string // OAuth or GitHub App client ID used to sign in with the device flow
+	}
+	GitHubApp struct {
+		AppID          int64  // GitHub App ID; when set the bot authenticates as the app instead of with GitHub.Token
+		PrivateKeyPath string // path to the app's PEM private key
+		InstallationID int64  // installation for calls that aren't tied to a repository (optional with a single installation)
+		TriggerLabel   string // label that hands an issue to the app, since apps can't be assigned issues (default "useful1")
+	}
+	Repair struct {
+		Enabled     bool // whether to run the agent again when checks fail on the bot's pull requests
+		MaxAttempts int  // repair passes per pull request before handing over to a human (default 2)
+		LogBudget   int  // maximum bytes of job log passed to the agent per failing check (default 20000)
+	}
+	Security struct {
+		TrustedAuthors []string // users whose issue text is always passed to the model and the agent
+		MinPermission  string   // repository role whose issue text is passed on (default "write"; "none" trusts everyone)
+	}
+	Failures struct {
+		MaxAttempts    int    // runs of an issue before handing it over to a human (default 3)
+		RetryDelay     int    // minutes before retrying after a transient failure, doubling with each attempt (default 15)
+		Unassign       bool   // whether the bot unassigns itself when it hands an issue over
+		FallbackUser   string // user mentioned when the bot hands an issue over
+		AssignFallback bool   // whether to also assign the issue to FallbackUser
+	}
+	Triggers struct {
+		Rules []string // rules selecting the issues to work on, e.g. "label:useful1:auto -label:wontfix" (default "assigned")
+	}
+	Metrics struct {
+		Listen string // address serving /metrics, /healthz and /readyz while monitoring, e.g. ":9090" (empty disables it)
+	}
+	Audit struct {
+		Enabled bool   // whether changes to repositories and prompts are recorded in the audit log (default true)
+		Path    string // audit log file (default ~/.useful1/audit.jsonl)
+	}
+	Workspace struct {
+		CloneProtocol    string              // "https" (default) clones with the GitHub token; "ssh" uses the host's SSH key
+		ForkOrganization string              // organization to fork into when the bot can't push (default the bot's account)
+		Root             string              // directory holding repository mirrors and issue worktrees (default ~/.useful1/workspaces)
+		CloneDepth       int                 // shallow clone: fetch only this many commits of history (0 fetches everything)
+		CloneFilter      string              // partial clone filter, e.g. "blob:none" (empty fetches every object)
+		SparsePaths      map[string][]string // directories to check out by "owner/repo" (empty checks out everything)
+		MaxAgeDays       int                 // prune worktrees untouched for this many days (default 14, 0 keeps them)
+		MaxDiskMB        int                 // disk quota for all workspaces; least recently used are pruned beyond it (0 means no quota)
+	}
 }
 
 // LoadConfig loads the configuration from standard locations
 func LoadConfig() (*Config, error) {
 	// Create a default config
 	cfg := &Config{}
-
-	// Set default values
-	cfg.Monitor.PollInterval = 1 // 1 minute
-	cfg.Budgets.Default = 5.0    // Default budget of $5
-	cfg.Logging.Level = "info"   // Default log level
-	cfg.VCS.Platform = "github"  // Default VCS platform
-	cfg.CLI.Timeout = 120        // Default timeout of 120 seconds
+	applyDefaults(cfg)
 
 	// Get config file path using GetConfigPath
 	configFile := GetConfigPath()
@@ -91,10 +149,42 @@ func LoadConfig() (*Config, error) {
 	if token := os.Getenv("USEFUL1_ANTHROPIC_TOKEN"); token != "" {
 		cfg.Anthropic.Token = token
 	}
+	applyGitHubAppEnv(cfg)
 
 	return cfg, nil
 }
 
+// applyDefaults sets the default values; loading a config file afterwards overrides the ones it sets
+func applyDefaults(cfg *Config) {
+	cfg.Monitor.PollInterval = 1 // 1 minute
+	cfg.Budgets.Default = 5.0    // Default budget of $5
+	cfg.Logging.Level = "info"   // Default log level
+	cfg.VCS.Platform = "github"  // Default VCS platform
+	cfg.CLI.Timeout = 120        // Default timeout of 120 seconds
+	cfg.Triage.MinScore = 3      // Ask questions when any triage score is below 3
+	cfg.Triage.MaxQuestionRounds = 2
+	cfg.Repair.MaxAttempts = 2
+	cfg.Repair.LogBudget = 20000
+	cfg.Workspace.MaxAgeDays = 14
+	cfg.Security.MinPermission = "write"
+	cfg.Failures.MaxAttempts = 3
+	cfg.Failures.RetryDelay = 15
+	cfg.Audit.Enabled = true
+}
+
+// applyGitHubAppEnv overrides the GitHub App and OAuth settings from environment variables
+func applyGitHubAppEnv(cfg *Config) {
+	if clientID := os.Getenv("USEFUL1_GITHUB_CLIENT_ID"); clientID != "" {
+		cfg.GitHubOAuth.ClientID = clientID
+	}
+	if appID, err := strconv.ParseInt(os.Getenv("USEFUL1_GITHUB_APP_ID"), 10, 64); err == nil {
+		cfg.GitHubApp.AppID = appID
+	}
+	if keyPath := os.Getenv("USEFUL1_GITHUB_APP_PRIVATE_KEY_PATH"); keyPath != "" {
+		cfg.GitHubApp.PrivateKeyPath = keyPath
+	}
+}
+
 // GetConfigPath returns the path to the config file
 func GetConfigPath() string {
 	return filepath.Join(os.Getenv("HOME"), ".useful1", "config.json")
@@ -123,6 +213,7 @@ func decodeCredentials(value string) (string, error) {
 // Load loads configuration from files, environment variables, etc.
 func Load() (*Config, error) {
 	config := &Config{}
+	applyDefaults(config)
 	configPath := GetConfigPath()
 
 	// Check if config file exists
@@ -173,6 +264,8 @@ func Load() (*Config, error) {
 		config.Anthropic.Token = envToken
 	}
 
+	applyGitHubAppEnv(config)
+
 	// Validate the configuration
 	if err := validateConfig(config); err != nil {
 		return nil, err
@@ -183,10 +276,14 @@ func Load() (*Config, error) {
 
 // validateConfig checks if the required configuration is present
 func validateConfig(config *Config) error {
-	if config.GitHub.Token == "" {
+	if config.GitHub.Token == "" && !config.UsesGitHubApp() {
 		return fmt.Errorf("github token is required")
 	}
 
+	if config.UsesGitHubApp() && config.GitHubApp.PrivateKeyPath == "" {
+		return fmt.Errorf("github app private key path is required")
+	}
+
 	if config.Anthropic.Token == "" {
 		return fmt.Errorf("anthropic token is required")
 	}
@@ -198,6 +295,85 @@ func validateConfig(config *Config) error {
 	return nil
 }
 
+// RunBudget returns the budget in USD for an agent run: the PR creation budget, or else
+// the default budget. Zero means no limit.
+func (c *Config) RunBudget() float64 {
+	if c.Budgets.PRCreation > 0 {
+		return c.Budgets.PRCreation
+	}
+	return c.Budgets.Default
+}
+
+// UsesGitHubEnterprise reports whether the bot talks to a GitHub Enterprise Server instead of github.com
+func (c *Config) UsesGitHubEnterprise() bool {
+	return c != nil && (c.GitHubEnterprise.WebURL != "" || c.GitHubEnterprise.APIURL != "")
+}
+
+// GitHubWebURL returns the GitHub web URL without a trailing slash
+func (c *Config) GitHubWebURL() string {
+	if c == nil || c.GitHubEnterprise.WebURL == "" {
+		return "https://github.com"
+	}
+	return strings.TrimSuffix(c.GitHubEnterprise.WebURL, "/")
+}
+
+// GitHubAPIURL returns the REST API base URL with a trailing slash, or "" for github.com
+func (c *Config) GitHubAPIURL() string {
+	switch {
+	case c == nil:
+		return ""
+	case c.GitHubEnterprise.APIURL != "":
+		return strings.TrimSuffix(c.GitHubEnterprise.APIURL, "/") + "/"
+	case c.GitHubEnterprise.WebURL != "":
+		return c.GitHubWebURL() + "/api/v3/"
+	}
+	return ""
+}
+
+// GitHubUploadURL returns the upload API base URL with a trailing slash, or "" for github.com
+func (c *Config) GitHubUploadURL() string {
+	switch {
+	case c == nil:
+		return ""
+	case c.GitHubEnterprise.UploadURL != "":
+		return strings.TrimSuffix(c.GitHubEnterprise.UploadURL, "/") + "/"
+	case c.GitHubEnterprise.WebURL != "":
+		return c.GitHubWebURL() + "/api/uploads/"
+	case strings.HasSuffix(c.GitHubAPIURL(), "/api/v3/"):
+		return strings.TrimSuffix(c.GitHubAPIURL(), "/api/v3/") + "/api/uploads/"
+	}
+	return ""
+}
+
+// GitHubGitHost returns the host used in SSH clone URLs
+func (c *Config) GitHubGitHost() string {
+	if c != nil && c.GitHubEnterprise.GitHost != "" {
+		return c.GitHubEnterprise.GitHost
+	}
+	if u, err := url.Parse(c.GitHubWebURL()); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return "github.com"
+}
+
+// UsesSSHClone reports whether repositories are cloned over SSH instead of HTTPS with the GitHub token
+func (c *Config) UsesSSHClone() bool {
+	return c != nil && strings.EqualFold(c.Workspace.CloneProtocol, "ssh")
+}
+
+// GitHubCloneURL returns the URL repositories are cloned from
+func (c *Config) GitHubCloneURL(owner, repo string) string {
+	if c.UsesSSHClone() {
+		return fmt.Sprintf("git@%s:%s/%s.git", c.GitHubGitHost(), owner, repo)
+	}
+	return fmt.Sprintf("%s/%s/%s.git", c.GitHubWebURL(), owner, repo)
+}
+
+// UsesGitHubApp reports whether the bot authenticates as a GitHub App
+func (c *Config) UsesGitHubApp() bool {
+	return c.GitHubApp.AppID != 0
+}
+
 // SaveToFile saves the configuration to the specified file path
 func (c *Config) SaveToFile(filePath string) error {
 	// Create the directory if it doesn't exist
@@ -220,6 +396,34 @@ func (c *Config) SaveToFile(filePath string) error {
 	return nil
 }
 
+// SaveGitHubLogin stores a GitHub token obtained by signing in, keeping the rest of the
+// config file as it is
+func SaveGitHubLogin(token, user, clientID string) error {
+	cfg := &Config{}
+	applyDefaults(cfg)
+	configPath := GetConfigPath()
+
+	// Credentials stay encoded since the file is written back as read
+	data, err := os.ReadFile(configPath)
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("unable to decode config: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	cfg.GitHub.Token = encodeCredentials(token)
+	cfg.GitHub.User = user
+	cfg.GitHubOAuth.ClientID = clientID
+
+	if cfg.CLI.Command == "" {
+		cfg.CLI.Command = "claude --dangerously-skip-permissions"
+	}
+
+	return cfg.SaveToFile(configPath)
+}
+
 // Configurator helps build and save configuration
 type Configurator struct {
 	config Config
@@ -227,9 +431,9 @@ type Configurator struct {
 
 // NewConfigurator creates a new configurator
 func NewConfigurator() *Configurator {
-	return &Configurator{
-		config: Config{},
-	}
+	c := &Configurator{}
+	applyDefaults(&c.config)
+	return c
 }
 
 // SetGitHubToken sets the GitHub token
@@ -242,6 +446,11 @@ func (c *Configurator) SetGitHubUser(user string) {
 	c.config.GitHub.User = user
 }
 
+// SetGitHubOAuthClientID sets the client ID used to sign in with the device flow
+func (c *Configurator) SetGitHubOAuthClientID(clientID string) {
+	c.config.GitHubOAuth.ClientID = clientID
+}
+
 // SetAnthropicToken sets the Anthropic token
 func (c *Configurator) SetAnthropicToken(token string) {
 	c.config.Anthropic.Token = token
@@ -271,8 +480,7 @@ func (c *Configurator) SetCLIToolPath(path string) {
 func (c *Configurator) SetMonitoringSettings(interval int, repoFilter []string, assignedOnly bool) {
 	c.config.Monitor.PollInterval = interval
 	c.config.Monitor.RepoFilter = repoFilter
-	// Always set to true, we only want to monitor assigned issues
-	c.config.Monitor.AssignedIssuesOnly = true
+	c.config.Monitor.AssignedIssuesOnly = assignedOnly
 }
 
 // Save saves the configuration to the user's home directory
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -185,3 +187,106 @@ func TestConfigurator(t *testing.T) {
 			configurator.config.Monitor.RepoFilter, []string{"repo1", "repo2"})
 	}
 }
+
+func TestGitHubEnterpriseURLs(t *testing.T) {
+	tests := []struct {
+		name       string
+		web        string
+		api        string
+		wantWeb    string
+		wantAPI    string
+		wantUpload string
+		wantHost   string
+	}{
+		{
+			name:     "github.com",
+			wantWeb:  "https://github.com",
+			wantHost: "github.com",
+		},
+		{
+			name:       "enterprise server",
+			web:        "https://ghe.example.com/",
+			wantWeb:    "https://ghe.example.com",
+			wantAPI:    "https://ghe.example.com/api/v3/",
+			wantUpload: "https://ghe.example.com/api/uploads/",
+			wantHost:   "ghe.example.com",
+		},
+		{
+			name:       "path prefix with explicit API",
+			web:        "https://example.com/github",
+			api:        "https://example.com/github/api/v3",
+			wantWeb:    "https://example.com/github",
+			wantAPI:    "https://example.com/github/api/v3/",
+			wantUpload: "https://example.com/github/api/uploads/",
+			wantHost:   "example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.GitHubEnterprise.WebURL = tt.web
+			cfg.GitHubEnterprise.APIURL = tt.api
+
+			if got := cfg.GitHubWebURL(); got != tt.wantWeb {
+				t.Errorf("GitHubWebURL() = %q, want %q", got, tt.wantWeb)
+			}
+			if got := cfg.GitHubAPIURL(); got != tt.wantAPI {
+				t.Errorf("GitHubAPIURL() = %q, want %q", got, tt.wantAPI)
+			}
+			if got := cfg.GitHubUploadURL(); got != tt.wantUpload {
+				t.Errorf("GitHubUploadURL() = %q, want %q", got, tt.wantUpload)
+			}
+			if got := cfg.GitHubGitHost(); got != tt.wantHost {
+				t.Errorf("GitHubGitHost() = %q, want %q", got, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestLoadAppliesDefaults(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+
+	// A config file written before the audit log existed
+	file := `{
+  "GitHub": {"Token": "` + encodeCredentials("github-token") + `", "User": "bot"},
+  "Anthropic": {"Token": "` + encodeCredentials("anthropic-token") + `"},
+  "CLI": {"Command": "claude"}
+}`
+	if err := os.MkdirAll(filepath.Join(home, ".useful1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(GetConfigPath(), []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.Audit.Enabled {
+		t.Error("Audit.Enabled = false, want true by default")
+	}
+}
+
+func TestConfiguratorSavesDefaults(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	configurator := NewConfigurator()
+	configurator.SetGitHubToken("github-token")
+	configurator.SetAnthropicToken("anthropic-token")
+	if err := configurator.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.Audit.Enabled || cfg.Workspace.MaxAgeDays != 14 {
+		t.Errorf("saved config has Audit.Enabled %v and MaxAgeDays %d, want the defaults", cfg.Audit.Enabled, cfg.Workspace.MaxAgeDays)
+	}
+}
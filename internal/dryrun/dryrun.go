@@ -0,0 +1,142 @@
+// Package dryrun records the changes the bot would make to repositories and issues
+// instead of making them, so prompts and settings can be tried safely.
+package dryrun
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kind is the kind of change an action would have made
+type Kind string
+
+// Kinds of recorded actions
+const (
+	KindComment     Kind = "comment"
+	KindCommentEdit Kind = "comment edit"
+	KindReviewReply Kind = "review reply"
+	KindAssign      Kind = "assign"
+	KindUnassign    Kind = "unassign"
+	KindBranch      Kind = "branch"
+	KindFork        Kind = "fork"
+	KindPush        Kind = "push"
+	KindPullRequest Kind = "pull request"
+)
+
+// Action is a change that would have been made
+type Action struct {
+	ID      string    `json:"id"` // identifies the action, e.g. as a stand-in comment ID
+	Kind    Kind      `json:"kind"`
+	Repo    string    `json:"repo"`             // "owner/repo"
+	Number  int       `json:"number,omitempty"` // issue or pull request number, zero if there is none
+	Summary string    `json:"summary"`          // one line describing the change
+	Body    string    `json:"body,omitempty"`   // text that would have been posted or pushed, if any
+	At      time.Time `json:"at"`
+}
+
+// Recorder collects the actions of a dry run. It is safe for concurrent use.
+type Recorder struct {
+	mutex   sync.Mutex
+	actions []Action
+}
+
+// NewRecorder creates an empty recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record adds an action and returns its ID
+func (r *Recorder) Record(action Action) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	action.ID = fmt.Sprintf("dry-run-%d", len(r.actions)+1)
+	if action.At.IsZero() {
+		action.At = time.Now()
+	}
+	r.actions = append(r.actions, action)
+	return action.ID
+}
+
+// Edit replaces the body of a recorded action, so a comment that is edited as a run goes
+// on shows up once with its final text. It reports whether the action was found.
+func (r *Recorder) Edit(id, body string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.actions {
+		if r.actions[i].ID == id {
+			r.actions[i].Body = body
+			return true
+		}
+	}
+	return false
+}
+
+// Actions returns a copy of the recorded actions in the order they were taken
+func (r *Recorder) Actions() []Action {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]Action(nil), r.actions...)
+}
+
+// WriteReport writes a Markdown report of the recorded actions, grouped by issue
+func (r *Recorder) WriteReport(w io.Writer) error {
+	actions := r.Actions()
+
+	var b strings.Builder
+	b.WriteString("# Dry run report\n\n")
+	if len(actions) == 0 {
+		b.WriteString("Nothing would have been posted or pushed.\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+	b.WriteString(fmt.Sprintf("%d changes would have been made.\n", len(actions)))
+
+	// Keep issues in the order they were first touched
+	var targets []string
+	groups := make(map[string][]Action)
+	for _, action := range actions {
+		target := action.Repo
+		if action.Number > 0 {
+			target = fmt.Sprintf("%s#%d", action.Repo, action.Number)
+		}
+		if _, ok := groups[target]; !ok {
+			targets = append(targets, target)
+		}
+		groups[target] = append(groups[target], action)
+	}
+
+	for _, target := range targets {
+		b.WriteString(fmt.Sprintf("\n## %s\n", target))
+		for _, action := range groups[target] {
+			b.WriteString(fmt.Sprintf("\n### %s: %s\n", action.Kind, action.Summary))
+			if action.Body != "" {
+				fence := codeFence(action.Body)
+				b.WriteString(fmt.Sprintf("\n%s\n%s\n%s\n", fence, strings.TrimRight(action.Body, "\n"), fence))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// codeFence returns a fence longer than any run of backticks in text, so bodies that
+// contain code blocks themselves are shown as they are
+func codeFence(text string) string {
+	longest, run := 0, 0
+	for _, c := range text {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
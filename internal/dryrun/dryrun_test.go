@@ -0,0 +1,69 @@
+package dryrun
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+)
+
+// readService only answers reads; any write reaching it panics through the nil embedded service
+type readService struct {
+	vcs.Service
+}
+
+func (readService) GetDefaultBranch(owner, repo string) (string, error) {
+	return "main", nil
+}
+
+func TestServiceRecordsChanges(t *testing.T) {
+	recorder := NewRecorder()
+	service := NewService(readService{}, recorder)
+
+	if branch, err := service.GetDefaultBranch("octo", "demo"); err != nil || branch != "main" {
+		t.Fatalf("GetDefaultBranch() = %q, %v; want reads passed through", branch, err)
+	}
+
+	if err := service.CreateBranch("octo", "demo", "fix/login", "main"); err != nil {
+		t.Fatal(err)
+	}
+	id, err := service.CreateComment("octo", "demo", 7, "Analyzing the issue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := service.UpdateComment("octo", "demo", id, "Opened pull request"); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.RespondToIssue("octo", "demo", 7, "Here is the fix:\n```go\nreturn nil\n```"); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.AssignIssue("octo", "demo", 7, []string{"maintainer"}); err != nil {
+		t.Fatal(err)
+	}
+
+	actions := recorder.Actions()
+	if len(actions) != 4 {
+		t.Fatalf("recorded %d actions, want 4: %+v", len(actions), actions)
+	}
+	// Edits of a recorded comment update it instead of adding another action
+	if actions[1].Kind != KindComment || actions[1].Body != "Opened pull request" {
+		t.Errorf("status comment = %+v, want its final text", actions[1])
+	}
+
+	var report strings.Builder
+	if err := recorder.WriteReport(&report); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"4 changes would have been made",
+		"## octo/demo\n",
+		"### branch: create `fix/login` from `main`",
+		"## octo/demo#7",
+		"### assign: assign @maintainer",
+		"````\nHere is the fix:\n```go\nreturn nil\n```\n````",
+	} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report is missing %q:\n%s", want, report.String())
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package dryrun
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+)
+
+// Service wraps a vcs.Service, passing reads through and recording every call that
+// would change a repository or issue
+type Service struct {
+	vcs.Service
+	recorder *Recorder
+}
+
+// NewService returns a service that records its changes in recorder instead of making them
+func NewService(service vcs.Service, recorder *Recorder) *Service {
+	return &Service{Service: service, recorder: recorder}
+}
+
+// RespondToIssue records a comment
+func (s *Service) RespondToIssue(owner, repo string, issueNumber int, comment string) error {
+	s.recorder.Record(Action{Kind: KindComment, Repo: owner + "/" + repo, Number: issueNumber, Summary: "post a comment", Body: comment})
+	return nil
+}
+
+// CreateComment records a comment and returns a stand-in ID that UpdateComment accepts
+func (s *Service) CreateComment(owner, repo string, issueNumber int, body string) (string, error) {
+	return s.recorder.Record(Action{Kind: KindComment, Repo: owner + "/" + repo, Number: issueNumber, Summary: "post a comment", Body: body}), nil
+}
+
+// UpdateComment replaces the text of a comment recorded earlier, or records an edit of an
+// existing comment
+func (s *Service) UpdateComment(owner, repo, commentID, body string) error {
+	if s.recorder.Edit(commentID, body) {
+		return nil
+	}
+	s.recorder.Record(Action{Kind: KindCommentEdit, Repo: owner + "/" + repo, Summary: "edit comment " + commentID, Body: body})
+	return nil
+}
+
+// AssignIssue records new assignees
+func (s *Service) AssignIssue(owner, repo string, issueNumber int, assignees []string) error {
+	s.recorder.Record(Action{Kind: KindAssign, Repo: owner + "/" + repo, Number: issueNumber, Summary: "assign " + mentions(assignees)})
+	return nil
+}
+
+// UnassignIssue records removed assignees
+func (s *Service) UnassignIssue(owner, repo string, issueNumber int, assignees []string) error {
+	s.recorder.Record(Action{Kind: KindUnassign, Repo: owner + "/" + repo, Number: issueNumber, Summary: "unassign " + mentions(assignees)})
+	return nil
+}
+
+// CreateBranch records a new branch
+func (s *Service) CreateBranch(owner, repo, branchName, baseBranch string) error {
+	s.recorder.Record(Action{Kind: KindBranch, Repo: owner + "/" + repo, Summary: fmt.Sprintf("create `%s` from `%s`", branchName, baseBranch)})
+	return nil
+}
+
+// CreateDraftPullRequest records a pull request and returns it without a number
+func (s *Service) CreateDraftPullRequest(owner, repo, title, body, head, base string) (vcs.PullRequest, error) {
+	s.recorder.Record(Action{Kind: KindPullRequest, Repo: owner + "/" + repo,
+		Summary: fmt.Sprintf("open draft %q from `%s` into `%s`", title, head, base), Body: body})
+	return &vcs.BasePullRequest{
+		Owner:      owner,
+		Repo:       repo,
+		Title:      title,
+		Body:       body,
+		State:      "open",
+		IsDraft:    true,
+		HeadBranch: head,
+		BaseBranch: base,
+	}, nil
+}
+
+// ReplyToReviewComment records a reply in a review thread
+func (s *Service) ReplyToReviewComment(owner, repo string, number int, commentID, body string) error {
+	s.recorder.Record(Action{Kind: KindReviewReply, Repo: owner + "/" + repo, Number: number, Summary: "reply to review comment " + commentID, Body: body})
+	return nil
+}
+
+// RateLimits passes through the wrapped service's rate limits, if it tracks them
+func (s *Service) RateLimits() []vcs.RateLimit {
+	if reporter, ok := s.Service.(vcs.RateLimitReporter); ok {
+		return reporter.RateLimits()
+	}
+	return nil
+}
+
+// mentions formats usernames as a list of @mentions
+func mentions(users []string) string {
+	return "@" + strings.Join(users, ", @")
+}
@@ -0,0 +1,147 @@
+// Package events carries what the monitor and the pipeline are doing to whoever is
+// watching: the NDJSON output of the CLI, the TUI or a wrapper script.
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+)
+
+// Type is the kind of an event
+type Type string
+
+// Types of events
+const (
+	TypePollFinished    Type = "poll_finished"    // the monitor checked for issues, with a PollStats in Data
+	TypeIssueDiscovered Type = "issue_discovered" // the monitor handed an issue to the pipeline
+	TypeStageStarted    Type = "stage_started"    // a run reached a stage
+	TypeStageFinished   Type = "stage_finished"   // a run moved past a stage, or stopped in it with Error set
+	TypeAgentFinished   Type = "agent_finished"   // the agent CLI exited, with Error set if it failed
+	TypeCostIncurred    Type = "cost_incurred"    // a run spent money on the bot's own model calls
+	TypePRCreated       Type = "pr_created"       // a run opened a pull request
+	TypeBranchPushed    Type = "branch_pushed"    // a run pushed its branch without opening a pull request
+	TypeError           Type = "error"            // something failed, as told by Category; Issue is empty if it wasn't about one issue
+	TypeStatus          Type = "status"           // the command changed state, such as "running" or "stopping"
+	TypeDryRun          Type = "dry_run"          // the changes a dry run recorded, in Data
+)
+
+// Event is something that happened. Only the fields that apply to its type are set.
+type Event struct {
+	Type     Type      `json:"type"`
+	Time     time.Time `json:"time"`
+	Issue    string    `json:"issue,omitempty"` // "owner/repo#n"
+	Title    string    `json:"title,omitempty"`
+	Stage    string    `json:"stage,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+	Branch   string    `json:"branch,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	Status   string    `json:"status,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Category string    `json:"category,omitempty"`         // what kind of failure an error event is, e.g. "search" or "transient"
+	CostUSD  float64   `json:"cost_usd,omitempty"`         // spent since the previous cost_incurred event of the run
+	TotalUSD float64   `json:"total_cost_usd,omitempty"`   // spent by the run so far
+	Elapsed  float64   `json:"elapsed_seconds,omitempty"`  // time since the run started
+	Duration float64   `json:"duration_seconds,omitempty"` // time a finished stage or agent run took
+	Data     any       `json:"data,omitempty"`             // e.g. monitor stats or dry-run actions
+}
+
+// PollStats counts the issues a poll of the monitor found
+type PollStats struct {
+	Found     int `json:"found"`     // issues matching a trigger rule's search
+	Skipped   int `json:"skipped"`   // found but not handed to the pipeline: filtered out, unchanged or deferred
+	Processed int `json:"processed"` // handed to the pipeline
+}
+
+// Summary describes the event in a line of text, leaving out the issue, or returns ""
+// for events that only matter to programs, such as stage_finished and cost_incurred
+func (e Event) Summary() string {
+	switch e.Type {
+	case TypeIssueDiscovered:
+		return "Picked up: " + e.Title
+	case TypeStageStarted:
+		if e.Detail != "" {
+			return e.Stage + ": " + e.Detail
+		}
+		return e.Stage
+	case TypePRCreated:
+		return "Opened pull request " + e.URL
+	case TypeBranchPushed:
+		return "Pushed " + e.Branch
+	case TypeError:
+		if e.Message != "" && e.Error != "" {
+			return e.Message + ": " + e.Error
+		}
+		return "Failed: " + e.Message + e.Error
+	case TypeStatus:
+		return e.Message
+	default:
+		return ""
+	}
+}
+
+// Bus passes events to its subscribers. It is safe for concurrent use, and a nil *Bus
+// drops everything published to it, so publishers don't need to check for one.
+type Bus struct {
+	mutex       sync.RWMutex
+	subscribers map[int]func(Event)
+	next        int
+}
+
+// NewBus creates a bus without subscribers
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[int]func(Event))}
+}
+
+// Subscribe calls handler with every event published from now on, until the returned
+// function is called. Handlers run on the publisher's goroutine, so they should be quick.
+func (b *Bus) Subscribe(handler func(Event)) (unsubscribe func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	id := b.next
+	b.next++
+	b.subscribers[id] = handler
+
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// Publish passes an event to every subscriber, stamping it with the current time if it
+// has none
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for _, handler := range b.subscribers {
+		handler(event)
+	}
+}
+
+// NDJSON returns a handler that writes each event to w as a line of JSON. Lines are
+// never interleaved, even when events are published concurrently.
+func NDJSON(w io.Writer) func(Event) {
+	var mutex sync.Mutex
+	encoder := json.NewEncoder(w)
+
+	return func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if err := encoder.Encode(event); err != nil {
+			logging.Error("Failed to write event", "type", event.Type, "error", err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNDJSONWritesValidLines(t *testing.T) {
+	var out bytes.Buffer
+	bus := NewBus()
+	unsubscribe := bus.Subscribe(NDJSON(&out))
+
+	bus.Publish(Event{Type: TypeError, Issue: "octo/demo#7", Error: `unexpected "}" in body` + "\nat line 2"})
+	bus.Publish(Event{Type: TypeStatus, Status: "running", Data: map[string]int{"issues": 3}})
+	unsubscribe()
+	bus.Publish(Event{Type: TypeStatus, Status: "stopping"})
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrote %d lines, want 2:\n%s", len(lines), out.String())
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatalf("invalid JSON %q: %v", lines[0], err)
+	}
+	if event.Type != TypeError || event.Error != `unexpected "}" in body`+"\nat line 2" || event.Time.IsZero() {
+		t.Errorf("unexpected event: %+v", event)
+	}
+}
+
+func TestNilBusDropsEvents(t *testing.T) {
+	var bus *Bus
+	bus.Publish(Event{Type: TypeStatus})
+}
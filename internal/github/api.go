@@ -0,0 +1,619 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/hellausefulsoftware/useful1/internal/audit"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/models"
+)
+
+const (
+	// perPage is the page size for list and search requests, the most GitHub allows
+	perPage = 100
+
+	// searchLimit is the most results GitHub returns for one search query
+	searchLimit = 1000
+)
+
+// API is the single layer issues, comments, pull requests and repositories are read and
+// written through. It follows pagination and only reads fields through their nil-safe
+// getters, so issues with empty bodies or deleted authors don't need special handling.
+type API struct {
+	client *github.Client
+}
+
+// NewAPI creates the data-access layer on top of an authenticated GitHub client
+func NewAPI(client *github.Client) *API {
+	return &API{client: client}
+}
+
+// Issue gets an issue without its comments
+func (a *API) Issue(ctx context.Context, owner, repo string, number int) (*github.Issue, error) {
+	issue, _, err := a.client.Issues.Get(ctx, owner, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issue: %w", err)
+	}
+	return issue, nil
+}
+
+// IssueComments lists every comment on an issue, oldest first
+func (a *API) IssueComments(ctx context.Context, owner, repo string, number int) ([]*github.IssueComment, error) {
+	return collect(a.IssueCommentsIter(ctx, owner, repo, number), 0)
+}
+
+// IssueCommentsIter streams the comments on an issue, oldest first
+func (a *API) IssueCommentsIter(ctx context.Context, owner, repo string, number int) iter.Seq2[*github.IssueComment, error] {
+	return pages(func(page int) ([]*github.IssueComment, *github.Response, error) {
+		opts := &github.IssueListCommentsOptions{
+			Sort:        github.String("created"),
+			Direction:   github.String("asc"),
+			ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+		}
+		comments, resp, err := a.client.Issues.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list issue comments: %w", err)
+		}
+		return comments, resp, nil
+	})
+}
+
+// IssueWithComments gets an issue with all its comments
+func (a *API) IssueWithComments(ctx context.Context, owner, repo string, number int) (*models.Issue, error) {
+	issue, err := a.Issue(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := a.IssueComments(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	result := ConvertIssue(issue, owner, repo)
+	for _, comment := range comments {
+		result.Comments = append(result.Comments, convertComment(comment))
+	}
+	return result, nil
+}
+
+// OpenIssues lists the open issues of a repository. Like the GitHub API, it includes open pull requests.
+func (a *API) OpenIssues(ctx context.Context, owner, repo string) ([]*github.Issue, error) {
+	return collect(a.OpenIssuesIter(ctx, owner, repo), 0)
+}
+
+// OpenIssuesIter streams the open issues and pull requests of a repository
+func (a *API) OpenIssuesIter(ctx context.Context, owner, repo string) iter.Seq2[*github.Issue, error] {
+	return pages(func(page int) ([]*github.Issue, *github.Response, error) {
+		opts := &github.IssueListByRepoOptions{
+			State:       "open",
+			ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+		}
+		issues, resp, err := a.client.Issues.ListByRepo(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list issues: %w", err)
+		}
+		return issues, resp, nil
+	})
+}
+
+// SearchIssues returns up to limit issues and pull requests matching query, sorted by sort
+// in descending order when it is set. A limit of zero or less returns every result.
+func (a *API) SearchIssues(ctx context.Context, query, sort string, limit int) ([]*github.Issue, error) {
+	return collect(a.SearchIssuesIter(ctx, query, sort, "desc"), limit)
+}
+
+// SearchIssuesIter streams the issues and pull requests matching query, sorted by sort in
+// order ("asc" or "desc") when sort is set. GitHub returns at most searchLimit results
+// for a query.
+func (a *API) SearchIssuesIter(ctx context.Context, query, sort, order string) iter.Seq2[*github.Issue, error] {
+	return pages(func(page int) ([]*github.Issue, *github.Response, error) {
+		opts := &github.SearchOptions{ListOptions: github.ListOptions{Page: page, PerPage: perPage}}
+		if sort != "" {
+			opts.Sort = sort
+			opts.Order = order
+		}
+		result, resp, err := a.client.Search.Issues(ctx, query, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to search issues: %w", err)
+		}
+		return result.Issues, resp, nil
+	})
+}
+
+// SearchIssuesUpdatedSince streams the issues and pull requests matching query that were
+// updated after since, least recently updated first. Since GitHub ends a search after
+// searchLimit results, a search reaching it continues from the last update time seen.
+func (a *API) SearchIssuesUpdatedSince(ctx context.Context, query string, since time.Time) iter.Seq2[*github.Issue, error] {
+	return func(yield func(*github.Issue, error) bool) {
+		qualifier := "updated:>" + since.UTC().Format(time.RFC3339)
+		var last time.Time
+		atLast := make(map[int64]bool) // results yielded that were updated at last
+
+		for {
+			count, progressed := 0, false
+			for issue, err := range a.SearchIssuesIter(ctx, query+" "+qualifier, "updated", "asc") {
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+				count++
+
+				// The next search starts at the last update time, so skip what it repeats
+				if atLast[issue.GetID()] {
+					continue
+				}
+				progressed = true
+
+				if updated := issue.GetUpdatedAt(); !updated.Equal(last) {
+					last = updated
+					atLast = make(map[int64]bool)
+				}
+				atLast[issue.GetID()] = true
+
+				if !yield(issue, nil) {
+					return
+				}
+			}
+
+			if count < searchLimit {
+				return
+			}
+			if !progressed {
+				logging.Warn("Search results share one update time, some were skipped", "query", query, "updated", last)
+				return
+			}
+			qualifier = "updated:>=" + last.UTC().Format(time.RFC3339)
+		}
+	}
+}
+
+// CreateComment posts a comment on an issue or pull request
+func (a *API) CreateComment(ctx context.Context, owner, repo string, number int, body string) (*github.IssueComment, error) {
+	comment, _, err := a.client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{
+		Body: github.String(body),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create issue comment: %w", err)
+	}
+	audit.Record(audit.Entry{
+		Action:  audit.ActionComment,
+		Repo:    owner + "/" + repo,
+		Number:  number,
+		Ref:     strconv.FormatInt(comment.GetID(), 10),
+		Summary: comment.GetHTMLURL(),
+		Body:    body,
+	})
+	return comment, nil
+}
+
+// EditComment replaces the body of an issue or pull request comment
+func (a *API) EditComment(ctx context.Context, owner, repo string, id int64, body string) (*github.IssueComment, error) {
+	comment, _, err := a.client.Issues.EditComment(ctx, owner, repo, id, &github.IssueComment{
+		Body: github.String(body),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit issue comment: %w", err)
+	}
+	audit.Record(audit.Entry{
+		Action:  audit.ActionCommentEdit,
+		Repo:    owner + "/" + repo,
+		Number:  issueNumberFromURL(comment.GetIssueURL()),
+		Ref:     strconv.FormatInt(id, 10),
+		Summary: comment.GetHTMLURL(),
+		Body:    body,
+	})
+	return comment, nil
+}
+
+// AddAssignees assigns users to an issue
+func (a *API) AddAssignees(ctx context.Context, owner, repo string, number int, assignees []string) error {
+	if _, _, err := a.client.Issues.AddAssignees(ctx, owner, repo, number, assignees); err != nil {
+		return fmt.Errorf("failed to add assignees: %w", err)
+	}
+	audit.Record(audit.Entry{Action: audit.ActionAssign, Repo: owner + "/" + repo, Number: number, Summary: strings.Join(assignees, ", ")})
+	return nil
+}
+
+// RemoveAssignees unassigns users from an issue
+func (a *API) RemoveAssignees(ctx context.Context, owner, repo string, number int, assignees []string) error {
+	if _, _, err := a.client.Issues.RemoveAssignees(ctx, owner, repo, number, assignees); err != nil {
+		return fmt.Errorf("failed to remove assignees: %w", err)
+	}
+	audit.Record(audit.Entry{Action: audit.ActionUnassign, Repo: owner + "/" + repo, Number: number, Summary: strings.Join(assignees, ", ")})
+	return nil
+}
+
+// UserPermission returns the role a user has on a repository: admin, maintain, write, triage, read or none
+func (a *API) UserPermission(ctx context.Context, owner, repo, username string) (string, error) {
+	level, _, err := a.client.Repositories.GetPermissionLevel(ctx, owner, repo, username)
+	if err != nil {
+		return "", fmt.Errorf("failed to get permission level: %w", err)
+	}
+	return level.GetPermission(), nil
+}
+
+// PullRequest gets a pull request
+func (a *API) PullRequest(ctx context.Context, owner, repo string, number int) (*github.PullRequest, error) {
+	pr, _, err := a.client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pull request: %w", err)
+	}
+	return pr, nil
+}
+
+// PullRequests lists the repository's pull requests in the given state
+func (a *API) PullRequests(ctx context.Context, owner, repo, state string) ([]*github.PullRequest, error) {
+	return collect(a.PullRequestsIter(ctx, owner, repo, state, ""), 0)
+}
+
+// PullRequestsIter streams the repository's pull requests in the given state, most
+// recently updated first when sort is "updated" and newest first otherwise
+func (a *API) PullRequestsIter(ctx context.Context, owner, repo, state, sort string) iter.Seq2[*github.PullRequest, error] {
+	return pages(func(page int) ([]*github.PullRequest, *github.Response, error) {
+		opts := &github.PullRequestListOptions{
+			State:       state,
+			Sort:        sort,
+			ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+		}
+		if sort != "" {
+			opts.Direction = "desc"
+		}
+		prs, resp, err := a.client.PullRequests.List(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list pull requests: %w", err)
+		}
+		return prs, resp, nil
+	})
+}
+
+// PullRequestsByNumber returns the full pull requests for numbers, typically search hits,
+// which lack branches and draft state. Rather than fetching each one it lists the
+// repository's pull requests, most recently updated first, until all are found.
+func (a *API) PullRequestsByNumber(ctx context.Context, owner, repo, state string, numbers []int) ([]*github.PullRequest, error) {
+	if len(numbers) == 0 {
+		return nil, nil
+	}
+
+	wanted := make(map[int]bool, len(numbers))
+	for _, number := range numbers {
+		wanted[number] = true
+	}
+
+	found := make(map[int]*github.PullRequest, len(numbers))
+	for pr, err := range a.PullRequestsIter(ctx, owner, repo, state, "updated") {
+		if err != nil {
+			return nil, err
+		}
+		if wanted[pr.GetNumber()] {
+			found[pr.GetNumber()] = pr
+		}
+		if len(found) == len(wanted) {
+			break
+		}
+	}
+
+	prs := make([]*github.PullRequest, 0, len(found))
+	for _, number := range numbers {
+		if pr, ok := found[number]; ok {
+			prs = append(prs, pr)
+			delete(found, number)
+		}
+	}
+	return prs, nil
+}
+
+// CreatePullRequest opens a pull request
+func (a *API) CreatePullRequest(ctx context.Context, owner, repo string, newPR *github.NewPullRequest) (*github.PullRequest, error) {
+	pr, _, err := a.client.PullRequests.Create(ctx, owner, repo, newPR)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pull request: %w", err)
+	}
+	audit.Record(audit.Entry{
+		Action:  audit.ActionPullRequest,
+		Repo:    owner + "/" + repo,
+		Number:  pr.GetNumber(),
+		Ref:     pr.GetHTMLURL(),
+		Summary: fmt.Sprintf("%s (%s into %s)", pr.GetTitle(), newPR.GetHead(), newPR.GetBase()),
+		Body:    newPR.GetBody(),
+	})
+	return pr, nil
+}
+
+// ReplyToReviewComment replies in the thread of an inline review comment on a pull request
+func (a *API) ReplyToReviewComment(ctx context.Context, owner, repo string, number int, commentID int64, body string) (*github.PullRequestComment, error) {
+	reply, _, err := a.client.PullRequests.CreateCommentInReplyTo(ctx, owner, repo, number, body, commentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reply to review comment: %w", err)
+	}
+	audit.Record(audit.Entry{
+		Action:  audit.ActionReviewReply,
+		Repo:    owner + "/" + repo,
+		Number:  number,
+		Ref:     strconv.FormatInt(reply.GetID(), 10),
+		Summary: fmt.Sprintf("reply to review comment %d", commentID),
+		Body:    body,
+	})
+	return reply, nil
+}
+
+// Repository gets a repository
+func (a *API) Repository(ctx context.Context, owner, repo string) (*github.Repository, error) {
+	repository, _, err := a.client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+	return repository, nil
+}
+
+// UserRepositories lists the repositories the authenticated user has access to
+func (a *API) UserRepositories(ctx context.Context) ([]*github.Repository, error) {
+	return collect(a.UserRepositoriesIter(ctx), 0)
+}
+
+// UserRepositoriesIter streams the repositories the authenticated user has access to
+func (a *API) UserRepositoriesIter(ctx context.Context) iter.Seq2[*github.Repository, error] {
+	return pages(func(page int) ([]*github.Repository, *github.Response, error) {
+		opts := &github.RepositoryListOptions{ListOptions: github.ListOptions{Page: page, PerPage: perPage}}
+		repos, resp, err := a.client.Repositories.List(ctx, "", opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list repositories: %w", err)
+		}
+		return repos, resp, nil
+	})
+}
+
+// InstallationRepositoriesIter streams the repositories of the GitHub App installation
+// ctx authenticates as
+func (a *API) InstallationRepositoriesIter(ctx context.Context) iter.Seq2[*github.Repository, error] {
+	return pages(func(page int) ([]*github.Repository, *github.Response, error) {
+		list, resp, err := a.client.Apps.ListRepos(ctx, &github.ListOptions{Page: page, PerPage: perPage})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list installation repositories: %w", err)
+		}
+		return list.Repositories, resp, nil
+	})
+}
+
+// CommentReactionsIter streams the reactions on an issue comment
+func (a *API) CommentReactionsIter(ctx context.Context, owner, repo string, commentID int64) iter.Seq2[*github.Reaction, error] {
+	return pages(func(page int) ([]*github.Reaction, *github.Response, error) {
+		opts := &github.ListOptions{Page: page, PerPage: perPage}
+		reactions, resp, err := a.client.Reactions.ListIssueCommentReactions(ctx, owner, repo, commentID, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list comment reactions: %w", err)
+		}
+		return reactions, resp, nil
+	})
+}
+
+// ReviewsIter streams the reviews submitted on a pull request
+func (a *API) ReviewsIter(ctx context.Context, owner, repo string, number int) iter.Seq2[*github.PullRequestReview, error] {
+	return pages(func(page int) ([]*github.PullRequestReview, *github.Response, error) {
+		opts := &github.ListOptions{Page: page, PerPage: perPage}
+		reviews, resp, err := a.client.PullRequests.ListReviews(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list pull request reviews: %w", err)
+		}
+		return reviews, resp, nil
+	})
+}
+
+// ReviewCommentsIter streams the inline review comments on a pull request, oldest first
+func (a *API) ReviewCommentsIter(ctx context.Context, owner, repo string, number int) iter.Seq2[*github.PullRequestComment, error] {
+	return pages(func(page int) ([]*github.PullRequestComment, *github.Response, error) {
+		opts := &github.PullRequestListCommentsOptions{
+			Sort:        "created",
+			Direction:   "asc",
+			ListOptions: github.ListOptions{Page: page, PerPage: perPage},
+		}
+		comments, resp, err := a.client.PullRequests.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list review comments: %w", err)
+		}
+		return comments, resp, nil
+	})
+}
+
+// CommitStatusesIter streams the latest status of each context on a commit
+func (a *API) CommitStatusesIter(ctx context.Context, owner, repo, sha string) iter.Seq2[*github.RepoStatus, error] {
+	return pages(func(page int) ([]*github.RepoStatus, *github.Response, error) {
+		opts := &github.ListOptions{Page: page, PerPage: perPage}
+		combined, resp, err := a.client.Repositories.GetCombinedStatus(ctx, owner, repo, sha, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get combined status: %w", err)
+		}
+		return combined.Statuses, resp, nil
+	})
+}
+
+// CheckRunsIter streams the check runs on a commit
+func (a *API) CheckRunsIter(ctx context.Context, owner, repo, sha string) iter.Seq2[*github.CheckRun, error] {
+	return pages(func(page int) ([]*github.CheckRun, *github.Response, error) {
+		opts := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{Page: page, PerPage: perPage}}
+		result, resp, err := a.client.Checks.ListCheckRunsForRef(ctx, owner, repo, sha, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list check runs: %w", err)
+		}
+		return result.CheckRuns, resp, nil
+	})
+}
+
+// CreateBranch creates a branch pointing at the head of baseBranch
+func (a *API) CreateBranch(ctx context.Context, owner, repo, branchName, baseBranch string) error {
+	baseRef, _, err := a.client.Git.GetRef(ctx, owner, repo, "refs/heads/"+baseBranch)
+	if err != nil {
+		return fmt.Errorf("failed to get base branch reference: %w", err)
+	}
+
+	_, _, err = a.client.Git.CreateRef(ctx, owner, repo, &github.Reference{
+		Ref:    github.String("refs/heads/" + branchName),
+		Object: &github.GitObject{SHA: github.String(baseRef.GetObject().GetSHA())},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create branch: %w", err)
+	}
+	audit.Record(audit.Entry{
+		Action:  audit.ActionBranch,
+		Repo:    owner + "/" + repo,
+		Ref:     branchName,
+		Summary: fmt.Sprintf("from %s at %s", baseBranch, baseRef.GetObject().GetSHA()),
+	})
+
+	logging.Info("Successfully created branch", "branch", branchName)
+	return nil
+}
+
+// ConvertIssue converts a GitHub issue into the domain model, without comments. An empty
+// owner or repo is taken from the issue URL, since search results don't carry them otherwise.
+func ConvertIssue(issue *github.Issue, owner, repo string) *models.Issue {
+	if owner == "" || repo == "" {
+		owner, repo, _ = parseRepoFromURL(issue.GetHTMLURL())
+	}
+
+	result := &models.Issue{
+		Owner:     owner,
+		Repo:      repo,
+		Number:    issue.GetNumber(),
+		Title:     issue.GetTitle(),
+		Body:      issue.GetBody(),
+		User:      issue.GetUser().GetLogin(),
+		State:     issue.GetState(),
+		CreatedAt: issue.GetCreatedAt(),
+		UpdatedAt: issue.GetUpdatedAt(),
+		URL:       issue.GetHTMLURL(),
+		Comments:  []*models.IssueComment{},
+		Labels:    make([]string, 0, len(issue.Labels)),
+		Assignees: make([]string, 0, len(issue.Assignees)),
+		Milestone: issue.GetMilestone().GetTitle(),
+	}
+
+	for _, label := range issue.Labels {
+		if name := label.GetName(); name != "" {
+			result.Labels = append(result.Labels, name)
+		}
+	}
+	for _, assignee := range issue.Assignees {
+		if login := assignee.GetLogin(); login != "" {
+			result.Assignees = append(result.Assignees, login)
+		}
+	}
+
+	return result
+}
+
+// convertComment converts a GitHub issue comment into the domain model
+func convertComment(comment *github.IssueComment) *models.IssueComment {
+	return &models.IssueComment{
+		ID:        comment.GetID(),
+		User:      comment.GetUser().GetLogin(),
+		Body:      comment.GetBody(),
+		CreatedAt: comment.GetCreatedAt(),
+	}
+}
+
+// toVCSIssue converts a domain issue into the VCS-neutral issue type
+func toVCSIssue(issue *models.Issue) *vcs.BaseIssue {
+	result := &vcs.BaseIssue{
+		Owner:     issue.Owner,
+		Repo:      issue.Repo,
+		Number:    issue.Number,
+		Title:     issue.Title,
+		Body:      issue.Body,
+		User:      issue.User,
+		State:     issue.State,
+		CreatedAt: issue.CreatedAt,
+		UpdatedAt: issue.UpdatedAt,
+		URL:       issue.URL,
+		Comments:  make([]vcs.IssueComment, 0, len(issue.Comments)),
+		Labels:    issue.Labels,
+		Assignees: issue.Assignees,
+		Milestone: issue.Milestone,
+	}
+
+	for _, comment := range issue.Comments {
+		result.Comments = append(result.Comments, vcs.IssueComment{
+			ID:        strconv.FormatInt(comment.ID, 10),
+			User:      comment.User,
+			Body:      comment.Body,
+			CreatedAt: comment.CreatedAt,
+		})
+	}
+
+	return result
+}
+
+// toVCSPullRequest converts a GitHub pull request into the VCS-neutral type, reporting
+// merged pull requests with the state "merged"
+func toVCSPullRequest(pr *github.PullRequest, owner, repo string) *vcs.BasePullRequest {
+	state := pr.GetState()
+	if pr.GetMerged() || pr.MergedAt != nil {
+		state = "merged"
+	}
+
+	return &vcs.BasePullRequest{
+		Owner:      owner,
+		Repo:       repo,
+		Number:     pr.GetNumber(),
+		Title:      pr.GetTitle(),
+		Body:       pr.GetBody(),
+		State:      state,
+		IsDraft:    pr.GetDraft(),
+		User:       pr.GetUser().GetLogin(),
+		HeadBranch: pr.GetHead().GetRef(),
+		BaseBranch: pr.GetBase().GetRef(),
+		URL:        pr.GetHTMLURL(),
+	}
+}
+
+// graphQLError is an error reported in the body of a GraphQL response
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+// graphQL runs a GraphQL query and decodes its data into out
+func (a *API) graphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	payload := map[string]interface{}{"query": query, "variables": variables}
+	req, err := a.client.NewRequest("POST", graphQLURL(a.client.BaseURL), payload)
+	if err != nil {
+		return fmt.Errorf("failed to create GraphQL request: %w", err)
+	}
+
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []graphQLError  `json:"errors"`
+	}
+	if _, err := a.client.Do(ctx, req, &resp); err != nil {
+		return fmt.Errorf("failed to run GraphQL query: %w", err)
+	}
+
+	// GraphQL reports errors such as missing issues with a 200 status
+	if len(resp.Errors) > 0 {
+		return fmt.Errorf("failed to run GraphQL query: %s", resp.Errors[0].Message)
+	}
+	if err := json.Unmarshal(resp.Data, out); err != nil {
+		return fmt.Errorf("failed to parse GraphQL response: %w", err)
+	}
+	return nil
+}
+
+// graphQLURL derives the GraphQL endpoint from the REST base URL, which is
+// https://api.github.com/ on github.com and https://host/api/v3/ on GitHub Enterprise Server
+func graphQLURL(base *url.URL) string {
+	endpoint := *base
+	if strings.HasSuffix(endpoint.Path, "/api/v3/") {
+		endpoint.Path = strings.TrimSuffix(endpoint.Path, "v3/") + "graphql"
+	} else {
+		endpoint.Path += "graphql"
+	}
+	return endpoint.String()
+}
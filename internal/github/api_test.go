@@ -0,0 +1,360 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// newTestAPI returns an API backed by a test server serving mux
+func newTestAPI(t *testing.T, mux *http.ServeMux) *API {
+	t.Helper()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return NewAPI(client)
+}
+
+// paginate serves pages in order, linking each to the next through the Link header
+func paginate(t *testing.T, pages ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			if _, err := fmt.Sscan(p, &page); err != nil {
+				t.Errorf("bad page %q", p)
+			}
+		}
+		if page < len(pages) {
+			next := *r.URL
+			query := next.Query()
+			query.Set("page", fmt.Sprint(page+1))
+			next.RawQuery = query.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next"`, r.Host, next.String()))
+		}
+		fmt.Fprint(w, pages[page-1])
+	}
+}
+
+func TestAPIIssueWithComments(t *testing.T) {
+	mux := http.NewServeMux()
+	// An issue with no body whose author was deleted
+	mux.HandleFunc("/repos/octo/demo/issues/1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"number":1,"title":"Crash","body":null,"user":null,"state":"open",
+			"labels":[{"name":"bug"},{}],"assignees":[{"login":"bot"}]}`)
+	})
+	mux.HandleFunc("/repos/octo/demo/issues/1/comments", paginate(t,
+		`[{"id":10,"body":"first","user":{"login":"alice"}}]`,
+		`[{"id":11,"user":null}]`,
+	))
+
+	issue, err := newTestAPI(t, mux).IssueWithComments(context.Background(), "octo", "demo", 1)
+	if err != nil {
+		t.Fatalf("IssueWithComments() error = %v", err)
+	}
+	if issue.Owner != "octo" || issue.Repo != "demo" || issue.Title != "Crash" || issue.Body != "" || issue.User != "" {
+		t.Errorf("unexpected issue %+v", issue)
+	}
+	if len(issue.Labels) != 1 || issue.Labels[0] != "bug" || len(issue.Assignees) != 1 {
+		t.Errorf("labels = %v, assignees = %v", issue.Labels, issue.Assignees)
+	}
+	if len(issue.Comments) != 2 || issue.Comments[0].User != "alice" || issue.Comments[1].ID != 11 {
+		t.Fatalf("comments from both pages expected, got %+v", issue.Comments)
+	}
+
+	vcsIssue := toVCSIssue(issue)
+	if comments := vcsIssue.GetComments(); len(comments) != 2 || comments[1].ID != "11" {
+		t.Errorf("vcs comments = %+v", comments)
+	}
+}
+
+func TestAPIIssueNotFound(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/octo/demo/issues/404", http.NotFound)
+
+	if _, err := newTestAPI(t, mux).IssueWithComments(context.Background(), "octo", "demo", 404); err == nil {
+		t.Fatal("IssueWithComments() succeeded for a missing issue")
+	}
+}
+
+func TestAPISearchIssues(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search/issues", paginate(t,
+		`{"total_count":3,"items":[{"number":1,"html_url":"https://github.com/octo/demo/issues/1"},{"number":2}]}`,
+		`{"total_count":3,"items":[{"number":3}]}`,
+	))
+	api := newTestAPI(t, mux)
+
+	all, err := api.SearchIssues(context.Background(), "is:issue", "updated", 0)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("SearchIssues() = %d results, %v; want 3", len(all), err)
+	}
+
+	limited, err := api.SearchIssues(context.Background(), "is:issue", "", 1)
+	if err != nil || len(limited) != 1 {
+		t.Fatalf("SearchIssues(limit 1) = %d results, %v; want 1", len(limited), err)
+	}
+
+	// Search results carry their repository only in the URL
+	issue := ConvertIssue(all[0], "", "")
+	if issue.Owner != "octo" || issue.Repo != "demo" {
+		t.Errorf("ConvertIssue() repository = %s/%s, want octo/demo", issue.Owner, issue.Repo)
+	}
+}
+
+func TestAPISearchIssuesUpdatedSince(t *testing.T) {
+	// More issues than one search returns, updated in pairs so windows split a pair
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := func(id int) time.Time { return base.Add(time.Duration(id/2) * time.Second) }
+	const total = 1500
+
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search/issues", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		query := r.URL.Query()
+		if query.Get("sort") != "updated" || query.Get("order") != "asc" {
+			t.Errorf("search sorted by %q %q", query.Get("sort"), query.Get("order"))
+		}
+
+		var after time.Time
+		inclusive := false
+		for _, term := range strings.Fields(query.Get("q")) {
+			if value, ok := strings.CutPrefix(term, "updated:>="); ok {
+				after, _ = time.Parse(time.RFC3339, value)
+				inclusive = true
+			} else if value, ok := strings.CutPrefix(term, "updated:>"); ok {
+				after, _ = time.Parse(time.RFC3339, value)
+			}
+		}
+
+		var matches []string
+		for id := 1; id <= total && len(matches) < searchLimit; id++ {
+			if updated(id).After(after) || (inclusive && updated(id).Equal(after)) {
+				matches = append(matches, fmt.Sprintf(`{"id":%d,"number":%d,"updated_at":%q}`,
+					id, id, updated(id).Format(time.RFC3339)))
+			}
+		}
+
+		page := 1
+		if p := query.Get("page"); p != "" {
+			fmt.Sscan(p, &page)
+		}
+		end := min(page*perPage, len(matches))
+		if end < len(matches) {
+			next := *r.URL
+			q := next.Query()
+			q.Set("page", fmt.Sprint(page+1))
+			next.RawQuery = q.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next"`, r.Host, next.String()))
+		}
+		fmt.Fprintf(w, `{"total_count":%d,"items":[%s]}`, len(matches), strings.Join(matches[(page-1)*perPage:end], ","))
+	})
+	api := newTestAPI(t, mux)
+
+	var got []int
+	for issue, err := range api.SearchIssuesUpdatedSince(context.Background(), "is:issue", base.Add(-time.Second)) {
+		if err != nil {
+			t.Fatalf("SearchIssuesUpdatedSince() error = %v", err)
+		}
+		got = append(got, issue.GetNumber())
+	}
+	if len(got) != total {
+		t.Fatalf("SearchIssuesUpdatedSince() = %d issues, want %d", len(got), total)
+	}
+	for i, number := range got {
+		if number != i+1 {
+			t.Fatalf("issue %d is #%d, want every issue once in update order", i, number)
+		}
+	}
+
+	// Stopping early doesn't fetch the remaining pages
+	requests = 0
+	read := 0
+	for _, err := range api.SearchIssuesUpdatedSince(context.Background(), "is:issue", base) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read++; read == 150 {
+			break
+		}
+	}
+	if requests != 2 {
+		t.Errorf("reading 150 results made %d requests, want 2", requests)
+	}
+}
+
+func TestAPIIssueLinks(t *testing.T) {
+	closing := func(owner string, number int) string {
+		return fmt.Sprintf(`{"nodes":[{"number":%d,"repository":{"name":"demo","owner":{"login":%q}}}]}`, number, owner)
+	}
+	prWith := func(number int, author, state, closes, head, body string) string {
+		return fmt.Sprintf(`{"number":%d,"state":%q,"isDraft":true,"author":%s,"headRefName":%q,"body":%q,
+			"repository":{"name":"demo","owner":{"login":"octo"}},"closingIssuesReferences":%s}`, number, state, author, head, body, closes)
+	}
+	pr := func(number int, author, state, closes string) string {
+		return prWith(number, author, state, closes, "fix-12", "")
+	}
+	none := `{"nodes":[]}`
+	bot := `{"__typename":"Bot","login":"useful1"}`
+	alice := `{"__typename":"User","login":"alice"}`
+	pages := []string{
+		`{"data":{"repository":{"issue":{"timelineItems":{"pageInfo":{"hasNextPage":true,"endCursor":"c1"},"nodes":[
+			{"source":` + pr(5, bot, "OPEN", closing("octo", 12)) + `},
+			{"source":` + pr(7, alice, "OPEN", closing("octo", 123)) + `},
+			{"source":` + pr(9, alice, "MERGED", closing("fork", 12)) + `},
+			{"source":` + prWith(10, bot, "OPEN", none, "fix-login", "Fixes #12\n\nCloses #12") + `},
+			{"source":` + prWith(11, bot, "OPEN", none, "bugfix/issue-12-login", "") + `},
+			{"source":` + prWith(13, bot, "OPEN", none, "bugfix/issue-123-login", "Follow-up to #12, closes #123") + `},
+			{"source":{}},
+			{}]}}}}}`,
+		`{"data":{"repository":{"issue":{"timelineItems":{"pageInfo":{"hasNextPage":false},"nodes":[
+			{"subject":` + pr(8, alice, "CLOSED", closing("octo", 12)) + `},
+			{"source":` + pr(5, bot, "OPEN", closing("octo", 12)) + `}]}}}}}`,
+	}
+
+	requests := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad GraphQL request: %v", err)
+		}
+		if req.Variables["number"] != float64(12) || (requests == 1) != (req.Variables["cursor"] == "c1") {
+			t.Errorf("request %d variables = %v", requests, req.Variables)
+		}
+		fmt.Fprint(w, pages[requests])
+		requests++
+	})
+
+	links, err := newTestAPI(t, mux).IssueLinks(context.Background(), "octo", "demo", 12)
+	if err != nil {
+		t.Fatalf("IssueLinks() error = %v", err)
+	}
+
+	// A pull request closing #123 or another repository's #12 only mentions the issue. The
+	// bot's pull requests into other branches close nothing but name the issue.
+	want := []string{
+		"5 open useful1[bot] created-by-bot",
+		"7 open alice mentions",
+		"9 merged alice mentions",
+		"10 open useful1[bot] created-by-bot",
+		"11 open useful1[bot] created-by-bot",
+		"13 open useful1[bot] mentions",
+		"8 closed alice closes",
+	}
+	var got []string
+	for _, link := range links {
+		pr := toVCSPullRequest(link.PullRequest, link.Owner, link.Repo)
+		got = append(got, fmt.Sprintf("%d %s %s %s", pr.GetNumber(), pr.GetState(), pr.GetUser(), linkType(link, "useful1[bot]")))
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("IssueLinks() = %q, want %q", got, want)
+	}
+}
+
+func TestAPIIssueLinksErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":{"repository":{"issue":null}},"errors":[{"message":"Could not resolve to an Issue with the number of 404."}]}`)
+	})
+
+	if _, err := newTestAPI(t, mux).IssueLinks(context.Background(), "octo", "demo", 404); err == nil {
+		t.Fatal("IssueLinks() succeeded for a missing issue")
+	}
+}
+
+func TestGraphQLURL(t *testing.T) {
+	for base, want := range map[string]string{
+		"https://api.github.com/":          "https://api.github.com/graphql",
+		"https://ghe.example.com/api/v3/":  "https://ghe.example.com/api/graphql",
+		"https://ghe.example.com/proxied/": "https://ghe.example.com/proxied/graphql",
+	} {
+		u, _ := url.Parse(base)
+		if got := graphQLURL(u); got != want {
+			t.Errorf("graphQLURL(%s) = %s, want %s", base, got, want)
+		}
+	}
+}
+
+func TestAPIWrites(t *testing.T) {
+	var comment, ref, reply map[string]interface{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/octo/demo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
+			t.Errorf("bad comment request: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":42}`)
+	})
+	mux.HandleFunc("/repos/octo/demo/git/ref/heads/main", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"ref":"refs/heads/main","object":{"sha":"abc123"}}`)
+	})
+	mux.HandleFunc("/repos/octo/demo/git/refs", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&ref); err != nil {
+			t.Errorf("bad ref request: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"ref":"refs/heads/fix-1"}`)
+	})
+	mux.HandleFunc("/repos/octo/demo/pulls/2/comments", func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&reply); err != nil {
+			t.Errorf("bad reply request: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":43}`)
+	})
+	api := newTestAPI(t, mux)
+
+	created, err := api.CreateComment(context.Background(), "octo", "demo", 1, "hello")
+	if err != nil || created.GetID() != 42 || comment["body"] != "hello" {
+		t.Errorf("CreateComment() = %v, %v; request %v", created, err, comment)
+	}
+
+	if err := api.CreateBranch(context.Background(), "octo", "demo", "fix-1", "main"); err != nil {
+		t.Fatalf("CreateBranch() error = %v", err)
+	}
+	if ref["ref"] != "refs/heads/fix-1" || ref["sha"] != "abc123" {
+		t.Errorf("CreateBranch() request = %v", ref)
+	}
+
+	replied, err := api.ReplyToReviewComment(context.Background(), "octo", "demo", 2, 7, "done")
+	if err != nil || replied.GetID() != 43 || reply["body"] != "done" || reply["in_reply_to"] != float64(7) {
+		t.Errorf("ReplyToReviewComment() = %v, %v; request %v", replied, err, reply)
+	}
+}
+
+func TestToVCSPullRequest(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+	}{
+		{`{"state":"open"}`, "open"},
+		{`{"state":"closed"}`, "closed"},
+		{`{"state":"closed","merged":true}`, "merged"},
+		{`{"state":"closed","merged_at":"2024-01-02T03:04:05Z"}`, "merged"}, // list results omit "merged"
+		{`{"user":null,"head":null}`, ""},
+	}
+
+	for _, tt := range tests {
+		var pr github.PullRequest
+		if err := json.Unmarshal([]byte(tt.json), &pr); err != nil {
+			t.Fatal(err)
+		}
+		if got := toVCSPullRequest(&pr, "octo", "demo").GetState(); got != tt.want {
+			t.Errorf("state of %s = %q, want %q", tt.json, got, tt.want)
+		}
+	}
+}
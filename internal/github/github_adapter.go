@@ -4,41 +4,59 @@ package github
 import (
 	"context"
 	"fmt"
-	"os"
-	"os/exec"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/go-github/v45/github"
+	"github.com/hellausefulsoftware/useful1/internal/auth"
 	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
 	"github.com/hellausefulsoftware/useful1/internal/config"
 	"github.com/hellausefulsoftware/useful1/internal/logging"
-	"golang.org/x/oauth2"
+	"github.com/hellausefulsoftware/useful1/internal/ratelimit"
+	"github.com/hellausefulsoftware/useful1/internal/workspace"
 )
 
 // Adapter provides a GitHub implementation of the vcs.Service interface
 type Adapter struct {
 	client   *github.Client
+	api      *API
 	config   *config.Config
 	username string
+	app      *auth.GitHubApp // set when authenticating as a GitHub App
 }
 
 // NewAdapter creates a new GitHub adapter
 func NewAdapter(cfg *config.Config) (*Adapter, error) {
-	if cfg.GitHub.Token == "" {
+	if cfg.GitHub.Token == "" && !cfg.UsesGitHubApp() {
 		return nil, fmt.Errorf("GitHub token is required")
 	}
 
 	// Create GitHub client
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: cfg.GitHub.Token},
-	)
-	tc := oauth2.NewClient(context.Background(), ts)
-	client := github.NewClient(tc)
+	client, err := auth.NewGitHubClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client: %w", err)
+	}
 
-	// Get username
+	var app *auth.GitHubApp
 	username := cfg.GitHub.User
-	if username == "" {
+
+	if cfg.UsesGitHubApp() {
+		// Apps act as their bot user, which can't be looked up through /user
+		app, err = auth.GitHubAppFromConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		username, err = app.BotLogin(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get GitHub App bot login: %w", err)
+		}
+		logging.Info("Authenticating as GitHub App", "app_id", cfg.GitHubApp.AppID, "username", username)
+	} else if username == "" {
 		// Try to get username from API if not set
 		logging.Info("Username not configured, getting from GitHub API...")
 		if user, _, err := client.Users.Get(context.Background(), ""); err == nil && user.GetLogin() != "" {
@@ -52,163 +70,218 @@ func NewAdapter(cfg *config.Config) (*Adapter, error) {
 
 	return &Adapter{
 		client:   client,
+		api:      NewAPI(client),
 		config:   cfg,
 		username: username,
+		app:      app,
 	}, nil
 }
 
-// GetIssue retrieves a basic issue without comments
-func (a *Adapter) GetIssue(owner, repo string, number int) (vcs.Issue, error) {
-	ctx := context.Background()
-	issue, _, err := a.client.Issues.Get(ctx, owner, repo, number)
+// installationContexts returns one context per installation to query, since an installation
+// token only sees the repositories of its own installation
+func (a *Adapter) installationContexts() ([]context.Context, error) {
+	if a.app == nil {
+		return []context.Context{context.Background()}, nil
+	}
+
+	installations, err := a.app.Installations(context.Background())
 	if err != nil {
-		return nil, fmt.Errorf("error getting issue: %w", err)
+		return nil, err
 	}
 
-	return a.convertGitHubIssue(issue, owner, repo), nil
+	contexts := make([]context.Context, 0, len(installations))
+	for _, installation := range installations {
+		contexts = append(contexts, auth.WithInstallation(context.Background(), installation.GetID()))
+	}
+	return contexts, nil
 }
 
-// GetIssueWithComments retrieves an issue with all its comments
-func (a *Adapter) GetIssueWithComments(owner, repo string, number int) (vcs.Issue, error) {
-	ctx := context.Background()
+// repoContext returns a context for requests about a repository that don't name it in
+// their path, such as GraphQL queries, so a GitHub App uses the repository's installation
+func (a *Adapter) repoContext(owner, repo string) (context.Context, error) {
+	if a.app == nil {
+		return context.Background(), nil
+	}
 
-	// Get issue details
-	issue, _, err := a.client.Issues.Get(ctx, owner, repo, number)
-	if err != nil {
-		return nil, fmt.Errorf("error getting issue: %w", err)
-	}
-
-	// Create base issue
-	result := a.convertGitHubIssue(issue, owner, repo)
-
-	// Get comments
-	comments, _, err := a.client.Issues.ListComments(
-		ctx,
-		owner,
-		repo,
-		number,
-		&github.IssueListCommentsOptions{
-			Sort:      github.String("created"),
-			Direction: github.String("asc"),
-			ListOptions: github.ListOptions{
-				PerPage: 100,
-			},
-		},
-	)
+	id, err := a.app.InstallationForRepo(context.Background(), owner, repo)
 	if err != nil {
-		return nil, fmt.Errorf("error getting comments: %w", err)
+		return nil, err
 	}
+	return auth.WithInstallation(context.Background(), id), nil
+}
 
-	// Process comments
-	var vcsComments []vcs.IssueComment
-	for _, comment := range comments {
-		vcsComments = append(vcsComments, vcs.IssueComment{
-			ID:        fmt.Sprintf("%d", comment.GetID()),
-			User:      comment.User.GetLogin(),
-			Body:      comment.GetBody(),
-			CreatedAt: comment.GetCreatedAt(),
-		})
+// GetIssue retrieves a basic issue without comments
+func (a *Adapter) GetIssue(owner, repo string, number int) (vcs.Issue, error) {
+	issue, err := a.api.Issue(context.Background(), owner, repo, number)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add comments to issue
-	baseIssue, ok := result.(*vcs.BaseIssue)
-	if !ok {
-		return nil, fmt.Errorf("failed to convert issue to BaseIssue type")
+	return toVCSIssue(ConvertIssue(issue, owner, repo)), nil
+}
+
+// GetIssueWithComments retrieves an issue with all its comments
+func (a *Adapter) GetIssueWithComments(owner, repo string, number int) (vcs.Issue, error) {
+	issue, err := a.api.IssueWithComments(context.Background(), owner, repo, number)
+	if err != nil {
+		return nil, err
 	}
-	baseIssue.Comments = vcsComments
 
-	return baseIssue, nil
+	return toVCSIssue(issue), nil
 }
 
-// GetAssignedIssues retrieves issues assigned to a user since a specific time
+// GetAssignedIssues retrieves up to limit issues assigned to a user since a specific time.
+// A limit of zero or less returns every issue.
 func (a *Adapter) GetAssignedIssues(username string, since time.Time, limit int) ([]vcs.Issue, error) {
-	// Only search for open issues assigned to the user
-	query := fmt.Sprintf("assignee:%s updated:>%s is:issue is:open",
-		username,
-		since.Format(time.RFC3339),
-	)
+	return collect(a.AssignedIssues(username, since), limit)
+}
 
-	logging.Info("Searching for assigned issues", "query", query)
+// AssignedIssues streams the open issues assigned to a user, or labelled for a GitHub App,
+// that were updated since a time, least recently updated first
+func (a *Adapter) AssignedIssues(username string, since time.Time) iter.Seq2[vcs.Issue, error] {
+	return a.SearchIssues(vcs.IssueQuery{Assignee: username}, since)
+}
 
-	// Search for issues
-	searchOpts := &github.SearchOptions{
-		Sort:  "updated",
-		Order: "desc",
-		ListOptions: github.ListOptions{
-			PerPage: limit,
-		},
-	}
+// SearchIssues streams the open issues matching a query that were updated since a time,
+// least recently updated first
+func (a *Adapter) SearchIssues(query vcs.IssueQuery, since time.Time) iter.Seq2[vcs.Issue, error] {
+	return func(yield func(vcs.Issue, error) bool) {
+		search := a.searchQuery(query)
+		logging.Info("Searching for issues", "query", search, "since", since.Format(time.RFC3339))
 
-	// Perform the search
-	ctx := context.Background()
-	result, _, err := a.client.Search.Issues(ctx, query, searchOpts)
-	if err != nil {
-		return nil, fmt.Errorf("error searching for issues: %w", err)
-	}
+		contexts, err := a.installationContexts()
+		if err != nil {
+			yield(nil, err)
+			return
+		}
 
-	var issues []vcs.Issue
+		for _, ctx := range contexts {
+			for issue, err := range a.api.SearchIssuesUpdatedSince(ctx, search, since) {
+				if err != nil {
+					yield(nil, fmt.Errorf("error searching for issues: %w", err))
+					return
+				}
 
-	// Convert GitHub issues to vcs.Issue
-	for _, issue := range result.Issues {
-		// Skip pull requests
-		if issue.PullRequestLinks != nil {
-			continue
-		}
+				// Skip pull requests
+				if issue.PullRequestLinks != nil {
+					continue
+				}
 
-		// Extract owner/repo from issue URL
-		parts := strings.Split(*issue.HTMLURL, "/")
-		if len(parts) < 7 {
-			logging.Warn("Skipping issue with invalid URL", "url", *issue.HTMLURL)
-			continue
-		}
+				// Search results only carry the repository in their URL
+				converted := ConvertIssue(issue, "", "")
+				if converted.Owner == "" {
+					logging.Warn("Skipping issue with invalid URL", "url", issue.GetHTMLURL())
+					continue
+				}
 
-		owner := parts[3]
-		repo := parts[4]
-
-		// Convert to vcs.Issue
-		vcsIssue := &vcs.BaseIssue{
-			Owner:     owner,
-			Repo:      repo,
-			Number:    *issue.Number,
-			Title:     *issue.Title,
-			Body:      *issue.Body,
-			User:      *issue.User.Login,
-			State:     *issue.State,
-			CreatedAt: *issue.CreatedAt,
-			UpdatedAt: *issue.UpdatedAt,
-			URL:       *issue.HTMLURL,
+				if !yield(toVCSIssue(converted), nil) {
+					return
+				}
+			}
 		}
+	}
+}
 
-		issues = append(issues, vcsIssue)
+// searchQuery compiles an issue query to GitHub's search syntax
+func (a *Adapter) searchQuery(query vcs.IssueQuery) string {
+	var terms []string
+	if query.Assignee != "" {
+		if a.app != nil {
+			// GitHub Apps can't be assigned issues, so they pick up labelled issues instead
+			label := a.config.GitHubApp.TriggerLabel
+			if label == "" {
+				label = "useful1"
+			}
+			terms = append(terms, fmt.Sprintf("label:%q", label))
+		} else {
+			terms = append(terms, "assignee:"+query.Assignee)
+		}
+	}
+	if query.Mentions != "" {
+		terms = append(terms, "mentions:"+strings.TrimSuffix(query.Mentions, "[bot]"))
+	}
+	for _, label := range query.Labels {
+		terms = append(terms, fmt.Sprintf("label:%q", label))
+	}
+	for _, label := range query.ExcludeLabels {
+		terms = append(terms, fmt.Sprintf("-label:%q", label))
+	}
+	if query.Milestone != "" {
+		terms = append(terms, fmt.Sprintf("milestone:%q", query.Milestone))
+	}
+	// GitHub matches any of several repo: qualifiers
+	for _, repo := range query.Repos {
+		terms = append(terms, "repo:"+repo)
+	}
+	if query.Raw != "" {
+		terms = append(terms, query.Raw)
 	}
 
-	return issues, nil
+	return strings.Join(append(terms, "is:issue", "is:open"), " ")
 }
 
 // RespondToIssue posts a comment on a GitHub issue
 func (a *Adapter) RespondToIssue(owner, repo string, issueNumber int, comment string) error {
-	_, _, err := a.client.Issues.CreateComment(
-		context.Background(),
-		owner,
-		repo,
-		issueNumber,
-		&github.IssueComment{
-			Body: github.String(comment),
-		},
-	)
+	_, err := a.api.CreateComment(context.Background(), owner, repo, issueNumber, comment)
+	return err
+}
 
+// CreateComment posts a comment on a GitHub issue and returns its ID
+func (a *Adapter) CreateComment(owner, repo string, issueNumber int, body string) (string, error) {
+	comment, err := a.api.CreateComment(context.Background(), owner, repo, issueNumber, body)
 	if err != nil {
-		return fmt.Errorf("failed to create issue comment: %w", err)
+		return "", err
 	}
+	return strconv.FormatInt(comment.GetID(), 10), nil
+}
 
-	return nil
+// UpdateComment replaces the body of an issue comment
+func (a *Adapter) UpdateComment(owner, repo, commentID, body string) error {
+	id, err := strconv.ParseInt(commentID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid comment ID %q: %w", commentID, err)
+	}
+
+	_, err = a.api.EditComment(context.Background(), owner, repo, id, body)
+	return err
+}
+
+// AssignIssue adds assignees to a GitHub issue
+func (a *Adapter) AssignIssue(owner, repo string, issueNumber int, assignees []string) error {
+	return a.api.AddAssignees(context.Background(), owner, repo, issueNumber, assignees)
+}
+
+// UnassignIssue removes assignees from a GitHub issue
+func (a *Adapter) UnassignIssue(owner, repo string, issueNumber int, assignees []string) error {
+	return a.api.RemoveAssignees(context.Background(), owner, repo, issueNumber, assignees)
+}
+
+// GetCommentReactions lists the reactions on an issue comment
+func (a *Adapter) GetCommentReactions(owner, repo, commentID string) ([]vcs.Reaction, error) {
+	id, err := strconv.ParseInt(commentID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid comment ID %q: %w", commentID, err)
+	}
+
+	var reactions []vcs.Reaction
+	for reaction, err := range a.api.CommentReactionsIter(context.Background(), owner, repo, id) {
+		if err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, vcs.Reaction{
+			User:    reaction.GetUser().GetLogin(),
+			Content: reaction.GetContent(),
+		})
+	}
+
+	return reactions, nil
 }
 
 // GetRepository retrieves repository information
 func (a *Adapter) GetRepository(owner, repo string) (vcs.Repository, error) {
-	repoInfo, _, err := a.client.Repositories.Get(context.Background(), owner, repo)
+	repoInfo, err := a.api.Repository(context.Background(), owner, repo)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get repository: %w", err)
+		return nil, err
 	}
 
 	return &vcs.BaseRepository{
@@ -221,143 +294,32 @@ func (a *Adapter) GetRepository(owner, repo string) (vcs.Repository, error) {
 
 // GetDefaultBranch gets the default branch for a repository
 func (a *Adapter) GetDefaultBranch(owner, repo string) (string, error) {
-	repoInfo, _, err := a.client.Repositories.Get(context.Background(), owner, repo)
+	repoInfo, err := a.api.Repository(context.Background(), owner, repo)
 	if err != nil {
-		return "", fmt.Errorf("failed to get repository info: %w", err)
+		return "", err
 	}
 
 	return repoInfo.GetDefaultBranch(), nil
 }
 
-// CloneRepository clones a GitHub repository to a local directory
+// CloneRepository returns a worktree for the issue from the shared repository mirror
 func (a *Adapter) CloneRepository(owner, repo, branch string, issueNumber int) (string, error) {
-	// Get user's home directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("failed to get user's home directory: %w", err)
-	}
-
-	// Create temp directory path
-	tempDir := fmt.Sprintf("%s/.useful1/temp/%s_%d", homeDir, repo, issueNumber)
-
-	// Create parent directory if it doesn't exist
-	baseDir := fmt.Sprintf("%s/.useful1/temp", homeDir)
-	err = os.MkdirAll(baseDir, 0755)
-	if err != nil {
-		return "", fmt.Errorf("failed to create base temp directory: %w", err)
-	}
-
-	// Check if directory already exists
-	_, err = os.Stat(tempDir)
-	repoExists := !os.IsNotExist(err)
-
-	repoURL := fmt.Sprintf("git@github.com:%s/%s.git", owner, repo)
-	logging.Info("Repository operations",
-		"owner", owner,
-		"repo", repo,
-		"branch", branch,
-		"issue", issueNumber,
-		"dir", tempDir,
-		"exists", repoExists)
-
-	if repoExists {
-		// Directory exists, update it
-		currentDir, dirErr := os.Getwd()
-		if dirErr != nil {
-			return "", fmt.Errorf("failed to get current directory: %w", dirErr)
-		}
-		defer func() {
-			if chDirErr := os.Chdir(currentDir); chDirErr != nil {
-				logging.Warn("Failed to return to original directory", "error", chDirErr)
-			}
-		}() // Return to original directory when done
-
-		if chDirErr := os.Chdir(tempDir); chDirErr != nil {
-			return "", fmt.Errorf("failed to change to repository directory: %w", chDirErr)
-		}
-
-		// Fetch latest changes
-		fetchCmd := exec.Command("git", "fetch", "origin")
-		if _, fetchErr := fetchCmd.CombinedOutput(); fetchErr != nil {
-			return "", fmt.Errorf("failed to fetch latest changes: %w", fetchErr)
-		}
-
-		// Checkout branch
-		checkoutCmd := exec.Command("git", "checkout", branch)
-		checkoutOutput, err := checkoutCmd.CombinedOutput()
-		if err != nil {
-			// Branch might not exist, create it
-			createCmd := exec.Command("git", "checkout", "-b", branch)
-			if _, err := createCmd.CombinedOutput(); err != nil {
-				return "", fmt.Errorf("failed to create branch: %w\nOutput: %s", err, string(checkoutOutput))
-			}
-		}
-	} else {
-		// Clone the repository
-		cloneCmd := exec.Command("git", "clone", repoURL, tempDir)
-		cloneOutput, err := cloneCmd.CombinedOutput()
-		if err != nil {
-			return "", fmt.Errorf("failed to clone repository: %w\nOutput: %s", err, string(cloneOutput))
-		}
-
-		// Change to repository directory
-		currentDir, err := os.Getwd()
-		if err != nil {
-			return "", fmt.Errorf("failed to get current directory: %w", err)
-		}
-		defer func() {
-			if chDirErr := os.Chdir(currentDir); chDirErr != nil {
-				logging.Warn("Failed to return to original directory", "error", chDirErr)
-			}
-		}() // Return to original directory when done
-
-		if chDirErr := os.Chdir(tempDir); chDirErr != nil {
-			return "", fmt.Errorf("failed to change to repository directory: %w", chDirErr)
-		}
-
-		// Create branch
-		createCmd := exec.Command("git", "checkout", "-b", branch)
-		if _, err := createCmd.CombinedOutput(); err != nil {
-			return "", fmt.Errorf("failed to create branch: %w", err)
-		}
-	}
-
-	return tempDir, nil
+	return workspace.NewManager(a.config).Prepare(owner, repo, branch, issueNumber, nil)
 }
 
-// CreateBranch creates a new branch from the specified base branch
+// CreateBranch creates a new branch from the specified base branch. Without push access
+// the branch is created in the bot's fork when the work is pushed, so nothing is done here.
 func (a *Adapter) CreateBranch(owner, repo, branchName, baseBranch string) error {
-	// Get the reference to the base branch
-	baseRef, _, err := a.client.Git.GetRef(
-		context.Background(),
-		owner,
-		repo,
-		fmt.Sprintf("refs/heads/%s", baseBranch),
-	)
+	canPush, err := workspace.CanPush(context.Background(), a.client, owner, repo)
 	if err != nil {
-		return fmt.Errorf("failed to get base branch reference: %w", err)
+		return err
 	}
-
-	// Create a new reference (branch) using the SHA from the base branch
-	newRef := &github.Reference{
-		Ref: github.String(fmt.Sprintf("refs/heads/%s", branchName)),
-		Object: &github.GitObject{
-			SHA: baseRef.Object.SHA,
-		},
+	if !canPush {
+		logging.Info("No push access, branch will be pushed to a fork", "owner", owner, "repo", repo, "branch", branchName)
+		return nil
 	}
 
-	_, _, err = a.client.Git.CreateRef(
-		context.Background(),
-		owner,
-		repo,
-		newRef,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to create branch: %w", err)
-	}
-
-	logging.Info("Successfully created branch", "branch", branchName)
-	return nil
+	return a.api.CreateBranch(context.Background(), owner, repo, branchName, baseBranch)
 }
 
 // CreateDraftPullRequest creates a new draft pull request
@@ -377,174 +339,290 @@ func (a *Adapter) CreateDraftPullRequest(owner, repo, title, body, head, base st
 		"head", head,
 		"base", base)
 
-	pr, _, err := a.client.PullRequests.Create(
-		context.Background(),
-		owner,
-		repo,
-		newPR,
-	)
-
+	pr, err := a.api.CreatePullRequest(context.Background(), owner, repo, newPR)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create draft PR: %w", err)
 	}
 
-	return &vcs.BasePullRequest{
-		Number:     pr.GetNumber(),
-		Title:      pr.GetTitle(),
-		Body:       pr.GetBody(),
-		State:      pr.GetState(),
-		IsDraft:    pr.GetDraft(),
-		User:       pr.User.GetLogin(),
-		HeadBranch: head,
-		BaseBranch: base,
-		URL:        pr.GetHTMLURL(),
-	}, nil
+	result := toVCSPullRequest(pr, owner, repo)
+	result.HeadBranch = head
+	result.BaseBranch = base
+	return result, nil
+}
+
+// GetPullRequest gets a pull request, reporting merged pull requests with the state "merged"
+func (a *Adapter) GetPullRequest(owner, repo string, number int) (vcs.PullRequest, error) {
+	pr, err := a.api.PullRequest(context.Background(), owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	return toVCSPullRequest(pr, owner, repo), nil
 }
 
-// GetPullRequestsForIssue gets all pull requests that reference an issue
-func (a *Adapter) GetPullRequestsForIssue(owner, repo string, issueNumber int) ([]vcs.PullRequest, error) {
-	// Search for PRs that mention the issue number in different formats
-	query := fmt.Sprintf("repo:%s/%s is:pr #%d OR \"issue %d\" OR \"fixes %d\" OR \"closes %d\"",
-		owner, repo, issueNumber, issueNumber, issueNumber, issueNumber)
+// GetPullRequestsForIssue gets the pull requests that close or cross-reference an issue
+func (a *Adapter) GetPullRequestsForIssue(owner, repo string, issueNumber int) ([]vcs.LinkedPullRequest, error) {
+	ctx, err := a.repoContext(owner, repo)
+	if err != nil {
+		return nil, err
+	}
 
-	var vcsPRs []vcs.PullRequest
-	opts := &github.SearchOptions{
-		ListOptions: github.ListOptions{
-			PerPage: 100,
-		},
+	links, err := a.api.IssueLinks(ctx, owner, repo, issueNumber)
+	if err != nil {
+		return nil, err
 	}
 
-	// Search for PRs
-	result, resp, err := a.client.Search.Issues(context.Background(), query, opts)
+	linked := make([]vcs.LinkedPullRequest, 0, len(links))
+	for _, link := range links {
+		linked = append(linked, vcs.LinkedPullRequest{
+			PullRequest: toVCSPullRequest(link.PullRequest, link.Owner, link.Repo),
+			Link:        linkType(link, a.username),
+		})
+	}
+	return linked, nil
+}
+
+// GetOpenPullRequestsByAuthor gets the open pull requests created by a user
+func (a *Adapter) GetOpenPullRequestsByAuthor(username string) ([]vcs.PullRequest, error) {
+	// Search finds pull requests opened by an app through its app/ qualifier
+	author := username
+	if strings.HasSuffix(author, "[bot]") {
+		author = "app/" + strings.TrimSuffix(author, "[bot]")
+	}
+	query := fmt.Sprintf("is:pr is:open author:%s", author)
+
+	contexts, err := a.installationContexts()
 	if err != nil {
-		logging.Debug("Search error, falling back to listing all PRs", "error", err)
-		return a.checkAllPullRequests(owner, repo, issueNumber)
-	}
-
-	if result.GetTotal() > 0 {
-		for _, issue := range result.Issues {
-			if issue.PullRequestLinks != nil {
-				// This is a PR, not an issue
-				pr, _, prErr := a.client.PullRequests.Get(
-					context.Background(),
-					owner,
-					repo,
-					*issue.Number,
-				)
-
-				if prErr != nil {
-					logging.Debug("Error getting PR", "number", *issue.Number, "error", prErr)
-					continue
-				}
+		return nil, err
+	}
 
-				vcsPRs = append(vcsPRs, &vcs.BasePullRequest{
-					Number:     pr.GetNumber(),
-					Title:      pr.GetTitle(),
-					Body:       pr.GetBody(),
-					State:      pr.GetState(),
-					IsDraft:    pr.GetDraft(),
-					User:       pr.User.GetLogin(),
-					HeadBranch: pr.Head.GetRef(),
-					BaseBranch: pr.Base.GetRef(),
-					URL:        pr.GetHTMLURL(),
-				})
-			}
+	// Search results don't include branches, so the full pull requests are listed per
+	// repository rather than fetched one by one
+	type repoKey struct{ owner, repo string }
+	var repos []repoKey
+	numbers := make(map[repoKey][]int)
+	for _, ctx := range contexts {
+		results, err := a.api.SearchIssues(ctx, query, "updated", 0)
+		if err != nil {
+			return nil, fmt.Errorf("error searching for pull requests: %w", err)
 		}
 
-		// Get next pages if available
-		for resp != nil && resp.NextPage != 0 {
-			opts.Page = resp.NextPage
-			result, resp, err = a.client.Search.Issues(context.Background(), query, opts)
-			if err != nil {
-				break
+		for _, issue := range results {
+			// Extract owner/repo from the PR URL
+			owner, repo, ok := parseRepoFromURL(issue.GetHTMLURL())
+			if !ok {
+				logging.Warn("Skipping pull request with invalid URL", "url", issue.GetHTMLURL())
+				continue
 			}
 
-			for _, issue := range result.Issues {
-				if issue.PullRequestLinks != nil {
-					pr, _, err := a.client.PullRequests.Get(
-						context.Background(),
-						owner,
-						repo,
-						*issue.Number,
-					)
-
-					if err != nil {
-						continue
-					}
-
-					vcsPRs = append(vcsPRs, &vcs.BasePullRequest{
-						Number:     pr.GetNumber(),
-						Title:      pr.GetTitle(),
-						Body:       pr.GetBody(),
-						State:      pr.GetState(),
-						IsDraft:    pr.GetDraft(),
-						User:       pr.User.GetLogin(),
-						HeadBranch: pr.Head.GetRef(),
-						BaseBranch: pr.Base.GetRef(),
-						URL:        pr.GetHTMLURL(),
-					})
-				}
+			key := repoKey{owner, repo}
+			if _, seen := numbers[key]; !seen {
+				repos = append(repos, key)
 			}
+			numbers[key] = append(numbers[key], issue.GetNumber())
 		}
-	} else {
-		// If search returned no results, try listing all PRs
-		additionalPRs, err := a.checkAllPullRequests(owner, repo, issueNumber)
-		if err == nil {
-			vcsPRs = append(vcsPRs, additionalPRs...)
+	}
+
+	var vcsPRs []vcs.PullRequest
+	for _, key := range repos {
+		prs, err := a.api.PullRequestsByNumber(context.Background(), key.owner, key.repo, "open", numbers[key])
+		if err != nil {
+			logging.Warn("Failed to list pull requests", "repo", key.owner+"/"+key.repo, "error", err)
+			continue
+		}
+		for _, pr := range prs {
+			vcsPRs = append(vcsPRs, toVCSPullRequest(pr, key.owner, key.repo))
 		}
 	}
 
 	return vcsPRs, nil
 }
 
-// checkAllPullRequests gets all PRs in a repo and checks their bodies for issue references
-func (a *Adapter) checkAllPullRequests(owner, repo string, issueNumber int) ([]vcs.PullRequest, error) {
-	var vcsPRs []vcs.PullRequest
-	opts := &github.PullRequestListOptions{
-		State: "all",
-		ListOptions: github.ListOptions{
-			PerPage: 100,
-		},
+// GetPullRequestReviews lists the reviews submitted on a pull request
+func (a *Adapter) GetPullRequestReviews(owner, repo string, number int) ([]vcs.Review, error) {
+	var reviews []vcs.Review
+	for review, err := range a.api.ReviewsIter(context.Background(), owner, repo, number) {
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, vcs.Review{
+			ID:          strconv.FormatInt(review.GetID(), 10),
+			User:        review.GetUser().GetLogin(),
+			State:       review.GetState(),
+			Body:        review.GetBody(),
+			SubmittedAt: review.GetSubmittedAt(),
+		})
 	}
 
-	for {
-		prs, resp, err := a.client.PullRequests.List(
-			context.Background(),
-			owner,
-			repo,
-			opts,
-		)
+	return reviews, nil
+}
 
+// GetReviewComments lists the inline review comments on a pull request
+func (a *Adapter) GetReviewComments(owner, repo string, number int) ([]vcs.ReviewComment, error) {
+	var comments []vcs.ReviewComment
+	for comment, err := range a.api.ReviewCommentsIter(context.Background(), owner, repo, number) {
 		if err != nil {
-			return nil, fmt.Errorf("failed to list PRs: %w", err)
+			return nil, err
 		}
 
-		// Check each PR body for the issue number
-		issueRef := fmt.Sprintf("#%d", issueNumber)
-		for _, pr := range prs {
-			if pr.Body != nil && strings.Contains(*pr.Body, issueRef) {
-				vcsPRs = append(vcsPRs, &vcs.BasePullRequest{
-					Number:     pr.GetNumber(),
-					Title:      pr.GetTitle(),
-					Body:       pr.GetBody(),
-					State:      pr.GetState(),
-					IsDraft:    pr.GetDraft(),
-					User:       pr.User.GetLogin(),
-					HeadBranch: pr.Head.GetRef(),
-					BaseBranch: pr.Base.GetRef(),
-					URL:        pr.GetHTMLURL(),
-				})
-			}
+		inReplyTo := ""
+		if comment.InReplyTo != nil {
+			inReplyTo = strconv.FormatInt(comment.GetInReplyTo(), 10)
+		}
+
+		// Outdated comments only have the line they were originally left on
+		line := comment.GetLine()
+		if line == 0 {
+			line = comment.GetOriginalLine()
+		}
+
+		comments = append(comments, vcs.ReviewComment{
+			ID:        strconv.FormatInt(comment.GetID(), 10),
+			ReviewID:  strconv.FormatInt(comment.GetPullRequestReviewID(), 10),
+			InReplyTo: inReplyTo,
+			User:      comment.GetUser().GetLogin(),
+			Body:      comment.GetBody(),
+			Path:      comment.GetPath(),
+			Line:      line,
+			DiffHunk:  comment.GetDiffHunk(),
+			CreatedAt: comment.GetCreatedAt(),
+		})
+	}
+
+	return comments, nil
+}
+
+// ReplyToReviewComment replies in the thread of an inline review comment
+func (a *Adapter) ReplyToReviewComment(owner, repo string, number int, commentID, body string) error {
+	id, err := strconv.ParseInt(commentID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid review comment ID %q: %w", commentID, err)
+	}
+
+	_, err = a.api.ReplyToReviewComment(context.Background(), owner, repo, number, id, body)
+	return err
+}
+
+// GetPullRequestChecks gets the commit statuses and check runs for a pull request's head commit
+func (a *Adapter) GetPullRequestChecks(owner, repo string, number int) (*vcs.CheckStatus, error) {
+	ctx := context.Background()
+
+	pr, err := a.api.PullRequest(ctx, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	sha := pr.GetHead().GetSHA()
+	status := &vcs.CheckStatus{HeadSHA: sha}
+
+	// Commit statuses reported by external CI services
+	for repoStatus, err := range a.api.CommitStatusesIter(ctx, owner, repo, sha) {
+		if err != nil {
+			return nil, err
 		}
+		status.Checks = append(status.Checks, vcs.CheckResult{
+			ID:      strconv.FormatInt(repoStatus.GetID(), 10),
+			Name:    repoStatus.GetContext(),
+			State:   commitStatusState(repoStatus.GetState()),
+			Summary: repoStatus.GetDescription(),
+			URL:     repoStatus.GetTargetURL(),
+		})
+	}
 
-		if resp.NextPage == 0 {
+	// Check runs, including GitHub Actions jobs
+	for run, err := range a.api.CheckRunsIter(ctx, owner, repo, sha) {
+		if err != nil {
+			return nil, err
+		}
+		status.Checks = append(status.Checks, vcs.CheckResult{
+			ID:      strconv.FormatInt(run.GetID(), 10),
+			Name:    run.GetName(),
+			State:   checkRunState(run.GetStatus(), run.GetConclusion()),
+			Summary: run.GetOutput().GetSummary(),
+			URL:     run.GetHTMLURL(),
+			// GitHub Actions check runs share their ID with the job, whose log can be downloaded
+			HasLogs: run.GetApp().GetSlug() == "github-actions",
+		})
+	}
+
+	status.State = vcs.CheckSuccess
+	for _, check := range status.Checks {
+		if check.State == vcs.CheckFailure {
+			status.State = vcs.CheckFailure
 			break
 		}
+		if check.State == vcs.CheckPending {
+			status.State = vcs.CheckPending
+		}
+	}
+
+	return status, nil
+}
 
-		opts.Page = resp.NextPage
+// GetCheckLogs downloads the job log for a failing check. Checks without a
+// downloadable log return their summary instead.
+func (a *Adapter) GetCheckLogs(owner, repo string, check vcs.CheckResult) (string, error) {
+	if !check.HasLogs {
+		return check.Summary, nil
 	}
 
-	return vcsPRs, nil
+	jobID, err := strconv.ParseInt(check.ID, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid job ID %q: %w", check.ID, err)
+	}
+
+	logURL, _, err := a.client.Actions.GetWorkflowJobLogs(context.Background(), owner, repo, jobID, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to get job log location: %w", err)
+	}
+
+	// The log URL is pre-signed, so it is fetched without the API credentials
+	resp, err := http.Get(logURL.String())
+	if err != nil {
+		return "", fmt.Errorf("failed to download job log: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			logging.Warn("Failed to close job log response", "error", closeErr)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download job log: unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read job log: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// commitStatusState maps a GitHub commit status state to a check state
+func commitStatusState(state string) string {
+	switch state {
+	case "success":
+		return vcs.CheckSuccess
+	case "failure", "error":
+		return vcs.CheckFailure
+	default:
+		return vcs.CheckPending
+	}
+}
+
+// checkRunState maps a GitHub check run status and conclusion to a check state
+func checkRunState(status, conclusion string) string {
+	if status != "completed" {
+		return vcs.CheckPending
+	}
+
+	switch conclusion {
+	case "failure", "timed_out", "cancelled", "action_required", "startup_failure":
+		return vcs.CheckFailure
+	default:
+		return vcs.CheckSuccess
+	}
 }
 
 // GetAuthenticatedUser gets the currently authenticated user
@@ -564,77 +642,106 @@ func (a *Adapter) GetAuthenticatedUser() (string, error) {
 	return a.username, nil
 }
 
-// GetRepositories gets a list of repositories the authenticated user has access to
-func (a *Adapter) GetRepositories() ([]vcs.Repository, error) {
-	var allRepos []vcs.Repository
-	opts := &github.RepositoryListOptions{
-		ListOptions: github.ListOptions{
-			PerPage: 100,
-		},
+// RateLimits returns the remaining API quota, as last reported by GitHub
+func (a *Adapter) RateLimits() []vcs.RateLimit {
+	quotas := ratelimit.Quotas()
+	limits := make([]vcs.RateLimit, 0, len(quotas))
+	for _, q := range quotas {
+		limits = append(limits, vcs.RateLimit{
+			Resource:  q.Resource,
+			Limit:     q.Limit,
+			Remaining: q.Remaining,
+			Reset:     q.Reset,
+		})
 	}
+	return limits
+}
 
-	for {
-		repos, resp, err := a.client.Repositories.List(context.Background(), "", opts)
+// CheckAuth checks that GitHub still accepts the bot's credentials. It reads the rate
+// limit endpoint, which doesn't count against the quota.
+func (a *Adapter) CheckAuth() error {
+	if _, _, err := a.client.RateLimits(context.Background()); err != nil {
+		return fmt.Errorf("failed to authenticate with GitHub: %w", err)
+	}
+	return nil
+}
+
+// GetUserPermission returns the permission level a user has on a repository
+func (a *Adapter) GetUserPermission(owner, repo, username string) (string, error) {
+	return a.api.UserPermission(context.Background(), owner, repo, username)
+}
+
+// GetRepositories gets all repositories accessible to the authenticated user
+func (a *Adapter) GetRepositories() ([]vcs.Repository, error) {
+	return collect(a.Repositories(), 0)
+}
+
+// Repositories streams the repositories of the authenticated user, or of every
+// installation for a GitHub App
+func (a *Adapter) Repositories() iter.Seq2[vcs.Repository, error] {
+	return func(yield func(vcs.Repository, error) bool) {
+		contexts, err := a.installationContexts()
 		if err != nil {
-			return nil, fmt.Errorf("failed to list repositories: %w", err)
+			yield(nil, err)
+			return
 		}
 
-		// Convert GitHub repos to our Repository interface
-		for _, repo := range repos {
-			vcsRepo := &vcs.BaseRepository{
-				Owner:           repo.GetOwner().GetLogin(),
-				Name:            repo.GetName(),
-				DefaultBranch:   repo.GetDefaultBranch(),
-				URL:             repo.GetHTMLURL(),
-				Description:     repo.GetDescription(),
-				HasIssues:       repo.GetHasIssues(),
-				StargazersCount: repo.GetStargazersCount(),
-				ForksCount:      repo.GetForksCount(),
+		for _, ctx := range contexts {
+			repos := a.api.UserRepositoriesIter(ctx)
+			if a.app != nil {
+				repos = a.api.InstallationRepositoriesIter(ctx)
 			}
-			allRepos = append(allRepos, vcsRepo)
-		}
 
-		if resp.NextPage == 0 {
-			break
-		}
+			for repo, err := range repos {
+				if err != nil {
+					yield(nil, err)
+					return
+				}
 
-		opts.Page = resp.NextPage
+				vcsRepo := &vcs.BaseRepository{
+					Owner:           repo.GetOwner().GetLogin(),
+					Name:            repo.GetName(),
+					DefaultBranch:   repo.GetDefaultBranch(),
+					URL:             repo.GetHTMLURL(),
+					Description:     repo.GetDescription(),
+					HasIssues:       repo.GetHasIssues(),
+					StargazersCount: repo.GetStargazersCount(),
+					ForksCount:      repo.GetForksCount(),
+				}
+				if !yield(vcsRepo, nil) {
+					return
+				}
+			}
+		}
 	}
-
-	return allRepos, nil
 }
 
-// convertGitHubIssue converts a GitHub issue to vcs.Issue
-func (a *Adapter) convertGitHubIssue(issue *github.Issue, owner, repo string) vcs.Issue {
-	baseIssue := &vcs.BaseIssue{
-		Owner:     owner,
-		Repo:      repo,
-		Number:    issue.GetNumber(),
-		Title:     issue.GetTitle(),
-		Body:      issue.GetBody(),
-		User:      issue.User.GetLogin(),
-		State:     issue.GetState(),
-		CreatedAt: issue.GetCreatedAt(),
-		UpdatedAt: issue.GetUpdatedAt(),
-		URL:       issue.GetHTMLURL(),
-		Comments:  []vcs.IssueComment{},
-		Labels:    make([]string, 0, len(issue.Labels)),
-		Assignees: make([]string, 0, len(issue.Assignees)),
+// parseRepoFromURL extracts the owner and repository from an issue or pull request URL.
+// It works from the end of the path so hosts serving GitHub under a path prefix parse too.
+func parseRepoFromURL(rawURL string) (string, string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", false
 	}
 
-	// Add labels
-	for _, label := range issue.Labels {
-		if label.Name != nil {
-			baseIssue.Labels = append(baseIssue.Labels, *label.Name)
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i := len(segments) - 2; i >= 2; i-- {
+		switch segments[i] {
+		case "issues", "pull", "pulls":
+			if segments[i-2] != "" && segments[i-1] != "" {
+				return segments[i-2], segments[i-1], true
+			}
 		}
 	}
 
-	// Add assignees
-	for _, assignee := range issue.Assignees {
-		if assignee.Login != nil {
-			baseIssue.Assignees = append(baseIssue.Assignees, *assignee.Login)
-		}
-	}
+	return "", "", false
+}
 
-	return baseIssue
+// issueNumberFromURL returns the number at the end of an issue or pull request URL, or 0
+func issueNumberFromURL(rawURL string) int {
+	number, err := strconv.Atoi(rawURL[strings.LastIndex(rawURL, "/")+1:])
+	if err != nil {
+		return 0
+	}
+	return number
 }
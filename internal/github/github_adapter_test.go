@@ -0,0 +1,61 @@
+package github
+
+import (
+	"testing"
+
+	"github.com/hellausefulsoftware/useful1/internal/auth"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/config"
+)
+
+func TestParseRepoFromURL(t *testing.T) {
+	tests := []struct {
+		url       string
+		wantOwner string
+		wantRepo  string
+		wantOK    bool
+	}{
+		{"https://github.com/octo/demo/issues/12", "octo", "demo", true},
+		{"https://github.com/octo/demo/pull/7", "octo", "demo", true},
+		{"https://ghe.example.com/octo/demo/issues/12", "octo", "demo", true},
+		{"https://example.com/github/octo/demo/pull/7", "octo", "demo", true},
+		{"https://example.com/a/b/octo/issues/issues/3", "octo", "issues", true},
+		{"https://github.com/octo/demo", "", "", false},
+		{"not a url\x7f", "", "", false},
+	}
+
+	for _, tt := range tests {
+		owner, repo, ok := parseRepoFromURL(tt.url)
+		if owner != tt.wantOwner || repo != tt.wantRepo || ok != tt.wantOK {
+			t.Errorf("parseRepoFromURL(%q) = %q, %q, %v; want %q, %q, %v",
+				tt.url, owner, repo, ok, tt.wantOwner, tt.wantRepo, tt.wantOK)
+		}
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	query := vcs.IssueQuery{
+		Mentions:      "useful1[bot]",
+		Labels:        []string{"useful1:auto"},
+		ExcludeLabels: []string{"needs design"},
+		Milestone:     "v2.0",
+		Repos:         []string{"acme/api", "acme/web"},
+		Raw:           "no:assignee",
+	}
+	want := `mentions:useful1 label:"useful1:auto" -label:"needs design" milestone:"v2.0" repo:acme/api repo:acme/web no:assignee is:issue is:open`
+
+	adapter := &Adapter{config: &config.Config{}}
+	if got := adapter.searchQuery(query); got != want {
+		t.Errorf("searchQuery() = %s, want %s", got, want)
+	}
+
+	// GitHub Apps can't be assigned issues and search for their trigger label instead
+	if got := adapter.searchQuery(vcs.IssueQuery{Assignee: "alice"}); got != "assignee:alice is:issue is:open" {
+		t.Errorf("searchQuery(assignee) = %s", got)
+	}
+	adapter.app = &auth.GitHubApp{}
+	adapter.config.GitHubApp.TriggerLabel = "bot-please"
+	if got := adapter.searchQuery(vcs.IssueQuery{Assignee: "app[bot]"}); got != `label:"bot-please" is:issue is:open` {
+		t.Errorf("searchQuery(assignee) for an app = %s", got)
+	}
+}
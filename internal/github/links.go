@@ -0,0 +1,228 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+)
+
+// issueLinksQuery reads the pull requests an issue's timeline links to. Cross-references
+// come from mentions in pull request bodies, titles and commits; connections are made
+// through the "Development" sidebar. Each pull request's closing references tell whether
+// merging it closes the issue.
+const issueLinksQuery = `
+query($owner: String!, $repo: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $repo) {
+    issue(number: $number) {
+      timelineItems(first: 100, after: $cursor, itemTypes: [CROSS_REFERENCED_EVENT, CONNECTED_EVENT]) {
+        pageInfo { hasNextPage endCursor }
+        nodes {
+          ... on CrossReferencedEvent { source { ...linkedPullRequest } }
+          ... on ConnectedEvent { subject { ...linkedPullRequest } }
+        }
+      }
+    }
+  }
+}
+
+fragment linkedPullRequest on PullRequest {
+  number
+  title
+  body
+  state
+  isDraft
+  url
+  author { __typename login }
+  headRefName
+  baseRefName
+  repository { name owner { login } }
+  closingIssuesReferences(first: 100) {
+    nodes { number repository { name owner { login } } }
+  }
+}`
+
+// graphQLRepository identifies a repository in GraphQL responses
+type graphQLRepository struct {
+	Name  string `json:"name"`
+	Owner struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// graphQLPullRequest is a pull request read through the linkedPullRequest fragment. Other
+// timeline sources, such as issues, decode with a zero number.
+type graphQLPullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	State   string `json:"state"`
+	IsDraft bool   `json:"isDraft"`
+	URL     string `json:"url"`
+	Author  *struct {
+		Typename string `json:"__typename"`
+		Login    string `json:"login"`
+	} `json:"author"`
+	HeadRefName             string            `json:"headRefName"`
+	BaseRefName             string            `json:"baseRefName"`
+	Repository              graphQLRepository `json:"repository"`
+	ClosingIssuesReferences struct {
+		Nodes []struct {
+			Number     int               `json:"number"`
+			Repository graphQLRepository `json:"repository"`
+		} `json:"nodes"`
+	} `json:"closingIssuesReferences"`
+}
+
+// issueLinksResponse is the data returned by issueLinksQuery
+type issueLinksResponse struct {
+	Repository *struct {
+		Issue *struct {
+			TimelineItems struct {
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Nodes []struct {
+					Source  *graphQLPullRequest `json:"source"`
+					Subject *graphQLPullRequest `json:"subject"`
+				} `json:"nodes"`
+			} `json:"timelineItems"`
+		} `json:"issue"`
+	} `json:"repository"`
+}
+
+// IssueLink is a pull request linked to an issue
+type IssueLink struct {
+	PullRequest *github.PullRequest
+	Owner       string
+	Repo        string
+	Closes      bool // merging the pull request closes the issue
+	Names       bool // the pull request names the issue in a closing keyword or its branch, as the bot's do
+}
+
+// IssueLinks returns the pull requests that close or cross-reference an issue, each once,
+// in timeline order. Unlike a text search, "#12" never matches issue #123.
+func (a *API) IssueLinks(ctx context.Context, owner, repo string, number int) ([]IssueLink, error) {
+	var links []IssueLink
+	seen := make(map[string]bool)
+	variables := map[string]interface{}{"owner": owner, "repo": repo, "number": number}
+
+	for {
+		var data issueLinksResponse
+		if err := a.graphQL(ctx, issueLinksQuery, variables, &data); err != nil {
+			return nil, fmt.Errorf("failed to get pull requests linked to issue: %w", err)
+		}
+		if data.Repository == nil || data.Repository.Issue == nil {
+			return nil, fmt.Errorf("issue %s/%s#%d not found", owner, repo, number)
+		}
+
+		timeline := data.Repository.Issue.TimelineItems
+		for _, node := range timeline.Nodes {
+			pr := node.Source
+			if pr == nil {
+				pr = node.Subject
+			}
+			if pr == nil || pr.Number == 0 {
+				continue
+			}
+
+			key := fmt.Sprintf("%s/%s#%d", pr.Repository.Owner.Login, pr.Repository.Name, pr.Number)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			links = append(links, IssueLink{
+				PullRequest: pr.toPullRequest(),
+				Owner:       pr.Repository.Owner.Login,
+				Repo:        pr.Repository.Name,
+				Closes:      pr.closes(owner, repo, number),
+				Names:       pr.names(owner, repo, number),
+			})
+		}
+
+		if !timeline.PageInfo.HasNextPage {
+			return links, nil
+		}
+		variables["cursor"] = timeline.PageInfo.EndCursor
+	}
+}
+
+// closes reports whether merging the pull request closes the issue
+func (p *graphQLPullRequest) closes(owner, repo string, number int) bool {
+	for _, ref := range p.ClosingIssuesReferences.Nodes {
+		if ref.Number == number &&
+			strings.EqualFold(ref.Repository.Owner.Login, owner) &&
+			strings.EqualFold(ref.Repository.Name, repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// names reports whether the pull request names the issue the way the bot's pull requests
+// do: with a closing keyword in the body, or with issue-N in the branch name. GitHub only
+// records closing references for pull requests into the default branch, so this also
+// finds the bot's pull requests into other branches.
+func (p *graphQLPullRequest) names(owner, repo string, number int) bool {
+	branch := regexp.MustCompile(fmt.Sprintf(`(^|[/-])issue-%d($|-)`, number))
+	if branch.MatchString(p.HeadRefName) {
+		return true
+	}
+
+	// Short references in the body only point at issues in the pull request's repository
+	if !strings.EqualFold(p.Repository.Owner.Login, owner) || !strings.EqualFold(p.Repository.Name, repo) {
+		return false
+	}
+	keyword := regexp.MustCompile(fmt.Sprintf(`(?i)\b(close[sd]?|fix(e[sd])?|resolve[sd]?) #%d\b`, number))
+	return keyword.MatchString(p.Body)
+}
+
+// toPullRequest converts the GraphQL fields to their REST equivalents
+func (p *graphQLPullRequest) toPullRequest() *github.PullRequest {
+	// GraphQL states are OPEN, CLOSED and MERGED; REST reports merged pull requests as closed
+	state := strings.ToLower(p.State)
+	merged := state == "merged"
+	if merged {
+		state = "closed"
+	}
+
+	pr := &github.PullRequest{
+		Number:  github.Int(p.Number),
+		Title:   github.String(p.Title),
+		Body:    github.String(p.Body),
+		State:   github.String(state),
+		Draft:   github.Bool(p.IsDraft),
+		Merged:  github.Bool(merged),
+		HTMLURL: github.String(p.URL),
+		Head:    &github.PullRequestBranch{Ref: github.String(p.HeadRefName)},
+		Base:    &github.PullRequestBranch{Ref: github.String(p.BaseRefName)},
+	}
+	if p.Author != nil {
+		// GraphQL logins of app bots lack the "[bot]" suffix REST logins carry
+		login := p.Author.Login
+		if p.Author.Typename == "Bot" {
+			login += "[bot]"
+		}
+		pr.User = &github.User{Login: github.String(login)}
+	}
+	return pr
+}
+
+// linkType classifies a link for the user the bot acts as. Whether the bot opened the pull
+// request for the issue is decided apart from whether it closes it, since the bot's pull
+// requests into a non-default branch don't.
+func linkType(link IssueLink, username string) vcs.LinkType {
+	byBot := username != "" && strings.EqualFold(link.PullRequest.GetUser().GetLogin(), username)
+	switch {
+	case byBot && (link.Closes || link.Names):
+		return vcs.LinkCreatedByBot
+	case link.Closes:
+		return vcs.LinkCloses
+	}
+	return vcs.LinkMentions
+}
@@ -0,0 +1,51 @@
+package github
+
+import (
+	"iter"
+
+	"github.com/google/go-github/v45/github"
+)
+
+// pages streams the items of a paginated list endpoint. fetch requests one page, where
+// zero is the first; the next page is only requested once the caller has consumed the
+// previous one, so breaking out of the loop stops fetching. An error ends the stream.
+func pages[T any](fetch func(page int) ([]T, *github.Response, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		page := 0
+		for {
+			items, resp, err := fetch(page)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			if resp == nil || resp.NextPage == 0 {
+				return
+			}
+			page = resp.NextPage
+		}
+	}
+}
+
+// collect reads up to limit items of a stream into a slice. A limit of zero or less reads
+// the whole stream.
+func collect[T any](seq iter.Seq2[T, error], limit int) ([]T, error) {
+	var all []T
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, item)
+		if limit > 0 && len(all) >= limit {
+			break
+		}
+	}
+	return all, nil
+}
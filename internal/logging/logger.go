@@ -84,12 +84,16 @@ func Initialize(cfg *Config) {
 // GetLogger returns the default logger
 func GetLogger() *slog.Logger {
 	loggerMu.Lock()
-	defer loggerMu.Unlock()
+	ready := initialized
+	loggerMu.Unlock()
 
-	if !initialized {
+	// Initialize takes the lock itself, so it must be called without holding it
+	if !ready {
 		Initialize(nil)
 	}
 
+	loggerMu.Lock()
+	defer loggerMu.Unlock()
 	return defaultLogger
 }
 
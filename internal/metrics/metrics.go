@@ -0,0 +1,298 @@
+// Package metrics exposes what the monitor is doing in the Prometheus text format, with
+// health and readiness checks for running it as a service.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/anthropic"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/events"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+)
+
+// durationBuckets are the upper bounds of the duration histograms, in seconds
+var durationBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// authCheckInterval is how long /readyz reuses the result of a VCS auth check
+const authCheckInterval = 30 * time.Second
+
+// histogram counts observations into durationBuckets
+type histogram struct {
+	buckets []uint64 // observations per bucket, not cumulative; the last one is +Inf
+	count   uint64
+	sum     float64
+}
+
+// observe adds an observation
+func (h *histogram) observe(value float64) {
+	if h.buckets == nil {
+		h.buckets = make([]uint64, len(durationBuckets)+1)
+	}
+	i := sort.SearchFloat64s(durationBuckets, value)
+	h.buckets[i]++
+	h.count++
+	h.sum += value
+}
+
+// Collector gathers metrics from the events of the monitor and the pipeline. Token usage
+// and rate limits are read when the metrics are scraped.
+type Collector struct {
+	service vcs.Service
+	now     func() time.Time
+
+	mutex     sync.Mutex
+	polls     uint64
+	seen      uint64
+	skipped   uint64
+	processed uint64
+	stages    map[string]*histogram
+	agent     histogram
+	failures  map[string]uint64
+
+	authMutex   sync.Mutex
+	authChecked time.Time
+	authErr     error
+}
+
+// NewCollector creates a collector fed by the events published on bus. The service's
+// rate limits are exported, and its credentials are checked for readiness.
+func NewCollector(bus *events.Bus, service vcs.Service) *Collector {
+	c := &Collector{
+		service:  service,
+		now:      time.Now,
+		stages:   make(map[string]*histogram),
+		failures: make(map[string]uint64),
+	}
+	bus.Subscribe(c.handle)
+	return c
+}
+
+// handle updates the metrics an event affects
+func (c *Collector) handle(event events.Event) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	switch event.Type {
+	case events.TypePollFinished:
+		c.polls++
+		if stats, ok := event.Data.(events.PollStats); ok {
+			c.seen += uint64(stats.Found)
+			c.skipped += uint64(stats.Skipped)
+			c.processed += uint64(stats.Processed)
+		}
+	case events.TypeStageFinished:
+		h, ok := c.stages[event.Stage]
+		if !ok {
+			h = &histogram{}
+			c.stages[event.Stage] = h
+		}
+		h.observe(event.Duration)
+	case events.TypeAgentFinished:
+		c.agent.observe(event.Duration)
+	case events.TypeError:
+		category := event.Category
+		if category == "" {
+			category = "other"
+		}
+		c.failures[category]++
+	}
+}
+
+// Handler serves /metrics, /healthz and /readyz
+func (c *Collector) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := c.WriteMetrics(w); err != nil {
+			logging.Warn("Failed to write metrics", "error", err)
+		}
+	})
+	// The process is alive as long as it can answer
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	// It is ready once the VCS accepts its credentials
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := c.checkAuth(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok\n")
+	})
+	return mux
+}
+
+// checkAuth checks the service's credentials, reusing the last result for authCheckInterval
+// so probes don't use up the API quota
+func (c *Collector) checkAuth() error {
+	c.authMutex.Lock()
+	defer c.authMutex.Unlock()
+
+	if !c.authChecked.IsZero() && c.now().Sub(c.authChecked) < authCheckInterval {
+		return c.authErr
+	}
+
+	if checker, ok := c.service.(vcs.AuthChecker); ok {
+		c.authErr = checker.CheckAuth()
+	} else {
+		_, c.authErr = c.service.GetAuthenticatedUser()
+	}
+	if c.authErr != nil {
+		logging.Warn("VCS auth check failed, reporting not ready", "error", c.authErr)
+	}
+	c.authChecked = c.now()
+	return c.authErr
+}
+
+// WriteMetrics writes every metric in the Prometheus text format
+func (c *Collector) WriteMetrics(w io.Writer) error {
+	var e exposition
+
+	c.mutex.Lock()
+	e.header("useful1_polls_total", "counter", "Polls of the monitor for issues matching the trigger rules.")
+	e.sample("useful1_polls_total", nil, float64(c.polls))
+	e.header("useful1_issues_seen_total", "counter", "Issues found by the monitor's searches.")
+	e.sample("useful1_issues_seen_total", nil, float64(c.seen))
+	e.header("useful1_issues_skipped_total", "counter", "Issues found but not handed to the pipeline: filtered out, unchanged or deferred.")
+	e.sample("useful1_issues_skipped_total", nil, float64(c.skipped))
+	e.header("useful1_issues_processed_total", "counter", "Issues handed to the pipeline, including retries.")
+	e.sample("useful1_issues_processed_total", nil, float64(c.processed))
+
+	e.header("useful1_stage_duration_seconds", "histogram", "Time runs spent in each stage.")
+	for _, stage := range sortedKeys(c.stages) {
+		e.histogram("useful1_stage_duration_seconds", []string{"stage", stage}, c.stages[stage])
+	}
+	e.header("useful1_agent_runtime_seconds", "histogram", "Time the agent CLI ran for.")
+	e.histogram("useful1_agent_runtime_seconds", nil, &c.agent)
+
+	e.header("useful1_failures_total", "counter", "Failures by category: search and process errors in the monitor, transient and permanent run failures.")
+	for _, category := range sortedKeys(c.failures) {
+		e.sample("useful1_failures_total", []string{"category", category}, float64(c.failures[category]))
+	}
+	c.mutex.Unlock()
+
+	usage := anthropic.Usage()
+	e.header("useful1_llm_calls_total", "counter", "Model API calls made by the bot itself.")
+	for _, u := range usage {
+		e.sample("useful1_llm_calls_total", []string{"model", u.Model}, float64(u.Calls))
+	}
+	e.header("useful1_llm_tokens_total", "counter", "Tokens used by the bot's own model calls.")
+	for _, u := range usage {
+		e.sample("useful1_llm_tokens_total", []string{"model", u.Model, "direction", "input"}, float64(u.InputTokens))
+		e.sample("useful1_llm_tokens_total", []string{"model", u.Model, "direction", "output"}, float64(u.OutputTokens))
+	}
+	e.header("useful1_llm_cost_usd_total", "counter", "Cost of the bot's own model calls at list prices, in USD.")
+	for _, u := range usage {
+		e.sample("useful1_llm_cost_usd_total", []string{"model", u.Model}, u.CostUSD)
+	}
+
+	if reporter, ok := c.service.(vcs.RateLimitReporter); ok {
+		limits := reporter.RateLimits()
+		e.header("useful1_github_rate_limit_remaining", "gauge", "API requests left in the current rate limit window, as last reported by GitHub.")
+		for _, limit := range limits {
+			e.sample("useful1_github_rate_limit_remaining", []string{"resource", limit.Resource}, float64(limit.Remaining))
+		}
+		e.header("useful1_github_rate_limit_limit", "gauge", "API requests allowed per rate limit window.")
+		for _, limit := range limits {
+			e.sample("useful1_github_rate_limit_limit", []string{"resource", limit.Resource}, float64(limit.Limit))
+		}
+	}
+
+	_, err := io.WriteString(w, e.String())
+	return err
+}
+
+// ListenAndServe serves the collector's handler on addr until ctx is done. It returns once
+// the address is bound, so a port that is already in use is reported right away.
+func ListenAndServe(ctx context.Context, addr string, c *Collector) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for metrics: %w", err)
+	}
+
+	server := &http.Server{Handler: c.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	context.AfterFunc(ctx, func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	})
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Error("Metrics server stopped", "error", err)
+		}
+	}()
+	logging.Info("Serving metrics and health checks", "address", listener.Addr().String())
+	return nil
+}
+
+// exposition builds a page in the Prometheus text format
+type exposition struct {
+	strings.Builder
+}
+
+// header writes the HELP and TYPE lines of a metric
+func (e *exposition) header(name, kind, help string) {
+	fmt.Fprintf(e, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes a value with labels given as name, value pairs
+func (e *exposition) sample(name string, labels []string, value float64) {
+	e.WriteString(name)
+	if len(labels) > 0 {
+		e.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				e.WriteByte(',')
+			}
+			fmt.Fprintf(e, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		e.WriteByte('}')
+	}
+	e.WriteString(" " + formatValue(value) + "\n")
+}
+
+// histogram writes the cumulative buckets, sum and count of a histogram
+func (e *exposition) histogram(name string, labels []string, h *histogram) {
+	var cumulative uint64
+	for i, bound := range durationBuckets {
+		if h.buckets != nil {
+			cumulative += h.buckets[i]
+		}
+		e.sample(name+"_bucket", append(labels[:len(labels):len(labels)], "le", formatValue(bound)), float64(cumulative))
+	}
+	e.sample(name+"_bucket", append(labels[:len(labels):len(labels)], "le", "+Inf"), float64(h.count))
+	e.sample(name+"_sum", labels, h.sum)
+	e.sample(name+"_count", labels, float64(h.count))
+}
+
+// escapeLabel escapes a label value as the text format requires
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// formatValue formats a sample value as briefly as it can be read back exactly
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of a map in order, so the output is stable between scrapes
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
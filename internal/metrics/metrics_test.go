@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/events"
+)
+
+// authService fails its auth check with authErr and counts the checks
+type authService struct {
+	vcs.Service
+	authErr error
+	checks  int
+}
+
+func (s *authService) CheckAuth() error {
+	s.checks++
+	return s.authErr
+}
+
+func (s *authService) RateLimits() []vcs.RateLimit {
+	return []vcs.RateLimit{{Resource: "core", Limit: 5000, Remaining: 4321}}
+}
+
+func TestCollectorMetrics(t *testing.T) {
+	bus := events.NewBus()
+	c := NewCollector(bus, &authService{})
+
+	bus.Publish(events.Event{Type: events.TypePollFinished, Data: events.PollStats{Found: 5, Skipped: 3, Processed: 2}})
+	bus.Publish(events.Event{Type: events.TypePollFinished, Data: events.PollStats{Found: 1, Processed: 1}})
+	bus.Publish(events.Event{Type: events.TypeStageFinished, Stage: `Branch "fix"`, Duration: 12})
+	bus.Publish(events.Event{Type: events.TypeAgentFinished, Duration: 400})
+	bus.Publish(events.Event{Type: events.TypeError, Category: "transient"})
+	bus.Publish(events.Event{Type: events.TypeError, Category: "transient"})
+	bus.Publish(events.Event{Type: events.TypeError})
+
+	var out strings.Builder
+	if err := c.WriteMetrics(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"useful1_polls_total 2\n",
+		"useful1_issues_seen_total 6\n",
+		"useful1_issues_skipped_total 3\n",
+		"useful1_issues_processed_total 3\n",
+		`useful1_stage_duration_seconds_bucket{stage="Branch \"fix\"",le="5"} 0` + "\n",
+		`useful1_stage_duration_seconds_bucket{stage="Branch \"fix\"",le="15"} 1` + "\n",
+		`useful1_stage_duration_seconds_sum{stage="Branch \"fix\""} 12` + "\n",
+		`useful1_agent_runtime_seconds_bucket{le="300"} 0` + "\n",
+		`useful1_agent_runtime_seconds_bucket{le="+Inf"} 1` + "\n",
+		`useful1_failures_total{category="other"} 1` + "\n",
+		`useful1_failures_total{category="transient"} 2` + "\n",
+		`useful1_github_rate_limit_remaining{resource="core"} 4321` + "\n",
+		"# TYPE useful1_llm_tokens_total counter\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics are missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestReadyzFailsWhenAuthFails(t *testing.T) {
+	service := &authService{authErr: errors.New("401 Bad credentials")}
+	c := NewCollector(events.NewBus(), service)
+	handler := c.Handler()
+
+	for _, tt := range []struct {
+		path string
+		want int
+	}{
+		{"/healthz", http.StatusOK},
+		{"/readyz", http.StatusServiceUnavailable},
+		{"/readyz", http.StatusServiceUnavailable},
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if recorder.Code != tt.want {
+			t.Errorf("%s returned %d, want %d", tt.path, recorder.Code, tt.want)
+		}
+	}
+	if service.checks != 1 {
+		t.Errorf("checked auth %d times, want 1 within the check interval", service.checks)
+	}
+}
@@ -20,6 +20,7 @@ type Issue struct {
 	URL       string
 	Labels    []string
 	Assignees []string
+	Milestone string
 }
 
 // IssueComment represents a comment on a GitHub issue
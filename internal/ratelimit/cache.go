@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxCachedBody is the largest response body kept for conditional requests
+const maxCachedBody = 1 << 20
+
+// Cache keeps the most recently used GET responses with their ETags. GitHub doesn't count
+// a conditional request answered with 304 Not Modified against the primary rate limit.
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int        // bytes of the cached bodies
+	order    *list.List // most recently used first
+	entries  map[string]*list.Element
+}
+
+// entry is a cached response
+type entry struct {
+	key    string
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// NewCache creates a cache whose response bodies take up at most maxBytes
+func NewCache(maxBytes int) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached response for key, if any
+func (c *Cache) get(key string) *entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*entry)
+}
+
+// store reads a response into the cache and returns it with its body intact. Responses
+// too large to cache are returned as they are.
+func (c *Cache) store(key string, resp *http.Response) (*http.Response, error) {
+	if resp.ContentLength > maxCachedBody {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBody+1))
+	closeErr := resp.Body.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) > maxCachedBody || len(body) > c.maxBytes {
+		return resp, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &entry{key: key, etag: resp.Header.Get("ETag"), header: resp.Header.Clone(), body: body}
+	if elem, ok := c.entries[key]; ok {
+		c.size -= len(elem.Value.(*entry).body)
+		elem.Value = e
+		c.order.MoveToFront(elem)
+	} else {
+		c.entries[key] = c.order.PushFront(e)
+	}
+	c.size += len(body)
+
+	for c.size > c.maxBytes {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).key)
+		c.size -= len(oldest.Value.(*entry).body)
+	}
+	return resp, nil
+}
+
+// response rebuilds the cached response for req, with the rate limit headers of the
+// 304 response that revalidated it
+func (e *entry) response(req *http.Request, revalidated http.Header) *http.Response {
+	header := e.header.Clone()
+	for name, values := range revalidated {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), "X-Ratelimit-") {
+			header[name] = values
+		}
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
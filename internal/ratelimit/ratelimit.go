@@ -0,0 +1,354 @@
+// Package ratelimit keeps GitHub API clients within their primary and secondary rate limits
+// and answers repeated reads from a cache through conditional requests
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+)
+
+// ErrExhausted means a rate limit won't reset soon enough to wait for it
+var ErrExhausted = errors.New("GitHub API rate limit exhausted")
+
+const (
+	// DefaultMaxWait is the longest a request waits for a rate limit before failing with ErrExhausted
+	DefaultMaxWait = 5 * time.Minute
+
+	// writeInterval spaces out mutating requests, as GitHub asks to avoid secondary rate limits
+	writeInterval = time.Second
+
+	// secondaryWait is how long to back off from a secondary rate limit without a Retry-After header
+	secondaryWait = time.Minute
+
+	// maxRetries is how often a request rejected by a rate limit is retried
+	maxRetries = 2
+)
+
+// Quota is the state of one rate limit, such as "core" or "search"
+type Quota struct {
+	Resource  string
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// Low reports whether the quota is at or below its reserve and hasn't reset yet. Reads
+// wait while the quota is low, leaving the reserve to writes that finish work in progress.
+func (q Quota) Low(now time.Time) bool {
+	return q.Limit > 0 && q.Remaining <= q.Limit/50 && q.Reset.After(now)
+}
+
+// Tracker records the rate limits reported to each credential
+type Tracker struct {
+	mu        sync.Mutex
+	quotas    map[string]Quota     // by identity and resource
+	paused    map[string]time.Time // secondary limit back-off by identity
+	nextWrite map[string]time.Time // earliest next mutating request by identity
+	now       func() time.Time
+}
+
+// NewTracker creates an empty rate limit tracker
+func NewTracker() *Tracker {
+	return &Tracker{
+		quotas:    make(map[string]Quota),
+		paused:    make(map[string]time.Time),
+		nextWrite: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// defaultTracker and defaultCache are shared by every GitHub client, so limits learned
+// by one client hold back the others using the same credentials. The cache keeps up to
+// 32 MiB of response bodies.
+var (
+	defaultTracker = NewTracker()
+	defaultCache   = NewCache(32 << 20)
+)
+
+// Quotas returns the lowest remaining quota per resource across all credentials
+func Quotas() []Quota {
+	return defaultTracker.Quotas()
+}
+
+// Quotas returns the lowest remaining quota per resource across all credentials
+func (t *Tracker) Quotas() []Quota {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lowest := make(map[string]Quota)
+	for _, q := range t.quotas {
+		if current, ok := lowest[q.Resource]; !ok || q.Remaining < current.Remaining {
+			lowest[q.Resource] = q
+		}
+	}
+
+	quotas := make([]Quota, 0, len(lowest))
+	for _, q := range lowest {
+		quotas = append(quotas, q)
+	}
+	sort.Slice(quotas, func(i, j int) bool { return quotas[i].Resource < quotas[j].Resource })
+	return quotas
+}
+
+// reserve returns how long a request must wait before it may be sent. Mutating requests
+// claim their slot so concurrent writers stay spaced apart.
+func (t *Tracker) reserve(identity, resource string, write bool) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	until := t.paused[identity]
+	if q, ok := t.quotas[identity+" "+resource]; ok && q.Reset.After(until) && (q.Remaining <= 0 || (!write && q.Low(now))) {
+		until = q.Reset
+	}
+	if write {
+		if next := t.nextWrite[identity]; next.After(until) {
+			until = next
+		}
+		start := until
+		if start.Before(now) {
+			start = now
+		}
+		t.nextWrite[identity] = start.Add(writeInterval)
+	}
+	return until.Sub(now)
+}
+
+// update records the rate limit headers of a response
+func (t *Tracker) update(identity, resource string, resp *http.Response) {
+	limit, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if r := resp.Header.Get("X-RateLimit-Resource"); r != "" {
+		resource = r
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.quotas[identity+" "+resource] = Quota{
+		Resource:  resource,
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0),
+	}
+}
+
+// limited reports whether a response was rejected by a rate limit and how long to back off.
+// Secondary limits pause every request made with the credential.
+func (t *Tracker) limited(identity string, resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	now := t.now()
+	var wait time.Duration
+	switch {
+	case resp.Header.Get("Retry-After") != "":
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		wait = time.Duration(seconds) * time.Second
+	case resp.Header.Get("X-RateLimit-Remaining") == "0":
+		// Primary limit: update has recorded the reset already
+		reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		return time.Unix(reset, 0).Sub(now), true
+	case resp.StatusCode == http.StatusTooManyRequests || mentionsSecondaryLimit(resp):
+		wait = secondaryWait
+	default:
+		return 0, false // an ordinary permission error
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := now.Add(wait); until.After(t.paused[identity]) {
+		t.paused[identity] = until
+	}
+	return wait, true
+}
+
+// mentionsSecondaryLimit reports whether a 403 response body describes a secondary rate
+// limit, leaving the body readable for the caller
+func mentionsSecondaryLimit(resp *http.Response) bool {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	lower := strings.ToLower(string(body))
+	return strings.Contains(lower, "secondary rate limit") || strings.Contains(lower, "abuse detection")
+}
+
+// Transport is an http.RoundTripper for the GitHub API that waits while a rate limit is
+// exhausted, retries requests rejected by one, spaces out mutating requests and revalidates
+// cached GET responses with If-None-Match. It must sit below the transport that adds
+// credentials, since limits are tracked per Authorization header.
+type Transport struct {
+	Base    http.RoundTripper // nil means http.DefaultTransport
+	MaxWait time.Duration     // longest wait before failing with ErrExhausted
+
+	tracker *Tracker
+	cache   *Cache
+}
+
+// NewTransport wraps base with the rate limit tracker and response cache shared by all clients
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{
+		Base:    base,
+		MaxWait: DefaultMaxWait,
+		tracker: defaultTracker,
+		cache:   defaultCache,
+	}
+}
+
+// RoundTrip sends a request once its rate limit allows it
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	identity := identityOf(req)
+	resource := resourceOf(req)
+	write := req.Method != http.MethodGet && req.Method != http.MethodHead
+	cacheable := req.Method == http.MethodGet && req.Header.Get("If-None-Match") == "" && req.Header.Get("Range") == ""
+	key := identity + " " + req.Header.Get("Accept") + " " + req.URL.String()
+
+	for attempt := 0; ; attempt++ {
+		if err := t.wait(req.Context(), identity, resource, write); err != nil {
+			return nil, err
+		}
+
+		send, err := t.prepare(req, attempt)
+		if err != nil {
+			return nil, err
+		}
+
+		var cached *entry
+		if cacheable {
+			if cached = t.cache.get(key); cached != nil {
+				if send == req {
+					send = req.Clone(req.Context())
+				}
+				send.Header.Set("If-None-Match", cached.etag)
+			}
+		}
+
+		resp, err := t.base().RoundTrip(send)
+		if err != nil {
+			return nil, err
+		}
+		t.tracker.update(identity, resource, resp)
+
+		if wait, limited := t.tracker.limited(identity, resp); limited {
+			if attempt < maxRetries && (req.Body == nil || req.GetBody != nil) && wait <= t.maxWait() {
+				logging.Warn("GitHub API rate limited, retrying",
+					"resource", resource,
+					"status", resp.StatusCode,
+					"wait", wait.Round(time.Second))
+				drain(resp)
+				continue
+			}
+			return resp, nil
+		}
+
+		if cached != nil && resp.StatusCode == http.StatusNotModified {
+			drain(resp)
+			return cached.response(req, resp.Header), nil
+		}
+		if cacheable && resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "" {
+			return t.cache.store(key, resp)
+		}
+		return resp, nil
+	}
+}
+
+// wait sleeps until the request's rate limit allows it, failing with ErrExhausted when
+// that is longer than MaxWait
+func (t *Transport) wait(ctx context.Context, identity, resource string, write bool) error {
+	d := t.tracker.reserve(identity, resource, write)
+	if d <= 0 {
+		return nil
+	}
+	if d > t.maxWait() {
+		return fmt.Errorf("%w: %s quota resets in %s", ErrExhausted, resource, d.Round(time.Second))
+	}
+	if d > writeInterval {
+		logging.Info("Waiting for GitHub API rate limit", "resource", resource, "wait", d.Round(time.Second))
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// prepare returns the request to send for an attempt, with a fresh body for retries
+func (t *Transport) prepare(req *http.Request, attempt int) (*http.Request, error) {
+	if attempt == 0 || req.Body == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rewind request body: %w", err)
+	}
+	retry := req.Clone(req.Context())
+	retry.Body = body
+	return retry, nil
+}
+
+// base returns the transport requests are sent with
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// maxWait returns the longest wait, defaulting when unset
+func (t *Transport) maxWait() time.Duration {
+	if t.MaxWait > 0 {
+		return t.MaxWait
+	}
+	return DefaultMaxWait
+}
+
+// identityOf names the credential a request is made with without keeping the secret
+func identityOf(req *http.Request) string {
+	auth := req.Header.Get("Authorization")
+	if auth == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(auth))
+	return hex.EncodeToString(sum[:8])
+}
+
+// resourceOf guesses which rate limit a request counts against before the response says so
+func resourceOf(req *http.Request) string {
+	switch path := req.URL.Path; {
+	case strings.Contains(path, "/search/"):
+		return "search"
+	case strings.HasSuffix(path, "/graphql"):
+		return "graphql"
+	}
+	return "core"
+}
+
+// drain discards the rest of a response so its connection can be reused
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	_ = resp.Body.Close()
+}
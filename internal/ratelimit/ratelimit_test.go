@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client whose transport has its own tracker and cache
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*http.Client, *Transport, string) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	transport := &Transport{MaxWait: time.Second, tracker: NewTracker(), cache: NewCache(1 << 20)}
+	return &http.Client{Transport: transport}, transport, server.URL
+}
+
+// get fetches url with a token and returns the status and body
+func get(t *testing.T, client *http.Client, url string) (int, string, error) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), nil
+}
+
+// setRate sets the rate limit headers of a response
+func setRate(w http.ResponseWriter, limit, remaining int, reset time.Time) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	w.Header().Set("X-RateLimit-Resource", "core")
+}
+
+func TestTransportConditionalRequests(t *testing.T) {
+	requests, revalidated := 0, 0
+	client, transport, url := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		setRate(w, 5000, 5000-requests, time.Now().Add(time.Hour))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `[{"number":1}]`)
+	})
+
+	for i := 0; i < 3; i++ {
+		status, body, err := get(t, client, url+"/repos/octo/demo/pulls")
+		if err != nil || status != http.StatusOK || body != `[{"number":1}]` {
+			t.Fatalf("request %d = %d %q, %v", i, status, body, err)
+		}
+	}
+	if revalidated != 2 {
+		t.Errorf("revalidated %d times, want 2", revalidated)
+	}
+
+	quotas := transport.tracker.Quotas()
+	if len(quotas) != 1 || quotas[0].Resource != "core" || quotas[0].Remaining != 4997 {
+		t.Errorf("Quotas() = %+v, want core with 4997 remaining from the last 304", quotas)
+	}
+}
+
+func TestCacheEvictsBySize(t *testing.T) {
+	cache := NewCache(10)
+	store := func(key, body string) {
+		t.Helper()
+		resp := &http.Response{Header: http.Header{"Etag": {`"` + key + `"`}}, Body: io.NopCloser(strings.NewReader(body)), ContentLength: -1}
+		resp, err := cache.store(key, resp)
+		if err != nil {
+			t.Fatalf("store(%q) error = %v", key, err)
+		}
+		if got, _ := io.ReadAll(resp.Body); string(got) != body {
+			t.Fatalf("store(%q) returned body %q, want %q", key, got, body)
+		}
+	}
+
+	store("a", "1234")
+	store("b", "1234")
+	cache.get("a") // a is now the most recently used
+	store("c", "1234")
+	store("huge", "12345678901") // larger than the whole cache, so never kept
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "huge": false} {
+		if got := cache.get(key) != nil; got != want {
+			t.Errorf("cached %q = %v, want %v", key, got, want)
+		}
+	}
+	if cache.size != 8 {
+		t.Errorf("cache size = %d, want 8", cache.size)
+	}
+}
+
+func TestTransportSecondaryRateLimit(t *testing.T) {
+	requests := 0
+	client, _, url := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"message":"You have exceeded a secondary rate limit"}`, http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
+
+	status, body, err := get(t, client, url+"/repos/octo/demo")
+	if err != nil || status != http.StatusOK || body != "ok" || requests != 2 {
+		t.Errorf("got %d %q, %v after %d requests; want ok after a retry", status, body, err, requests)
+	}
+}
+
+func TestTransportPrimaryRateLimit(t *testing.T) {
+	requests := 0
+	client, _, url := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		setRate(w, 5000, 90, time.Now().Add(time.Hour))
+		fmt.Fprint(w, "ok")
+	})
+
+	// Writes may use the reserve, but reads wait for the reset, which is too far away
+	if _, _, err := get(t, client, url+"/first"); err != nil {
+		t.Fatalf("first request failed: %v", err)
+	}
+	if _, _, err := get(t, client, url+"/next"); !errors.Is(err, ErrExhausted) {
+		t.Errorf("read with a low quota error = %v, want ErrExhausted", err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, url+"/write", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	if resp, err := client.Do(req); err != nil {
+		t.Errorf("write with a low quota failed: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+
+	// Other credentials have their own quota
+	req, _ = http.NewRequest(http.MethodGet, url+"/anonymous", nil)
+	if resp, err := client.Do(req); err != nil {
+		t.Errorf("request with other credentials failed: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+	if requests != 3 {
+		t.Errorf("server saw %d requests, want 3", requests)
+	}
+}
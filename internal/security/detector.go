@@ -0,0 +1,91 @@
+package security
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Finding is a passage of issue text that looks like an attempt to steer the agent
+type Finding struct {
+	Source string // where the text came from, e.g. "comment by @alice"
+	Rule   string // what the passage seems to ask for
+	Match  string // the passage itself, shortened
+}
+
+// detectorRule flags text that matches a pattern
+type detectorRule struct {
+	description string
+	pattern     *regexp.Regexp
+	trustedOK   bool // trusted authors may ask for this, so only untrusted text is flagged
+}
+
+// detectorRules are the patterns the detector looks for. They err on the side of
+// flagging, since a false positive only costs a maintainer a look at the issue.
+var detectorRules = []detectorRule{
+	{
+		description: "tries to override the bot's instructions",
+		pattern:     regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b[^.\n]{0,30}\b(previous|prior|above|earlier|system|your|all)\b[^.\n]{0,20}\b(instructions|prompts?|rules|guidelines)\b|\byou are now\b|\bnew instructions\s*:`),
+	},
+	{
+		description: "reads environment variables",
+		pattern:     regexp.MustCompile(`(?i)\bprintenv\b|\benv\s*[|>]|/proc/(self|\d+)/environ|\$\{?[A-Z_]*(TOKEN|SECRET|API_KEY|PASSWORD|CREDENTIALS?)[A-Z_]*\}?`),
+	},
+	{
+		description: "asks for secrets",
+		pattern:     regexp.MustCompile(`(?i)\b(print|dump|list|send|post|upload|output|echo|show|reveal|leak|exfiltrate|copy)\b\W+(\w+\W+){0,4}(environment variables|env vars|secrets|credentials|api keys?|access tokens?|private keys?)\b|\$\{\{\s*secrets\.`),
+	},
+	{
+		description: "reads credential files",
+		pattern:     regexp.MustCompile(`(?i)~/\.ssh\b|\bid_(rsa|ed25519|ecdsa)\b|\.aws/credentials|\.git-credentials|\.netrc\b|\.docker/config\.json|\.useful1/`),
+	},
+	{
+		description: "changes CI configuration",
+		pattern:     regexp.MustCompile(`(?i)\.github/workflows\b|\.gitlab-ci\.ya?ml\b|\.circleci/|\bJenkinsfile\b|\bazure-pipelines\.ya?ml\b|\.travis\.ya?ml\b`),
+		trustedOK:   true, // maintainers file issues about CI
+	},
+	{
+		description: "runs a downloaded script",
+		pattern:     regexp.MustCompile(`(?i)\b(curl|wget)\b[^\n|]*\|\s*(sudo\s+)?(ba|z)?sh\b`),
+	},
+	{
+		description: "sends data to another host",
+		pattern:     regexp.MustCompile(`(?i)\b(curl|wget)\b[^\n]*\s(-d|--data(-[a-z]+)?|-F|--form|-T|--upload-file|--post-data|--post-file)\b|\bnc\b[^\n]*\s-e\b|\b(webhook\.site|requestbin|ngrok\.io|interact\.sh|burpcollaborator)\b`),
+	},
+}
+
+// maxMatchLength limits how much of a flagged passage is quoted back
+const maxMatchLength = 80
+
+// Scan looks for passages in text that try to make the agent leak secrets, reach other
+// hosts, change CI or ignore its instructions. source describes where the text came from.
+// It returns at most one finding per rule.
+func Scan(source, text string) []Finding {
+	return scan(source, text, false)
+}
+
+// ScanTrusted is Scan for text by trusted users. Requests a maintainer may well make,
+// such as changing CI configuration, aren't flagged.
+func ScanTrusted(source, text string) []Finding {
+	return scan(source, text, true)
+}
+
+// scan applies the detector rules, skipping those trusted authors may trigger if trusted is set
+func scan(source, text string, trusted bool) []Finding {
+	var findings []Finding
+	for _, rule := range detectorRules {
+		if trusted && rule.trustedOK {
+			continue
+		}
+		match := rule.pattern.FindString(text)
+		if match == "" {
+			continue
+		}
+
+		match = strings.Join(strings.Fields(match), " ")
+		if runes := []rune(match); len(runes) > maxMatchLength {
+			match = string(runes[:maxMatchLength]) + "…"
+		}
+		findings = append(findings, Finding{Source: source, Rule: rule.description, Match: match})
+	}
+	return findings
+}
@@ -0,0 +1,42 @@
+package security
+
+import (
+	"regexp"
+	"strings"
+)
+
+// redaction replaces matches of a pattern, which can refer to its groups
+type redaction struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// redactions remove credentials that can turn up in command output and error messages
+var redactions = []redaction{
+	{regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`), ""}, // terminal escape sequences
+	{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), "[REDACTED]"},
+	{regexp.MustCompile(`\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{20,}\b`), "[REDACTED]"},                                       // GitHub tokens
+	{regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{20,}\b`), "[REDACTED]"},                                                 // fine-grained GitHub tokens
+	{regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{10,}`), "[REDACTED]"},                                                      // Anthropic API keys
+	{regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`), "[REDACTED]"},                                                             // AWS access key IDs
+	{regexp.MustCompile(`(?i)\b(bearer|token)\s+[A-Za-z0-9._~+/=-]{16,}`), "$1 [REDACTED]"},                                // authorization headers
+	{regexp.MustCompile(`(://)[^/\s:@]+:[^/\s@]+@`), "${1}[REDACTED]@"},                                                    // credentials in URLs
+	{regexp.MustCompile(`(?i)\b(\w*(?:token|secret|password|passwd|api_?key))(\s*[=:]\s*)[^\s"']+`), "${1}${2}[REDACTED]"}, // assignments
+}
+
+// Redact removes terminal escape sequences and anything that looks like a credential from
+// text before it is shown outside the bot, for example in an issue comment. secrets are
+// known values, such as the configured tokens, that are removed wherever they appear.
+func Redact(text string, secrets ...string) string {
+	for _, secret := range secrets {
+		// Very short values would blank out ordinary words
+		if len(secret) >= 8 {
+			text = strings.ReplaceAll(text, secret, "[REDACTED]")
+		}
+	}
+
+	for _, r := range redactions {
+		text = r.pattern.ReplaceAllString(text, r.replacement)
+	}
+	return text
+}
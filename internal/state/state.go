@@ -0,0 +1,230 @@
+// Package state persists per-issue workflow state across monitor restarts
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Status describes where an issue is in the workflow
+type Status string
+
+// Issue status constants
+const (
+	StatusNew              Status = ""                  // Issue has not been seen before
+	StatusParked           Status = "parked"            // Waiting for the issue author to answer clarifying questions
+	StatusAwaitingApproval Status = "awaiting_approval" // Implementation plan posted, waiting for approval
+	StatusApproved         Status = "approved"          // Implementation plan approved, ready to start work
+	StatusRunning          Status = "running"           // Issue is currently being worked on
+	StatusCompleted        Status = "completed"         // A pull request has been opened for the issue
+	StatusCancelled        Status = "cancelled"         // Work was cancelled with a chat command
+	StatusNeedsHuman       Status = "needs_human"       // Issue text looked like prompt injection; a maintainer has to review it
+	StatusRetrying         Status = "retrying"          // A run failed and the issue will be tried again at RetryAt
+	StatusFailed           Status = "failed"            // Runs kept failing and the issue was handed over to a human
+)
+
+// IssueState holds everything we remember about a single issue or pull request
+type IssueState struct {
+	Status         Status    `json:"status"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	ParkedAt       time.Time `json:"parked_at,omitempty"`
+	Questions      []string  `json:"questions,omitempty"`
+	QuestionRounds int       `json:"question_rounds,omitempty"`
+	Plan           string    `json:"plan,omitempty"`
+	PlanPostedAt   time.Time `json:"plan_posted_at,omitempty"`
+	ApprovedBy     string    `json:"approved_by,omitempty"`
+	Budget         float64   `json:"budget,omitempty"`      // per-issue budget override in USD
+	BaseBranch     string    `json:"base_branch,omitempty"` // base branch override
+	LastCommandAt  time.Time `json:"last_command_at,omitempty"`
+	Branch         string    `json:"branch,omitempty"`
+	PullRequest    int       `json:"pull_request,omitempty"` // pull request opened for an issue
+	Issue          int       `json:"issue,omitempty"`        // issue a pull request resolves
+	LastReviewAt   time.Time `json:"last_review_at,omitempty"`
+	RepairAttempts int       `json:"repair_attempts,omitempty"`
+	LastRepairSHA  string    `json:"last_repair_sha,omitempty"` // head commit the last repair pass ran against
+	FlaggedSHA     string    `json:"flagged_sha,omitempty"`     // head commit whose CI logs were flagged as prompt injection
+	ReviewedAt     time.Time `json:"reviewed_at,omitempty"`     // when a maintainer cleared flagged issue text with a retry
+	StatusComment  string    `json:"status_comment,omitempty"`  // ID of the comment the bot edits to report progress
+	Attempts       int       `json:"attempts,omitempty"`        // failed runs so far
+	LastFailure    string    `json:"last_failure,omitempty"`    // sanitized error of the last failed run
+	RetryAt        time.Time `json:"retry_at,omitempty"`        // when a failed run is tried again
+}
+
+// ParseIssueKey splits a key built by IssueKey into its parts
+func ParseIssueKey(key string) (owner, repo string, number int, err error) {
+	fullName, num, ok := strings.Cut(key, "#")
+	owner, repo, slash := strings.Cut(fullName, "/")
+	if !ok || !slash || owner == "" || repo == "" {
+		return "", "", 0, fmt.Errorf("invalid issue key %q", key)
+	}
+
+	number, err = strconv.Atoi(num)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid issue key %q: %w", key, err)
+	}
+	return owner, repo, number, nil
+}
+
+// Store is a small JSON-file backed key/value store for issue state
+type Store struct {
+	path   string
+	mutex  sync.Mutex
+	issues map[string]*IssueState
+}
+
+// DefaultPath returns the default location of the state file
+func DefaultPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".useful1", "state.json")
+}
+
+// IssueKey builds the key used to store state for an issue
+func IssueKey(owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", owner, repo, number)
+}
+
+// Open loads the store from the given path, creating an empty store if the file does not exist.
+// An empty path gives an in-memory store that is never written to disk.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path:   path,
+		issues: make(map[string]*IssueState),
+	}
+
+	if path == "" {
+		return s, nil
+	}
+
+	issues, err := readIssues(path)
+	if err != nil {
+		return nil, err
+	}
+	s.issues = issues
+	return s, nil
+}
+
+// readIssues reads the state file at path; a missing file gives an empty map
+func readIssues(path string) (map[string]*IssueState, error) {
+	issues := make(map[string]*IssueState)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return issues, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &issues); err != nil {
+			return nil, fmt.Errorf("failed to decode state file: %w", err)
+		}
+	}
+	return issues, nil
+}
+
+// OpenReadOnly loads the store like Open, but keeps changes in memory and never writes
+// them back, so a dry run sees the real state without changing it
+func OpenReadOnly(path string) (*Store, error) {
+	s, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	s.path = ""
+	return s, nil
+}
+
+// Get returns a copy of the state for an issue; unknown issues get a zero state
+func (s *Store) Get(key string) IssueState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if st, ok := s.issues[key]; ok {
+		return *st
+	}
+	return IssueState{}
+}
+
+// All returns a copy of the state of every issue and pull request, by key
+func (s *Store) All() map[string]IssueState {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	all := make(map[string]IssueState, len(s.issues))
+	for key, st := range s.issues {
+		all[key] = *st
+	}
+	return all
+}
+
+// Put replaces the state for an issue and writes the store to disk
+func (s *Store) Put(key string, st IssueState) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	st.UpdatedAt = time.Now()
+	return s.update(func(issues map[string]*IssueState) { issues[key] = &st })
+}
+
+// Delete removes the state for an issue and writes the store to disk
+func (s *Store) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(issues map[string]*IssueState) { delete(issues, key) })
+}
+
+// update applies a change to the store and writes it to disk; callers must hold the mutex.
+// Several processes, such as the solve runs of a batch, can share the state file, so the
+// file is locked and read again and the change is applied to what it holds now. That way
+// no process overwrites the issues another one saved since the store was opened.
+func (s *Store) update(change func(map[string]*IssueState)) error {
+	if s.path == "" {
+		change(s.issues)
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	lock, err := os.OpenFile(s.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open state lock: %w", err)
+	}
+	defer func() { _ = lock.Close() }()
+
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock state file: %w", err)
+	}
+	defer func() { _ = syscall.Flock(int(lock.Fd()), syscall.LOCK_UN) }()
+
+	issues, err := readIssues(s.path)
+	if err != nil {
+		return err
+	}
+	change(issues)
+	s.issues = issues
+
+	data, err := json.MarshalIndent(s.issues, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated state file
+	tmpPath := s.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+
+	return nil
+}
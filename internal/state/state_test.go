@@ -0,0 +1,41 @@
+package state
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestConcurrentStoresKeepEachOthersIssues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// Two solve runs open the file before either has saved anything
+	first, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	second, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	if err := first.Put(IssueKey("octo", "demo", 1), IssueState{Status: StatusCompleted}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := second.Put(IssueKey("octo", "demo", 2), IssueState{Status: StatusFailed}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := first.Delete(IssueKey("octo", "demo", 3)); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if got := reopened.Get(IssueKey("octo", "demo", 1)).Status; got != StatusCompleted {
+		t.Errorf("issue 1 status = %q, want %q", got, StatusCompleted)
+	}
+	if got := reopened.Get(IssueKey("octo", "demo", 2)).Status; got != StatusFailed {
+		t.Errorf("issue 2 status = %q, want %q", got, StatusFailed)
+	}
+}
@@ -1,17 +1,32 @@
 package tui
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"strconv"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/hellausefulsoftware/useful1/internal/auth"
 	"github.com/hellausefulsoftware/useful1/internal/config"
 	"github.com/hellausefulsoftware/useful1/internal/github"
 )
 
+// deviceCodeMsg carries the device code to show while signing in to GitHub
+type deviceCodeMsg struct {
+	code *auth.DeviceCode
+	err  error
+}
+
+// deviceLoginMsg carries the result of signing in to GitHub
+type deviceLoginMsg struct {
+	auth *auth.GitHubAuth
+	err  error
+}
+
 // executionResultMsg represents a message with execution results
 type executionResultMsg struct {
 	output string
@@ -55,6 +70,11 @@ type ConfigScreen struct {
 	resultError              error
 	showGithubHelp           bool
 	showAnthropicHelp        bool
+	githubClientID           string           // client ID for signing in with the device flow
+	deviceFlow               *auth.DeviceFlow // set while signing in to GitHub
+	deviceCode               *auth.DeviceCode
+	deviceLoginErr           error
+	cancelDeviceLogin        context.CancelFunc
 }
 
 // updateInputReferences updates all the input field references from the inputs slice
@@ -170,6 +190,7 @@ func NewConfigScreen(app *App) *ConfigScreen {
 	// Define menu items
 	menuItems := []string{
 		"GitHub Token Help - Get help generating a GitHub token",
+		"Sign in with GitHub - Authorize useful1 in your browser",
 		"Anthropic API Key Help - Get help generating an Anthropic API key",
 		"Save Configuration - Save your settings",
 	}
@@ -179,6 +200,14 @@ func NewConfigScreen(app *App) *ConfigScreen {
 		panic(fmt.Sprintf("Input field count mismatch: expected %d, got %d", fieldCount, len(inputs)))
 	}
 
+	// Keep the device flow client ID so saving doesn't drop an earlier sign-in
+	configurator := config.NewConfigurator()
+	githubClientID := os.Getenv("USEFUL1_GITHUB_CLIENT_ID")
+	if githubClientID == "" && app.GetConfig() != nil {
+		githubClientID = app.GetConfig().GitHubOAuth.ClientID
+	}
+	configurator.SetGitHubOAuthClientID(githubClientID)
+
 	return &ConfigScreen{
 		BaseScreen:               NewBaseScreen(app, "Configuration"),
 		githubTokenInput:         githubTokenInput,
@@ -195,10 +224,11 @@ func NewConfigScreen(app *App) *ConfigScreen {
 		menuItems:                menuItems,
 		selectedMenuItem:         0,
 		inMenuSelection:          false,
-		configurator:             config.NewConfigurator(),
+		configurator:             configurator,
 		executing:                false,
 		showGithubHelp:           false,
 		showAnthropicHelp:        false,
+		githubClientID:           githubClientID,
 	}
 }
 
@@ -218,6 +248,19 @@ func (c *ConfigScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if c.deviceFlow != nil {
+			// Cancel signing in and go back to the form
+			if key.Matches(msg, c.app.keyMap.Back) {
+				if c.cancelDeviceLogin != nil {
+					c.cancelDeviceLogin()
+				}
+				c.deviceFlow = nil
+				c.deviceCode = nil
+				c.deviceLoginErr = nil
+			}
+			return c, nil
+		}
+
 		if c.executing {
 			// Allow going back if we're showing results
 			if key.Matches(msg, c.app.keyMap.Back) {
@@ -331,11 +374,14 @@ func (c *ConfigScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					c.showGithubHelp = true
 					c.showAnthropicHelp = false
 					c.inMenuSelection = false
-				case 1: // Anthropic API Key Help
+				case 1: // Sign in with GitHub
+					c.inMenuSelection = false
+					return c, c.startDeviceLogin()
+				case 2: // Anthropic API Key Help
 					c.showAnthropicHelp = true
 					c.showGithubHelp = false
 					c.inMenuSelection = false
-				case 2: // Save Configuration
+				case 3: // Save Configuration
 					// Validate input
 					if c.githubTokenInput.Value() == "" || c.anthropicTokenInput.Value() == "" || c.cliCommandInput.Value() == "" {
 						c.result = "Please fill in all required fields"
@@ -353,6 +399,35 @@ func (c *ConfigScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return c, nil
 		}
 
+	case deviceCodeMsg:
+		if c.deviceFlow == nil {
+			return c, nil
+		}
+		if msg.err != nil {
+			c.deviceLoginErr = msg.err
+			return c, nil
+		}
+		c.deviceCode = msg.code
+		return c, c.waitForDeviceLogin()
+
+	case deviceLoginMsg:
+		if c.deviceFlow == nil {
+			return c, nil
+		}
+		if msg.err != nil {
+			c.deviceLoginErr = msg.err
+			return c, nil
+		}
+
+		c.inputs[fieldGitHubToken].SetValue(msg.auth.Token)
+		c.inputs[fieldGitHubUsername].SetValue(msg.auth.User)
+		c.updateInputReferences()
+		c.configurator.SetGitHubOAuthClientID(c.githubClientID)
+
+		c.deviceFlow = nil
+		c.deviceCode = nil
+		return c, nil
+
 	case executionResultMsg:
 		c.result = msg.output
 		c.resultError = msg.err
@@ -400,11 +475,31 @@ func (c *ConfigScreen) View() string {
 		return lipgloss.NewStyle().Width(c.app.GetWidth()).Align(lipgloss.Left).Render(content)
 	}
 
+	// Show the device code while signing in to GitHub
+	if c.deviceFlow != nil {
+		content := theme.Title.Render("Sign in with GitHub") + "\n\n"
+
+		switch {
+		case c.deviceLoginErr != nil:
+			content += lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Error)).Render("Sign-in failed: "+c.deviceLoginErr.Error()) + "\n\n"
+		case c.deviceCode == nil:
+			content += theme.Text.Render("Requesting a device code...") + "\n\n"
+		default:
+			content += theme.Text.Render("1. Open "+c.deviceCode.VerificationURI) + "\n" +
+				theme.Text.Render("2. Enter this code:") + "\n\n" +
+				theme.Bold.Render("   "+c.deviceCode.UserCode) + "\n\n" +
+				theme.Faint.Render("Waiting for you to authorize useful1...") + "\n\n"
+		}
+
+		content += theme.Faint.Render("Press ESC to go back to the configuration screen")
+		return lipgloss.NewStyle().Width(c.app.GetWidth()).Align(lipgloss.Left).Render(content)
+	}
+
 	// Show GitHub help screen if enabled
 	if c.showGithubHelp {
 		content := theme.Title.Render("GitHub Token Guide") + "\n\n" +
 			theme.Bold.Render("To get a GitHub Personal Access Token:") + "\n\n" +
-			theme.Text.Render("1. Go to https://github.com/settings/tokens") + "\n" +
+			theme.Text.Render("1. Go to "+c.app.GetConfig().GitHubWebURL()+"/settings/tokens") + "\n" +
 			theme.Text.Render("2. Click 'Generate new token' / 'Generate new token (classic)'") + "\n" +
 			theme.Text.Render("3. Give it a name like 'Useful1 CLI'") + "\n" +
 			theme.Text.Render("4. Set an expiration period (e.g., 90 days)") + "\n" +
@@ -563,6 +658,43 @@ func (c *ConfigScreen) ShortHelp() []key.Binding {
 	return keys
 }
 
+// startDeviceLogin requests a device code to start signing in to GitHub
+func (c *ConfigScreen) startDeviceLogin() tea.Cmd {
+	c.deviceFlow = auth.NewDeviceFlow(c.app.GetConfig(), c.githubClientID, auth.DeviceFlowScopes...)
+	c.deviceCode = nil
+	c.deviceLoginErr = nil
+
+	if c.githubClientID == "" {
+		c.deviceLoginErr = fmt.Errorf("no OAuth client ID configured, set USEFUL1_GITHUB_CLIENT_ID")
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancelDeviceLogin = cancel
+	flow := c.deviceFlow
+
+	return func() tea.Msg {
+		code, err := flow.RequestCode(ctx)
+		return deviceCodeMsg{code: code, err: err}
+	}
+}
+
+// waitForDeviceLogin polls until the user authorizes the device code
+func (c *ConfigScreen) waitForDeviceLogin() tea.Cmd {
+	cfg, flow, code := c.app.GetConfig(), c.deviceFlow, c.deviceCode
+	if c.cancelDeviceLogin != nil {
+		// Release the finished code request
+		c.cancelDeviceLogin()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancelDeviceLogin = cancel
+
+	return func() tea.Msg {
+		githubAuth, err := auth.CompleteDeviceLogin(ctx, cfg, flow, code)
+		return deviceLoginMsg{auth: githubAuth, err: err}
+	}
+}
+
 // startExecution begins the execution process
 func (c *ConfigScreen) startExecution() tea.Cmd {
 	return func() tea.Msg {
@@ -579,7 +711,7 @@ func (c *ConfigScreen) startExecution() tea.Cmd {
 		if username == "" {
 			if githubToken != "" {
 				// Create a temporary GitHub client to get user info
-				tempClient := github.NewClient(githubToken)
+				tempClient := github.NewClientWithConfig(githubToken, c.app.GetConfig())
 				if user, err := tempClient.GetUserInfo(); err == nil && user.GetLogin() != "" {
 					username = user.GetLogin()
 				}
@@ -642,7 +774,7 @@ func (c *ConfigScreen) startExecution() tea.Cmd {
 		pollIntervalMins := (pollIntervalSecs + 59) / 60
 
 		repoFilter := []string{}
-		assignedOnly := true // Always monitor assigned issues only
+		assignedOnly := true // Triggers.Rules in the config file can select other issues
 
 		c.configurator.SetMonitoringSettings(pollIntervalMins, repoFilter, assignedOnly)
 
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"fmt"
+	"iter"
 	"strings"
 	"sync"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/hellausefulsoftware/useful1/internal/cli"
 	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/events"
 	"github.com/hellausefulsoftware/useful1/internal/github"
 	"github.com/hellausefulsoftware/useful1/internal/logging"
 	"github.com/hellausefulsoftware/useful1/internal/workflow"
@@ -35,6 +37,7 @@ type MonitorScreen struct {
 	executor         *cli.Executor
 	vcsService       vcs.Service  // Platform-agnostic VCS service
 	monitor          *vcs.Monitor // Platform-agnostic VCS monitor
+	events           *events.Bus  // what the monitor reports while it checks issues
 	running          bool
 	runOnce          bool
 	pollTime         time.Time
@@ -47,6 +50,8 @@ type MonitorScreen struct {
 	showCreatePRForm bool
 	fetchingRepos    bool
 	selectAllRepos   bool
+	nextRepo         func() (vcs.Repository, error, bool) // reads the repository stream while loading
+	stopRepos        func()
 }
 
 // Issue represents a GitHub issue for display
@@ -106,6 +111,7 @@ func NewMonitorScreen(app *App) *MonitorScreen {
 	// Create VCS service
 	var vcsService vcs.Service
 	var monitor *vcs.Monitor
+	bus := events.NewBus()
 
 	if app.GetConfig() != nil {
 		// Create GitHub adapter
@@ -123,6 +129,7 @@ func NewMonitorScreen(app *App) *MonitorScreen {
 				Config:    app.GetConfig(),
 				Service:   adapter,
 				Processor: processor,
+				Events:    bus,
 			}
 
 			// Create monitor
@@ -138,6 +145,7 @@ func NewMonitorScreen(app *App) *MonitorScreen {
 		executor:         executor,
 		vcsService:       vcsService,
 		monitor:          monitor,
+		events:           bus,
 		running:          false,
 		logs:             []string{},
 		repositories:     []Repository{},
@@ -172,27 +180,32 @@ func (m *MonitorScreen) Init() tea.Cmd {
 	return tea.Batch(cmds...)
 }
 
-// fetchRepositories fetches repositories the user has access to
+// repoBatchSize is how many repositories are added to the list at a time while loading
+const repoBatchSize = 100
+
+// fetchRepositories fetches the next batch of repositories the user has access to
 func (m *MonitorScreen) fetchRepositories() tea.Msg {
 	if m.vcsService == nil {
 		return fetchRepositoriesMsg{
-			repos: nil,
-			err:   fmt.Errorf("VCS service not configured"),
+			err:  fmt.Errorf("VCS service not configured"),
+			done: true,
 		}
 	}
 
-	// Fetch repositories from the VCS service
-	vcsRepos, err := m.vcsService.GetRepositories()
-	if err != nil {
-		return fetchRepositoriesMsg{
-			repos: nil,
-			err:   err,
-		}
+	// Pages are fetched as the stream is read, so long lists appear batch by batch
+	if m.nextRepo == nil {
+		m.nextRepo, m.stopRepos = iter.Pull2(m.vcsService.Repositories())
 	}
 
-	// Convert to our Repository type
-	repos := make([]Repository, 0, len(vcsRepos))
-	for _, repo := range vcsRepos {
+	var repos []Repository
+	for len(repos) < repoBatchSize {
+		repo, err, ok := m.nextRepo()
+		if !ok || err != nil {
+			m.stopRepos()
+			m.nextRepo = nil
+			return fetchRepositoriesMsg{repos: repos, err: err, done: true}
+		}
+
 		ownerName := repo.GetOwner()
 		repoName := repo.GetName()
 
@@ -203,7 +216,6 @@ func (m *MonitorScreen) fetchRepositories() tea.Msg {
 		repos = append(repos, Repository{
 			FullName:      ownerName + "/" + repoName,
 			Description:   repo.GetDescription(),
-			Selected:      true, // Default to selected
 			HasIssues:     repo.GetHasIssues(),
 			Stars:         repo.GetStargazersCount(),
 			ForksCount:    repo.GetForksCount(),
@@ -211,10 +223,15 @@ func (m *MonitorScreen) fetchRepositories() tea.Msg {
 		})
 	}
 
-	return fetchRepositoriesMsg{
-		repos: repos,
-		err:   nil,
+	return fetchRepositoriesMsg{repos: repos}
+}
+
+// triggerSummary describes the trigger rules that select issues to work on
+func triggerSummary(rules []string) string {
+	if len(rules) == 0 {
+		rules = []string{vcs.DefaultTriggerRule}
 	}
+	return "Trigger rules: " + strings.Join(rules, "; ")
 }
 
 // Update handles UI updates for the monitor screen
@@ -223,11 +240,22 @@ func (m *MonitorScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case fetchRepositoriesMsg:
+		// Keep the cursor on "Select All", which sits below the list
+		if m.cursor == len(m.repositories) {
+			m.cursor += len(msg.repos)
+		}
+		for _, repo := range msg.repos {
+			repo.Selected = m.selectAllRepos
+			m.repositories = append(m.repositories, repo)
+		}
+		if !msg.done {
+			return m, m.fetchRepositories
+		}
+
 		m.fetchingRepos = false
 		if msg.err != nil {
 			m.logs = append(m.logs, "Error fetching repositories: "+msg.err.Error())
 		} else {
-			m.repositories = msg.repos
 			if len(m.repositories) > 0 {
 				m.logs = append(m.logs, fmt.Sprintf("Loaded %d repositories", len(m.repositories)))
 			} else {
@@ -290,6 +318,12 @@ func (m *MonitorScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, nil
 
 			case key.Matches(msg, m.app.keyMap.Execute):
+				// The filter would leave out repositories that haven't loaded yet
+				if m.fetchingRepos {
+					m.logs = append(m.logs, "Still loading repositories, please wait")
+					return m, nil
+				}
+
 				// Launch monitoring for selected repositories
 				selectedCount := 0
 				var selectedRepos []string
@@ -305,7 +339,7 @@ func (m *MonitorScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.app.GetConfig().Monitor.RepoFilter = selectedRepos
 
 					m.logs = append(m.logs, fmt.Sprintf("Set repository filter to %d repositories", selectedCount))
-					m.logs = append(m.logs, "Monitoring assigned issues only")
+					m.logs = append(m.logs, triggerSummary(m.app.GetConfig().Triggers.Rules))
 
 					// Update the monitor with the new config
 					if m.monitor != nil {
@@ -343,9 +377,7 @@ func (m *MonitorScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							len(m.app.GetConfig().Monitor.RepoFilter)))
 					}
 
-					// Always monitor assigned issues only
-					m.app.GetConfig().Monitor.AssignedIssuesOnly = true
-					m.logs = append(m.logs, "Monitoring assigned issues only")
+					m.logs = append(m.logs, triggerSummary(m.app.GetConfig().Triggers.Rules))
 
 					// Log the poll interval in seconds
 					pollIntervalSecs := m.app.GetConfig().Monitor.PollInterval * 60
@@ -375,9 +407,7 @@ func (m *MonitorScreen) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							len(m.app.GetConfig().Monitor.RepoFilter)))
 					}
 
-					// Always check assigned issues only
-					m.app.GetConfig().Monitor.AssignedIssuesOnly = true
-					m.logs = append(m.logs, "Checking assigned issues only")
+					m.logs = append(m.logs, triggerSummary(m.app.GetConfig().Triggers.Rules))
 
 					// Log the poll interval in seconds
 					pollIntervalSecs := m.app.GetConfig().Monitor.PollInterval * 60
@@ -447,7 +477,7 @@ func (m *MonitorScreen) View() string {
 
 	// Repository list section (visible when not monitoring)
 	if m.repoListVisible && !m.running {
-		if m.fetchingRepos {
+		if m.fetchingRepos && len(m.repositories) == 0 {
 			content += m.spinner.View() + " Loading repositories...\n\n"
 		} else if len(m.repositories) > 0 {
 			content += theme.Subtitle.Render("Select Repositories:") + "\n\n"
@@ -538,6 +568,9 @@ func (m *MonitorScreen) View() string {
 					startIdx+1, endIdx, len(m.repositories))
 				repoList.WriteString("\n" + theme.Faint.Render(paginationInfo))
 			}
+			if m.fetchingRepos {
+				repoList.WriteString("\n" + m.spinner.View() + " Loading more repositories...")
+			}
 
 			content += repoListStyle.Render(repoList.String()) + "\n\n"
 
@@ -563,7 +596,14 @@ func (m *MonitorScreen) View() string {
 			statusLine += fmt.Sprintf("Monitoring (next poll in %s)", nextPollIn)
 		}
 		infoStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Info))
-		content += infoStyle.Render(statusLine) + "\n\n"
+		content += infoStyle.Render(statusLine) + "\n"
+		if m.monitor != nil {
+			for _, limit := range m.monitor.RateLimits() {
+				content += theme.Text.Render(fmt.Sprintf("API quota (%s): %d/%d, resets %s",
+					limit.Resource, limit.Remaining, limit.Limit, limit.Reset.Format("15:04"))) + "\n"
+			}
+		}
+		content += "\n"
 
 		// Show which repositories are being monitored
 		if len(m.repositories) > 0 {
@@ -591,7 +631,7 @@ func (m *MonitorScreen) View() string {
 		content += theme.Subtitle.Render("GitHub Issue Monitoring") + "\n\n"
 
 		// Display monitoring mode and settings
-		content += theme.Text.Render("Mode: Monitoring assigned issues only") + "\n"
+		content += theme.Text.Render(triggerSummary(m.app.GetConfig().Triggers.Rules)) + "\n"
 
 		// Show poll interval
 		pollIntervalSecs := m.app.GetConfig().Monitor.PollInterval * 60
@@ -698,6 +738,14 @@ func (m *MonitorScreen) checkIssues() tea.Cmd {
 		}
 		logging.Initialize(logConfig)
 
+		// Show what happened to each issue, as reported by the monitor
+		var activity []string
+		unsubscribe := m.events.Subscribe(func(event events.Event) {
+			if line := event.Summary(); line != "" {
+				activity = append(activity, fmt.Sprintf("%s: %s", event.Issue, line))
+			}
+		})
+
 		if m.runOnce {
 			logs = append(logs, "Running a one-time check for issues...")
 			err = m.monitor.CheckOnce()
@@ -706,6 +754,7 @@ func (m *MonitorScreen) checkIssues() tea.Cmd {
 			// Simulating a check that doesn't start continuous monitoring
 			err = m.monitor.CheckOnce()
 		}
+		unsubscribe()
 
 		// Restore original logger
 		origLogConfig := &logging.Config{
@@ -723,6 +772,7 @@ func (m *MonitorScreen) checkIssues() tea.Cmd {
 		} else {
 			logs = append(logs, capturedLogs...)
 		}
+		logs = append(logs, activity...)
 
 		if err != nil {
 			logs = append(logs, "Error checking issues: "+err.Error())
@@ -871,4 +921,5 @@ type tickMsg time.Time
 type fetchRepositoriesMsg struct {
 	repos []Repository
 	err   error
+	done  bool // false while more repositories are being fetched
 }
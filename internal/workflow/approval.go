@@ -0,0 +1,237 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/models"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+// planMarker is embedded in implementation plan comments so they can be found again
+const planMarker = "<!-- useful1:plan -->"
+
+// Approval commands recognised in issue comments
+const (
+	approveCommand        = "/approve"
+	requestChangesCommand = "/request-changes"
+)
+
+// Planner generates an implementation plan for an issue
+type Planner interface {
+	GenerateImplementationPlan(issue *models.Issue) (string, error)
+}
+
+// approvalDecision is the outcome of reviewing the responses to a posted plan
+type approvalDecision int
+
+const (
+	approvalPending approvalDecision = iota
+	approvalApproved
+	approvalChangesRequested
+)
+
+// requiresApproval reports whether the plan-approval gate applies to an issue
+func (p *Pipeline) requiresApproval(issue vcs.Issue) bool {
+	approval := p.config.Approval
+	if !approval.Enabled {
+		return false
+	}
+
+	// Without any repo or label restrictions the gate applies everywhere
+	if len(approval.Repos) == 0 && len(approval.Labels) == 0 {
+		return true
+	}
+
+	fullName := fmt.Sprintf("%s/%s", issue.GetOwner(), issue.GetRepo())
+	for _, repo := range approval.Repos {
+		if strings.EqualFold(repo, fullName) {
+			return true
+		}
+	}
+
+	for _, label := range issue.GetLabels() {
+		for _, required := range approval.Labels {
+			if strings.EqualFold(label, required) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// awaitApproval posts the implementation plan and waits for an authorized user to approve it.
+// It returns true once the plan has been approved and work can start.
+func (p *Pipeline) awaitApproval(issue vcs.Issue, st *state.IssueState, username string) (bool, error) {
+	key := state.IssueKey(issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
+
+	if st.Status != state.StatusAwaitingApproval {
+		return false, p.postPlan(issue, st)
+	}
+
+	decision, approver := p.reviewPlanResponses(issue, st, username)
+	switch decision {
+	case approvalApproved:
+		st.Status = state.StatusApproved
+		st.ApprovedBy = approver
+		if err := p.store.Put(key, *st); err != nil {
+			logging.Warn("Failed to save approved issue state", "issue", key, "error", err)
+		}
+		logging.Info("Implementation plan approved", "issue", key, "approved_by", approver)
+		return true, nil
+
+	case approvalChangesRequested:
+		logging.Info("Changes requested to implementation plan, re-planning", "issue", key)
+		return false, p.postPlan(issue, st)
+
+	default:
+		logging.Info("Waiting for implementation plan approval", "issue", key, "plan_posted_at", st.PlanPostedAt)
+		return false, nil
+	}
+}
+
+// postPlan generates an implementation plan and posts it for approval
+func (p *Pipeline) postPlan(issue vcs.Issue, st *state.IssueState) error {
+	key := state.IssueKey(issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
+
+	plan, err := p.planner.GenerateImplementationPlan(toModelIssue(issue))
+	if err != nil {
+		return fmt.Errorf("failed to generate implementation plan: %w", err)
+	}
+
+	if err := p.service.RespondToIssue(issue.GetOwner(), issue.GetRepo(), issue.GetNumber(), formatPlanComment(plan)); err != nil {
+		return fmt.Errorf("failed to post implementation plan: %w", err)
+	}
+
+	st.Status = state.StatusAwaitingApproval
+	st.Plan = plan
+	st.PlanPostedAt = time.Now()
+	if err := p.store.Put(key, *st); err != nil {
+		logging.Warn("Failed to save issue state", "issue", key, "error", err)
+	}
+
+	logging.Info("Posted implementation plan for approval", "issue", key, "plan_length", len(plan))
+	return nil
+}
+
+// reviewPlanResponses looks at the comments and reactions since the plan was posted.
+// The most recent command from an authorized user wins; a 👍 on the plan counts as approval.
+func (p *Pipeline) reviewPlanResponses(issue vcs.Issue, st *state.IssueState, username string) (approvalDecision, string) {
+	decision := approvalPending
+	approver := ""
+
+	var planComment *vcs.IssueComment
+	since := st.PlanPostedAt
+
+	comments := issue.GetComments()
+	for i := range comments {
+		comment := comments[i]
+
+		if comment.User == username {
+			if strings.Contains(comment.Body, planMarker) {
+				planComment = &comments[i]
+				since = comment.CreatedAt
+				decision = approvalPending
+				approver = ""
+			}
+			continue
+		}
+
+		if comment.CreatedAt.Before(since) {
+			continue
+		}
+
+		command := strings.ToLower(strings.TrimSpace(comment.Body))
+		switch {
+		case strings.HasPrefix(command, approveCommand):
+			if p.canApprove(issue, comment.User, username) {
+				decision = approvalApproved
+				approver = comment.User
+			}
+		case strings.HasPrefix(command, requestChangesCommand):
+			if p.canApprove(issue, comment.User, username) {
+				decision = approvalChangesRequested
+				approver = ""
+			}
+		}
+	}
+
+	if decision != approvalPending || planComment == nil || planComment.ID == "" {
+		return decision, approver
+	}
+
+	reactions, err := p.service.GetCommentReactions(issue.GetOwner(), issue.GetRepo(), planComment.ID)
+	if err != nil {
+		logging.Warn("Failed to get reactions on implementation plan", "error", err)
+		return decision, approver
+	}
+
+	for _, reaction := range reactions {
+		if reaction.Content == "+1" && p.canApprove(issue, reaction.User, username) {
+			return approvalApproved, reaction.User
+		}
+	}
+
+	return decision, approver
+}
+
+// canApprove reports whether a user is allowed to approve plans on the issue's repository
+func (p *Pipeline) canApprove(issue vcs.Issue, user, username string) bool {
+	if user == "" || user == username {
+		return false
+	}
+
+	if approvers := p.config.Approval.Approvers; len(approvers) > 0 {
+		for _, approver := range approvers {
+			if strings.EqualFold(approver, user) {
+				return true
+			}
+		}
+		return false
+	}
+
+	permission, err := p.service.GetUserPermission(issue.GetOwner(), issue.GetRepo(), user)
+	if err != nil {
+		logging.Warn("Failed to check user permission", "user", user, "error", err)
+		return false
+	}
+
+	return permission == "admin" || permission == "maintain" || permission == "write"
+}
+
+// WaitingIssues returns the issues waiting on plan approval. Approval can arrive as a
+// reaction, which doesn't update the issue, so it implements vcs.WaitingProcessor.
+func (p *Pipeline) WaitingIssues() []vcs.Issue {
+	var waiting []vcs.Issue
+	for key, st := range p.store.All() {
+		if st.Status != state.StatusAwaitingApproval {
+			continue
+		}
+
+		owner, repo, number, err := state.ParseIssueKey(key)
+		if err != nil {
+			logging.Warn("Skipping invalid issue key", "key", key, "error", err)
+			continue
+		}
+		waiting = append(waiting, &vcs.BaseIssue{Owner: owner, Repo: repo, Number: number})
+	}
+	return waiting
+}
+
+// formatPlanComment builds the comment presenting a plan for approval
+func formatPlanComment(plan string) string {
+	var b strings.Builder
+
+	b.WriteString("Here is how I plan to implement this issue:\n\n")
+	b.WriteString(strings.TrimSpace(plan))
+	b.WriteString("\n\n---\n")
+	b.WriteString(fmt.Sprintf("A maintainer can approve this plan by commenting `%s` or reacting with 👍. ", approveCommand))
+	b.WriteString(fmt.Sprintf("To ask for a different approach, comment `%s` followed by what should change and I'll post a revised plan.\n\n", requestChangesCommand))
+	b.WriteString(planMarker)
+
+	return b.String()
+}
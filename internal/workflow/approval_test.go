@@ -0,0 +1,133 @@
+package workflow
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/models"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+// fakePlanner returns a fixed plan and counts calls
+type fakePlanner struct {
+	calls int
+}
+
+func (f *fakePlanner) GenerateImplementationPlan(issue *models.Issue) (string, error) {
+	f.calls++
+	return "1. Change the handler", nil
+}
+
+func newApprovalPipeline(t *testing.T, service *fakeService, planner *fakePlanner) *Pipeline {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Approval.Enabled = true
+
+	p, err := NewPipeline(PipelineConfig{Config: cfg, Service: service, Planner: planner})
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+	return p
+}
+
+func TestRequiresApproval(t *testing.T) {
+	p := newApprovalPipeline(t, &fakeService{}, &fakePlanner{})
+	p.config.Approval.Repos = []string{"octo/prod"}
+	p.config.Approval.Labels = []string{"needs-plan"}
+
+	tests := []struct {
+		name  string
+		issue *vcs.BaseIssue
+		want  bool
+	}{
+		{"matching repo", &vcs.BaseIssue{Owner: "octo", Repo: "prod"}, true},
+		{"matching label", &vcs.BaseIssue{Owner: "octo", Repo: "demo", Labels: []string{"Needs-Plan"}}, true},
+		{"no match", &vcs.BaseIssue{Owner: "octo", Repo: "demo", Labels: []string{"bug"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.requiresApproval(tt.issue); got != tt.want {
+				t.Errorf("requiresApproval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAwaitApproval(t *testing.T) {
+	planPostedAt := time.Now().Add(-time.Hour)
+	planComment := vcs.IssueComment{ID: "100", User: "useful1", Body: "plan\n" + planMarker, CreatedAt: planPostedAt}
+
+	tests := []struct {
+		name         string
+		comments     []vcs.IssueComment
+		reactions    []vcs.Reaction
+		wantApproved bool
+		wantReplan   bool
+	}{
+		{
+			name:     "no response",
+			comments: []vcs.IssueComment{planComment},
+		},
+		{
+			name: "approve command from maintainer",
+			comments: []vcs.IssueComment{planComment,
+				{User: "maintainer", Body: "/approve", CreatedAt: planPostedAt.Add(time.Minute)}},
+			wantApproved: true,
+		},
+		{
+			name: "approve command from outsider is ignored",
+			comments: []vcs.IssueComment{planComment,
+				{User: "outsider", Body: "/approve", CreatedAt: planPostedAt.Add(time.Minute)}},
+		},
+		{
+			name:         "thumbs up from maintainer",
+			comments:     []vcs.IssueComment{planComment},
+			reactions:    []vcs.Reaction{{User: "outsider", Content: "+1"}, {User: "maintainer", Content: "+1"}},
+			wantApproved: true,
+		},
+		{
+			name: "changes requested",
+			comments: []vcs.IssueComment{planComment,
+				{User: "maintainer", Body: "/request-changes use a cache instead", CreatedAt: planPostedAt.Add(time.Minute)}},
+			wantReplan: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{
+				reactions:   map[string][]vcs.Reaction{"100": tt.reactions},
+				permissions: map[string]string{"maintainer": "write", "outsider": "read"},
+			}
+			planner := &fakePlanner{}
+			p := newApprovalPipeline(t, service, planner)
+
+			issue := &vcs.BaseIssue{Owner: "octo", Repo: "demo", Number: 3, Comments: tt.comments}
+			st := state.IssueState{Status: state.StatusAwaitingApproval, Plan: "old plan", PlanPostedAt: planPostedAt}
+
+			approved, err := p.awaitApproval(issue, &st, "useful1")
+			if err != nil {
+				t.Fatalf("awaitApproval() error = %v", err)
+			}
+			if approved != tt.wantApproved {
+				t.Errorf("awaitApproval() = %v, want %v", approved, tt.wantApproved)
+			}
+			if approved && (st.Status != state.StatusApproved || st.ApprovedBy != "maintainer") {
+				t.Errorf("unexpected state after approval: %+v", st)
+			}
+
+			replanned := planner.calls > 0
+			if replanned != tt.wantReplan {
+				t.Errorf("re-planned = %v, want %v", replanned, tt.wantReplan)
+			}
+			if tt.wantReplan && (len(service.comments) != 1 || !strings.Contains(service.comments[0], planMarker)) {
+				t.Errorf("expected a revised plan comment, got %q", service.comments)
+			}
+		})
+	}
+}
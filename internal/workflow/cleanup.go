@@ -0,0 +1,63 @@
+package workflow
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+	"github.com/hellausefulsoftware/useful1/internal/workspace"
+)
+
+// cleanupInterval is how often workspaces are pruned; checking issue state costs API calls
+const cleanupInterval = time.Hour
+
+// Cleanup prunes workspaces for closed issues and merged pull requests, old workspaces and
+// workspaces beyond the disk quota. It runs at most once per cleanupInterval and implements
+// vcs.CleanupProcessor.
+func (p *Pipeline) Cleanup() error {
+	if time.Since(p.lastCleanup) < cleanupInterval {
+		return nil
+	}
+	p.lastCleanup = time.Now()
+
+	removed, err := p.PruneWorkspaces(false)
+	if err != nil {
+		return err
+	}
+	if len(removed) > 0 {
+		logging.Info("Pruned workspaces", "count", len(removed))
+	}
+	return nil
+}
+
+// PruneWorkspaces applies the configured workspace pruning policy and returns what was
+// removed, or with dryRun what would be. Workspaces with an in-flight run are kept.
+func (p *Pipeline) PruneWorkspaces(dryRun bool) ([]workspace.Entry, error) {
+	opts := workspace.PruneOptionsFromConfig(p.config)
+	opts.Finished = p.worktreeFinished
+	opts.DryRun = dryRun
+	return p.workspaces.Prune(opts)
+}
+
+// worktreeFinished reports whether the issue a worktree belongs to is closed or its pull request merged
+func (p *Pipeline) worktreeFinished(wt workspace.Worktree) (bool, error) {
+	issue, err := p.service.GetIssue(wt.Owner, wt.Repo, wt.Issue)
+	if err != nil {
+		return false, err
+	}
+	if strings.EqualFold(issue.GetState(), "closed") {
+		return true, nil
+	}
+
+	st := p.store.Get(state.IssueKey(wt.Owner, wt.Repo, wt.Issue))
+	if st.PullRequest == 0 {
+		return false, nil
+	}
+
+	pr, err := p.service.GetPullRequest(wt.Owner, wt.Repo, st.PullRequest)
+	if err != nil {
+		return false, err
+	}
+	return pr.GetState() == "merged", nil
+}
@@ -0,0 +1,241 @@
+package workflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/chatops"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+// locatedComment is a comment together with the issue or pull request it was left on
+type locatedComment struct {
+	replyTo chatops.Target
+	comment vcs.IssueComment
+}
+
+// handleCommands runs chat commands left on the issue, or on the bot's pull requests
+// for it, since the last time commands were handled. Commands are read from issue, while
+// commands that prompt the model get trusted, the issue with untrusted text withheld.
+func (p *Pipeline) handleCommands(issue, trusted vcs.Issue, username string, prs []vcs.LinkedPullRequest) {
+	key := state.IssueKey(issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
+	target := chatops.Target{Owner: issue.GetOwner(), Repo: issue.GetRepo(), Number: issue.GetNumber()}
+
+	var comments []locatedComment
+	for _, comment := range issue.GetComments() {
+		comments = append(comments, locatedComment{replyTo: target, comment: comment})
+	}
+
+	for _, pr := range prs {
+		if pr.GetUser() != username {
+			continue
+		}
+
+		// Pull request conversations are read through the issues API
+		prIssue, err := p.service.GetIssueWithComments(pr.GetOwner(), pr.GetRepo(), pr.GetNumber())
+		if err != nil {
+			logging.Warn("Failed to get pull request comments", "pr", pr.GetNumber(), "error", err)
+			continue
+		}
+
+		prTarget := chatops.Target{Owner: pr.GetOwner(), Repo: pr.GetRepo(), Number: pr.GetNumber()}
+		for _, comment := range prIssue.GetComments() {
+			comments = append(comments, locatedComment{replyTo: prTarget, comment: comment})
+		}
+	}
+
+	since := p.store.Get(key).LastCommandAt
+	if since.IsZero() {
+		// Commands left before the bot picked the issue up, or before its state was lost,
+		// were meant for an earlier run, so only commands after this point are handled
+		since = commandBaseline(comments, username, time.Now())
+		st := p.store.Get(key)
+		st.LastCommandAt = since
+		if err := p.store.Put(key, st); err != nil {
+			logging.Warn("Failed to save issue state", "issue", key, "error", err)
+		}
+	}
+
+	var pending []locatedComment
+	for _, located := range comments {
+		if located.comment.CreatedAt.After(since) {
+			pending = append(pending, located)
+		}
+	}
+
+	if len(pending) == 0 {
+		return
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool {
+		return pending[i].comment.CreatedAt.Before(pending[j].comment.CreatedAt)
+	})
+
+	dispatcher := chatops.NewDispatcher(p.service, &commandHandler{pipeline: p, issue: trusted}, username)
+
+	latest := since
+	for _, located := range pending {
+		handled, err := dispatcher.Handle(located.replyTo, target, located.comment)
+		if err != nil {
+			logging.Warn("Failed to handle chat command", "issue", key, "error", err)
+			if !handled {
+				// Leave this command and the ones after it for the next poll
+				break
+			}
+		}
+		if handled {
+			latest = located.comment.CreatedAt
+		}
+	}
+
+	if latest.Equal(since) {
+		return
+	}
+
+	// Handlers update the state themselves, so reload it before recording progress
+	st := p.store.Get(key)
+	st.LastCommandAt = latest
+	if err := p.store.Put(key, st); err != nil {
+		logging.Warn("Failed to save issue state", "issue", key, "error", err)
+	}
+}
+
+// commandBaseline returns the time from which commands are handled on an issue seen without
+// a record of handled commands: the bot's last comment if it has commented, or else now
+func commandBaseline(comments []locatedComment, username string, now time.Time) time.Time {
+	var last time.Time
+	for _, located := range comments {
+		if located.comment.User == username && located.comment.CreatedAt.After(last) {
+			last = located.comment.CreatedAt
+		}
+	}
+	if last.IsZero() {
+		return now
+	}
+	return last
+}
+
+// targetKey returns the state store key for a command target
+func targetKey(target chatops.Target) string {
+	return state.IssueKey(target.Owner, target.Repo, target.Number)
+}
+
+// commandHandler carries out chat commands for a single issue
+type commandHandler struct {
+	pipeline *Pipeline
+	issue    vcs.Issue
+}
+
+// Retry starts work on the issue again
+func (h *commandHandler) Retry(target chatops.Target) (string, error) {
+	st := h.pipeline.store.Get(targetKey(target))
+
+	// A retry on a flagged issue means a maintainer has reviewed its text
+	if st.Status == state.StatusNeedsHuman {
+		st.ReviewedAt = time.Now()
+	}
+
+	// A retry also starts the failure policy over
+	st.Attempts = 0
+	st.RetryAt = time.Time{}
+
+	// Keep an existing approval so the agreed plan is reused
+	st.Status = state.StatusNew
+	if st.ApprovedBy != "" {
+		st.Status = state.StatusApproved
+	}
+
+	if err := h.pipeline.store.Put(targetKey(target), st); err != nil {
+		return "", fmt.Errorf("failed to save issue state: %w", err)
+	}
+	return "retrying this issue now.", nil
+}
+
+// Cancel stops any further work on the issue
+func (h *commandHandler) Cancel(target chatops.Target) (string, error) {
+	st := h.pipeline.store.Get(targetKey(target))
+	st.Status = state.StatusCancelled
+
+	if err := h.pipeline.store.Put(targetKey(target), st); err != nil {
+		return "", fmt.Errorf("failed to save issue state: %w", err)
+	}
+	return fmt.Sprintf("cancelled. I won't work on this issue until someone comments `%s %s`.", chatops.Prefix, chatops.ActionRetry), nil
+}
+
+// Plan generates a fresh implementation plan
+func (h *commandHandler) Plan(target chatops.Target) (string, error) {
+	st := h.pipeline.store.Get(targetKey(target))
+
+	// With the approval gate the plan goes through the usual approval comment
+	if h.pipeline.requiresApproval(h.issue) && st.Status != state.StatusRunning && st.Status != state.StatusCompleted {
+		return "", h.pipeline.postPlan(h.issue, &st)
+	}
+
+	plan, err := h.pipeline.planner.GenerateImplementationPlan(toModelIssue(h.issue))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate implementation plan: %w", err)
+	}
+
+	st.Plan = plan
+	if err := h.pipeline.store.Put(targetKey(target), st); err != nil {
+		return "", fmt.Errorf("failed to save issue state: %w", err)
+	}
+	return "here is the implementation plan I'll follow:\n\n" + strings.TrimSpace(plan), nil
+}
+
+// SetBudget overrides the budget of the agent run that implements the issue
+func (h *commandHandler) SetBudget(target chatops.Target, amount float64) (string, error) {
+	st := h.pipeline.store.Get(targetKey(target))
+	st.Budget = amount
+
+	if err := h.pipeline.store.Put(targetKey(target), st); err != nil {
+		return "", fmt.Errorf("failed to save issue state: %w", err)
+	}
+	return fmt.Sprintf("the agent will stop once a run on this issue has spent $%.2f.", amount), nil
+}
+
+// SetBase overrides the base branch for the issue
+func (h *commandHandler) SetBase(target chatops.Target, branch string) (string, error) {
+	st := h.pipeline.store.Get(targetKey(target))
+	st.BaseBranch = branch
+
+	if err := h.pipeline.store.Put(targetKey(target), st); err != nil {
+		return "", fmt.Errorf("failed to save issue state: %w", err)
+	}
+	return fmt.Sprintf("pull requests for this issue will target `%s`.", branch), nil
+}
+
+// Status reports what the bot knows about the issue
+func (h *commandHandler) Status(target chatops.Target) (string, error) {
+	st := h.pipeline.store.Get(targetKey(target))
+
+	status := string(st.Status)
+	if status == "" {
+		status = "new"
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("status of %s:\n\n", target.String()))
+	b.WriteString(fmt.Sprintf("- **State:** %s\n", status))
+	if !st.UpdatedAt.IsZero() {
+		b.WriteString(fmt.Sprintf("- **Last update:** %s\n", st.UpdatedAt.Format(time.RFC3339)))
+	}
+	if st.ApprovedBy != "" {
+		b.WriteString(fmt.Sprintf("- **Plan approved by:** @%s\n", st.ApprovedBy))
+	}
+	if st.Budget > 0 {
+		b.WriteString(fmt.Sprintf("- **Budget:** $%.2f\n", st.Budget))
+	}
+	if st.BaseBranch != "" {
+		b.WriteString(fmt.Sprintf("- **Base branch:** `%s`\n", st.BaseBranch))
+	}
+	if st.Status == state.StatusParked && len(st.Questions) > 0 {
+		b.WriteString(fmt.Sprintf("- **Waiting on answers to:** %d question(s)\n", len(st.Questions)))
+	}
+
+	return b.String(), nil
+}
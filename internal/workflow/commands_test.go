@@ -0,0 +1,109 @@
+package workflow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/chatops"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+func TestHandleCommandsIgnoresOldCommands(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name       string
+		comments   []vcs.IssueComment
+		wantBudget float64
+		wantStatus state.Status
+	}{
+		{
+			name: "first time the issue is seen",
+			comments: []vcs.IssueComment{
+				{User: "maintainer", Body: "/useful1 cancel", CreatedAt: start},
+			},
+		},
+		{
+			name: "state lost after the bot commented",
+			comments: []vcs.IssueComment{
+				{User: "maintainer", Body: "/useful1 cancel", CreatedAt: start},
+				{User: "bot", Body: "@maintainer cancelled.", CreatedAt: start.Add(time.Minute)},
+				{User: "maintainer", Body: "/useful1 budget 5", CreatedAt: start.Add(2 * time.Minute)},
+			},
+			wantBudget: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &fakeService{permissions: map[string]string{"maintainer": "write"}}
+			p := newApprovalPipeline(t, service, &fakePlanner{})
+			issue := &vcs.BaseIssue{Owner: "octo", Repo: "demo", Number: 1, Comments: tt.comments}
+
+			p.handleCommands(issue, issue, "bot", nil)
+
+			st := p.store.Get(state.IssueKey("octo", "demo", 1))
+			if st.Status != tt.wantStatus || st.Budget != tt.wantBudget {
+				t.Errorf("state after commands = %+v, want status %q and budget %v", st, tt.wantStatus, tt.wantBudget)
+			}
+			if st.LastCommandAt.IsZero() {
+				t.Error("LastCommandAt was not recorded")
+			}
+		})
+	}
+}
+
+func TestHandleCommandsRetriesFailedPermissionLookup(t *testing.T) {
+	service := &fakeService{permissions: map[string]string{"maintainer": "write"}, permissionErr: errors.New("rate limited")}
+	p := newApprovalPipeline(t, service, &fakePlanner{})
+	key := state.IssueKey("octo", "demo", 1)
+
+	start := time.Now().Add(-time.Hour)
+	if err := p.store.Put(key, state.IssueState{LastCommandAt: start}); err != nil {
+		t.Fatal(err)
+	}
+	issue := &vcs.BaseIssue{Owner: "octo", Repo: "demo", Number: 1, Comments: []vcs.IssueComment{
+		{User: "maintainer", Body: "/useful1 budget 5", CreatedAt: start.Add(time.Minute)},
+	}}
+
+	p.handleCommands(issue, issue, "bot", nil)
+	if st := p.store.Get(key); !st.LastCommandAt.Equal(start) || st.Budget != 0 {
+		t.Fatalf("state after failed lookup = %+v, want the command left for the next poll", st)
+	}
+
+	service.permissionErr = nil
+	p.handleCommands(issue, issue, "bot", nil)
+	if st := p.store.Get(key); st.Budget != 5 {
+		t.Fatalf("budget after retry = %v, want 5", st.Budget)
+	}
+}
+
+func TestBudgetCommandCapsAgentRun(t *testing.T) {
+	p := newApprovalPipeline(t, &fakeService{}, &fakePlanner{})
+	p.config.Budgets.PRCreation = 10
+	target := chatops.Target{Owner: "octo", Repo: "demo", Number: 1}
+
+	reply, err := (&commandHandler{pipeline: p}).SetBudget(target, 3)
+	if err != nil {
+		t.Fatalf("SetBudget() error = %v", err)
+	}
+	if !strings.Contains(reply, "$3.00") {
+		t.Errorf("SetBudget() reply = %q, want it to name the budget", reply)
+	}
+
+	st := p.store.Get(state.IssueKey("octo", "demo", 1))
+	if got := p.runConfig(&st).RunBudget(); got != 3 {
+		t.Errorf("run budget = %v, want the issue's 3", got)
+	}
+	if p.config.Budgets.PRCreation != 10 {
+		t.Errorf("configured budget changed to %v", p.config.Budgets.PRCreation)
+	}
+
+	// A per-run budget, as given to solve, takes precedence
+	p.budget = 4
+	if got := p.runConfig(&st).RunBudget(); got != 4 {
+		t.Errorf("run budget = %v, want the per-run 4", got)
+	}
+}
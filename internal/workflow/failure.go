@@ -0,0 +1,257 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+
+	"github.com/hellausefulsoftware/useful1/internal/anthropic"
+	"github.com/hellausefulsoftware/useful1/internal/chatops"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/ratelimit"
+	"github.com/hellausefulsoftware/useful1/internal/security"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+	"github.com/hellausefulsoftware/useful1/internal/workspace"
+)
+
+// failureMarker is embedded in handoff comments so they can be recognised later
+const failureMarker = "<!-- useful1:failure -->"
+
+// maxExcerptLines and maxExcerptBytes limit the log excerpt in a handoff comment
+const (
+	maxExcerptLines = 30
+	maxExcerptBytes = 3000
+)
+
+// FailureKind tells whether trying a failed run again can help
+type FailureKind string
+
+// Failure kinds
+const (
+	FailureTransient FailureKind = "transient" // the next attempt may succeed, e.g. after a timeout or rate limit
+	FailurePermanent FailureKind = "permanent" // the next attempt would fail the same way, e.g. a rejected push
+)
+
+// permanentMessages mark errors that retrying can't fix. Errors from git and the agent
+// carry their output as text, so this is the only way to recognise most of them.
+var permanentMessages = []string{
+	"no commits between",
+	"a pull request already exists",
+	"[rejected]",
+	"[remote rejected]",
+	"non-fast-forward",
+	"protected branch",
+	"permission denied",
+	"permission to",
+	"authentication failed",
+	"repository not found",
+	"could not read username",
+	"invalid issue",
+}
+
+// classifyFailure decides whether a failed run is worth another attempt. Errors that
+// can't be recognised count as transient; the attempt limit still bounds the retries.
+func classifyFailure(err error) FailureKind {
+	var rateErr *github.RateLimitError
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &rateErr) || errors.As(err, &abuseErr) ||
+		errors.Is(err, ratelimit.ErrExhausted) || errors.Is(err, workspace.ErrInFlight) {
+		return FailureTransient
+	}
+
+	// Another attempt would spend the budget again; a maintainer can raise it first
+	if errors.Is(err, anthropic.ErrBudgetExceeded) {
+		return FailurePermanent
+	}
+
+	var apiErr *github.ErrorResponse
+	if errors.As(err, &apiErr) && apiErr.Response != nil {
+		switch status := apiErr.Response.StatusCode; {
+		case status == 429 || status >= 500:
+			return FailureTransient
+		case status == 401 || status == 403 || status == 404 || status == 410 || status == 422:
+			return FailurePermanent
+		}
+	}
+
+	// Network errors are recognised by type before the message is searched, since their
+	// text can name a host or path that looks like a permanent failure
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return FailureTransient
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, m := range permanentMessages {
+		if strings.Contains(message, m) {
+			return FailurePermanent
+		}
+	}
+
+	return FailureTransient
+}
+
+// handleFailure applies the failure policy to a failed run. Transient failures are
+// retried with a growing delay until Failures.MaxAttempts runs have failed; after that,
+// or after a permanent failure, the issue is handed over to a human. It returns runErr.
+func (p *Pipeline) handleFailure(issue vcs.Issue, st *state.IssueState, status *statusReporter, username string, runErr error) error {
+	key := state.IssueKey(issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
+	kind := classifyFailure(runErr)
+
+	st.Attempts++
+	st.LastFailure = p.logExcerpt(runErr)
+	// Error output can run to many lines; the status comment only gives the gist
+	reason, _, _ := strings.Cut(security.Redact(runErr.Error(), p.config.GitHub.Token, p.config.Anthropic.Token), "\n")
+
+	if kind == FailureTransient && st.Attempts < p.maxAttempts() {
+		delay := p.retryDelay(st.Attempts)
+		st.Status = state.StatusRetrying
+		st.RetryAt = time.Now().Add(delay)
+		if err := p.store.Put(key, *st); err != nil {
+			logging.Warn("Failed to save issue state", "issue", key, "error", err)
+		}
+
+		logging.Warn("Run failed, retrying later",
+			"issue", key,
+			"kind", kind,
+			"attempt", st.Attempts,
+			"retry_at", st.RetryAt,
+			"error", runErr)
+		status.failed(kind, reason, fmt.Sprintf("I'll try again in %s (attempt %d of %d failed).", formatElapsed(delay), st.Attempts, p.maxAttempts()))
+		return runErr
+	}
+
+	logging.Error("Run failed, handing the issue over to a human",
+		"issue", key,
+		"kind", kind,
+		"attempts", st.Attempts,
+		"error", runErr)
+
+	st.Status = state.StatusFailed
+	st.RetryAt = time.Time{}
+	if err := p.store.Put(key, *st); err != nil {
+		logging.Warn("Failed to save issue state", "issue", key, "error", err)
+	}
+	status.failed(kind, reason, "I've handed this issue over to a human; see the comment below.")
+
+	if err := p.handOff(issue, st, kind, username); err != nil {
+		logging.Warn("Failed to hand issue over", "issue", key, "error", err)
+	}
+	return runErr
+}
+
+// handOff posts the handoff comment and updates the assignees as configured
+func (p *Pipeline) handOff(issue vcs.Issue, st *state.IssueState, kind FailureKind, username string) error {
+	owner, repo, number := issue.GetOwner(), issue.GetRepo(), issue.GetNumber()
+	failures := p.config.Failures
+
+	if err := p.service.RespondToIssue(owner, repo, number, formatHandoff(st, kind, failures.FallbackUser)); err != nil {
+		return fmt.Errorf("failed to post handoff comment: %w", err)
+	}
+
+	var errs []error
+	if failures.Unassign && username != "" {
+		if err := p.service.UnassignIssue(owner, repo, number, []string{username}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if failures.AssignFallback && failures.FallbackUser != "" {
+		if err := p.service.AssignIssue(owner, repo, number, []string{failures.FallbackUser}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// formatHandoff builds the comment handing a failed issue over to a human
+func formatHandoff(st *state.IssueState, kind FailureKind, fallback string) string {
+	var b strings.Builder
+
+	if kind == FailurePermanent {
+		b.WriteString("I couldn't resolve this issue, and trying again wouldn't help")
+	} else {
+		b.WriteString(fmt.Sprintf("I couldn't resolve this issue after %d attempts", st.Attempts))
+	}
+	if fallback != "" {
+		b.WriteString(fmt.Sprintf(", so I'm handing it over to @%s", fallback))
+	}
+	b.WriteString(".\n\n")
+
+	b.WriteString("<details><summary>Last error</summary>\n\n```text\n")
+	b.WriteString(strings.ReplaceAll(st.LastFailure, "```", "'''"))
+	b.WriteString("\n```\n\n</details>\n\n")
+
+	b.WriteString(fmt.Sprintf("Comment `%s %s` to have me try again.\n\n", chatops.Prefix, chatops.ActionRetry))
+	b.WriteString(failureMarker)
+
+	return b.String()
+}
+
+// logExcerpt returns the end of an error's text with credentials removed, short enough
+// to show in a comment. Errors from git and the agent include their output.
+func (p *Pipeline) logExcerpt(err error) string {
+	text := security.Redact(err.Error(), p.config.GitHub.Token, p.config.Anthropic.Token)
+
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) > maxExcerptLines {
+		lines = append([]string{"…"}, lines[len(lines)-maxExcerptLines:]...)
+	}
+	text = strings.Join(lines, "\n")
+
+	if len(text) > maxExcerptBytes {
+		text = "…" + strings.ToValidUTF8(text[len(text)-maxExcerptBytes:], "")
+	}
+	return text
+}
+
+// maxAttempts returns the configured number of runs before an issue is handed over
+func (p *Pipeline) maxAttempts() int {
+	if p.config.Failures.MaxAttempts <= 0 {
+		return 3
+	}
+	return p.config.Failures.MaxAttempts
+}
+
+// maxRetryDelay caps the retry delay, however many attempts have failed
+const maxRetryDelay = 24 * time.Hour
+
+// retryDelay returns how long to wait before the next attempt after the given number of
+// failed attempts, doubling the configured delay each time up to maxRetryDelay
+func (p *Pipeline) retryDelay(attempts int) time.Duration {
+	delay := time.Duration(p.config.Failures.RetryDelay) * time.Minute
+	if delay <= 0 {
+		delay = 15 * time.Minute
+	}
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// RetriesDue returns the issues whose failed runs are due for another attempt. It
+// implements vcs.RetryProcessor, so the monitor retries them even if nothing changed.
+func (p *Pipeline) RetriesDue(now time.Time) []vcs.Issue {
+	var due []vcs.Issue
+	for key, st := range p.store.All() {
+		if st.Status != state.StatusRetrying || st.RetryAt.After(now) {
+			continue
+		}
+
+		owner, repo, number, err := state.ParseIssueKey(key)
+		if err != nil {
+			logging.Warn("Skipping invalid issue key", "key", key, "error", err)
+			continue
+		}
+		due = append(due, &vcs.BaseIssue{Owner: owner, Repo: repo, Number: number})
+	}
+	return due
+}
@@ -0,0 +1,426 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/anthropic"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/dryrun"
+	"github.com/hellausefulsoftware/useful1/internal/events"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/models"
+	"github.com/hellausefulsoftware/useful1/internal/security"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+	"github.com/hellausefulsoftware/useful1/internal/workspace"
+)
+
+// Pipeline runs the complete resolution flow for a single issue: triage,
+// branch creation, the agent run and the pull request.
+// It implements vcs.IssueProcessor so the monitor can drive it.
+type Pipeline struct {
+	config   *config.Config
+	service  vcs.Service
+	store    *state.Store
+	triager  Triager
+	planner  Planner
+	followUp FollowUpRunner
+
+	workspaces  *workspace.Manager
+	recorder    *dryrun.Recorder
+	events      *events.Bus
+	onDemand    bool
+	skipPR      bool
+	budget      float64
+	lastCleanup time.Time
+}
+
+// PipelineConfig holds the dependencies for creating a pipeline
+type PipelineConfig struct {
+	Config  *config.Config
+	Service vcs.Service
+	Store   *state.Store
+	Triager Triager // Optional, defaults to the Anthropic analyzer
+	Planner Planner // Optional, defaults to the Anthropic analyzer
+
+	// FollowUp re-runs the agent on existing pull request branches. Optional, defaults to the implementation workflow
+	FollowUp FollowUpRunner
+
+	// Workspaces holds the issue worktrees cleaned up once work is finished. Optional, defaults to the configured root
+	Workspaces *workspace.Manager
+
+	// Recorder turns the pipeline into a dry run: the agent still runs in the local worktree,
+	// but comments, branches, pushes and pull requests are recorded instead of made. Optional
+	Recorder *dryrun.Recorder
+
+	// Events receives the progress of each run: stages, costs and pull requests. Optional
+	Events *events.Bus
+
+	// OnDemand is set when issues are handed to the pipeline directly, as by the solve
+	// command, rather than found by polling. Issues are then worked on even if the bot
+	// commented last, and failed runs are retried without waiting.
+	OnDemand bool
+
+	// SkipPullRequest leaves the pushed branch without opening a pull request
+	SkipPullRequest bool
+
+	// Budget caps the cost of each run in USD, ahead of a budget set with the chat command.
+	// It is not saved in the issue state. Optional, defaults to the issue's or configured budget
+	Budget float64
+}
+
+// FollowUpRunner re-runs the agent on an existing branch and pushes any follow-up commits
+type FollowUpRunner interface {
+	RunFollowUp(owner, repo, branchName string, issueNumber int, prompt, description string) (bool, error)
+}
+
+// NewPipeline creates a new issue pipeline
+func NewPipeline(cfg PipelineConfig) (*Pipeline, error) {
+	if cfg.Config == nil {
+		return nil, fmt.Errorf("config is required for pipeline")
+	}
+
+	if cfg.Service == nil {
+		return nil, fmt.Errorf("service is required for pipeline")
+	}
+
+	service := cfg.Service
+	if cfg.Recorder != nil {
+		service = dryrun.NewService(service, cfg.Recorder)
+	}
+
+	store := cfg.Store
+	if store == nil {
+		// Fall back to an in-memory store so the pipeline still works without persistence
+		store, _ = state.Open("")
+	}
+
+	analyzer := anthropic.NewAnalyzer(cfg.Config)
+
+	triager := cfg.Triager
+	if triager == nil {
+		triager = analyzer
+	}
+
+	planner := cfg.Planner
+	if planner == nil {
+		planner = analyzer
+	}
+
+	followUp := cfg.FollowUp
+	if followUp == nil {
+		followUp = newImplementationWorkflow(cfg.Config, cfg.Recorder)
+	}
+
+	workspaces := cfg.Workspaces
+	if workspaces == nil {
+		workspaces = workspace.NewManager(cfg.Config)
+	}
+
+	return &Pipeline{
+		config:     cfg.Config,
+		service:    service,
+		store:      store,
+		triager:    triager,
+		planner:    planner,
+		followUp:   followUp,
+		workspaces: workspaces,
+		recorder:   cfg.Recorder,
+		events:     cfg.Events,
+		onDemand:   cfg.OnDemand,
+		budget:     cfg.Budget,
+		skipPR:     cfg.SkipPullRequest,
+	}, nil
+}
+
+// Process handles a single issue discovered by the monitor
+func (p *Pipeline) Process(issue vcs.Issue) error {
+	// Get authenticated username
+	username, authErr := p.service.GetAuthenticatedUser()
+	if authErr != nil {
+		logging.Warn("Failed to get authenticated user", "error", authErr)
+	}
+
+	logging.Info("Processing issue in main execution flow",
+		"number", issue.GetNumber(),
+		"owner", issue.GetOwner(),
+		"repo", issue.GetRepo(),
+		"title", issue.GetTitle())
+
+	// Check if the issue is already closed
+	if strings.ToLower(issue.GetState()) == "closed" {
+		logging.Info("Issue is closed, skipping")
+		return nil
+	}
+
+	// Check if we already have a PR for this issue
+	prs, prErr := p.service.GetPullRequestsForIssue(issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
+	if prErr != nil {
+		logging.Warn("Failed to check for existing pull requests", "error", prErr)
+	}
+
+	// Keep text from untrusted users out of every prompt built for the issue; commands are
+	// still read from the original comments and check permissions themselves
+	guard := security.NewGuard(p.config, p.service, username)
+	trusted := withholdUntrusted(issue, guard)
+
+	// Run any chat commands before deciding what to do with the issue
+	p.handleCommands(issue, trusted, username, prs)
+
+	key := state.IssueKey(issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
+	st := p.store.Get(key)
+
+	if st.Status == state.StatusCancelled {
+		logging.Info("Issue was cancelled with a chat command, skipping", "issue", key)
+		return nil
+	}
+
+	if st.Status == state.StatusNeedsHuman {
+		logging.Info("Issue is waiting for a maintainer to review flagged text, skipping", "issue", key)
+		return nil
+	}
+
+	if st.Status == state.StatusCompleted {
+		logging.Info("Issue already has a pull request, skipping", "issue", key)
+		return nil
+	}
+
+	if st.Status == state.StatusFailed {
+		logging.Info("Issue was handed over to a human after failed runs, skipping", "issue", key)
+		return nil
+	}
+
+	if st.Status == state.StatusRetrying && time.Now().Before(st.RetryAt) && !p.onDemand {
+		logging.Info("Issue is waiting to be retried, skipping", "issue", key, "retry_at", st.RetryAt)
+		return nil
+	}
+
+	// Check if the last comment was from the bot; approvals can arrive as reactions, so
+	// issues waiting on one are still checked, and retries are due whatever was said last
+	comments := issue.GetComments()
+	if len(comments) > 0 && comments[len(comments)-1].User == username && !p.onDemand &&
+		st.Status != state.StatusAwaitingApproval && st.Status != state.StatusRetrying {
+		logging.Info("Last comment was from bot, skipping to avoid duplicate responses")
+		return nil
+	}
+
+	// Check if the bot already has an open PR that closes the issue; PRs that only
+	// mention it don't count
+	for _, pr := range prs {
+		if pr.Link == vcs.LinkCreatedByBot && pr.GetState() == "open" {
+			logging.Info("Issue already has an open pull request from the bot, skipping", "pr", pr.GetNumber())
+			return nil
+		}
+	}
+
+	// Hand issues that try to steer the agent to a human instead of running it
+	proceed, err := p.screen(issue, guard, &st, username)
+	if err != nil {
+		return err
+	}
+	if !proceed {
+		return nil
+	}
+
+	// From here on only trusted text is used
+	issue = trusted
+
+	// Make sure the issue is specific enough to work on before spending on an agent run
+	if p.config.Triage.Enabled && (st.Status == state.StatusNew || st.Status == state.StatusParked) {
+		proceed, err := p.triage(issue, &st)
+		if err != nil {
+			return err
+		}
+		if !proceed {
+			return nil
+		}
+	}
+
+	// Let a human agree on the approach before any code is changed
+	if p.requiresApproval(issue) &&
+		(st.Status == state.StatusNew || st.Status == state.StatusParked || st.Status == state.StatusAwaitingApproval) {
+		approved, err := p.awaitApproval(issue, &st, username)
+		if err != nil {
+			return err
+		}
+		if !approved {
+			return nil
+		}
+	}
+
+	st.Status = state.StatusRunning
+	if err := p.store.Put(key, st); err != nil {
+		logging.Warn("Failed to save issue state", "issue", key, "error", err)
+	}
+
+	// Report progress in a single comment that is edited as the run goes on
+	status := p.newStatusReporter(issue, &st)
+	if err := p.implement(issue, &st, status); err != nil {
+		return p.handleFailure(issue, &st, status, username, err)
+	}
+
+	st.Status = state.StatusCompleted
+	if err := p.store.Put(key, st); err != nil {
+		logging.Warn("Failed to save issue state", "issue", key, "error", err)
+	}
+
+	return nil
+}
+
+// runConfig returns a copy of the config with the per-run or per-issue budget applied,
+// so other issues are unaffected. The agent is stopped at the budget.
+func (p *Pipeline) runConfig(st *state.IssueState) *config.Config {
+	runConfig := *p.config
+	budget := st.Budget
+	if p.budget > 0 {
+		budget = p.budget
+	}
+	if budget > 0 {
+		runConfig.Budgets.PRCreation = budget
+		runConfig.Budgets.Default = budget
+	}
+	return &runConfig
+}
+
+// implement creates the branch, runs the agent and opens the pull request.
+// Overrides from the issue state (plan, budget, base branch) are applied to this run.
+func (p *Pipeline) implement(issue vcs.Issue, st *state.IssueState, status *statusReporter) error {
+	status.stage(stageAnalyzing, "")
+
+	// Create implementation workflow to handle the issue
+	implementationWorkflow := newImplementationWorkflow(p.runConfig(st), p.recorder)
+
+	// Generate branch name for the issue
+	branchName, prTitle, genErr := implementationWorkflow.GenerateBranchAndTitle(
+		issue.GetOwner(),
+		issue.GetRepo(),
+		issue.GetTitle(),
+		issue.GetBody(),
+	)
+	if genErr != nil {
+		return fmt.Errorf("failed to generate branch name: %w", genErr)
+	}
+
+	logging.Info("Generated branch name with workflow",
+		"branch", branchName,
+		"pr_title", prTitle)
+
+	// Get default branch, unless a base branch was chosen for this issue
+	defaultBranch := st.BaseBranch
+	if defaultBranch == "" {
+		var defaultErr error
+		defaultBranch, defaultErr = p.service.GetDefaultBranch(issue.GetOwner(), issue.GetRepo())
+		if defaultErr != nil {
+			logging.Warn("Failed to get default branch, using 'main'", "error", defaultErr)
+			defaultBranch = "main" // Default fallback
+		}
+	}
+
+	// Create the branch
+	logging.Info("Creating branch", "branch", branchName, "base", defaultBranch)
+	if createErr := p.service.CreateBranch(issue.GetOwner(), issue.GetRepo(), branchName, defaultBranch); createErr != nil {
+		return fmt.Errorf("failed to create branch: %w", createErr)
+	}
+	status.stage(stageBranchCreated, fmt.Sprintf("`%s` from `%s`", branchName, defaultBranch))
+	status.stage(stageAgentRunning, "")
+
+	// Create implementation plan and get Claude output
+	agentStarted := time.Now()
+	claudeOutput, planErr := implementationWorkflow.CreateImplementationPromptAndExecuteWithPlan(
+		issue.GetOwner(),
+		issue.GetRepo(),
+		branchName,
+		issue.GetNumber(),
+		st.Plan,
+	)
+	status.agentFinished(agentStarted, planErr)
+	if planErr != nil {
+		logging.Warn("Failed to create implementation plan", "error", planErr)
+		claudeOutput = "" // Empty if there was an error
+		// Continue anyway - we'll still create the PR
+	}
+
+	// Leave the pushed branch for a human to open the pull request
+	if p.skipPR {
+		if planErr != nil {
+			return fmt.Errorf("failed to run the agent: %w", planErr)
+		}
+		status.pushed(branchName)
+		st.Branch = branchName
+		return nil
+	}
+
+	status.stage(stageVerifying, "")
+
+	logging.Info("Creating PR",
+		"owner", issue.GetOwner(),
+		"repo", issue.GetRepo(),
+		"title", prTitle,
+		"branch", branchName,
+		"base", defaultBranch)
+
+	// Create the PR using the implementation output
+	pr, prErr := implementationWorkflow.CreatePullRequestForIssue(
+		issue.GetOwner(),
+		issue.GetRepo(),
+		branchName,
+		defaultBranch,
+		issue.GetNumber(),
+		claudeOutput,
+		// Pass the worktree that was used for implementation
+		p.workspaces.WorktreePath(issue.GetOwner(), issue.GetRepo(), issue.GetNumber()),
+	)
+	if prErr != nil {
+		// The agent's failure usually explains why there was nothing to open a pull request for
+		return fmt.Errorf("failed to create draft PR: %w", errors.Join(prErr, planErr))
+	}
+	status.opened(pr.GetNumber(), pr.GetHTMLURL())
+
+	logging.Info("Successfully created draft PR",
+		"pr_number", pr.GetNumber(),
+		"url", pr.GetURL())
+
+	st.Branch = branchName
+	st.PullRequest = pr.GetNumber()
+
+	// Remember which issue the pull request belongs to so review feedback can be addressed in its workspace
+	prKey := state.IssueKey(issue.GetOwner(), issue.GetRepo(), pr.GetNumber())
+	if err := p.store.Put(prKey, state.IssueState{Issue: issue.GetNumber(), Branch: branchName}); err != nil {
+		logging.Warn("Failed to save pull request state", "pr", prKey, "error", err)
+	}
+
+	return nil
+}
+
+// toModelIssue converts a VCS issue into the model used by the analyzer
+func toModelIssue(issue vcs.Issue) *models.Issue {
+	result := &models.Issue{
+		Owner:     issue.GetOwner(),
+		Repo:      issue.GetRepo(),
+		Number:    issue.GetNumber(),
+		Title:     issue.GetTitle(),
+		Body:      issue.GetBody(),
+		User:      issue.GetUser(),
+		State:     issue.GetState(),
+		CreatedAt: issue.GetCreatedAt(),
+		UpdatedAt: issue.GetUpdatedAt(),
+		URL:       issue.GetURL(),
+		Comments:  []*models.IssueComment{},
+		Labels:    issue.GetLabels(),
+		Assignees: issue.GetAssignees(),
+		Milestone: issue.GetMilestone(),
+	}
+
+	for _, comment := range issue.GetComments() {
+		result.Comments = append(result.Comments, &models.IssueComment{
+			User:      comment.User,
+			Body:      comment.Body,
+			CreatedAt: comment.CreatedAt,
+		})
+	}
+
+	return result
+}
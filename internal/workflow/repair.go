@@ -0,0 +1,198 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/security"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+// failingCheck is a failed check together with the tail of its log
+type failingCheck struct {
+	check vcs.CheckResult
+	log   string
+}
+
+// repairFailingChecks runs a repair pass when CI fails on a pull request's head commit.
+// Each head commit is repaired at most once, and a pull request gets at most Repair.MaxAttempts passes.
+func (p *Pipeline) repairFailingChecks(pr vcs.PullRequest) error {
+	owner, repo, number := pr.GetOwner(), pr.GetRepo(), pr.GetNumber()
+	key := state.IssueKey(owner, repo, number)
+	st := p.store.Get(key)
+
+	status, err := p.service.GetPullRequestChecks(owner, repo, number)
+	if err != nil {
+		return fmt.Errorf("failed to get pull request checks: %w", err)
+	}
+
+	if status.State != vcs.CheckFailure {
+		logging.Debug("Pull request checks are not failing", "pr", key, "state", status.State)
+		return nil
+	}
+
+	// Already handled this commit; wait for a new one
+	if status.HeadSHA == st.LastRepairSHA {
+		return nil
+	}
+
+	failing := status.Failing()
+	names := make([]string, 0, len(failing))
+	for _, check := range failing {
+		names = append(names, check.Name)
+	}
+
+	maxAttempts := p.maxRepairAttempts()
+	if st.RepairAttempts >= maxAttempts {
+		logging.Info("Repair attempts exhausted, leaving failing checks to a human",
+			"pr", key,
+			"attempts", st.RepairAttempts)
+
+		st.LastRepairSHA = status.HeadSHA
+		if err := p.store.Put(key, st); err != nil {
+			logging.Warn("Failed to save pull request state", "pr", key, "error", err)
+		}
+
+		comment := fmt.Sprintf("CI is still failing on %s (%s) after %d repair attempt(s). I'm leaving this one to a human.",
+			shortSHA(status.HeadSHA), strings.Join(names, ", "), st.RepairAttempts)
+		if err := p.service.RespondToIssue(owner, repo, number, comment); err != nil {
+			return fmt.Errorf("failed to post repair summary: %w", err)
+		}
+		return nil
+	}
+
+	checks := make([]failingCheck, 0, len(failing))
+	for _, check := range failing {
+		log, logErr := p.service.GetCheckLogs(owner, repo, check)
+		if logErr != nil {
+			logging.Warn("Failed to get check logs", "pr", key, "check", check.Name, "error", logErr)
+			log = check.Summary
+		}
+		checks = append(checks, failingCheck{check: check, log: truncateLog(log, p.repairLogBudget())})
+	}
+
+	// Logs carry output of code anyone can change, so they are screened like issue text. Once
+	// a maintainer clears the pull request with a retry, that commit's logs aren't scanned again.
+	if st.FlaggedSHA != status.HeadSHA {
+		var findings []security.Finding
+		for _, failing := range checks {
+			findings = append(findings, security.Scan(fmt.Sprintf("log of %s", failing.check.Name), failing.log)...)
+		}
+		if len(findings) > 0 {
+			st.FlaggedSHA = status.HeadSHA
+			return p.escalate(owner, repo, number, &st, repairEscalation, findings)
+		}
+	}
+
+	st.RepairAttempts++
+	issueNumber := pullRequestIssue(pr, st)
+
+	logging.Info("Repairing failing checks",
+		"pr", key,
+		"sha", status.HeadSHA,
+		"checks", len(checks),
+		"attempt", st.RepairAttempts,
+		"max_attempts", maxAttempts)
+
+	pushed, runErr := p.followUp.RunFollowUp(owner, repo, pr.GetHeadBranch(), issueNumber,
+		formatRepairPrompt(pr, checks), "Fixed failing CI checks")
+
+	// Count the attempt even if it failed so a broken run can't loop forever
+	st.Issue = issueNumber
+	st.Branch = pr.GetHeadBranch()
+	st.LastRepairSHA = status.HeadSHA
+	if err := p.store.Put(key, st); err != nil {
+		logging.Warn("Failed to save pull request state", "pr", key, "error", err)
+	}
+
+	var outcome string
+	switch {
+	case runErr != nil:
+		outcome = fmt.Sprintf("the repair run failed: %v", runErr)
+	case pushed:
+		outcome = "I pushed a fix; CI will run again on the new commit."
+	default:
+		outcome = "I didn't find anything to change, so this may need a human to take a look."
+	}
+
+	comment := fmt.Sprintf("CI failed on %s (%s). Repair attempt %d of %d: %s",
+		shortSHA(status.HeadSHA), strings.Join(names, ", "), st.RepairAttempts, maxAttempts, outcome)
+	if err := p.service.RespondToIssue(owner, repo, number, comment); err != nil {
+		logging.Warn("Failed to post repair summary", "pr", key, "error", err)
+	}
+
+	if runErr != nil {
+		return fmt.Errorf("failed to repair failing checks: %w", runErr)
+	}
+	return nil
+}
+
+// formatRepairPrompt builds the agent prompt for fixing failing checks
+func formatRepairPrompt(pr vcs.PullRequest, checks []failingCheck) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("CI is failing on pull request #%d (%q), branch `%s`, which is checked out in the current directory.\n",
+		pr.GetNumber(), pr.GetTitle(), pr.GetHeadBranch()))
+	b.WriteString("Find the cause of each failure below and fix it on this branch. ")
+	b.WriteString("Fix the code rather than disabling or skipping the failing checks. Do not create a new branch.\n")
+	b.WriteString(security.UntrustedNotice + "\n")
+
+	for _, failing := range checks {
+		b.WriteString(fmt.Sprintf("\n## %s\n", failing.check.Name))
+		if failing.check.URL != "" {
+			b.WriteString(fmt.Sprintf("Details: %s\n", failing.check.URL))
+		}
+		if failing.log != "" {
+			b.WriteString(security.Delimit("log of "+failing.check.Name, failing.log) + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// truncateLog keeps the last budget bytes of a log, where failures are usually reported
+func truncateLog(log string, budget int) string {
+	log = strings.TrimSpace(log)
+	if budget <= 0 || len(log) <= budget {
+		return log
+	}
+
+	tail := log[len(log)-budget:]
+	// Start at a line boundary so the first line isn't cut in half, or failing that at
+	// the start of a character
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+
+	return fmt.Sprintf("... (%d bytes truncated)\n%s", len(log)-len(tail), tail)
+}
+
+// shortSHA abbreviates a commit hash for comments
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// maxRepairAttempts returns the configured number of repair passes per pull request
+func (p *Pipeline) maxRepairAttempts() int {
+	if p.config.Repair.MaxAttempts <= 0 {
+		return 2
+	}
+	return p.config.Repair.MaxAttempts
+}
+
+// repairLogBudget returns the configured log budget per failing check
+func (p *Pipeline) repairLogBudget() int {
+	if p.config.Repair.LogBudget <= 0 {
+		return 20000
+	}
+	return p.config.Repair.LogBudget
+}
@@ -0,0 +1,363 @@
+package workflow
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/security"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+// linkedIssuePattern finds the issue a pull request body says it resolves
+var linkedIssuePattern = regexp.MustCompile(`(?i)\b(?:fix(?:es|ed)?|close[sd]?|resolve[sd]?)\s+#(\d+)`)
+
+// reviewThread is a review comment thread with feedback that hasn't been addressed yet
+type reviewThread struct {
+	root     vcs.ReviewComment   // first comment in the thread, which carries the file/line context
+	comments []vcs.ReviewComment // new comments in the thread
+}
+
+// reviewFeedback is the review feedback on a pull request that hasn't been addressed yet
+type reviewFeedback struct {
+	threads []reviewThread
+	reviews []vcs.Review // reviews with a summary or a changes-requested verdict
+	latest  time.Time    // time of the newest piece of feedback
+}
+
+// empty reports whether there is no feedback to address
+func (f reviewFeedback) empty() bool {
+	return len(f.threads) == 0 && len(f.reviews) == 0
+}
+
+// collectReviewFeedback gathers review comments and reviews from other users made after since
+func collectReviewFeedback(reviews []vcs.Review, comments []vcs.ReviewComment, username string, since time.Time) reviewFeedback {
+	feedback := reviewFeedback{latest: since}
+
+	byID := make(map[string]vcs.ReviewComment, len(comments))
+	for _, comment := range comments {
+		byID[comment.ID] = comment
+	}
+
+	threadIndex := make(map[string]int)
+	for _, comment := range comments {
+		if comment.User == username || !comment.CreatedAt.After(since) {
+			continue
+		}
+
+		rootID := comment.ID
+		if comment.InReplyTo != "" {
+			rootID = comment.InReplyTo
+		}
+
+		i, ok := threadIndex[rootID]
+		if !ok {
+			root, found := byID[rootID]
+			if !found {
+				root = comment
+			}
+			feedback.threads = append(feedback.threads, reviewThread{root: root})
+			i = len(feedback.threads) - 1
+			threadIndex[rootID] = i
+		}
+		feedback.threads[i].comments = append(feedback.threads[i].comments, comment)
+
+		if comment.CreatedAt.After(feedback.latest) {
+			feedback.latest = comment.CreatedAt
+		}
+	}
+
+	for _, review := range reviews {
+		if review.User == username || !review.SubmittedAt.After(since) {
+			continue
+		}
+
+		// Approvals and empty comment reviews (which only wrap inline comments) need no action
+		hasSummary := strings.TrimSpace(review.Body) != ""
+		if review.State != vcs.ReviewChangesRequested && !(review.State == vcs.ReviewCommented && hasSummary) {
+			continue
+		}
+
+		feedback.reviews = append(feedback.reviews, review)
+		if review.SubmittedAt.After(feedback.latest) {
+			feedback.latest = review.SubmittedAt
+		}
+	}
+
+	sort.SliceStable(feedback.reviews, func(i, j int) bool {
+		return feedback.reviews[i].SubmittedAt.Before(feedback.reviews[j].SubmittedAt)
+	})
+
+	return feedback
+}
+
+// trustedOnly returns the feedback left by trusted users. Threads keep their root for
+// its file and line even when the root's author isn't trusted.
+func (f reviewFeedback) trustedOnly(trusted func(user string) bool) reviewFeedback {
+	filtered := reviewFeedback{latest: f.latest}
+	for _, thread := range f.threads {
+		kept := reviewThread{root: thread.root}
+		for _, comment := range thread.comments {
+			if trusted(comment.User) {
+				kept.comments = append(kept.comments, comment)
+			}
+		}
+		if len(kept.comments) > 0 {
+			filtered.threads = append(filtered.threads, kept)
+		}
+	}
+	for _, review := range f.reviews {
+		if trusted(review.User) {
+			filtered.reviews = append(filtered.reviews, review)
+		}
+	}
+	return filtered
+}
+
+// scan runs the injection detector over the feedback posted after since, which only
+// trusted users are left to have written
+func (f reviewFeedback) scan(since time.Time) []security.Finding {
+	var findings []security.Finding
+	for _, review := range f.reviews {
+		if review.SubmittedAt.After(since) {
+			findings = append(findings, security.ScanTrusted(fmt.Sprintf("review by @%s", review.User), review.Body)...)
+		}
+	}
+	for _, thread := range f.threads {
+		for _, comment := range thread.comments {
+			if comment.CreatedAt.After(since) {
+				findings = append(findings, security.ScanTrusted(fmt.Sprintf("review comment by @%s", comment.User), comment.Body)...)
+			}
+		}
+	}
+	return findings
+}
+
+// formatReviewPrompt builds the agent prompt for addressing review feedback
+func formatReviewPrompt(pr vcs.PullRequest, feedback reviewFeedback) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("You previously opened pull request #%d (%q) from branch `%s`, which is checked out in the current directory.\n",
+		pr.GetNumber(), pr.GetTitle(), pr.GetHeadBranch()))
+	b.WriteString("Reviewers have left the feedback below. Address every point by changing the code on this branch. ")
+	b.WriteString("Keep the existing work unless a reviewer asks for it to change. Do not create a new branch.\n")
+	b.WriteString(security.UntrustedNotice + "\n")
+
+	if len(feedback.reviews) > 0 {
+		b.WriteString("\n## Review summaries\n")
+		for _, review := range feedback.reviews {
+			verdict := "commented"
+			if review.State == vcs.ReviewChangesRequested {
+				verdict = "requested changes"
+			}
+			b.WriteString(fmt.Sprintf("\n@%s %s:\n%s\n", review.User, verdict,
+				security.Delimit("review by @"+review.User, strings.TrimSpace(review.Body))))
+		}
+	}
+
+	if len(feedback.threads) > 0 {
+		b.WriteString("\n## Inline comments\n")
+		for _, thread := range feedback.threads {
+			location := thread.root.Path
+			if thread.root.Line > 0 {
+				location = fmt.Sprintf("%s:%d", thread.root.Path, thread.root.Line)
+			}
+			b.WriteString(fmt.Sprintf("\n### %s\n", location))
+
+			if thread.root.DiffHunk != "" {
+				b.WriteString("```diff\n")
+				b.WriteString(thread.root.DiffHunk)
+				b.WriteString("\n```\n")
+			}
+
+			for _, comment := range thread.comments {
+				b.WriteString(security.Delimit("review comment by @"+comment.User, strings.TrimSpace(comment.Body)) + "\n")
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// linkedIssueNumber returns the issue number a pull request body says it resolves, or 0
+func linkedIssueNumber(body string) int {
+	match := linkedIssuePattern.FindStringSubmatch(body)
+	if match == nil {
+		return 0
+	}
+
+	number, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+	return number
+}
+
+// ProcessPullRequest follows up on a pull request opened by the bot: it runs chat
+// commands left on it, addresses new review feedback and, when enabled, repairs failing
+// CI checks. It implements vcs.PullRequestProcessor.
+func (p *Pipeline) ProcessPullRequest(pr vcs.PullRequest) error {
+	username, err := p.service.GetAuthenticatedUser()
+	if err != nil {
+		return fmt.Errorf("failed to get authenticated user: %w", err)
+	}
+
+	if pr.GetUser() != username {
+		return nil
+	}
+
+	key := state.IssueKey(pr.GetOwner(), pr.GetRepo(), pr.GetNumber())
+	p.handlePullRequestCommands(pr, p.store.Get(key), username)
+
+	if p.store.Get(key).Status == state.StatusNeedsHuman {
+		logging.Info("Pull request is waiting for a maintainer to review flagged text, skipping", "pr", key)
+		return nil
+	}
+
+	addressed, err := p.addressReviewFeedback(pr, username)
+	if err != nil {
+		return err
+	}
+
+	// A review follow-up pushes a new commit, so CI results are stale until it reruns
+	if addressed || !p.config.Repair.Enabled {
+		return nil
+	}
+
+	return p.repairFailingChecks(pr)
+}
+
+// handlePullRequestCommands runs chat commands left on a pull request against the issue it
+// resolves, found through the state implement saved for the pull request or its body.
+// Commands on the issue itself are handled along with them, in the order they were left,
+// since both share the issue's record of handled commands.
+func (p *Pipeline) handlePullRequestCommands(pr vcs.PullRequest, st state.IssueState, username string) {
+	issueNumber := st.Issue
+	if issueNumber == 0 {
+		issueNumber = linkedIssueNumber(pr.GetBody())
+	}
+	if issueNumber == 0 {
+		return
+	}
+
+	issue, err := p.service.GetIssueWithComments(pr.GetOwner(), pr.GetRepo(), issueNumber)
+	if err != nil {
+		logging.Warn("Failed to get the issue for pull request commands",
+			"pr", pr.GetNumber(),
+			"issue", issueNumber,
+			"error", err)
+		return
+	}
+
+	trusted := withholdUntrusted(issue, security.NewGuard(p.config, p.service, username))
+	p.handleCommands(issue, trusted, username, []vcs.LinkedPullRequest{{PullRequest: pr, Link: vcs.LinkCreatedByBot}})
+}
+
+// pullRequestIssue returns the number of the issue a pull request resolves, which keys its workspace
+func pullRequestIssue(pr vcs.PullRequest, st state.IssueState) int {
+	if st.Issue != 0 {
+		return st.Issue
+	}
+	if number := linkedIssueNumber(pr.GetBody()); number != 0 {
+		return number
+	}
+	return pr.GetNumber()
+}
+
+// addressReviewFeedback re-runs the agent with any new review feedback and replies to it.
+// It returns true when there was feedback to address.
+func (p *Pipeline) addressReviewFeedback(pr vcs.PullRequest, username string) (bool, error) {
+	owner, repo, number := pr.GetOwner(), pr.GetRepo(), pr.GetNumber()
+	key := state.IssueKey(owner, repo, number)
+	st := p.store.Get(key)
+
+	reviews, err := p.service.GetPullRequestReviews(owner, repo, number)
+	if err != nil {
+		return false, fmt.Errorf("failed to get pull request reviews: %w", err)
+	}
+
+	comments, err := p.service.GetReviewComments(owner, repo, number)
+	if err != nil {
+		return false, fmt.Errorf("failed to get review comments: %w", err)
+	}
+
+	feedback := collectReviewFeedback(reviews, comments, username, st.LastReviewAt)
+	if feedback.empty() {
+		logging.Debug("No new review feedback", "pr", key)
+		return false, nil
+	}
+
+	// Only feedback from trusted users reaches the agent; the rest is marked as handled
+	guard := security.NewGuard(p.config, p.service, username)
+	feedback = feedback.trustedOnly(func(user string) bool { return guard.Trusted(owner, repo, user) })
+	if feedback.empty() {
+		logging.Info("Ignoring review feedback from untrusted users", "pr", key)
+		st.LastReviewAt = feedback.latest
+		if err := p.store.Put(key, st); err != nil {
+			logging.Warn("Failed to save pull request state", "pr", key, "error", err)
+		}
+		return false, nil
+	}
+
+	// Feedback from before a maintainer cleared the pull request with a retry isn't scanned again
+	if findings := feedback.scan(st.ReviewedAt); len(findings) > 0 {
+		return true, p.escalate(owner, repo, number, &st, reviewEscalation, findings)
+	}
+
+	issueNumber := pullRequestIssue(pr, st)
+
+	logging.Info("Addressing review feedback",
+		"pr", key,
+		"issue", issueNumber,
+		"threads", len(feedback.threads),
+		"reviews", len(feedback.reviews))
+
+	pushed, runErr := p.followUp.RunFollowUp(owner, repo, pr.GetHeadBranch(), issueNumber,
+		formatReviewPrompt(pr, feedback), "Addressed code review feedback")
+
+	// Record the feedback as handled even if the run failed, so the same feedback isn't retried every poll
+	st.Issue = issueNumber
+	st.Branch = pr.GetHeadBranch()
+	st.LastReviewAt = feedback.latest
+	if err := p.store.Put(key, st); err != nil {
+		logging.Warn("Failed to save pull request state", "pr", key, "error", err)
+	}
+
+	if runErr != nil {
+		return true, fmt.Errorf("failed to address review feedback: %w", runErr)
+	}
+
+	reply := "I pushed a follow-up commit to address this."
+	if !pushed {
+		reply = "I looked into this but didn't end up changing anything, so this may need a human to follow up."
+	}
+
+	for _, thread := range feedback.threads {
+		if err := p.service.ReplyToReviewComment(owner, repo, number, thread.root.ID, reply); err != nil {
+			logging.Warn("Failed to reply to review comment", "pr", key, "comment", thread.root.ID, "error", err)
+		}
+	}
+
+	if len(feedback.reviews) > 0 {
+		var reviewers []string
+		seen := make(map[string]bool)
+		for _, review := range feedback.reviews {
+			if !seen[review.User] {
+				seen[review.User] = true
+				reviewers = append(reviewers, "@"+review.User)
+			}
+		}
+
+		comment := fmt.Sprintf("%s thanks for the review! %s", strings.Join(reviewers, " "), reply)
+		if err := p.service.RespondToIssue(owner, repo, number, comment); err != nil {
+			logging.Warn("Failed to reply to review", "pr", key, "error", err)
+		}
+	}
+
+	return true, nil
+}
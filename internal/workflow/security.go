@@ -0,0 +1,126 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hellausefulsoftware/useful1/internal/chatops"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/security"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+// securityMarker is embedded in escalation comments so they can be recognised later
+const securityMarker = "<!-- useful1:security -->"
+
+// trustedIssue is an issue whose text from untrusted users has been withheld
+type trustedIssue struct {
+	vcs.Issue
+	body     string
+	comments []vcs.IssueComment
+}
+
+// GetBody returns the description, or a placeholder if its author isn't trusted
+func (i *trustedIssue) GetBody() string {
+	return i.body
+}
+
+// GetComments returns the comments with the text of untrusted users withheld
+func (i *trustedIssue) GetComments() []vcs.IssueComment {
+	return i.comments
+}
+
+// withholdUntrusted replaces the description and comments of untrusted users with a
+// placeholder. Authors and timestamps are kept so replies can still be tracked.
+func withholdUntrusted(issue vcs.Issue, guard *security.Guard) vcs.Issue {
+	owner, repo := issue.GetOwner(), issue.GetRepo()
+	trusted := &trustedIssue{
+		Issue: issue,
+		body:  guard.FilterBody(owner, repo, issue.GetNumber(), issue.GetUser(), issue.GetBody()),
+	}
+
+	for _, comment := range issue.GetComments() {
+		comment.Body = guard.FilterComment(owner, repo, comment.User, comment.Body)
+		trusted.comments = append(trusted.comments, comment)
+	}
+
+	return trusted
+}
+
+// screen runs the injection detector over the original issue text, so attempts by
+// untrusted users are reported even though their text is withheld from the agent. Text
+// by trusted users is scanned with ScanTrusted. Flagged issues get a comment asking a
+// maintainer to review them and are set aside. Text from before a maintainer cleared
+// the issue with a retry isn't scanned again.
+// It returns true when the pipeline should continue with the issue.
+func (p *Pipeline) screen(issue vcs.Issue, guard *security.Guard, st *state.IssueState, username string) (bool, error) {
+	scan := func(source, user, text string) []security.Finding {
+		if guard.Trusted(issue.GetOwner(), issue.GetRepo(), user) {
+			return security.ScanTrusted(source, text)
+		}
+		return security.Scan(source, text)
+	}
+
+	var findings []security.Finding
+	if st.ReviewedAt.IsZero() {
+		findings = append(findings, scan("title", issue.GetUser(), issue.GetTitle())...)
+		findings = append(findings, scan(fmt.Sprintf("description by @%s", issue.GetUser()), issue.GetUser(), issue.GetBody())...)
+	}
+	for _, comment := range issue.GetComments() {
+		if comment.User == username || !comment.CreatedAt.After(st.ReviewedAt) {
+			continue
+		}
+		findings = append(findings, scan(fmt.Sprintf("comment by @%s", comment.User), comment.User, comment.Body)...)
+	}
+
+	if len(findings) == 0 {
+		return true, nil
+	}
+
+	return false, p.escalate(issue.GetOwner(), issue.GetRepo(), issue.GetNumber(), st, issueEscalation, findings)
+}
+
+// Openings of the comments handing flagged text to a maintainer
+const (
+	issueEscalation  = "I haven't started on this issue because parts of it read like instructions aimed at me rather than a description of the problem"
+	reviewEscalation = "I haven't acted on the new review feedback because parts of it read like instructions aimed at me rather than feedback on the code"
+	repairEscalation = "I haven't tried to fix the failing checks because parts of their logs read like instructions aimed at me rather than build output"
+)
+
+// escalate hands flagged text on an issue or pull request to a maintainer: it posts a
+// comment listing the findings and sets the issue aside until a maintainer's retry
+func (p *Pipeline) escalate(owner, repo string, number int, st *state.IssueState, opening string, findings []security.Finding) error {
+	key := state.IssueKey(owner, repo, number)
+	for _, finding := range findings {
+		logging.Warn("Possible prompt injection",
+			"issue", key,
+			"source", finding.Source,
+			"rule", finding.Rule,
+			"match", finding.Match)
+	}
+
+	if err := p.service.RespondToIssue(owner, repo, number, formatEscalation(opening, findings)); err != nil {
+		return fmt.Errorf("failed to post security escalation: %w", err)
+	}
+
+	st.Status = state.StatusNeedsHuman
+	if err := p.store.Put(key, *st); err != nil {
+		logging.Warn("Failed to save issue state", "issue", key, "error", err)
+	}
+	return nil
+}
+
+// formatEscalation builds the comment handing flagged text to a maintainer
+func formatEscalation(opening string, findings []security.Finding) string {
+	var b strings.Builder
+
+	b.WriteString(opening + ":\n\n")
+	for _, finding := range findings {
+		b.WriteString(fmt.Sprintf("- %s %s: `%s`\n", finding.Source, finding.Rule, strings.ReplaceAll(finding.Match, "`", "'")))
+	}
+	b.WriteString(fmt.Sprintf("\nA maintainer can review it and comment `%s %s` to let me go ahead anyway.\n\n", chatops.Prefix, chatops.ActionRetry))
+	b.WriteString(securityMarker)
+
+	return b.String()
+}
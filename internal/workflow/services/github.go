@@ -4,38 +4,68 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/v45/github"
-	"golang.org/x/oauth2"
 
 	"github.com/hellausefulsoftware/useful1/internal/anthropic"
+	"github.com/hellausefulsoftware/useful1/internal/audit"
+	"github.com/hellausefulsoftware/useful1/internal/auth"
 	"github.com/hellausefulsoftware/useful1/internal/cli"
 	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/dryrun"
+	gh "github.com/hellausefulsoftware/useful1/internal/github"
 	"github.com/hellausefulsoftware/useful1/internal/logging"
 	"github.com/hellausefulsoftware/useful1/internal/models"
+	"github.com/hellausefulsoftware/useful1/internal/security"
+	"github.com/hellausefulsoftware/useful1/internal/workspace"
 )
 
 // GitHubImplementationService provides GitHub implementation services
 type GitHubImplementationService struct {
-	config *config.Config
+	config     *config.Config
+	api        *gh.API
+	workspaces *workspace.Manager
+	recorder   *dryrun.Recorder // records pushes, forks, pull requests and comments instead of making them
+
+	targetsMu sync.Mutex
+	targets   map[string]*workspace.PushTarget // push targets by "owner/repo"
 }
 
 // NewGitHubImplementationService creates a new GitHub implementation service
 func NewGitHubImplementationService(cfg *config.Config) *GitHubImplementationService {
 	return &GitHubImplementationService{
-		config: cfg,
+		config:     cfg,
+		api:        gh.NewAPI(createGitHubClient(cfg)),
+		workspaces: workspace.NewManager(cfg),
+		targets:    make(map[string]*workspace.PushTarget),
 	}
 }
 
+// WithRecorder makes the service record the changes it would make to repositories and
+// issues instead of making them. The agent still runs and commits in the local worktree.
+func (s *GitHubImplementationService) WithRecorder(recorder *dryrun.Recorder) *GitHubImplementationService {
+	s.recorder = recorder
+	return s
+}
+
 // CreateImplementationPromptAndExecute creates an implementation plan and executes it using CLI
 // Returns the Claude CLI output so it can be used in PR descriptions
 func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner, repo, branchName string, issueNumber int) (string, error) {
+	return s.CreateImplementationPromptAndExecuteWithPlan(owner, repo, branchName, issueNumber, "")
+}
+
+// CreateImplementationPromptAndExecuteWithPlan executes a previously agreed implementation plan using CLI.
+// An empty plan generates a new one, like CreateImplementationPromptAndExecute.
+func (s *GitHubImplementationService) CreateImplementationPromptAndExecuteWithPlan(owner, repo, branchName string, issueNumber int, plan string) (string, error) {
 	// Create a partial issue object to get started
 	issue := &models.Issue{
 		Owner:  owner,
@@ -46,10 +76,11 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 	}
 
 	// Clone or update the repository and get the directory
-	repoDir, err := s.cloneRepository(owner, repo, branchName, issueNumber)
+	repoDir, release, err := s.cloneRepository(owner, repo, branchName, issueNumber)
 	if err != nil {
 		return "", fmt.Errorf("failed to prepare repository: %w", err)
 	}
+	defer release()
 
 	logging.Info("Creating implementation plan for issue",
 		"owner", owner,
@@ -58,11 +89,8 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 		"issue", issueNumber,
 		"dir", repoDir)
 
-	// Create GitHub client
-	githubClient := createGitHubClient(s.config)
-
 	// Get the full issue details to generate an implementation plan
-	fullIssue, err := s.getIssueDetails(githubClient, issue.Owner, issue.Repo, issue.Number)
+	fullIssue, err := s.trustedIssue(issue.Owner, issue.Repo, issue.Number)
 	if err != nil {
 		logging.Warn("Failed to get full issue details, using limited issue data",
 			"error", err)
@@ -75,12 +103,19 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 	// Generate an implementation plan using Anthropic API if token is available
 	var implementationContent string
 
-	if s.config.Anthropic.Token == "" {
+	if plan != "" {
+		// Use the plan that was approved on the issue
+		implementationContent = fmt.Sprintf("# Developer Instructions for Issue #%d: %s\n\n",
+			issue.Number, issue.Title)
+		implementationContent += plan
+		logging.Info("Using approved implementation plan", "plan_length", len(plan))
+	} else if s.config.Anthropic.Token == "" {
 		// Use a simple default implementation placeholder
 		implementationContent = fmt.Sprintf("# Implementation Plan for Issue #%d: %s\n\n",
 			issue.Number, issue.Title)
 		implementationContent += "## Problem Description\n\n"
-		implementationContent += issue.Body + "\n\n"
+		implementationContent += security.UntrustedNotice + "\n\n"
+		implementationContent += security.Delimit("description by @"+issue.User, issue.Body) + "\n\n"
 		implementationContent += "## Implementation Notes\n\n"
 		implementationContent += "The implementation details will be added here.\n"
 	} else {
@@ -99,7 +134,8 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 			implementationContent = fmt.Sprintf("# Implementation Plan for Issue #%d: %s\n\n",
 				issue.Number, issue.Title)
 			implementationContent += "## Problem Description\n\n"
-			implementationContent += issue.Body + "\n\n"
+			implementationContent += security.UntrustedNotice + "\n\n"
+			implementationContent += security.Delimit("description by @"+issue.User, issue.Body) + "\n\n"
 			implementationContent += "## Implementation Notes\n\n"
 			implementationContent += "The implementation details will be added here.\n"
 		} else {
@@ -137,7 +173,7 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 		"plan_length", len(implementationContent))
 
 	// Create a temporary file in the current directory to store the issue details
-	issueDetailFile, err := os.CreateTemp("", "issue-*.txt")
+	issueDetailFile, err := os.CreateTemp("", workspace.IssueFilePattern)
 	if err != nil {
 		return "", fmt.Errorf("failed to create temporary issue detail file: %w", err)
 	}
@@ -148,7 +184,8 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 	}()
 
 	// Write issue details to the file
-	issueContent := fmt.Sprintf("Issue #%d: %s\n\n%s", issue.Number, issue.Title, issue.Body)
+	issueContent := fmt.Sprintf("Issue #%d: %s\n\n%s\n\n%s", issue.Number, issue.Title,
+		security.UntrustedNotice, security.Delimit("description by @"+issue.User, issue.Body))
 	if _, writeErr := issueDetailFile.WriteString(issueContent); writeErr != nil {
 		return "", fmt.Errorf("failed to write to issue detail file: %w", writeErr)
 	}
@@ -162,12 +199,12 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 		"owner":     owner,
 		"repo":      repo,
 		"issue":     issueNumber,
-		"url":       fmt.Sprintf("https://github.com/%s/%s/issues/%d", owner, repo, issueNumber),
+		"url":       fmt.Sprintf("%s/%s/%s/issues/%d", s.config.GitHubWebURL(), owner, repo, issueNumber),
 		"branch":    branchName,
 	}
 
 	// Create a temporary metadata file
-	metadataFile, err := os.CreateTemp("", "metadata-*.json")
+	metadataFile, err := os.CreateTemp("", workspace.MetadataFilePattern)
 	if err != nil {
 		return "", fmt.Errorf("failed to create metadata file: %w", err)
 	}
@@ -190,22 +227,122 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 		return "", fmt.Errorf("failed to close metadata file: %w", closeErr)
 	}
 
+	if _, err := s.executeAndPush(issue, branchName, implementationContent, "Created files to implement solution"); err != nil {
+		return "", err
+	}
+
+	return implementationContent, nil
+}
+
+// trustedIssue gets an issue with its comments, withholding the text of untrusted users
+func (s *GitHubImplementationService) trustedIssue(owner, repo string, number int) (*models.Issue, error) {
+	issue, err := s.api.IssueWithComments(context.Background(), owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+	return s.guard().FilterIssue(issue), nil
+}
+
+// guard creates a guard that looks up permissions through the GitHub API
+func (s *GitHubImplementationService) guard() *security.Guard {
+	permissions := security.PermissionFunc(func(owner, repo, username string) (string, error) {
+		return s.api.UserPermission(context.Background(), owner, repo, username)
+	})
+	return security.NewGuard(s.config, permissions, s.config.GitHub.User)
+}
+
+// RunFollowUp re-runs the CLI tool on an existing branch in the issue's workspace, for example with
+// review feedback or CI failures as the prompt, and pushes any follow-up commits.
+// description summarizes the follow-up for the commit message. It reports whether anything was pushed.
+func (s *GitHubImplementationService) RunFollowUp(owner, repo, branchName string, issueNumber int, prompt, description string) (bool, error) {
+	// Reuse the workspace the branch was implemented in
+	repoDir, release, err := s.cloneRepository(owner, repo, branchName, issueNumber)
+	if err != nil {
+		return false, fmt.Errorf("failed to prepare repository: %w", err)
+	}
+	defer release()
+
+	logging.Info("Running follow-up on existing branch",
+		"owner", owner,
+		"repo", repo,
+		"branch", branchName,
+		"issue", issueNumber,
+		"dir", repoDir)
+
+	issue := &models.Issue{
+		Owner:  owner,
+		Repo:   repo,
+		Number: issueNumber,
+		Title:  fmt.Sprintf("Issue #%d", issueNumber),
+	}
+	if fullIssue, detailsErr := s.trustedIssue(owner, repo, issueNumber); detailsErr == nil {
+		issue = fullIssue
+	} else {
+		logging.Warn("Failed to get full issue details, using limited issue data", "error", detailsErr)
+	}
+
+	currentDir, dirErr := os.Getwd()
+	if dirErr != nil {
+		return false, fmt.Errorf("failed to get current directory: %w", dirErr)
+	}
+	defer func() {
+		if chDirErr := os.Chdir(currentDir); chDirErr != nil {
+			logging.Warn("Failed to return to original directory", "error", chDirErr)
+		}
+	}()
+
+	if chDirErr := os.Chdir(repoDir); chDirErr != nil {
+		return false, fmt.Errorf("failed to change to repository directory: %w", chDirErr)
+	}
+
+	return s.executeAndPush(issue, branchName, prompt, description)
+}
+
+// executeAndPush runs the CLI tool with a prompt in the current directory, then commits
+// and pushes any changes it made to the branch. It reports whether anything was pushed.
+// description summarizes the change for the generated commit message.
+func (s *GitHubImplementationService) executeAndPush(issue *models.Issue, branchName, prompt, description string) (bool, error) {
+	pushed := false
+
 	logging.Info("Executing Claude CLI with implementation plan as prompt",
-		"plan_length", len(implementationContent))
+		"plan_length", len(prompt))
+
+	// The agent reaches the API through a meter that stops it at the run's budget
+	budget := s.config.RunBudget()
+	meter, err := anthropic.StartMeter(budget)
+	if err != nil {
+		return false, fmt.Errorf("failed to start the API meter: %w", err)
+	}
+	defer func() { _ = meter.Close() }()
 
 	// Create executor to handle CLI command execution
 	executor := cli.NewExecutor(s.config)
+	executor.SetEnv(meter.Env()...)
 
 	// Set up the arguments - no need for -p flag now
 	args := []string{}
 
 	// Execute the CLI tool using the executor with the prompt content
-	output, err := executor.ExecuteWithOutput(args, implementationContent)
+	audit.Record(audit.Entry{
+		Action:  audit.ActionAgentRun,
+		Repo:    issue.Owner + "/" + issue.Repo,
+		Number:  issue.Number,
+		Ref:     branchName,
+		Model:   s.config.CLI.Command,
+		Summary: description,
+		Body:    prompt,
+	})
+	output, err := executor.ExecuteWithOutput(args, prompt)
+	if meter.Exceeded() {
+		// Whatever the agent left half done isn't committed
+		logging.Warn("Agent run stopped at its budget", "budget_usd", budget, "spent_usd", meter.Spent())
+		return false, fmt.Errorf("agent spent $%.2f of its $%.2f budget: %w", meter.Spent(), budget, anthropic.ErrBudgetExceeded)
+	}
 	if err != nil {
 		logging.Error("Failed to execute Claude CLI with implementation plan",
 			"error", err,
 			"output", output)
-		return "", fmt.Errorf("failed to execute Claude CLI: %w", err)
+		return false, fmt.Errorf("failed to execute Claude CLI: %w", err)
 	}
 
 	// Check if the git repo has any changes
@@ -213,7 +350,7 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 	statusOut, err := statusCmd.CombinedOutput()
 	if err != nil {
 		logging.Error("Failed to check git status", "error", err)
-		return "", fmt.Errorf("failed to check git status: %w", err)
+		return false, fmt.Errorf("failed to check git status: %w", err)
 	}
 
 	// If there are changes, commit them
@@ -234,16 +371,18 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 		// Generate a commit message
 		commitMsg, err := analyzer.GenerateCommitMessage(
 			&models.Issue{
-				Number: issueNumber,
+				Owner:  issue.Owner,
+				Repo:   issue.Repo,
+				Number: issue.Number,
 				Title:  issue.Title,
 				Body:   issue.Body,
 			},
 			changedFiles,
-			"Created files to implement solution")
+			description)
 
 		if err != nil {
 			// Fallback commit message if generation fails
-			commitMsg = fmt.Sprintf("feat: implement solution for issue #%d", issueNumber)
+			commitMsg = fmt.Sprintf("feat: implement solution for issue #%d", issue.Number)
 			logging.Warn("Failed to generate commit message, using fallback",
 				"error", err,
 				"fallback", commitMsg)
@@ -253,24 +392,27 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 		addCmd := exec.Command("git", "add", ".")
 		addOut, err := addCmd.CombinedOutput()
 		if err != nil {
-			return "", fmt.Errorf("failed to git add: %w\nOutput: %s", err, string(addOut))
+			return false, fmt.Errorf("failed to git add: %w\nOutput: %s", err, string(addOut))
 		}
 
 		// Commit the changes
 		commitCmd := exec.Command("git", "commit", "-m", commitMsg)
+		if identity := gitIdentityEnv(s.config); identity != nil {
+			commitCmd.Env = append(os.Environ(), identity...)
+		}
 		commitOut, err := commitCmd.CombinedOutput()
 		if err != nil {
-			return "", fmt.Errorf("failed to commit: %w\nOutput: %s", err, string(commitOut))
+			return false, fmt.Errorf("failed to commit: %w\nOutput: %s", err, string(commitOut))
 		}
 		logging.Info("Successfully committed changes", "message", commitMsg)
 
 		// Push to the branch
-		pushCmd := exec.Command("git", "push", "origin", branchName)
-		pushOut, err := pushCmd.CombinedOutput()
+		pushOut, err := s.push(issue, branchName)
 		if err != nil {
-			return "", fmt.Errorf("failed to push: %w\nOutput: %s", err, string(pushOut))
+			return false, fmt.Errorf("failed to push: %w\nOutput: %s", err, string(pushOut))
 		}
 		logging.Info("Successfully pushed changes to remote")
+		pushed = true
 	} else {
 		logging.Info("No changes detected in repository after Claude CLI execution")
 
@@ -284,14 +426,14 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 				logging.Info("Found unpushed commits, pushing to remote", "count", unpushedCount)
 
 				// Push commits to the remote branch
-				pushCmd := exec.Command("git", "push", "origin", branchName)
-				pushOut, pushErr := pushCmd.CombinedOutput()
+				pushOut, pushErr := s.push(issue, branchName)
 				if pushErr != nil {
 					logging.Warn("Failed to push commits",
 						"error", pushErr,
 						"output", string(pushOut))
 				} else {
 					logging.Info("Successfully pushed commits to remote branch")
+					pushed = true
 				}
 			} else {
 				logging.Warn("No changes or unpushed commits found - PR creation may fail without commits")
@@ -303,7 +445,7 @@ func (s *GitHubImplementationService) CreateImplementationPromptAndExecute(owner
 		}
 	}
 
-	return implementationContent, nil
+	return pushed, nil
 }
 
 // GenerateBranchAndTitle generates a branch name and PR title
@@ -424,254 +566,188 @@ func sanitizeBranchName(input string) string {
 
 // createGitHubClient creates a GitHub client from config
 func createGitHubClient(cfg *config.Config) *github.Client {
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: cfg.GitHub.Token},
-	)
-	tc := oauth2.NewClient(context.Background(), ts)
-	return github.NewClient(tc)
-}
-
-// cloneRepository clones a GitHub repository to a local directory
-func (s *GitHubImplementationService) cloneRepository(owner, repo, branch string, issueNumber int) (string, error) {
-	// Get user's home directory
-	homeDir, err := os.UserHomeDir()
+	client, err := auth.NewGitHubClient(cfg)
 	if err != nil {
-		return "", fmt.Errorf("failed to get user's home directory: %w", err)
+		// Requests fail with an authentication error, which surfaces where they are made
+		logging.Error("Failed to create authenticated GitHub client", "error", err)
+		client = github.NewClient(http.DefaultClient)
+		if urlErr := auth.SetGitHubBaseURLs(client, cfg); urlErr != nil {
+			logging.Error("Failed to set GitHub Enterprise URLs", "error", urlErr)
+		}
 	}
+	return client
+}
 
-	// Create temp directory path
-	tempDir := fmt.Sprintf("%s/.useful1/temp/%s_%d", homeDir, repo, issueNumber)
+// pushTarget returns where branches for owner/repo are pushed, forking the repository once
+// when the bot can't push to it
+func (s *GitHubImplementationService) pushTarget(owner, repo string) (*workspace.PushTarget, error) {
+	key := owner + "/" + repo
 
-	// Create parent directory if it doesn't exist
-	baseDir := fmt.Sprintf("%s/.useful1/temp", homeDir)
-	err = os.MkdirAll(baseDir, 0755)
-	if err != nil {
-		return "", fmt.Errorf("failed to create base temp directory: %w", err)
-	}
+	s.targetsMu.Lock()
+	defer s.targetsMu.Unlock()
 
-	// Check if directory already exists
-	_, err = os.Stat(tempDir)
-	repoExists := !os.IsNotExist(err)
+	if target, ok := s.targets[key]; ok {
+		return target, nil
+	}
 
-	repoURL := fmt.Sprintf("git@github.com:%s/%s.git", owner, repo)
-	logging.Info("Repository operations",
-		"owner", owner,
-		"repo", repo,
-		"branch", branch,
-		"issue", issueNumber,
-		"dir", tempDir,
-		"exists", repoExists)
-
-	// Handle existing repositories
-	if repoExists {
-		// Check if the existing directory is a valid git repository
-		// If not, remove it and clone fresh
-		isValid, validateErr := s.validateGitRepository(tempDir)
-		if validateErr != nil {
-			return "", validateErr
-		}
+	if s.recorder != nil {
+		return s.dryRunPushTarget(owner, repo)
+	}
 
-		if !isValid {
-			// Not a valid git repo, need to remove it and clone fresh
-			repoExists = false
-		} else {
-			// It's a valid repository, update it
-			_, updateErr := s.updateExistingRepository(tempDir, branch)
-			if updateErr != nil {
-				return "", updateErr
-			}
-		}
+	target, err := workspace.ResolvePushTarget(context.Background(), createGitHubClient(s.config), owner, repo, s.config.Workspace.ForkOrganization)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine where to push: %w", err)
 	}
+	s.targets[key] = target
+	return target, nil
+}
 
-	// Handle non-existing or invalid repositories that need to be cloned
-	if !repoExists {
-		_, err = s.cloneFreshRepository(repoURL, tempDir, branch)
-		if err != nil {
-			return "", err
+// dryRunPushTarget records the fork a push would need instead of creating it. Nothing
+// is pushed on a dry run, so origin stands in for the fork. The caller holds targetsMu.
+func (s *GitHubImplementationService) dryRunPushTarget(owner, repo string) (*workspace.PushTarget, error) {
+	canPush, err := workspace.CanPush(context.Background(), createGitHubClient(s.config), owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine where to push: %w", err)
+	}
+	if !canPush {
+		fork := "the bot's account"
+		if s.config.Workspace.ForkOrganization != "" {
+			fork = s.config.Workspace.ForkOrganization
 		}
+		s.recorder.Record(dryrun.Action{Kind: dryrun.KindFork, Repo: owner + "/" + repo, Summary: "fork into " + fork})
 	}
 
-	logging.Info("Repository ready", "dir", tempDir, "branch", branch)
-	return tempDir, nil
+	target := &workspace.PushTarget{Remote: "origin", Owner: owner, Repo: repo}
+	s.targets[owner+"/"+repo] = target
+	return target, nil
 }
 
-// validateGitRepository checks if a directory is a valid git repository
-func (s *GitHubImplementationService) validateGitRepository(repoDir string) (bool, error) {
-	currentDir, err := os.Getwd()
+// gitCommand returns a git command with credentials for owner/repo
+func (s *GitHubImplementationService) gitCommand(owner, repo string, args ...string) (*exec.Cmd, error) {
+	token, err := workspace.Token(s.config, owner, repo)
 	if err != nil {
-		return false, fmt.Errorf("failed to get current directory: %w", err)
+		return nil, fmt.Errorf("failed to get git credentials: %w", err)
 	}
-	defer func() {
-		if chDirErr := os.Chdir(currentDir); chDirErr != nil {
-			logging.Warn("Failed to return to original directory", "error", chDirErr)
-		}
-	}() // Return to original directory when done
+	return workspace.Command(token, args...), nil
+}
 
-	if chDirErr := os.Chdir(repoDir); chDirErr != nil {
-		return false, fmt.Errorf("failed to change to repository directory: %w", chDirErr)
+// pushCommand returns the command that pushes a branch to the repository's push target
+func (s *GitHubImplementationService) pushCommand(owner, repo, branch string) (*exec.Cmd, error) {
+	target, err := s.pushTarget(owner, repo)
+	if err != nil {
+		return nil, err
 	}
+	return s.gitCommand(target.Owner, target.Repo, "push", "--set-upstream", target.Remote, branch)
+}
 
-	// Check if this is a valid git repository
-	checkGitCmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
-	checkOut, err := checkGitCmd.CombinedOutput()
-
-	if err != nil || strings.TrimSpace(string(checkOut)) != "true" {
-		// Not a valid git repository, remove it
-		logging.Warn("Directory exists but is not a valid git repository, removing it",
-			"dir", repoDir)
-
-		// Go back to original directory before removing
-		if chDirErr := os.Chdir(currentDir); chDirErr != nil {
-			logging.Warn("Failed to return to original directory", "error", chDirErr)
+// push pushes a branch from the current directory and returns git's output. On a dry
+// run the push is recorded with the commit it would have pushed.
+func (s *GitHubImplementationService) push(issue *models.Issue, branch string) ([]byte, error) {
+	if s.recorder != nil {
+		target, err := s.pushTarget(issue.Owner, issue.Repo)
+		if err != nil {
+			return nil, err
 		}
-
-		// Remove the invalid repository directory
-		if err := os.RemoveAll(repoDir); err != nil {
-			return false, fmt.Errorf("failed to remove invalid repository directory: %w", err)
+		commit, err := exec.Command("git", "show", "--stat", "--format=%H%n%n%B", "HEAD").CombinedOutput()
+		if err != nil {
+			return commit, fmt.Errorf("failed to describe commit: %w", err)
 		}
-
-		return false, nil
+		s.recorder.Record(dryrun.Action{
+			Kind:    dryrun.KindPush,
+			Repo:    issue.Owner + "/" + issue.Repo,
+			Number:  issue.Number,
+			Summary: fmt.Sprintf("push `%s` to %s/%s", branch, target.Owner, target.Repo),
+			Body:    string(commit),
+		})
+		return nil, nil
 	}
 
-	return true, nil
-}
-
-// updateExistingRepository updates an existing git repository
-func (s *GitHubImplementationService) updateExistingRepository(repoDir, branch string) (string, error) {
-	currentDir, err := os.Getwd()
+	pushCmd, err := s.pushCommand(issue.Owner, issue.Repo, branch)
 	if err != nil {
-		return "", fmt.Errorf("failed to get current directory: %w", err)
+		return nil, err
 	}
-	defer func() {
-		if chDirErr := os.Chdir(currentDir); chDirErr != nil {
-			logging.Warn("Failed to return to original directory", "error", chDirErr)
-		}
-	}() // Return to original directory when done
-
-	if chDirErr := os.Chdir(repoDir); chDirErr != nil {
-		return "", fmt.Errorf("failed to change to repository directory: %w", chDirErr)
+	output, err := pushCmd.CombinedOutput()
+	if err == nil {
+		s.auditPush(issue, branch)
 	}
+	return output, err
+}
 
-	logging.Info("Repository directory exists and is valid, attempting to update", "dir", repoDir)
-
-	// Fetch and checkout the branch
-	// First, make sure we have the latest from remote
-	fetchCmd := exec.Command("git", "fetch", "origin")
-	fetchOut, err := fetchCmd.CombinedOutput()
+// auditPush records a push from the current directory with the commit that was pushed
+func (s *GitHubImplementationService) auditPush(issue *models.Issue, branch string) {
+	sha, err := exec.Command("git", "rev-parse", "HEAD").Output()
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch latest changes: %w\nOutput: %s", err, string(fetchOut))
+		logging.Warn("Failed to read pushed commit for the audit log", "error", err)
+	}
+	summary := "push " + branch
+	s.targetsMu.Lock()
+	target, ok := s.targets[issue.Owner+"/"+issue.Repo]
+	s.targetsMu.Unlock()
+	if ok {
+		summary = fmt.Sprintf("push %s to %s/%s", branch, target.Owner, target.Repo)
+	}
+	audit.Record(audit.Entry{
+		Action:  audit.ActionPush,
+		Repo:    issue.Owner + "/" + issue.Repo,
+		Number:  issue.Number,
+		Ref:     strings.TrimSpace(string(sha)),
+		Summary: summary,
+	})
+}
+
+// gitIdentityEnv returns environment variables that attribute commits to the GitHub App's
+// bot user, or nil when the bot commits as the configured user
+func gitIdentityEnv(cfg *config.Config) []string {
+	if !cfg.UsesGitHubApp() {
+		return nil
 	}
 
-	// Try to checkout the branch (it may already be checked out)
-	checkoutCmd := exec.Command("git", "checkout", branch)
-	checkoutOut, err := checkoutCmd.CombinedOutput()
+	app, err := auth.GitHubAppFromConfig(cfg)
 	if err != nil {
-		// Branch might not exist locally yet
-		checkoutTrackCmd := exec.Command("git", "checkout", "-b", branch, "--track", "origin/"+branch)
-		trackOut, trackErr := checkoutTrackCmd.CombinedOutput()
-		if trackErr != nil {
-			logging.Warn("Failed to checkout tracking branch",
-				"error", trackErr,
-				"output", string(trackOut),
-				"branch", branch)
-			// Try to create the branch locally
-			createBranchCmd := exec.Command("git", "checkout", "-b", branch)
-			createOut, createErr := createBranchCmd.CombinedOutput()
-			if createErr != nil {
-				return "", fmt.Errorf("failed to create branch locally: %w\nOutput: %s", createErr, string(createOut))
-			}
-		}
-	} else {
-		logging.Info("Checked out branch", "branch", branch, "output", string(checkoutOut))
+		logging.Warn("Failed to load GitHub App for commit identity", "error", err)
+		return nil
 	}
 
-	// Pull latest changes
-	pullCmd := exec.Command("git", "pull", "origin", branch)
-	pullOut, err := pullCmd.CombinedOutput()
+	name, email, err := app.CommitIdentity(context.Background())
 	if err != nil {
-		logging.Warn("Failed to pull latest changes, may be a new branch",
-			"error", err,
-			"output", string(pullOut),
-			"branch", branch)
-	} else {
-		logging.Info("Pulled latest changes from remote", "branch", branch)
+		logging.Warn("Failed to get GitHub App commit identity", "error", err)
+		return nil
 	}
 
-	return "", nil
+	return []string{
+		"GIT_AUTHOR_NAME=" + name,
+		"GIT_AUTHOR_EMAIL=" + email,
+		"GIT_COMMITTER_NAME=" + name,
+		"GIT_COMMITTER_EMAIL=" + email,
+	}
 }
 
-// getIssueDetails retrieves full details of an issue including comments
-func (s *GitHubImplementationService) getIssueDetails(client *github.Client, owner, repo string, number int) (*models.Issue, error) {
-	ctx := context.Background()
-
-	// Get issue details
-	issue, _, err := client.Issues.Get(ctx, owner, repo, number)
+// cloneRepository prepares the issue's worktree from the shared repository mirror. The
+// worktree is locked against pruning until the returned function is called.
+func (s *GitHubImplementationService) cloneRepository(owner, repo, branch string, issueNumber int) (string, func(), error) {
+	target, err := s.pushTarget(owner, repo)
 	if err != nil {
-		return nil, fmt.Errorf("error getting issue: %w", err)
-	}
-
-	// Create our issue object
-	result := &models.Issue{
-		Owner:     owner,
-		Repo:      repo,
-		Number:    number,
-		Title:     *issue.Title,
-		Body:      *issue.Body,
-		User:      *issue.User.Login,
-		State:     *issue.State,
-		CreatedAt: *issue.CreatedAt,
-		UpdatedAt: *issue.UpdatedAt,
-		URL:       *issue.HTMLURL,
-		Comments:  []*models.IssueComment{},
-		Labels:    make([]string, 0, len(issue.Labels)),
-		Assignees: make([]string, 0, len(issue.Assignees)),
-	}
-
-	// Add labels
-	for _, label := range issue.Labels {
-		if label.Name != nil {
-			result.Labels = append(result.Labels, *label.Name)
-		}
+		return "", nil, err
 	}
 
-	// Add assignees
-	for _, assignee := range issue.Assignees {
-		if assignee.Login != nil {
-			result.Assignees = append(result.Assignees, *assignee.Login)
-		}
+	release, err := s.workspaces.Lock(owner, repo, issueNumber)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to lock workspace: %w", err)
 	}
 
-	// Get comments
-	comments, _, err := client.Issues.ListComments(
-		ctx,
-		owner,
-		repo,
-		number,
-		&github.IssueListCommentsOptions{
-			Sort:      github.String("created"),
-			Direction: github.String("asc"),
-			ListOptions: github.ListOptions{
-				PerPage: 100,
-			},
-		},
-	)
+	logging.Info("Repository operations",
+		"owner", owner,
+		"repo", repo,
+		"branch", branch,
+		"issue", issueNumber,
+		"push_to", target.Owner+"/"+target.Repo)
+
+	dir, err := s.workspaces.Prepare(owner, repo, branch, issueNumber, target)
 	if err != nil {
-		// We'll continue even without comments
-		logging.Warn("Failed to get issue comments", "error", err)
-	} else {
-		// Process comments
-		for _, comment := range comments {
-			// Add to our comments list
-			result.Comments = append(result.Comments, &models.IssueComment{
-				ID:        *comment.ID,
-				User:      *comment.User.Login,
-				Body:      *comment.Body,
-				CreatedAt: *comment.CreatedAt,
-			})
-		}
+		release()
+		return "", nil, err
 	}
-
-	return result, nil
+	return dir, release, nil
 }
 
 // CreatePullRequestForIssue creates a PR specifically linked to an issue
@@ -679,8 +755,7 @@ func (s *GitHubImplementationService) getIssueDetails(client *github.Client, own
 // repoDir is the directory where the repository is cloned
 func (s *GitHubImplementationService) CreatePullRequestForIssue(owner, repo, branch, base string, issueNumber int, claudeOutput string, repoDir string) (*github.PullRequest, error) {
 	// Get issue details first
-	githubClient := createGitHubClient(s.config)
-	issue, _, err := githubClient.Issues.Get(context.Background(), owner, repo, issueNumber)
+	issue, err := s.api.Issue(context.Background(), owner, repo, issueNumber)
 	if err != nil {
 		logging.Error("Failed to get issue details",
 			"error", err,
@@ -690,17 +765,11 @@ func (s *GitHubImplementationService) CreatePullRequestForIssue(owner, repo, bra
 		return nil, fmt.Errorf("failed to get issue details: %w", err)
 	}
 
-	// Use issue title as PR title
-	title := fmt.Sprintf("Fix #%d: %s", issueNumber, *issue.Title)
+	// Create an issue model for anthropic, without text from untrusted users
+	issueModel := s.guard().FilterIssue(gh.ConvertIssue(issue, owner, repo))
 
-	// Create an issue model for anthropic
-	issueModel := &models.Issue{
-		Owner:  owner,
-		Repo:   repo,
-		Number: issueNumber,
-		Title:  *issue.Title,
-		Body:   *issue.Body,
-	}
+	// Use issue title as PR title
+	title := fmt.Sprintf("Fix #%d: %s", issueNumber, issueModel.Title)
 
 	// Generate PR description using Anthropic API
 	var body string
@@ -814,38 +883,50 @@ func (s *GitHubImplementationService) createPullRequestInternal(owner, repo, bra
 		"title", title,
 		"issue", issueNum)
 
-	// Create the GitHub client
-	githubClient := createGitHubClient(s.config)
+	// Branches pushed to a fork are opened as cross-repository pull requests
+	target, err := s.pushTarget(owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	head := target.Head(branch)
 
 	// Call GitHub API to create the PR
 	newPR := &github.NewPullRequest{
 		Title: github.String(title),
 		Body:  github.String(body),
-		Head:  github.String(branch),
+		Head:  github.String(head),
 		Base:  github.String(base),
 		Draft: github.Bool(false), // Create as ready for review by default
 	}
+	if target.Fork {
+		newPR.MaintainerCanModify = github.Bool(true)
+	}
+
+	if s.recorder != nil {
+		s.recorder.Record(dryrun.Action{
+			Kind:    dryrun.KindPullRequest,
+			Repo:    owner + "/" + repo,
+			Number:  issueNum,
+			Summary: fmt.Sprintf("open %q from `%s` into `%s`", title, head, base),
+			Body:    body,
+		})
+		return &github.PullRequest{Title: github.String(title), Body: github.String(body), State: github.String("open")}, nil
+	}
 
 	logging.Info("Making GitHub API call to create PR",
 		"owner", owner,
 		"repo", repo,
-		"head", branch,
+		"head", head,
 		"base", base)
 
-	pr, resp, err := githubClient.PullRequests.Create(
-		context.Background(),
-		owner,
-		repo,
-		newPR,
-	)
-
+	pr, err := s.api.CreatePullRequest(context.Background(), owner, repo, newPR)
 	if err != nil {
 		// Log more details about the error
-		if resp != nil {
+		var apiErr *github.ErrorResponse
+		if errors.As(err, &apiErr) && apiErr.Response != nil {
 			logging.Error("GitHub API error details",
-				"status", resp.Status,
-				"rate_limit", resp.Rate.Limit,
-				"rate_remaining", resp.Rate.Remaining)
+				"status", apiErr.Response.Status,
+				"message", apiErr.Message)
 		}
 
 		// Handle common errors
@@ -863,35 +944,12 @@ func (s *GitHubImplementationService) createPullRequestInternal(owner, repo, bra
 			return nil, fmt.Errorf("cannot create draft PR: a pull request already exists: %w", err)
 		}
 
-		return nil, fmt.Errorf("failed to create PR: %w", err)
+		return nil, err
 	}
 
 	logging.Info("Successfully created PR",
-		"pr_number", *pr.Number,
-		"pr_url", *pr.HTMLURL)
-
-	// If we have an issue number, post a comment to the issue
-	if issueNum > 0 {
-		// Format PR notification comment
-		commentMsg := fmt.Sprintf("🚀 A pull request has been created to address this issue: [#%d](%s)\n\nThis PR was created using AI assistance through [useful1](https://github.com/hellausefulsoftware/useful1).",
-			*pr.Number,
-			*pr.HTMLURL)
-
-		// Post comment to the issue
-		_, err := s.RespondToIssue(owner, repo, issueNum, commentMsg)
-		if err != nil {
-			logging.Warn("Failed to post PR notification comment to issue",
-				"error", err,
-				"issue", issueNum,
-				"pr", *pr.Number)
-			// We don't want to fail the PR creation just because the comment failed
-			// so we just log a warning here
-		} else {
-			logging.Info("Posted PR notification comment to issue",
-				"issue", issueNum,
-				"pr", *pr.Number)
-		}
-	}
+		"pr_number", pr.GetNumber(),
+		"pr_url", pr.GetHTMLURL())
 
 	return pr, nil
 }
@@ -904,20 +962,12 @@ func (s *GitHubImplementationService) RespondToIssue(owner, repo string, issueNu
 		"issue", issueNumber,
 		"comment_length", len(comment))
 
-	// Create GitHub client
-	githubClient := createGitHubClient(s.config)
-
-	// Post the comment
-	resp, _, err := githubClient.Issues.CreateComment(
-		context.Background(),
-		owner,
-		repo,
-		issueNumber,
-		&github.IssueComment{
-			Body: github.String(comment),
-		},
-	)
+	if s.recorder != nil {
+		s.recorder.Record(dryrun.Action{Kind: dryrun.KindComment, Repo: owner + "/" + repo, Number: issueNumber, Summary: "post a comment", Body: comment})
+		return "", nil
+	}
 
+	resp, err := s.api.CreateComment(context.Background(), owner, repo, issueNumber, comment)
 	if err != nil {
 		logging.Error("Failed to post comment to issue",
 			"error", err,
@@ -935,63 +985,3 @@ func (s *GitHubImplementationService) RespondToIssue(owner, repo string, issueNu
 
 	return "", nil
 }
-
-// cloneFreshRepository clones a fresh git repository
-func (s *GitHubImplementationService) cloneFreshRepository(repoURL, repoDir, branch string) (string, error) {
-	// Clone the repository - let git clone create the directory structure
-	logging.Info("Cloning repository", "url", repoURL, "dir", repoDir)
-
-	cloneCmd := exec.Command("git", "clone", repoURL, repoDir)
-	cloneOut, err := cloneCmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("failed to clone repository: %w\nOutput: %s", err, string(cloneOut))
-	}
-
-	// Change to repo directory
-	currentDir, dirErr := os.Getwd()
-	if dirErr != nil {
-		return "", fmt.Errorf("failed to get current directory: %w", dirErr)
-	}
-	defer func() {
-		if chDirErr := os.Chdir(currentDir); chDirErr != nil {
-			logging.Warn("Failed to return to original directory", "error", chDirErr)
-		}
-	}() // Return to original directory when done
-
-	if chDirErr := os.Chdir(repoDir); chDirErr != nil {
-		return "", fmt.Errorf("failed to change to repository directory: %w", chDirErr)
-	}
-
-	// Checkout the branch
-	checkoutCmd := exec.Command("git", "checkout", branch)
-	checkoutOut, err := checkoutCmd.CombinedOutput()
-	if err != nil {
-		// Branch might not exist locally yet
-		fetchCmd := exec.Command("git", "fetch", "origin", branch+":"+branch)
-		fetchOut, fetchErr := fetchCmd.CombinedOutput()
-		if fetchErr != nil {
-			logging.Warn("Failed to fetch branch",
-				"error", fetchErr,
-				"output", string(fetchOut),
-				"branch", branch)
-
-			// Create the branch
-			createCmd := exec.Command("git", "checkout", "-b", branch)
-			createOut, createErr := createCmd.CombinedOutput()
-			if createErr != nil {
-				return "", fmt.Errorf("failed to create branch: %w\nOutput: %s", createErr, string(createOut))
-			}
-		} else {
-			// Try checkout again after fetch
-			retryCmd := exec.Command("git", "checkout", branch)
-			retryOut, retryErr := retryCmd.CombinedOutput()
-			if retryErr != nil {
-				return "", fmt.Errorf("failed to checkout branch after fetch: %w\nOutput: %s", retryErr, string(retryOut))
-			}
-		}
-	} else {
-		logging.Info("Checked out branch", "branch", branch, "output", string(checkoutOut))
-	}
-
-	return "", nil
-}
@@ -1,12 +1,9 @@
 package services
 
 import (
-	"context"
-
 	"github.com/google/go-github/v45/github"
 	"github.com/hellausefulsoftware/useful1/internal/cli"
 	"github.com/hellausefulsoftware/useful1/internal/config"
-	"golang.org/x/oauth2"
 )
 
 // Provider creates service instances
@@ -23,12 +20,7 @@ func NewProvider(cfg *config.Config) *Provider {
 
 // GetGitHubClient returns a GitHub API client
 func (p *Provider) GetGitHubClient() *github.Client {
-	// Create GitHub client
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: p.config.GitHub.Token},
-	)
-	tc := oauth2.NewClient(context.Background(), ts)
-	return github.NewClient(tc)
+	return createGitHubClient(p.config)
 }
 
 // GetCLIExecutor returns a CLI executor
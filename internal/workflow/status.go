@@ -0,0 +1,215 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/anthropic"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/events"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+// statusMarker is embedded in the status comment so it can be recognised later
+const statusMarker = "<!-- useful1:status -->"
+
+// Stages of a run, as shown in the status comment
+const (
+	stageAnalyzing     = "Analyzing the issue"
+	stageBranchCreated = "Branch created"
+	stageAgentRunning  = "Agent running"
+	stageVerifying     = "Verifying changes"
+)
+
+// stageEntry is a stage that has been reached, with any detail worth showing
+type stageEntry struct {
+	name   string
+	detail string
+	at     time.Time
+}
+
+// statusReporter keeps a single comment on the issue up to date while the pipeline works
+// on it. The comment's ID is kept in the issue state, so later runs, even after a
+// restart, edit the same comment instead of adding new ones.
+type statusReporter struct {
+	service vcs.Service
+	store   *state.Store
+	issue   vcs.Issue
+	st      *state.IssueState
+
+	started    time.Time
+	startSpent float64
+	lastSpent  float64 // spent by the run when the last cost_incurred event was published
+	stages     []stageEntry
+	now        func() time.Time
+	events     *events.Bus
+}
+
+// newStatusReporter starts reporting a run on the issue
+func (p *Pipeline) newStatusReporter(issue vcs.Issue, st *state.IssueState) *statusReporter {
+	return &statusReporter{
+		service:    p.service,
+		store:      p.store,
+		issue:      issue,
+		st:         st,
+		started:    time.Now(),
+		startSpent: anthropic.Spent(),
+		now:        time.Now,
+		events:     p.events,
+	}
+}
+
+// stage records that the run has reached a stage and updates the comment
+func (r *statusReporter) stage(name, detail string) {
+	r.finishStage("")
+	r.stages = append(r.stages, stageEntry{name: name, detail: detail, at: r.now()})
+	r.publish(r.render("🔄 Working on this issue", "", false))
+	r.emit(events.Event{Type: events.TypeStageStarted, Stage: name, Detail: detail})
+}
+
+// opened reports the pull request the run opened
+func (r *statusReporter) opened(number int, url string) {
+	r.publish(r.render(fmt.Sprintf("✅ Opened pull request #%d", number),
+		fmt.Sprintf("The changes are ready for review in %s.", url), false))
+	r.finishStage("")
+	r.emit(events.Event{Type: events.TypePRCreated, URL: url})
+}
+
+// pushed reports a run that pushed its branch without opening a pull request
+func (r *statusReporter) pushed(branch string) {
+	r.publish(r.render(fmt.Sprintf("✅ Pushed `%s`", branch),
+		"No pull request was opened for the changes.", false))
+	r.finishStage("")
+	r.emit(events.Event{Type: events.TypeBranchPushed, Branch: branch})
+}
+
+// failed reports why the run stopped, followed by a note on what happens next
+func (r *statusReporter) failed(kind FailureKind, reason, note string) {
+	outcome := fmt.Sprintf("**Reason:** %s", reason)
+	if note != "" {
+		outcome += "\n\n" + note
+	}
+	r.publish(r.render("❌ Stopped working on this issue", outcome, true))
+	r.finishStage(reason)
+	r.emit(events.Event{Type: events.TypeError, Category: string(kind), Error: reason, Detail: note})
+}
+
+// finishStage publishes the end of the stage in progress, if there is one, with the
+// reason it failed if errMessage is set
+func (r *statusReporter) finishStage(errMessage string) {
+	if len(r.stages) == 0 {
+		return
+	}
+	last := r.stages[len(r.stages)-1]
+	r.emit(events.Event{
+		Type:     events.TypeStageFinished,
+		Stage:    last.name,
+		Detail:   last.detail,
+		Error:    errMessage,
+		Duration: r.now().Sub(last.at).Seconds(),
+	})
+}
+
+// agentFinished reports that the agent CLI, started at started, has exited
+func (r *statusReporter) agentFinished(started time.Time, err error) {
+	event := events.Event{Type: events.TypeAgentFinished, Duration: r.now().Sub(started).Seconds()}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	r.emit(event)
+}
+
+// emit publishes an event about the run, preceded by a cost_incurred event if the run
+// has spent money since the last one
+func (r *statusReporter) emit(event events.Event) {
+	if r.events == nil {
+		return
+	}
+	key := state.IssueKey(r.issue.GetOwner(), r.issue.GetRepo(), r.issue.GetNumber())
+	elapsed := r.now().Sub(r.started).Seconds()
+
+	if spent := anthropic.Spent() - r.startSpent; spent > r.lastSpent {
+		r.events.Publish(events.Event{
+			Type:     events.TypeCostIncurred,
+			Issue:    key,
+			CostUSD:  spent - r.lastSpent,
+			TotalUSD: spent,
+			Elapsed:  elapsed,
+		})
+		r.lastSpent = spent
+	}
+
+	event.Issue = key
+	event.Elapsed = elapsed
+	r.events.Publish(event)
+}
+
+// render builds the comment body. Each stage shows how far into the run it was reached.
+// Until there is an outcome the last stage is still in progress; after a failure it is
+// the stage that failed.
+func (r *statusReporter) render(headline, outcome string, failed bool) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("### %s\n\n", headline))
+	for i, entry := range r.stages {
+		line := entry.name
+		if entry.detail != "" {
+			line += ": " + entry.detail
+		}
+		reached := formatElapsed(entry.at.Sub(r.started))
+
+		switch {
+		case i < len(r.stages)-1 || (outcome != "" && !failed):
+			b.WriteString(fmt.Sprintf("- [x] %s (%s)\n", line, reached))
+		case failed:
+			b.WriteString(fmt.Sprintf("- [ ] %s (failed)\n", line))
+		default:
+			b.WriteString(fmt.Sprintf("- [ ] %s… (since %s)\n", line, reached))
+		}
+	}
+
+	if outcome != "" {
+		b.WriteString("\n" + outcome + "\n")
+	}
+
+	b.WriteString(fmt.Sprintf("\nElapsed: %s · Model cost: $%.2f\n\n",
+		formatElapsed(r.now().Sub(r.started)),
+		anthropic.Spent()-r.startSpent))
+	b.WriteString(statusMarker)
+
+	return b.String()
+}
+
+// publish edits the status comment, or posts it if there isn't one yet. Failing to
+// report progress never stops the run.
+func (r *statusReporter) publish(body string) {
+	owner, repo, number := r.issue.GetOwner(), r.issue.GetRepo(), r.issue.GetNumber()
+	key := state.IssueKey(owner, repo, number)
+
+	if r.st.StatusComment != "" {
+		err := r.service.UpdateComment(owner, repo, r.st.StatusComment, body)
+		if err == nil {
+			return
+		}
+		// The comment may have been deleted, so post a new one
+		logging.Warn("Failed to update status comment, posting a new one", "issue", key, "comment", r.st.StatusComment, "error", err)
+	}
+
+	id, err := r.service.CreateComment(owner, repo, number, body)
+	if err != nil {
+		logging.Warn("Failed to post status comment", "issue", key, "error", err)
+		return
+	}
+
+	r.st.StatusComment = id
+	if err := r.store.Put(key, *r.st); err != nil {
+		logging.Warn("Failed to save issue state", "issue", key, "error", err)
+	}
+}
+
+// formatElapsed formats a duration to the second, like "4m12s"
+func formatElapsed(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
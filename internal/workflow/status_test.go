@@ -0,0 +1,101 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/events"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+// commentService keeps comments by ID so edits can be checked
+type commentService struct {
+	vcs.Service
+	bodies  map[string]string
+	created int
+}
+
+func (c *commentService) CreateComment(owner, repo string, issueNumber int, body string) (string, error) {
+	c.created++
+	id := fmt.Sprintf("%d", 100+c.created)
+	c.bodies[id] = body
+	return id, nil
+}
+
+func (c *commentService) UpdateComment(owner, repo, commentID, body string) error {
+	if _, ok := c.bodies[commentID]; !ok {
+		return errors.New("comment not found")
+	}
+	c.bodies[commentID] = body
+	return nil
+}
+
+func TestStatusReporterEditsOneComment(t *testing.T) {
+	service := &commentService{bodies: map[string]string{}}
+	p, err := NewPipeline(PipelineConfig{Config: &config.Config{}, Service: service})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issue := &vcs.BaseIssue{Owner: "octo", Repo: "demo", Number: 7}
+	key := state.IssueKey("octo", "demo", 7)
+	st := p.store.Get(key)
+
+	var published []events.Event
+	p.events = events.NewBus()
+	p.events.Subscribe(func(event events.Event) { published = append(published, event) })
+
+	status := p.newStatusReporter(issue, &st)
+	status.stage(stageAnalyzing, "")
+	status.stage(stageBranchCreated, "`fix/login` from `main`")
+	status.stage(stageAgentRunning, "")
+
+	if service.created != 1 {
+		t.Fatalf("created %d comments, want 1", service.created)
+	}
+	id := p.store.Get(key).StatusComment
+	body := service.bodies[id]
+	for _, want := range []string{"- [x] Analyzing the issue", "- [x] Branch created: `fix/login` from `main`", "- [ ] Agent running…", "Model cost: $", statusMarker} {
+		if !strings.Contains(body, want) {
+			t.Errorf("status comment is missing %q:\n%s", want, body)
+		}
+	}
+
+	status.failed(FailureTransient, "no commits between branches", "I'll try again in 15m0s.")
+	var types []string
+	for _, event := range published {
+		types = append(types, string(event.Type))
+	}
+	want := "stage_started stage_finished stage_started stage_finished stage_started stage_finished error"
+	if got := strings.Join(types, " "); got != want {
+		t.Errorf("published %s, want %s", got, want)
+	}
+	if last := published[len(published)-2]; last.Issue != key || last.Stage != stageAgentRunning || last.Error != "no commits between branches" {
+		t.Errorf("unexpected stage_finished event: %+v", last)
+	}
+	if body := service.bodies[id]; !strings.Contains(body, "- [ ] Agent running (failed)") ||
+		!strings.Contains(body, "**Reason:** no commits between branches") || !strings.Contains(body, "I'll try again in 15m0s.") {
+		t.Errorf("unexpected failure comment:\n%s", body)
+	}
+
+	// A later run, for example after a restart, keeps editing the same comment
+	st = p.store.Get(key)
+	status = p.newStatusReporter(issue, &st)
+	status.stage(stageAnalyzing, "")
+	status.opened(12, "https://github.com/octo/demo/pull/12")
+
+	if service.created != 1 || !strings.Contains(service.bodies[id], "✅ Opened pull request #12") {
+		t.Errorf("second run did not edit comment %s (created %d):\n%s", id, service.created, service.bodies[id])
+	}
+
+	// A deleted status comment is replaced
+	delete(service.bodies, id)
+	status.stage(stageVerifying, "")
+	if service.created != 2 || p.store.Get(key).StatusComment == id {
+		t.Errorf("deleted status comment was not replaced (created %d)", service.created)
+	}
+}
@@ -0,0 +1,132 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/anthropic"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+	"github.com/hellausefulsoftware/useful1/internal/models"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+// triageMarker is embedded in clarifying-question comments so they can be recognised later
+const triageMarker = "<!-- useful1:triage -->"
+
+// Triager scores an issue before any work starts on it
+type Triager interface {
+	TriageIssue(issue *models.Issue) (*anthropic.TriageResult, error)
+}
+
+// triage decides whether an issue is specific enough to work on.
+// Underspecified issues get clarifying questions and are parked until the author replies.
+// It returns true when the pipeline should continue with the issue.
+func (p *Pipeline) triage(issue vcs.Issue, st *state.IssueState) (bool, error) {
+	key := state.IssueKey(issue.GetOwner(), issue.GetRepo(), issue.GetNumber())
+
+	if st.Status == state.StatusParked {
+		if !authorRepliedSince(issue, st.ParkedAt) {
+			logging.Info("Issue is waiting for the author to answer clarifying questions, skipping",
+				"issue", key,
+				"parked_at", st.ParkedAt)
+			return false, nil
+		}
+
+		logging.Info("Issue author replied to clarifying questions, resuming", "issue", key)
+
+		// Don't keep asking forever; after enough rounds work with what we have
+		if st.QuestionRounds >= p.maxQuestionRounds() {
+			logging.Info("Maximum clarification rounds reached, proceeding", "issue", key, "rounds", st.QuestionRounds)
+			return true, nil
+		}
+	}
+
+	result, err := p.triager.TriageIssue(toModelIssue(issue))
+	if err != nil {
+		// A failed triage should not block work on the issue
+		logging.Warn("Failed to triage issue, proceeding without triage", "issue", key, "error", err)
+		return true, nil
+	}
+
+	if !needsClarification(result, p.minTriageScore()) {
+		logging.Info("Issue passed triage", "issue", key)
+		return true, nil
+	}
+
+	comment := formatClarifyingQuestions(issue.GetUser(), result.Questions)
+	if err := p.service.RespondToIssue(issue.GetOwner(), issue.GetRepo(), issue.GetNumber(), comment); err != nil {
+		return false, fmt.Errorf("failed to post clarifying questions: %w", err)
+	}
+
+	st.Status = state.StatusParked
+	st.ParkedAt = time.Now()
+	st.Questions = result.Questions
+	st.QuestionRounds++
+	if err := p.store.Put(key, *st); err != nil {
+		logging.Warn("Failed to save parked issue state", "issue", key, "error", err)
+	}
+
+	logging.Info("Issue needs clarification, parked until the author replies",
+		"issue", key,
+		"questions", len(result.Questions),
+		"round", st.QuestionRounds)
+
+	return false, nil
+}
+
+// needsClarification reports whether a triage result is too weak to start work
+func needsClarification(result *anthropic.TriageResult, minScore int) bool {
+	if len(result.Questions) == 0 {
+		// Nothing useful to ask, so parking the issue would only stall it
+		return false
+	}
+
+	return result.Clarity < minScore || result.Scope < minScore || result.Feasibility < minScore
+}
+
+// authorRepliedSince reports whether the issue author commented after the given time
+func authorRepliedSince(issue vcs.Issue, since time.Time) bool {
+	for _, comment := range issue.GetComments() {
+		if comment.User == issue.GetUser() && comment.CreatedAt.After(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatClarifyingQuestions builds the comment asking the author for more detail
+func formatClarifyingQuestions(author string, questions []string) string {
+	var b strings.Builder
+
+	if author != "" {
+		b.WriteString(fmt.Sprintf("Thanks @%s! ", author))
+	}
+	b.WriteString("Before I start working on this I need a bit more detail:\n\n")
+
+	for i, question := range questions {
+		b.WriteString(fmt.Sprintf("%d. %s\n", i+1, question))
+	}
+
+	b.WriteString("\nReply to this issue with the answers and I'll pick it up again.\n\n")
+	b.WriteString(triageMarker)
+
+	return b.String()
+}
+
+// minTriageScore returns the configured minimum triage score
+func (p *Pipeline) minTriageScore() int {
+	if p.config.Triage.MinScore <= 0 {
+		return 3
+	}
+	return p.config.Triage.MinScore
+}
+
+// maxQuestionRounds returns the configured number of clarification rounds
+func (p *Pipeline) maxQuestionRounds() int {
+	if p.config.Triage.MaxQuestionRounds <= 0 {
+		return 2
+	}
+	return p.config.Triage.MaxQuestionRounds
+}
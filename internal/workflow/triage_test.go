@@ -0,0 +1,165 @@
+package workflow
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/anthropic"
+	"github.com/hellausefulsoftware/useful1/internal/common/vcs"
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/models"
+	"github.com/hellausefulsoftware/useful1/internal/state"
+)
+
+// fakeService records comments posted by the pipeline
+type fakeService struct {
+	vcs.Service
+	comments    []string
+	reactions   map[string][]vcs.Reaction
+	permissions map[string]string
+	// permissionErr, when set, fails permission lookups
+	permissionErr error
+	// issues holds the issues and pull request conversations by number
+	issues map[int]*vcs.BaseIssue
+}
+
+func (f *fakeService) GetIssueWithComments(owner, repo string, number int) (vcs.Issue, error) {
+	if issue, ok := f.issues[number]; ok {
+		return issue, nil
+	}
+	return nil, fmt.Errorf("issue #%d not found", number)
+}
+
+func (f *fakeService) RespondToIssue(owner, repo string, issueNumber int, comment string) error {
+	f.comments = append(f.comments, comment)
+	return nil
+}
+
+func (f *fakeService) GetCommentReactions(owner, repo, commentID string) ([]vcs.Reaction, error) {
+	return f.reactions[commentID], nil
+}
+
+func (f *fakeService) GetUserPermission(owner, repo, username string) (string, error) {
+	if f.permissionErr != nil {
+		return "", f.permissionErr
+	}
+	if permission, ok := f.permissions[username]; ok {
+		return permission, nil
+	}
+	return "none", nil
+}
+
+// fakeTriager returns a fixed triage result and counts calls
+type fakeTriager struct {
+	result *anthropic.TriageResult
+	calls  int
+}
+
+func (f *fakeTriager) TriageIssue(issue *models.Issue) (*anthropic.TriageResult, error) {
+	f.calls++
+	return f.result, nil
+}
+
+func newTestPipeline(t *testing.T, triager Triager) (*Pipeline, *fakeService) {
+	t.Helper()
+
+	cfg := &config.Config{}
+	cfg.Triage.Enabled = true
+
+	service := &fakeService{}
+	p, err := NewPipeline(PipelineConfig{Config: cfg, Service: service, Triager: triager})
+	if err != nil {
+		t.Fatalf("NewPipeline() error = %v", err)
+	}
+	return p, service
+}
+
+func TestTriageParksUnderspecifiedIssue(t *testing.T) {
+	triager := &fakeTriager{result: &anthropic.TriageResult{
+		Clarity: 2, Scope: 4, Feasibility: 4,
+		Questions: []string{"Which endpoint fails?", "What did you expect?"},
+	}}
+	p, service := newTestPipeline(t, triager)
+
+	issue := &vcs.BaseIssue{Owner: "octo", Repo: "demo", Number: 7, User: "alice"}
+	var st state.IssueState
+
+	proceed, err := p.triage(issue, &st)
+	if err != nil {
+		t.Fatalf("triage() error = %v", err)
+	}
+	if proceed {
+		t.Fatal("triage() proceeded on an underspecified issue")
+	}
+	if len(service.comments) != 1 {
+		t.Fatalf("expected 1 comment, got %d", len(service.comments))
+	}
+	if !strings.Contains(service.comments[0], "2. What did you expect?") ||
+		!strings.Contains(service.comments[0], triageMarker) {
+		t.Errorf("unexpected comment: %q", service.comments[0])
+	}
+	if st.Status != state.StatusParked || st.QuestionRounds != 1 {
+		t.Errorf("unexpected state: %+v", st)
+	}
+
+	saved := p.store.Get(state.IssueKey("octo", "demo", 7))
+	if saved.Status != state.StatusParked {
+		t.Errorf("state was not saved, got %+v", saved)
+	}
+}
+
+func TestTriageWaitsForAuthorReply(t *testing.T) {
+	triager := &fakeTriager{result: &anthropic.TriageResult{Clarity: 5, Scope: 5, Feasibility: 5}}
+	p, _ := newTestPipeline(t, triager)
+
+	parkedAt := time.Now().Add(-time.Hour)
+	st := state.IssueState{Status: state.StatusParked, ParkedAt: parkedAt, QuestionRounds: 1}
+
+	// A reply from someone other than the author does not resume the issue
+	issue := &vcs.BaseIssue{Owner: "octo", Repo: "demo", Number: 7, User: "alice",
+		Comments: []vcs.IssueComment{{User: "bob", CreatedAt: parkedAt.Add(time.Minute)}}}
+
+	proceed, err := p.triage(issue, &st)
+	if err != nil {
+		t.Fatalf("triage() error = %v", err)
+	}
+	if proceed || triager.calls != 0 {
+		t.Fatalf("triage() resumed without an author reply (proceed=%v, calls=%d)", proceed, triager.calls)
+	}
+
+	// Once the author answers, the issue is re-triaged and proceeds
+	issue.Comments = append(issue.Comments, vcs.IssueComment{User: "alice", CreatedAt: parkedAt.Add(2 * time.Minute)})
+
+	proceed, err = p.triage(issue, &st)
+	if err != nil {
+		t.Fatalf("triage() error = %v", err)
+	}
+	if !proceed || triager.calls != 1 {
+		t.Fatalf("triage() did not resume after author reply (proceed=%v, calls=%d)", proceed, triager.calls)
+	}
+}
+
+func TestTriageStopsAskingAfterMaxRounds(t *testing.T) {
+	triager := &fakeTriager{result: &anthropic.TriageResult{
+		Clarity: 1, Scope: 1, Feasibility: 1, Questions: []string{"Why?"},
+	}}
+	p, service := newTestPipeline(t, triager)
+
+	parkedAt := time.Now().Add(-time.Hour)
+	st := state.IssueState{Status: state.StatusParked, ParkedAt: parkedAt, QuestionRounds: 2}
+	issue := &vcs.BaseIssue{Owner: "octo", Repo: "demo", Number: 7, User: "alice",
+		Comments: []vcs.IssueComment{{User: "alice", CreatedAt: parkedAt.Add(time.Minute)}}}
+
+	proceed, err := p.triage(issue, &st)
+	if err != nil {
+		t.Fatalf("triage() error = %v", err)
+	}
+	if !proceed {
+		t.Fatal("triage() kept asking after the maximum number of rounds")
+	}
+	if len(service.comments) != 0 {
+		t.Errorf("expected no comments, got %d", len(service.comments))
+	}
+}
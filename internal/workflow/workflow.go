@@ -4,6 +4,7 @@ package workflow
 import (
 	"github.com/google/go-github/v45/github"
 	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/dryrun"
 	"github.com/hellausefulsoftware/useful1/internal/workflow/services"
 )
 
@@ -29,6 +30,12 @@ func NewImplementationWorkflowWithService(cfg *config.Config, service *services.
 	}
 }
 
+// newImplementationWorkflow creates an implementation workflow that records its changes
+// instead of making them when recorder is set
+func newImplementationWorkflow(cfg *config.Config, recorder *dryrun.Recorder) *ImplementationWorkflow {
+	return NewImplementationWorkflowWithService(cfg, services.NewGitHubImplementationService(cfg).WithRecorder(recorder))
+}
+
 // GenerateBranchAndTitle generates a branch name and PR title for an issue
 func (w *ImplementationWorkflow) GenerateBranchAndTitle(owner, repo, title, body string) (string, string, error) {
 	return w.implementationService.GenerateBranchAndTitle(owner, repo, title, body)
@@ -40,6 +47,18 @@ func (w *ImplementationWorkflow) CreateImplementationPromptAndExecute(owner, rep
 	return w.implementationService.CreateImplementationPromptAndExecute(owner, repo, branchName, issueNumber)
 }
 
+// CreateImplementationPromptAndExecuteWithPlan executes an already approved implementation plan
+// Returns the Claude CLI output for use in PR description
+func (w *ImplementationWorkflow) CreateImplementationPromptAndExecuteWithPlan(owner, repo, branchName string, issueNumber int, plan string) (string, error) {
+	return w.implementationService.CreateImplementationPromptAndExecuteWithPlan(owner, repo, branchName, issueNumber, plan)
+}
+
+// RunFollowUp re-runs the agent on an existing branch with a follow-up prompt
+// Returns whether follow-up commits were pushed
+func (w *ImplementationWorkflow) RunFollowUp(owner, repo, branchName string, issueNumber int, prompt, description string) (bool, error) {
+	return w.implementationService.RunFollowUp(owner, repo, branchName, issueNumber, prompt, description)
+}
+
 // CreatePullRequestForIssue creates a PR specifically linked to an issue
 // claudeOutput parameter contains the implementation output from Claude CLI
 // repoDir is the directory where the repository is cloned
@@ -0,0 +1,115 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+	"github.com/hellausefulsoftware/useful1/internal/audit"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+)
+
+// ForkRemote is the git remote the bot's fork is added as
+const ForkRemote = "fork"
+
+// GitHub creates forks asynchronously, so new forks are polled until they can be pushed to
+var (
+	forkPollInterval = 2 * time.Second
+	forkPollAttempts = 30
+)
+
+// PushTarget is the repository the bot pushes its branches to
+type PushTarget struct {
+	Remote string // git remote the branch is pushed to
+	Owner  string
+	Repo   string
+	Fork   bool // whether the target is the bot's fork rather than the upstream repository
+}
+
+// Head returns the pull request head for a branch pushed to the target
+func (t *PushTarget) Head(branch string) string {
+	if t.Fork {
+		return t.Owner + ":" + branch
+	}
+	return branch
+}
+
+// CanPush reports whether the authenticated user can push to a repository.
+// Replies to installation tokens carry no permissions; an installed app is trusted to have write access.
+func CanPush(ctx context.Context, client *github.Client, owner, repo string) (bool, error) {
+	info, _, err := client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return false, fmt.Errorf("failed to get repository info: %w", err)
+	}
+
+	permissions := info.GetPermissions()
+	return permissions == nil || permissions["push"], nil
+}
+
+// ResolvePushTarget returns where branches for owner/repo are pushed. When the bot can't
+// push to the repository it forks it, into organization if set, and pushes to the fork.
+func ResolvePushTarget(ctx context.Context, client *github.Client, owner, repo, organization string) (*PushTarget, error) {
+	canPush, err := CanPush(ctx, client, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	if canPush {
+		return &PushTarget{Remote: "origin", Owner: owner, Repo: repo}, nil
+	}
+
+	logging.Info("No push access, contributing through a fork", "owner", owner, "repo", repo, "organization", organization)
+
+	// Forking a repository that is already forked returns the existing fork
+	fork, _, err := client.Repositories.CreateFork(ctx, owner, repo, &github.RepositoryCreateForkOptions{
+		Organization: organization,
+	})
+	if err != nil {
+		if _, accepted := err.(*github.AcceptedError); !accepted {
+			return nil, fmt.Errorf("failed to fork %s/%s: %w", owner, repo, err)
+		}
+	}
+	if fork.GetOwner().GetLogin() == "" || fork.GetName() == "" {
+		return nil, fmt.Errorf("failed to fork %s/%s: empty response", owner, repo)
+	}
+	audit.Record(audit.Entry{
+		Action:  audit.ActionFork,
+		Repo:    owner + "/" + repo,
+		Ref:     fork.GetFullName(),
+		Summary: "fork into " + fork.GetOwner().GetLogin(),
+	})
+
+	target := &PushTarget{
+		Remote: ForkRemote,
+		Owner:  fork.GetOwner().GetLogin(),
+		Repo:   fork.GetName(),
+		Fork:   true,
+	}
+	if err := waitForFork(ctx, client, target.Owner, target.Repo); err != nil {
+		return nil, err
+	}
+
+	logging.Info("Fork ready", "fork", target.Owner+"/"+target.Repo)
+	return target, nil
+}
+
+// waitForFork polls until a new fork exists
+func waitForFork(ctx context.Context, client *github.Client, owner, repo string) error {
+	for attempt := 0; attempt < forkPollAttempts; attempt++ {
+		_, resp, err := client.Repositories.Get(ctx, owner, repo)
+		if err == nil {
+			return nil
+		}
+		if resp == nil || resp.StatusCode != http.StatusNotFound {
+			return fmt.Errorf("failed to check fork %s/%s: %w", owner, repo, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(forkPollInterval):
+		}
+	}
+	return fmt.Errorf("fork %s/%s was not ready in time", owner, repo)
+}
@@ -0,0 +1,81 @@
+// Package workspace prepares the local git checkouts the agent works in
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hellausefulsoftware/useful1/internal/auth"
+	"github.com/hellausefulsoftware/useful1/internal/config"
+)
+
+// tokenEnv is the environment variable the credential helper reads the token from
+const tokenEnv = "USEFUL1_GIT_TOKEN"
+
+// credentialHelper answers git's credential requests from the environment, so the token
+// is never written to .git/config or shown in the process list
+const credentialHelper = `!f() { test "$1" = get || exit 0; echo username=x-access-token; echo "password=$` + tokenEnv + `"; }; f`
+
+// Token returns the token git uses for owner/repo, or "" when cloning over SSH
+func Token(cfg *config.Config, owner, repo string) (string, error) {
+	if cfg.UsesSSHClone() {
+		return "", nil
+	}
+	return auth.GitToken(context.Background(), cfg, owner, repo)
+}
+
+// Command returns a git command that authenticates HTTPS remotes with token.
+// An empty token leaves authentication to git, e.g. an SSH key.
+func Command(token string, args ...string) *exec.Cmd {
+	if token == "" {
+		return exec.Command("git", args...)
+	}
+
+	// The empty helper clears any helpers configured for the user so only ours answers
+	gitArgs := append([]string{"-c", "credential.helper=", "-c", "credential.helper=" + credentialHelper}, args...)
+	cmd := exec.Command("git", gitArgs...)
+	cmd.Env = append(os.Environ(), tokenEnv+"="+token, "GIT_TERMINAL_PROMPT=0")
+	return cmd
+}
+
+// SetRemote points a remote of the repository in dir at url, adding the remote if needed
+func SetRemote(dir, name, url string) error {
+	current, err := remoteURL(dir, name)
+	if err != nil {
+		return addRemote(dir, name, url)
+	}
+	if current == url {
+		return nil
+	}
+
+	cmd := exec.Command("git", "remote", "set-url", name, url)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to set remote %s: %w\nOutput: %s", name, err, string(out))
+	}
+	return nil
+}
+
+// remoteURL returns the URL a remote points at
+func remoteURL(dir, name string) (string, error) {
+	cmd := exec.Command("git", "remote", "get-url", name)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// addRemote adds a new remote to the repository in dir
+func addRemote(dir, name, url string) error {
+	cmd := exec.Command("git", "remote", "add", name, url)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to add remote %s: %w\nOutput: %s", name, err, string(out))
+	}
+	return nil
+}
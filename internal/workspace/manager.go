@@ -0,0 +1,364 @@
+package workspace
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+)
+
+// repoLocks serializes git operations on a mirror across managers, keyed by mirror path
+var repoLocks sync.Map
+
+// Manager keeps one bare mirror per repository and a git worktree per issue, so each
+// issue costs an incremental fetch rather than a full clone
+type Manager struct {
+	config     *config.Config
+	root       string
+	legacyRoot string // full clones made by older versions
+	tempDir    string // where runs write their prompt files
+
+	cloneURL func(owner, repo string) string
+}
+
+// Worktree is the checkout an issue is worked on in
+type Worktree struct {
+	Owner  string
+	Repo   string
+	Issue  int
+	Branch string
+	Path   string
+}
+
+// DefaultRoot returns the directory mirrors and worktrees are kept in by default
+func DefaultRoot() string {
+	return filepath.Join(filepath.Dir(config.GetConfigPath()), "workspaces")
+}
+
+// NewManager creates a workspace manager for the configured root directory
+func NewManager(cfg *config.Config) *Manager {
+	root := cfg.Workspace.Root
+	if root == "" {
+		root = DefaultRoot()
+	}
+
+	return &Manager{
+		config:     cfg,
+		root:       root,
+		legacyRoot: LegacyRoot(),
+		tempDir:    os.TempDir(),
+		cloneURL:   cfg.GitHubCloneURL,
+	}
+}
+
+// MirrorPath returns where the bare mirror of a repository is kept
+func (m *Manager) MirrorPath(owner, repo string) string {
+	return filepath.Join(m.root, "mirrors", owner, repo+".git")
+}
+
+// WorktreePath returns where the worktree for an issue is checked out
+func (m *Manager) WorktreePath(owner, repo string, issue int) string {
+	return filepath.Join(m.root, "worktrees", owner, repo, strconv.Itoa(issue))
+}
+
+// Prepare brings the mirror up to date and returns a worktree for the issue with branch
+// checked out. A nil target pushes to origin.
+func (m *Manager) Prepare(owner, repo, branch string, issue int, target *PushTarget) (string, error) {
+	if target == nil {
+		target = &PushTarget{Remote: "origin", Owner: owner, Repo: repo}
+	}
+
+	mirror := m.MirrorPath(owner, repo)
+	unlock := lockMirror(mirror)
+	defer unlock()
+
+	token, err := Token(m.config, owner, repo)
+	if err != nil {
+		return "", fmt.Errorf("failed to get git credentials: %w", err)
+	}
+
+	if err := m.ensureMirror(owner, repo, token); err != nil {
+		return "", err
+	}
+	if err := m.fetch(mirror, owner, repo, target, token); err != nil {
+		return "", err
+	}
+
+	path := m.WorktreePath(owner, repo, issue)
+	logging.Info("Preparing worktree",
+		"owner", owner,
+		"repo", repo,
+		"issue", issue,
+		"branch", branch,
+		"dir", path)
+
+	if _, statErr := os.Stat(path); statErr == nil {
+		if isWorktree(path) {
+			m.updateWorktree(path, branch, target, token)
+			return path, nil
+		}
+
+		logging.Warn("Directory exists but is not a valid worktree, removing it", "dir", path)
+		if err := os.RemoveAll(path); err != nil {
+			return "", fmt.Errorf("failed to remove invalid worktree: %w", err)
+		}
+		if out, err := runGit("", mirror, "worktree", "prune"); err != nil {
+			return "", fmt.Errorf("failed to prune worktrees: %w\nOutput: %s", err, out)
+		}
+	}
+
+	if err := m.addWorktree(mirror, path, owner, repo, branch, target, token); err != nil {
+		return "", err
+	}
+
+	logging.Info("Worktree ready", "dir", path, "branch", branch)
+	return path, nil
+}
+
+// ensureMirror clones the bare mirror if it doesn't exist yet and points origin at the clone URL
+func (m *Manager) ensureMirror(owner, repo, token string) error {
+	mirror := m.MirrorPath(owner, repo)
+	url := m.cloneURL(owner, repo)
+
+	if out, err := runGit("", mirror, "rev-parse", "--is-bare-repository"); err == nil && strings.TrimSpace(out) == "true" {
+		// Mirrors cloned before a protocol change still point at the old URL
+		return SetRemote(mirror, "origin", url)
+	}
+
+	if err := os.RemoveAll(mirror); err != nil {
+		return fmt.Errorf("failed to remove invalid mirror: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(mirror), 0755); err != nil {
+		return fmt.Errorf("failed to create mirror directory: %w", err)
+	}
+
+	args := []string{"clone", "--bare"}
+	if depth := m.config.Workspace.CloneDepth; depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	if filter := m.config.Workspace.CloneFilter; filter != "" {
+		args = append(args, "--filter="+filter)
+	}
+	args = append(args, url, mirror)
+
+	logging.Info("Creating repository mirror", "url", url, "dir", mirror)
+	if out, err := runGit(token, "", args...); err != nil {
+		return fmt.Errorf("failed to clone repository mirror: %w\nOutput: %s", err, out)
+	}
+
+	// Bare clones don't track the remote; keep its branches apart from the local issue branches
+	if out, err := runGit("", mirror, "config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*"); err != nil {
+		return fmt.Errorf("failed to configure mirror: %w\nOutput: %s", err, out)
+	}
+	return nil
+}
+
+// fetch updates the mirror from origin and, when pushing to a fork, from the fork
+func (m *Manager) fetch(mirror, owner, repo string, target *PushTarget, token string) error {
+	args := []string{"fetch", "--prune"}
+	if depth := m.config.Workspace.CloneDepth; depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+
+	if out, err := runGit(token, mirror, append(args, "origin")...); err != nil {
+		return fmt.Errorf("failed to fetch latest changes: %w\nOutput: %s", err, out)
+	}
+
+	if !target.Fork {
+		return nil
+	}
+
+	if err := SetRemote(mirror, target.Remote, m.cloneURL(target.Owner, target.Repo)); err != nil {
+		return err
+	}
+	forkToken, err := Token(m.config, target.Owner, target.Repo)
+	if err != nil {
+		return fmt.Errorf("failed to get git credentials for fork: %w", err)
+	}
+	if out, err := runGit(forkToken, mirror, append(args, target.Remote)...); err != nil {
+		return fmt.Errorf("failed to fetch fork: %w\nOutput: %s", err, out)
+	}
+	return nil
+}
+
+// addWorktree checks out a new worktree for branch, continuing from the pushed branch when
+// there is one and starting from the default branch otherwise
+func (m *Manager) addWorktree(mirror, path, owner, repo, branch string, target *PushTarget, token string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create worktree directory: %w", err)
+	}
+
+	args := []string{"worktree", "add", "-B", branch}
+	startPoint := ""
+	for _, remote := range []string{target.Remote, "origin"} {
+		if remoteBranchExists(mirror, remote, branch) {
+			startPoint = remote + "/" + branch
+			args = append(args, "--track")
+			break
+		}
+	}
+	if startPoint == "" {
+		defaultBranch, err := runGit("", mirror, "symbolic-ref", "--short", "HEAD")
+		if err != nil {
+			return fmt.Errorf("failed to get default branch: %w\nOutput: %s", err, defaultBranch)
+		}
+		startPoint = "origin/" + strings.TrimSpace(defaultBranch)
+		args = append(args, "--no-track")
+	}
+
+	sparse := m.sparsePaths(owner, repo)
+	if len(sparse) > 0 {
+		args = append(args, "--no-checkout")
+	}
+	args = append(args, path, startPoint)
+
+	if out, err := runGit(token, mirror, args...); err != nil {
+		return fmt.Errorf("failed to add worktree: %w\nOutput: %s", err, out)
+	}
+
+	if len(sparse) > 0 {
+		if out, err := runGit(token, path, append([]string{"sparse-checkout", "set", "--cone"}, sparse...)...); err != nil {
+			return fmt.Errorf("failed to configure sparse checkout: %w\nOutput: %s", err, out)
+		}
+		if out, err := runGit(token, path, "checkout", branch); err != nil {
+			return fmt.Errorf("failed to check out branch: %w\nOutput: %s", err, out)
+		}
+	}
+	return nil
+}
+
+// updateWorktree brings an existing worktree up to date with its pushed branch
+func (m *Manager) updateWorktree(path, branch string, target *PushTarget, token string) {
+	if out, err := runGit(token, path, "checkout", branch); err != nil {
+		logging.Warn("Failed to check out branch in worktree", "error", err, "output", out, "branch", branch)
+		return
+	}
+
+	if !remoteBranchExists(path, target.Remote, branch) {
+		return
+	}
+	if out, err := runGit(token, path, "merge", "--ff-only", target.Remote+"/"+branch); err != nil {
+		logging.Warn("Failed to update worktree from remote branch",
+			"error", err,
+			"output", out,
+			"branch", branch)
+	}
+}
+
+// sparsePaths returns the directories to check out for a repository, if it is configured for sparse checkout
+func (m *Manager) sparsePaths(owner, repo string) []string {
+	name := owner + "/" + repo
+	for key, paths := range m.config.Workspace.SparsePaths {
+		if strings.EqualFold(key, name) {
+			return paths
+		}
+	}
+	return nil
+}
+
+// Worktrees lists the issue worktrees under the root
+func (m *Manager) Worktrees() ([]Worktree, error) {
+	paths, err := filepath.Glob(filepath.Join(m.root, "worktrees", "*", "*", "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list worktrees: %w", err)
+	}
+	sort.Strings(paths)
+
+	var worktrees []Worktree
+	for _, path := range paths {
+		issue, err := strconv.Atoi(filepath.Base(path))
+		if err != nil {
+			continue
+		}
+
+		repoDir := filepath.Dir(path)
+		wt := Worktree{
+			Owner: filepath.Base(filepath.Dir(repoDir)),
+			Repo:  filepath.Base(repoDir),
+			Issue: issue,
+			Path:  path,
+		}
+		if out, err := runGit("", path, "branch", "--show-current"); err == nil {
+			wt.Branch = strings.TrimSpace(out)
+		}
+		worktrees = append(worktrees, wt)
+	}
+	return worktrees, nil
+}
+
+// Remove deletes an issue's worktree and its local branch from the mirror
+func (m *Manager) Remove(wt Worktree) error {
+	mirror := m.MirrorPath(wt.Owner, wt.Repo)
+	unlock := lockMirror(mirror)
+	defer unlock()
+
+	if out, err := runGit("", mirror, "worktree", "remove", "--force", wt.Path); err != nil {
+		// The mirror may be gone or the worktree unregistered; remove the files regardless
+		logging.Debug("git worktree remove failed, removing directory", "dir", wt.Path, "error", err, "output", out)
+		if err := os.RemoveAll(wt.Path); err != nil {
+			return fmt.Errorf("failed to remove worktree: %w", err)
+		}
+		_, _ = runGit("", mirror, "worktree", "prune")
+	}
+
+	if wt.Branch != "" {
+		if out, err := runGit("", mirror, "branch", "-D", wt.Branch); err != nil {
+			logging.Debug("Failed to delete worktree branch", "branch", wt.Branch, "error", err, "output", out)
+		}
+	}
+	return nil
+}
+
+// GC removes the worktrees done reports as finished, e.g. for closed issues or merged
+// pull requests, and returns the ones it removed. Worktrees done can't decide on and
+// worktrees with an in-flight run are kept.
+func (m *Manager) GC(done func(Worktree) (bool, error)) ([]Worktree, error) {
+	entries, err := m.Prune(PruneOptions{Finished: done})
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Worktree
+	for _, e := range entries {
+		if e.Kind == KindWorktree {
+			removed = append(removed, e.Worktree)
+		}
+	}
+	return removed, nil
+}
+
+// lockMirror holds the lock for a mirror until the returned function is called
+func lockMirror(mirror string) func() {
+	mu, _ := repoLocks.LoadOrStore(mirror, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// isWorktree reports whether dir is the top of a git checkout
+func isWorktree(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err != nil {
+		return false
+	}
+	out, err := runGit("", dir, "rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// remoteBranchExists reports whether the repository in dir has fetched remote/branch
+func remoteBranchExists(dir, remote, branch string) bool {
+	_, err := runGit("", dir, "rev-parse", "--verify", "--quiet", "refs/remotes/"+remote+"/"+branch)
+	return err == nil
+}
+
+// runGit runs an authenticated git command in dir, or the current directory when dir is empty
+func runGit(token, dir string, args ...string) (string, error) {
+	cmd := Command(token, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
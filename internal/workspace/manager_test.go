@@ -0,0 +1,230 @@
+package workspace
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/config"
+)
+
+// git runs a git command in dir and fails the test on error
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+	return string(out)
+}
+
+// newTestManager creates a manager whose repositories are cloned from local upstreams
+func newTestManager(t *testing.T, cfg *config.Config) (*Manager, string) {
+	t.Helper()
+
+	upstream := filepath.Join(t.TempDir(), "demo")
+	git(t, "", "init", "-q", "-b", "main", upstream)
+	for _, file := range []string{"api/api.go", "web/index.html", "README.md"} {
+		path := filepath.Join(upstream, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(t, upstream, "add", ".")
+	git(t, upstream, "commit", "-q", "-m", "initial")
+	git(t, upstream, "branch", "fix-2")
+
+	cfg.Workspace.CloneProtocol = "ssh" // no token needed for local clones
+	cfg.Workspace.Root = t.TempDir()
+
+	m := NewManager(cfg)
+	m.legacyRoot = t.TempDir()
+	m.tempDir = t.TempDir()
+	m.cloneURL = func(owner, repo string) string { return "file://" + upstream }
+	return m, upstream
+}
+
+func TestManagerPrepare(t *testing.T) {
+	m, upstream := newTestManager(t, &config.Config{})
+
+	// A new branch starts from the default branch
+	dir, err := m.Prepare("octo", "demo", "fix-1", 1, nil)
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if got := git(t, dir, "branch", "--show-current"); got != "fix-1\n" {
+		t.Errorf("worktree is on %q, want fix-1", got)
+	}
+
+	// A branch that exists upstream is checked out tracking it
+	dir2, err := m.Prepare("octo", "demo", "fix-2", 2, nil)
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	if got := git(t, dir2, "rev-parse", "--abbrev-ref", "@{u}"); got != "origin/fix-2\n" {
+		t.Errorf("fix-2 tracks %q, want origin/fix-2", got)
+	}
+
+	// Preparing again reuses the worktree and picks up new upstream commits
+	git(t, upstream, "checkout", "-q", "fix-2")
+	git(t, upstream, "commit", "-q", "--allow-empty", "-m", "more")
+	want := git(t, upstream, "rev-parse", "HEAD")
+	if again, err := m.Prepare("octo", "demo", "fix-2", 2, nil); err != nil || again != dir2 {
+		t.Fatalf("Prepare() again = %q, %v; want %q", again, err, dir2)
+	}
+	if got := git(t, dir2, "rev-parse", "HEAD"); got != want {
+		t.Errorf("worktree at %q, want %q", got, want)
+	}
+
+	// Both worktrees share one mirror
+	if got := git(t, m.MirrorPath("octo", "demo"), "rev-parse", "--is-bare-repository"); got != "true\n" {
+		t.Errorf("mirror is not bare: %q", got)
+	}
+
+	removed, err := m.GC(func(wt Worktree) (bool, error) { return wt.Issue == 1, nil })
+	if err != nil {
+		t.Fatalf("GC() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0].Issue != 1 || removed[0].Branch != "fix-1" {
+		t.Errorf("GC() removed %+v, want the worktree for issue 1", removed)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("worktree for issue 1 still exists: %v", err)
+	}
+	if _, err := os.Stat(dir2); err != nil {
+		t.Errorf("worktree for issue 2 was removed: %v", err)
+	}
+}
+
+func TestManagerSparseCheckout(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Workspace.SparsePaths = map[string][]string{"Octo/Demo": {"api"}}
+	m, _ := newTestManager(t, cfg)
+
+	dir, err := m.Prepare("octo", "demo", "fix-1", 1, nil)
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+
+	for file, wantExists := range map[string]bool{"api/api.go": true, "README.md": true, "web/index.html": false} {
+		_, err := os.Stat(filepath.Join(dir, file))
+		if exists := err == nil; exists != wantExists {
+			t.Errorf("%s exists = %v, want %v", file, exists, wantExists)
+		}
+	}
+}
+
+// age sets the modification time of everything under path to d ago
+func age(t *testing.T, path string, d time.Duration) {
+	t.Helper()
+	old := time.Now().Add(-d)
+	err := filepath.Walk(path, func(p string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(p, old, old)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// loadDefaultConfig loads a config file that leaves every workspace setting at its default
+func loadDefaultConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GITHUB_TOKEN", "")
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	file := fmt.Sprintf(`{"GitHub": {"Token": %q}, "Anthropic": {"Token": %q}, "CLI": {"Command": "claude"}}`,
+		base64.StdEncoding.EncodeToString([]byte("github-token")),
+		base64.StdEncoding.EncodeToString([]byte("anthropic-token")))
+	if err := os.MkdirAll(filepath.Dir(config.GetConfigPath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config.GetConfigPath(), []byte(file), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() error = %v", err)
+	}
+	return cfg
+}
+
+func TestManagerPrune(t *testing.T) {
+	cfg := loadDefaultConfig(t)
+	m, _ := newTestManager(t, cfg)
+
+	dirs := map[int]string{}
+	for issue := 1; issue <= 3; issue++ {
+		dir, err := m.Prepare("octo", "demo", fmt.Sprintf("fix-%d", issue), issue, nil)
+		if err != nil {
+			t.Fatalf("Prepare() error = %v", err)
+		}
+		dirs[issue] = dir
+	}
+
+	// Issue 2 has a run in progress, so it is kept however old it is
+	release, err := m.Lock("octo", "demo", 2)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	defer release()
+	if _, err := m.Lock("octo", "demo", 2); err != ErrInFlight {
+		t.Errorf("second Lock() error = %v, want ErrInFlight", err)
+	}
+
+	legacy := filepath.Join(m.legacyRoot, "demo_7")
+	tempFile := filepath.Join(m.tempDir, "useful1-issue-123.txt")
+	otherFile := filepath.Join(m.tempDir, "issue-456.txt") // another program's file
+	if err := os.MkdirAll(legacy, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{tempFile, otherFile} {
+		if err := os.WriteFile(path, []byte("prompt"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{dirs[1], dirs[2], legacy, tempFile, otherFile} {
+		age(t, path, 30*24*time.Hour)
+	}
+
+	// The default age limit of 14 days applies without any workspace settings
+	removed, err := m.Prune(PruneOptionsFromConfig(cfg))
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	got := map[string]bool{}
+	for _, e := range removed {
+		got[e.Path] = true
+	}
+	if len(removed) != 3 || !got[dirs[1]] || !got[legacy] || !got[tempFile] {
+		t.Errorf("Prune() by age removed %v, want issue 1, the legacy clone and the temp file", got)
+	}
+
+	// Over quota the idle worktree goes, but the mirror stays while issue 2 uses it
+	removed, err = m.Prune(PruneOptions{MaxBytes: 1})
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if len(removed) != 1 || removed[0].Path != dirs[3] {
+		t.Errorf("Prune() by quota removed %+v, want only issue 3", removed)
+	}
+	for _, path := range []string{dirs[2], m.MirrorPath("octo", "demo"), otherFile} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s was removed: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hellausefulsoftware/useful1/internal/config"
+	"github.com/hellausefulsoftware/useful1/internal/logging"
+)
+
+// ErrInFlight means another run is using the workspace
+var ErrInFlight = errors.New("workspace is in use by another run")
+
+// staleTempFileAge is how old leftover agent prompt files must be before they are removed
+const staleTempFileAge = 24 * time.Hour
+
+// Patterns of the prompt files runs write to the temp directory. The useful1- prefix keeps
+// pruning away from other programs' files there.
+const (
+	IssueFilePattern    = "useful1-issue-*.txt"
+	MetadataFilePattern = "useful1-metadata-*.json"
+)
+
+// Kind tells what an Entry on disk is
+type Kind string
+
+// Workspace entry kinds
+const (
+	KindWorktree Kind = "worktree" // an issue worktree
+	KindMirror   Kind = "mirror"   // a repository's bare mirror
+	KindLegacy   Kind = "legacy"   // a full clone in ~/.useful1/temp from older versions
+	KindTempFile Kind = "tempfile" // an issue or metadata file left behind by a crashed run
+)
+
+// Entry is something the workspace layer keeps on disk
+type Entry struct {
+	Worktree // repository, issue and path; Owner and Issue are unknown for some kinds
+	Kind     Kind
+	Size     int64     // bytes on disk
+	ModTime  time.Time // last time anything in it changed
+	InFlight bool
+}
+
+// PruneOptions decide which workspaces Prune removes. Workspaces with an in-flight run are always kept.
+type PruneOptions struct {
+	Finished func(Worktree) (bool, error) // reports whether an issue is done; nil skips the check
+	MaxAge   time.Duration                // remove workspaces untouched for longer than this (0 disables)
+	MaxBytes int64                        // then remove the least recently used until the total fits (0 disables)
+	DryRun   bool                         // report what would be removed without removing it
+}
+
+// PruneOptionsFromConfig returns the age and quota limits from the configuration
+func PruneOptionsFromConfig(cfg *config.Config) PruneOptions {
+	return PruneOptions{
+		MaxAge:   time.Duration(cfg.Workspace.MaxAgeDays) * 24 * time.Hour,
+		MaxBytes: int64(cfg.Workspace.MaxDiskMB) * 1024 * 1024,
+	}
+}
+
+// LegacyRoot returns the directory older versions cloned repositories into
+func LegacyRoot() string {
+	return filepath.Join(filepath.Dir(config.GetConfigPath()), "temp")
+}
+
+// Lock marks the issue's workspace as in use until the returned function is called.
+// It fails with ErrInFlight while another live process holds the lock.
+func (m *Manager) Lock(owner, repo string, issue int) (func(), error) {
+	path := m.lockPath(owner, repo, issue)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, writeErr := file.WriteString(strconv.Itoa(os.Getpid()))
+			if closeErr := file.Close(); writeErr == nil {
+				writeErr = closeErr
+			}
+			if writeErr != nil {
+				_ = os.Remove(path)
+				return nil, fmt.Errorf("failed to write workspace lock: %w", writeErr)
+			}
+			return func() {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					logging.Warn("Failed to release workspace lock", "lock", path, "error", err)
+				}
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create workspace lock: %w", err)
+		}
+		if lockHeld(path) {
+			return nil, ErrInFlight
+		}
+
+		// The process that held the lock is gone, e.g. after a crash
+		logging.Warn("Removing stale workspace lock", "lock", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale workspace lock: %w", err)
+		}
+	}
+	return nil, ErrInFlight
+}
+
+// InFlight reports whether a live run holds the issue's workspace lock
+func (m *Manager) InFlight(owner, repo string, issue int) bool {
+	return lockHeld(m.lockPath(owner, repo, issue))
+}
+
+// lockPath returns where the lock for an issue's workspace lives, outside the worktree so
+// the agent never commits it
+func (m *Manager) lockPath(owner, repo string, issue int) string {
+	return filepath.Join(m.root, "locks", owner, repo, strconv.Itoa(issue)+".lock")
+}
+
+// lockHeld reports whether a lock file names a running process
+func lockHeld(path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	return processAlive(pid)
+}
+
+// processAlive reports whether a process exists
+func processAlive(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// List returns every worktree, mirror, legacy clone and leftover temp file with its size
+func (m *Manager) List() ([]Entry, error) {
+	var entries []Entry
+
+	worktrees, err := m.Worktrees()
+	if err != nil {
+		return nil, err
+	}
+	for _, wt := range worktrees {
+		entries = append(entries, m.entry(KindWorktree, wt))
+	}
+
+	mirrors, err := filepath.Glob(filepath.Join(m.root, "mirrors", "*", "*.git"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mirrors: %w", err)
+	}
+	for _, path := range mirrors {
+		entries = append(entries, m.entry(KindMirror, Worktree{
+			Owner: filepath.Base(filepath.Dir(path)),
+			Repo:  strings.TrimSuffix(filepath.Base(path), ".git"),
+			Path:  path,
+		}))
+	}
+
+	legacy, err := filepath.Glob(filepath.Join(m.legacyRoot, "*_*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list legacy clones: %w", err)
+	}
+	for _, path := range legacy {
+		name := filepath.Base(path)
+		sep := strings.LastIndex(name, "_")
+		issue, convErr := strconv.Atoi(name[sep+1:])
+		if convErr != nil {
+			continue
+		}
+		entries = append(entries, m.entry(KindLegacy, Worktree{Repo: name[:sep], Issue: issue, Path: path}))
+	}
+
+	for _, pattern := range []string{IssueFilePattern, MetadataFilePattern} {
+		files, err := filepath.Glob(filepath.Join(m.tempDir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list temp files: %w", err)
+		}
+		for _, path := range files {
+			entries = append(entries, m.entry(KindTempFile, Worktree{Path: path}))
+		}
+	}
+
+	return entries, nil
+}
+
+// entry measures a workspace on disk
+func (m *Manager) entry(kind Kind, wt Worktree) Entry {
+	e := Entry{Worktree: wt, Kind: kind}
+	e.Size, e.ModTime = diskUsage(wt.Path)
+	if kind == KindWorktree {
+		e.InFlight = m.InFlight(wt.Owner, wt.Repo, wt.Issue)
+	}
+	return e
+}
+
+// diskUsage returns the bytes used under path and the latest modification time in it
+func diskUsage(path string) (int64, time.Time) {
+	var size int64
+	var latest time.Time
+
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // skip what can't be read rather than failing the whole walk
+		}
+		info, infoErr := d.Info()
+		if infoErr != nil {
+			return nil
+		}
+		if !d.IsDir() {
+			size += info.Size()
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return size, latest
+}
+
+// Prune removes finished, old and, beyond the quota, least recently used workspaces and
+// returns what it removed. Worktrees with an in-flight run and mirrors that still have
+// worktrees are never removed.
+func (m *Manager) Prune(opts PruneOptions) ([]Entry, error) {
+	entries, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var removed []Entry
+	var kept []Entry
+	remove := func(e Entry, reason string) bool {
+		if !opts.DryRun {
+			if err := m.removeEntry(e); err != nil {
+				logging.Warn("Failed to remove workspace", "kind", e.Kind, "path", e.Path, "error", err)
+				return false
+			}
+		}
+		logging.Info("Pruned workspace", "kind", e.Kind, "path", e.Path, "reason", reason, "size", e.Size, "dry_run", opts.DryRun)
+		removed = append(removed, e)
+		return true
+	}
+
+	for _, e := range entries {
+		age := now.Sub(e.ModTime)
+		switch {
+		case e.InFlight:
+		case e.Kind == KindTempFile && age > staleTempFileAge:
+			if remove(e, "stale temp file") {
+				continue
+			}
+		case e.Kind == KindWorktree && opts.Finished != nil && m.finished(opts.Finished, e.Worktree):
+			if remove(e, "finished") {
+				continue
+			}
+		case (e.Kind == KindWorktree || e.Kind == KindLegacy) && opts.MaxAge > 0 && age > opts.MaxAge:
+			if remove(e, "age") {
+				continue
+			}
+		}
+		kept = append(kept, e)
+	}
+
+	if opts.MaxBytes <= 0 {
+		return removed, nil
+	}
+
+	var total int64
+	for _, e := range kept {
+		total += e.Size
+	}
+	if total <= opts.MaxBytes {
+		return removed, nil
+	}
+
+	// Over quota: free the least recently used worktrees and legacy clones first,
+	// then the mirrors no remaining worktree depends on
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].ModTime.Before(kept[j].ModTime) })
+	used := make(map[string]bool)
+	for _, e := range kept {
+		if e.Kind == KindWorktree && e.InFlight {
+			used[e.Owner+"/"+e.Repo] = true
+		}
+	}
+
+	for _, e := range kept {
+		if total <= opts.MaxBytes {
+			break
+		}
+		if (e.Kind == KindWorktree && !e.InFlight) || e.Kind == KindLegacy {
+			if remove(e, "quota") {
+				total -= e.Size
+				continue
+			}
+		}
+		if e.Kind == KindWorktree {
+			used[e.Owner+"/"+e.Repo] = true
+		}
+	}
+	for _, e := range kept {
+		if total <= opts.MaxBytes {
+			break
+		}
+		if e.Kind == KindMirror && !used[e.Owner+"/"+e.Repo] && remove(e, "quota") {
+			total -= e.Size
+		}
+	}
+
+	if total > opts.MaxBytes {
+		logging.Warn("Workspaces are still over the disk quota", "bytes", total, "quota", opts.MaxBytes)
+	}
+	return removed, nil
+}
+
+// finished asks whether an issue is done, keeping the worktree when that can't be decided
+func (m *Manager) finished(done func(Worktree) (bool, error), wt Worktree) bool {
+	finished, err := done(wt)
+	if err != nil {
+		logging.Warn("Failed to check whether worktree is finished, keeping it",
+			"repo", wt.Owner+"/"+wt.Repo,
+			"issue", wt.Issue,
+			"error", err)
+		return false
+	}
+	return finished
+}
+
+// removeEntry deletes a workspace from disk, holding the issue lock for worktrees so a
+// run can't start in it meanwhile
+func (m *Manager) removeEntry(e Entry) error {
+	switch e.Kind {
+	case KindWorktree:
+		release, err := m.Lock(e.Owner, e.Repo, e.Issue)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return m.Remove(e.Worktree)
+	case KindMirror:
+		unlock := lockMirror(e.Path)
+		defer unlock()
+		return os.RemoveAll(e.Path)
+	case KindTempFile:
+		return os.Remove(e.Path)
+	default:
+		return os.RemoveAll(e.Path)
+	}
+}
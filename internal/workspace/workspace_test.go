@@ -0,0 +1,108 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v45/github"
+)
+
+func TestCommandCredentialHelper(t *testing.T) {
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, out)
+	}
+
+	cmd := Command("s3cret", "credential", "fill")
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader("protocol=https\nhost=github.com\npath=octo/demo.git\n\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git credential fill failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "username=x-access-token\n") || !strings.Contains(string(out), "password=s3cret\n") {
+		t.Errorf("credential helper returned %q", out)
+	}
+
+	if err := SetRemote(dir, "origin", "https://github.com/octo/demo.git"); err != nil {
+		t.Fatalf("SetRemote() error = %v", err)
+	}
+	if err := SetRemote(dir, "origin", "https://github.com/octo/other.git"); err != nil {
+		t.Fatalf("SetRemote() error = %v", err)
+	}
+
+	gitConfig, err := os.ReadFile(filepath.Join(dir, ".git", "config"))
+	if err != nil {
+		t.Fatalf("failed to read git config: %v", err)
+	}
+	if strings.Contains(string(gitConfig), "s3cret") {
+		t.Error("token was written to .git/config")
+	}
+	if got, _ := remoteURL(dir, "origin"); got != "https://github.com/octo/other.git" {
+		t.Errorf("origin = %q after SetRemote", got)
+	}
+}
+
+func TestResolvePushTarget(t *testing.T) {
+	forkPollInterval = time.Millisecond
+	forkChecks := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/octo/writable", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name":"writable","permissions":{"pull":true,"push":true}}`)
+	})
+	mux.HandleFunc("/repos/octo/readonly", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name":"readonly","permissions":{"pull":true,"push":false}}`)
+	})
+	mux.HandleFunc("/repos/octo/readonly/forks", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Query().Get("organization") != "bots" {
+			http.Error(w, "bad fork request", http.StatusBadRequest)
+			return
+		}
+		// GitHub accepts the fork and finishes creating it in the background
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"name":"readonly","owner":{"login":"bots"}}`)
+	})
+	mux.HandleFunc("/repos/bots/readonly", func(w http.ResponseWriter, r *http.Request) {
+		forkChecks++
+		if forkChecks < 3 {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"name":"readonly"}`)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	target, err := ResolvePushTarget(context.Background(), client, "octo", "writable", "bots")
+	if err != nil {
+		t.Fatalf("ResolvePushTarget(writable) error = %v", err)
+	}
+	if target.Fork || target.Remote != "origin" || target.Head("fix-1") != "fix-1" {
+		t.Errorf("unexpected target for a writable repository: %+v", target)
+	}
+
+	target, err = ResolvePushTarget(context.Background(), client, "octo", "readonly", "bots")
+	if err != nil {
+		t.Fatalf("ResolvePushTarget(readonly) error = %v", err)
+	}
+	if !target.Fork || target.Remote != ForkRemote || target.Head("fix-1") != "bots:fix-1" {
+		t.Errorf("unexpected target for a read-only repository: %+v", target)
+	}
+	if forkChecks != 3 {
+		t.Errorf("checked the fork %d times, want 3", forkChecks)
+	}
+}